@@ -0,0 +1,263 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storagecache/armstoragecache/v4"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/klog/v2"
+)
+
+const (
+	amlfsGCLeaseName          = "azurelustre-csi-amlfs-gc"
+	amlfsGCLeaseDuration      = 15 * time.Second
+	amlfsGCLeaseRenewDeadline = 10 * time.Second
+	amlfsGCLeaseRetryPeriod   = 2 * time.Second
+
+	// clusterIDNamespace is the namespace whose UID identifies the Kubernetes
+	// cluster in the clusterIDTag of the AMLFS clusters it creates
+	clusterIDNamespace = "kube-system"
+
+	eventReasonOrphanedAmlFilesystem             = "OrphanedAmlFilesystem"
+	eventReasonOrphanedAmlFilesystemDeleted      = "OrphanedAmlFilesystemDeleted"
+	eventReasonOrphanedAmlFilesystemDeleteFailed = "OrphanedAmlFilesystemDeleteFailed"
+)
+
+// getClusterID returns the UID of the kube-system namespace, which identifies
+// the Kubernetes cluster for as long as it exists
+func (d *Driver) getClusterID(ctx context.Context) (string, error) {
+	namespace, err := d.kubeClient.CoreV1().Namespaces().Get(ctx, clusterIDNamespace, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get namespace %s: %w", clusterIDNamespace, err)
+	}
+	return string(namespace.UID), nil
+}
+
+// runAmlFilesystemGC collects orphaned AMLFS clusters every amlfsGCInterval
+// while this controller instance holds the leader lease
+func (d *Driver) runAmlFilesystemGC(ctx context.Context) {
+	identity, err := os.Hostname()
+	if err != nil {
+		klog.Errorf("failed to get hostname, not collecting orphaned AMLFS clusters: %v", err)
+		return
+	}
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Name: amlfsGCLeaseName, Namespace: d.leaderElectionNamespace},
+		Client:     d.kubeClient.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+	}
+	config := leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   amlfsGCLeaseDuration,
+		RenewDeadline:   amlfsGCLeaseRenewDeadline,
+		RetryPeriod:     amlfsGCLeaseRetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            amlfsGCLeaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				klog.Infof("acquired lease %s/%s, collecting orphaned AMLFS clusters every %v",
+					d.leaderElectionNamespace, amlfsGCLeaseName, d.amlfsGCInterval)
+				// The previous leader tracked its own grace periods
+				d.amlfsGCLock.Lock()
+				d.amlfsOrphanedSince = nil
+				d.amlfsGCLock.Unlock()
+				wait.UntilWithContext(ctx, func(ctx context.Context) {
+					if err := d.collectOrphanedAmlFilesystems(ctx); err != nil {
+						klog.Errorf("failed to collect orphaned AMLFS clusters: %v", err)
+					}
+				}, d.amlfsGCInterval)
+			},
+			OnStoppedLeading: func() {
+				klog.Infof("lost lease %s/%s, no longer collecting orphaned AMLFS clusters",
+					d.leaderElectionNamespace, amlfsGCLeaseName)
+			},
+		},
+	}
+
+	// Unlike the CSI sidecars the driver keeps serving after losing the
+	// lease, so it runs for the lease again
+	go wait.UntilWithContext(ctx, func(ctx context.Context) {
+		leaderelection.RunOrDie(ctx, config)
+	}, amlfsGCLeaseRetryPeriod)
+}
+
+// isOrphanCandidate returns whether the AMLFS cluster was created by this
+// driver for a persistent volume and is not being created or deleted, and
+// whether it may be deleted. Clusters created for another Kubernetes cluster
+// are skipped, those created before the clusterIDTag was added cannot be told
+// apart from them so they are only reported.
+func (d *Driver) isOrphanCandidate(amlFilesystem *AmlFilesystemSummary) (bool, bool) {
+	if amlFilesystem.Tags[createdByTag] != azureLustreDriverTag || amlFilesystem.Tags[pvNameTag] == "" {
+		return false, false
+	}
+	clusterID, tagged := amlFilesystem.Tags[clusterIDTag]
+	if tagged && clusterID != d.clusterID {
+		return false, false
+	}
+	switch armstoragecache.AmlFilesystemProvisioningStateType(amlFilesystem.ProvisioningState) {
+	case armstoragecache.AmlFilesystemProvisioningStateTypeSucceeded, armstoragecache.AmlFilesystemProvisioningStateTypeFailed:
+		return true, tagged
+	default:
+		return false, false
+	}
+}
+
+// getAmlFilesystemSubscriptions returns the subscriptions the driver creates
+// AMLFS clusters in: that of the driver, as an empty string, and those set by
+// the subscription-id parameter of the storage classes of the driver
+func (d *Driver) getAmlFilesystemSubscriptions(ctx context.Context) []string {
+	subscriptions := []string{""}
+	storageClasses, err := d.kubeClient.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		klog.Warningf("failed to list storage classes, only collecting the orphaned AMLFS clusters of the driver subscription: %v", err)
+		return subscriptions
+	}
+	defaultSubscriptionID := d.currentAzureClients().cloud.SubscriptionID
+	for _, storageClass := range storageClasses.Items {
+		if storageClass.Provisioner != d.Name {
+			continue
+		}
+		for key, value := range storageClass.Parameters {
+			if !strings.EqualFold(key, VolumeContextSubscriptionID) || isDefaultSubscription(defaultSubscriptionID, value) {
+				continue
+			}
+			if !slices.ContainsFunc(subscriptions, func(subscriptionID string) bool { return strings.EqualFold(subscriptionID, value) }) {
+				subscriptions = append(subscriptions, value)
+			}
+		}
+	}
+	return subscriptions
+}
+
+// amlfsGCKey returns the key of an AMLFS cluster in amlfsOrphanedSince
+func amlfsGCKey(subscriptionID, resourceGroupName, amlFilesystemName string) string {
+	return strings.ToLower(subscriptionID + "/" + resourceGroupName + "/" + amlFilesystemName)
+}
+
+// collectOrphanedAmlFilesystems reports the driver-created AMLFS clusters
+// whose persistent volume no longer exists and, when amlfsGCDeleteGracePeriod
+// is set, deletes those orphaned for longer than it
+func (d *Driver) collectOrphanedAmlFilesystems(ctx context.Context) error {
+	// A collection of the previous term may still be finishing
+	d.amlfsGCLock.Lock()
+	defer d.amlfsGCLock.Unlock()
+
+	dynamicProvisioner := d.currentAzureClients().dynamicProvisioner
+	orphanedSince := make(map[string]time.Time)
+	for _, subscriptionID := range d.getAmlFilesystemSubscriptions(ctx) {
+		amlFilesystems, err := dynamicProvisioner.ListAmlFilesystems(ctx, subscriptionID)
+		if err != nil {
+			if subscriptionID == "" {
+				return fmt.Errorf("failed to list AMLFS clusters: %w", err)
+			}
+			// The storage class may use a provisioner secret with access to
+			// a subscription the driver identity cannot list
+			klog.Warningf("failed to list AMLFS clusters of subscription %s: %v", subscriptionID, err)
+			prefix := amlfsGCKey(subscriptionID, "", "")
+			for key, since := range d.amlfsOrphanedSince {
+				if strings.HasPrefix(key, prefix) {
+					orphanedSince[key] = since
+				}
+			}
+			continue
+		}
+		d.collectOrphanedAmlFilesystemsOf(ctx, dynamicProvisioner, amlFilesystems, orphanedSince)
+	}
+
+	d.amlfsOrphanedSince = orphanedSince
+	orphanedAmlFilesystems.Set(float64(len(orphanedSince)))
+	return nil
+}
+
+// collectOrphanedAmlFilesystemsOf reports and deletes the orphaned clusters
+// among those of a subscription, recording when each was first reported in
+// orphanedSince
+func (d *Driver) collectOrphanedAmlFilesystemsOf(ctx context.Context, dynamicProvisioner DynamicProvisionerInterface,
+	amlFilesystems []*AmlFilesystemSummary, orphanedSince map[string]time.Time,
+) {
+	now := time.Now()
+	for _, amlFilesystem := range amlFilesystems {
+		candidate, deletable := d.isOrphanCandidate(amlFilesystem)
+		if !candidate {
+			continue
+		}
+		pvName := amlFilesystem.Tags[pvNameTag]
+		if _, err := d.kubeClient.CoreV1().PersistentVolumes().Get(ctx, pvName, metav1.GetOptions{}); err == nil {
+			continue
+		} else if !apierrors.IsNotFound(err) {
+			klog.Warningf("failed to get persistent volume %s of AMLFS cluster %s: %v", pvName, amlFilesystem.AmlFilesystemName, err)
+			continue
+		}
+
+		key := amlfsGCKey(amlFilesystem.SubscriptionID, amlFilesystem.ResourceGroupName, amlFilesystem.AmlFilesystemName)
+		since, reported := d.amlfsOrphanedSince[key]
+		if !reported {
+			since = now
+			message := fmt.Sprintf("AMLFS cluster %s in resource group %s was created for persistent volume %s, which no longer exists",
+				amlFilesystem.AmlFilesystemName, amlFilesystem.ResourceGroupName, pvName)
+			if !deletable {
+				message += fmt.Sprintf(". It has no %s tag, so it may belong to another Kubernetes cluster and is not deleted", clusterIDTag)
+			}
+			klog.Warningf("orphaned AMLFS cluster: %s", message)
+			d.recordAmlFilesystemEvent(ctx, amlFilesystem, v1.EventTypeWarning, eventReasonOrphanedAmlFilesystem, "%s", message)
+		}
+		orphanedSince[key] = since
+
+		if !deletable || d.amlfsGCDeleteGracePeriod <= 0 || now.Sub(since) < d.amlfsGCDeleteGracePeriod {
+			continue
+		}
+		klog.Infof("deleting AMLFS cluster %s in resource group %s, orphaned since %v",
+			amlFilesystem.AmlFilesystemName, amlFilesystem.ResourceGroupName, since.Format(time.RFC3339))
+		if err := dynamicProvisioner.DeleteAmlFilesystem(ctx, amlFilesystem.SubscriptionID, amlFilesystem.ResourceGroupName, amlFilesystem.AmlFilesystemName); err != nil {
+			orphanedAmlFilesystemDeletions.WithLabelValues(metricsResultFailure).Inc()
+			klog.Errorf("failed to delete orphaned AMLFS cluster %s in resource group %s: %v",
+				amlFilesystem.AmlFilesystemName, amlFilesystem.ResourceGroupName, err)
+			d.recordAmlFilesystemEvent(ctx, amlFilesystem, v1.EventTypeWarning, eventReasonOrphanedAmlFilesystemDeleteFailed,
+				"Failed to delete orphaned AMLFS cluster %s in resource group %s: %v",
+				amlFilesystem.AmlFilesystemName, amlFilesystem.ResourceGroupName, err)
+			continue
+		}
+		orphanedAmlFilesystemDeletions.WithLabelValues(metricsResultSuccess).Inc()
+		delete(orphanedSince, key)
+		d.recordAmlFilesystemEvent(ctx, amlFilesystem, v1.EventTypeNormal, eventReasonOrphanedAmlFilesystemDeleted,
+			"Deleted AMLFS cluster %s in resource group %s, orphaned for longer than %v",
+			amlFilesystem.AmlFilesystemName, amlFilesystem.ResourceGroupName, d.amlfsGCDeleteGracePeriod)
+	}
+}
+
+// recordAmlFilesystemEvent records an event on the persistent volume claim
+// the AMLFS cluster was created for, the claim itself may no longer exist
+func (d *Driver) recordAmlFilesystemEvent(ctx context.Context, amlFilesystem *AmlFilesystemSummary, eventType, reason, messageFmt string, args ...any) {
+	recorder := d.getEventRecorder()
+	pvcName, pvcNamespace := amlFilesystem.Tags[pvcNameTag], amlFilesystem.Tags[pvcNamespaceTag]
+	if recorder == nil || pvcName == "" || pvcNamespace == "" {
+		return
+	}
+	recorder.Eventf(d.getPVCReference(ctx, pvcName, pvcNamespace), eventType, reason, messageFmt, args...)
+}
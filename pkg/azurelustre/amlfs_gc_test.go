@@ -0,0 +1,207 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+const fakeClusterID = "fake-cluster-id"
+
+func newDriverCreatedAmlFilesystem(name, pvName, clusterID string) *AmlFilesystemProperties {
+	return &AmlFilesystemProperties{
+		ResourceGroupName: "fake-resource-group",
+		AmlFilesystemName: name,
+		Tags: map[string]string{
+			createdByTag:    azureLustreDriverTag,
+			clusterIDTag:    clusterID,
+			pvNameTag:       pvName,
+			pvcNameTag:      "pvc-" + pvName,
+			pvcNamespaceTag: "default",
+		},
+	}
+}
+
+func getRecordedEvents(recorder *record.FakeRecorder) []string {
+	var events []string
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestGetClusterID(t *testing.T) {
+	d := NewFakeDriver()
+	d.kubeClient = kubefake.NewClientset(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterIDNamespace, UID: fakeClusterID},
+	})
+	clusterID, err := d.getClusterID(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, fakeClusterID, clusterID)
+
+	d.kubeClient = kubefake.NewClientset()
+	_, err = d.getClusterID(context.Background())
+	require.ErrorContains(t, err, "failed to get namespace kube-system")
+}
+
+func TestCollectOrphanedAmlFilesystems(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		recorder := record.NewFakeRecorder(10)
+		dynamicProvisioner := &FakeDynamicProvisioner{
+			Filesystems: []*AmlFilesystemProperties{
+				newDriverCreatedAmlFilesystem("bound", "pv-bound", fakeClusterID),
+				newDriverCreatedAmlFilesystem("orphaned", "pv-deleted", fakeClusterID),
+				newDriverCreatedAmlFilesystem(clusterRequestFailureName, "pv-deleted-2", fakeClusterID),
+				newDriverCreatedAmlFilesystem("other-cluster", "pv-other", "other-cluster-id"),
+				{ResourceGroupName: "fake-resource-group", AmlFilesystemName: "static", Tags: map[string]string{}},
+			},
+		}
+		d := NewFakeDriver()
+		d.clusterID = fakeClusterID
+		d.amlfsGCDeleteGracePeriod = time.Hour
+		d.dynamicProvisioner = dynamicProvisioner
+		d.eventRecorder = recorder
+		d.kubeClient = kubefake.NewClientset(&corev1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "pv-bound"}})
+		deletionsBefore := gatherMetricValues(t, legacyregistry.DefaultGatherer, "azurelustre_csi_orphaned_amlfs_deletions_total")
+
+		// Orphans are reported once and kept during the grace period
+		require.NoError(t, d.collectOrphanedAmlFilesystems(context.Background()))
+		time.Sleep(30 * time.Minute)
+		require.NoError(t, d.collectOrphanedAmlFilesystems(context.Background()))
+		assert.Equal(t, []string{
+			"Warning OrphanedAmlFilesystem AMLFS cluster orphaned in resource group fake-resource-group was created for persistent volume pv-deleted, which no longer exists",
+			"Warning OrphanedAmlFilesystem AMLFS cluster " + clusterRequestFailureName + " in resource group fake-resource-group was created for persistent volume pv-deleted-2, which no longer exists",
+		}, getRecordedEvents(recorder))
+		assert.Len(t, dynamicProvisioner.Filesystems, 5)
+		assert.Equal(t, map[string]float64{"": 2}, gatherMetricValues(t, legacyregistry.DefaultGatherer, "azurelustre_csi_orphaned_amlfs_clusters"))
+
+		time.Sleep(30 * time.Minute)
+		require.NoError(t, d.collectOrphanedAmlFilesystems(context.Background()))
+		assert.Equal(t, []string{
+			"Normal OrphanedAmlFilesystemDeleted Deleted AMLFS cluster orphaned in resource group fake-resource-group, orphaned for longer than 1h0m0s",
+			"Warning OrphanedAmlFilesystemDeleteFailed Failed to delete orphaned AMLFS cluster " + clusterRequestFailureName +
+				" in resource group fake-resource-group: rpc error: code = InvalidArgument desc = error occurred calling API: " + clusterRequestFailureName,
+		}, getRecordedEvents(recorder))
+		remaining := make([]string, 0, len(dynamicProvisioner.Filesystems))
+		for _, filesystem := range dynamicProvisioner.Filesystems {
+			remaining = append(remaining, filesystem.AmlFilesystemName)
+		}
+		assert.Equal(t, []string{"bound", clusterRequestFailureName, "other-cluster", "static"}, remaining)
+		assert.Equal(t, map[string]float64{"": 1}, gatherMetricValues(t, legacyregistry.DefaultGatherer, "azurelustre_csi_orphaned_amlfs_clusters"))
+
+		deletionsAfter := gatherMetricValues(t, legacyregistry.DefaultGatherer, "azurelustre_csi_orphaned_amlfs_deletions_total")
+		assert.InDelta(t, 1, deletionsAfter["result=success"]-deletionsBefore["result=success"], 0)
+		assert.InDelta(t, 1, deletionsAfter["result=failure"]-deletionsBefore["result=failure"], 0)
+	})
+}
+
+func TestCollectOrphanedAmlFilesystems_ReportOnly(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		dynamicProvisioner := &FakeDynamicProvisioner{
+			Filesystems: []*AmlFilesystemProperties{
+				newDriverCreatedAmlFilesystem("orphaned", "pv-deleted", fakeClusterID),
+			},
+		}
+		d := NewFakeDriver()
+		d.clusterID = fakeClusterID
+		d.dynamicProvisioner = dynamicProvisioner
+		d.eventRecorder = record.NewFakeRecorder(10)
+		d.kubeClient = kubefake.NewClientset()
+
+		require.NoError(t, d.collectOrphanedAmlFilesystems(context.Background()))
+		time.Sleep(24 * time.Hour)
+		require.NoError(t, d.collectOrphanedAmlFilesystems(context.Background()))
+		assert.Len(t, dynamicProvisioner.Filesystems, 1)
+		assert.Zero(t, dynamicProvisioner.fakeCallCount["DeleteAmlFilesystem"])
+	})
+}
+
+func TestCollectOrphanedAmlFilesystems_Untagged(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		recorder := record.NewFakeRecorder(10)
+		untagged := newDriverCreatedAmlFilesystem("untagged", "pv-deleted", "")
+		delete(untagged.Tags, clusterIDTag)
+		dynamicProvisioner := &FakeDynamicProvisioner{Filesystems: []*AmlFilesystemProperties{untagged}}
+		d := NewFakeDriver()
+		d.clusterID = fakeClusterID
+		d.amlfsGCDeleteGracePeriod = time.Hour
+		d.dynamicProvisioner = dynamicProvisioner
+		d.eventRecorder = recorder
+		d.kubeClient = kubefake.NewClientset()
+
+		// Clusters created before the cluster ID tag are only reported
+		require.NoError(t, d.collectOrphanedAmlFilesystems(context.Background()))
+		time.Sleep(2 * time.Hour)
+		require.NoError(t, d.collectOrphanedAmlFilesystems(context.Background()))
+		assert.Equal(t, []string{
+			"Warning OrphanedAmlFilesystem AMLFS cluster untagged in resource group fake-resource-group was created for persistent volume pv-deleted, which no longer exists. " +
+				"It has no k8s-azure-cluster-id tag, so it may belong to another Kubernetes cluster and is not deleted",
+		}, getRecordedEvents(recorder))
+		assert.Len(t, dynamicProvisioner.Filesystems, 1)
+		assert.Zero(t, dynamicProvisioner.fakeCallCount["DeleteAmlFilesystem"])
+	})
+}
+
+func TestCollectOrphanedAmlFilesystems_Subscriptions(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		otherSubscription := newDriverCreatedAmlFilesystem("other-subscription", "pv-deleted", fakeClusterID)
+		otherSubscription.SubscriptionID = "other-subscription-id"
+		unknownSubscription := newDriverCreatedAmlFilesystem("unknown-subscription", "pv-deleted-2", fakeClusterID)
+		unknownSubscription.SubscriptionID = "unknown-subscription-id"
+		dynamicProvisioner := &FakeDynamicProvisioner{Filesystems: []*AmlFilesystemProperties{otherSubscription, unknownSubscription}}
+		d := NewFakeDriver()
+		d.clusterID = fakeClusterID
+		d.amlfsGCDeleteGracePeriod = time.Hour
+		d.dynamicProvisioner = dynamicProvisioner
+		d.eventRecorder = record.NewFakeRecorder(10)
+		d.kubeClient = kubefake.NewClientset(
+			&storagev1.StorageClass{
+				ObjectMeta:  metav1.ObjectMeta{Name: "other-subscription"},
+				Provisioner: d.Name,
+				Parameters:  map[string]string{"subscription-ID": "Other-Subscription-ID"},
+			},
+			&storagev1.StorageClass{
+				ObjectMeta:  metav1.ObjectMeta{Name: "other-driver"},
+				Provisioner: "other.csi.azure.com",
+				Parameters:  map[string]string{"subscription-id": "unknown-subscription-id"},
+			},
+		)
+
+		assert.Equal(t, []string{"", "Other-Subscription-ID"}, d.getAmlFilesystemSubscriptions(context.Background()))
+		require.NoError(t, d.collectOrphanedAmlFilesystems(context.Background()))
+		time.Sleep(2 * time.Hour)
+		require.NoError(t, d.collectOrphanedAmlFilesystems(context.Background()))
+		require.Len(t, dynamicProvisioner.Filesystems, 1)
+		assert.Equal(t, "unknown-subscription", dynamicProvisioner.Filesystems[0].AmlFilesystemName)
+	})
+}
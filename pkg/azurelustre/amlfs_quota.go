@@ -0,0 +1,163 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storagecache/armstoragecache/v4"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// amlfsQuotaCacheTTL is how long the AMLFS usages of a location are reused
+	// before they are listed again, so a burst of claims lists them once
+	amlfsQuotaCacheTTL = 30 * time.Second
+
+	quotaUnitCount = "count"
+	quotaUnitTiB   = "tib"
+)
+
+// amlfsQuotaUsage is the current usage and limit of an AMLFS quota of the
+// subscription in a location
+type amlfsQuotaUsage struct {
+	name    string
+	unit    string
+	current int64
+	limit   int64
+}
+
+type amlfsQuotaCacheEntry struct {
+	usages    []*amlfsQuotaUsage
+	expiresAt time.Time
+}
+
+// required returns how much of the quota an AMLFS cluster of the given size
+// uses, 0 for units the driver does not know
+func (u *amlfsQuotaUsage) required(clusterSize float32) int64 {
+	switch strings.ToLower(u.unit) {
+	case quotaUnitCount:
+		return 1
+	case quotaUnitTiB:
+		return int64(math.Ceil(float64(clusterSize)))
+	default:
+		return 0
+	}
+}
+
+// isAmlFilesystemQuota reports whether the usage named name limits AMLFS
+// clusters rather than other StorageCache resources such as caches
+func isAmlFilesystemQuota(name string) bool {
+	name = strings.ToLower(name)
+	return strings.Contains(name, "amlfilesystem") || strings.Contains(name, "amlfs")
+}
+
+func listAmlFilesystemQuotaUsages(ctx context.Context, usagesClient *armstoragecache.AscUsagesClient, location string) ([]*amlfsQuotaUsage, error) {
+	var usages []*amlfsQuotaUsage
+	pager := usagesClient.NewListPager(location, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, usage := range page.Value {
+			if usage == nil || usage.Name == nil || usage.Name.Value == nil || usage.Limit == nil || usage.CurrentValue == nil {
+				continue
+			}
+			if !isAmlFilesystemQuota(*usage.Name.Value) {
+				continue
+			}
+			unit := quotaUnitCount
+			if usage.Unit != nil {
+				unit = *usage.Unit
+			}
+			usages = append(usages, &amlfsQuotaUsage{
+				name:    *usage.Name.Value,
+				unit:    unit,
+				current: int64(*usage.CurrentValue),
+				limit:   int64(*usage.Limit),
+			})
+		}
+	}
+	return usages, nil
+}
+
+// checkAmlFilesystemQuota returns ResourceExhausted when creating an AMLFS
+// cluster of the given size in the location would exceed a quota of the
+// subscription, that of the driver when empty. The usages are cached for amlfsQuotaCacheTTL and the clusters
+// admitted from the cache are added to them, so a burst of claims neither
+// lists the usages for every claim nor overshoots the quota. The check is best
+// effort: when the usages cannot be listed, the creation goes ahead and ARM
+// enforces the quota. The returned func takes the cluster out of the cached
+// usages again, for a creation that fails before it is sent to ARM.
+func (d *DynamicProvisioner) checkAmlFilesystemQuota(ctx context.Context, subscriptionID, location string, clusterSize float32) (func(), error) {
+	noRelease := func() {}
+	clients, err := d.storageClientsFor(ctx, subscriptionID)
+	if err != nil {
+		return noRelease, err
+	}
+	if clients.usagesClient == nil || location == "" {
+		return noRelease, nil
+	}
+
+	caches := d.getCaches()
+	caches.quotaLock.Lock()
+	defer caches.quotaLock.Unlock()
+
+	cacheKey := d.cacheKey(subscriptionID, location)
+	entry := caches.quotaCache[cacheKey]
+	if entry == nil || time.Now().After(entry.expiresAt) {
+		usages, err := listAmlFilesystemQuotaUsages(ctx, clients.usagesClient, location)
+		if err != nil {
+			klog.Warningf("failed to list AMLFS quota usages in location %s, skipping quota check: %v", location, err)
+			return noRelease, nil
+		}
+		entry = &amlfsQuotaCacheEntry{usages: usages, expiresAt: time.Now().Add(amlfsQuotaCacheTTL)}
+		if caches.quotaCache == nil {
+			caches.quotaCache = map[string]*amlfsQuotaCacheEntry{}
+		}
+		caches.quotaCache[cacheKey] = entry
+	}
+
+	for _, usage := range entry.usages {
+		required := usage.required(clusterSize)
+		if required > 0 && usage.limit >= 0 && usage.current+required > usage.limit {
+			return noRelease, status.Errorf(codes.ResourceExhausted,
+				"cannot create AMLFS cluster in location %s, quota %s would be exceeded: current usage %d, limit %d, required %d (%s)",
+				location, usage.name, usage.current, usage.limit, required, usage.unit)
+		}
+	}
+	for _, usage := range entry.usages {
+		usage.current += usage.required(clusterSize)
+	}
+	return func() {
+		caches.quotaLock.Lock()
+		defer caches.quotaLock.Unlock()
+		// Usages listed again since then do not count the cluster
+		if caches.quotaCache[cacheKey] != entry {
+			return
+		}
+		for _, usage := range entry.usages {
+			usage.current -= usage.required(clusterSize)
+		}
+	}, nil
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storagecache/armstoragecache/v4"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storagecache/armstoragecache/v4/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newResourceUsage(name, unit string, current, limit int32) *armstoragecache.ResourceUsage {
+	return &armstoragecache.ResourceUsage{
+		Name:         &armstoragecache.ResourceUsageName{Value: to.Ptr(name)},
+		Unit:         to.Ptr(unit),
+		CurrentValue: to.Ptr(current),
+		Limit:        to.Ptr(limit),
+	}
+}
+
+// newFakeUsagesClient returns a usages client reporting 4 of 5 AMLFS clusters
+// and 100 of 200 TiB used, failing for errorLocation, and counts the calls
+func newFakeUsagesClient(t *testing.T, listCalls *int) *armstoragecache.AscUsagesClient {
+	fakeUsagesServer := fake.AscUsagesServer{
+		NewListPager: func(location string, _ *armstoragecache.AscUsagesClientListOptions) azfake.PagerResponder[armstoragecache.AscUsagesClientListResponse] {
+			*listCalls++
+			resp := azfake.PagerResponder[armstoragecache.AscUsagesClientListResponse]{}
+			if location == errorLocation {
+				resp.AddError(errors.New("fake usages error"))
+				return resp
+			}
+			resp.AddPage(http.StatusOK, armstoragecache.AscUsagesClientListResponse{
+				ResourceUsagesListResult: armstoragecache.ResourceUsagesListResult{
+					Value: []*armstoragecache.ResourceUsage{
+						newResourceUsage("AmlFilesystem", "Count", 4, 5),
+						newResourceUsage("AmlFilesystemCapacity", "TiB", 100, 200),
+						newResourceUsage("Cache", "Count", 10, 10),
+					},
+				},
+			}, nil)
+			return resp
+		},
+	}
+	clientFactory, err := armstoragecache.NewClientFactory("fake-subscription-id", &azfake.TokenCredential{},
+		&arm.ClientOptions{
+			ClientOptions: azcore.ClientOptions{
+				Transport: fake.NewAscUsagesServerTransport(&fakeUsagesServer),
+			},
+		},
+	)
+	require.NoError(t, err)
+	return clientFactory.NewAscUsagesClient()
+}
+
+func TestCheckAmlFilesystemQuota(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		listCalls := 0
+		dynamicProvisioner := &DynamicProvisioner{usagesClient: newFakeUsagesClient(t, &listCalls)}
+
+		// The first cluster fits, the second exceeds the cluster count
+		// counting the first even though the usages are cached
+		_, err := dynamicProvisioner.checkAmlFilesystemQuota(context.Background(), "", expectedLocation, 48)
+		require.NoError(t, err)
+		_, err = dynamicProvisioner.checkAmlFilesystemQuota(context.Background(), "", expectedLocation, 48)
+		require.Error(t, err)
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		assert.Equal(t, "cannot create AMLFS cluster in location fake-location, quota AmlFilesystem would be exceeded: current usage 5, limit 5, required 1 (Count)",
+			status.Convert(err).Message())
+		assert.Equal(t, 1, listCalls)
+
+		// Expired usages are listed again
+		time.Sleep(amlfsQuotaCacheTTL + time.Second)
+		release, err := dynamicProvisioner.checkAmlFilesystemQuota(context.Background(), "", expectedLocation, 48)
+		require.NoError(t, err)
+		assert.Equal(t, 2, listCalls)
+
+		// A released cluster no longer counts
+		release()
+		release, err = dynamicProvisioner.checkAmlFilesystemQuota(context.Background(), "", expectedLocation, 48)
+		require.NoError(t, err)
+		assert.Equal(t, 2, listCalls)
+
+		// Nor does one released after the usages were listed again
+		time.Sleep(amlfsQuotaCacheTTL + time.Second)
+		_, err = dynamicProvisioner.checkAmlFilesystemQuota(context.Background(), "", expectedLocation, 48)
+		require.NoError(t, err)
+		release()
+		_, err = dynamicProvisioner.checkAmlFilesystemQuota(context.Background(), "", expectedLocation, 48)
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		assert.Equal(t, 3, listCalls)
+
+		// Capacity is checked in TiB
+		time.Sleep(amlfsQuotaCacheTTL + time.Second)
+		_, err = dynamicProvisioner.checkAmlFilesystemQuota(context.Background(), "", expectedLocation, 128)
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		assert.ErrorContains(t, err, "quota AmlFilesystemCapacity would be exceeded: current usage 100, limit 200, required 128 (TiB)")
+	})
+}
+
+func TestCheckAmlFilesystemQuota_Skipped(t *testing.T) {
+	listCalls := 0
+	dynamicProvisioner := &DynamicProvisioner{usagesClient: newFakeUsagesClient(t, &listCalls)}
+	_, err := dynamicProvisioner.checkAmlFilesystemQuota(context.Background(), "", errorLocation, 48)
+	require.NoError(t, err, "usages cannot be listed")
+	_, err = dynamicProvisioner.checkAmlFilesystemQuota(context.Background(), "", "", 48)
+	require.NoError(t, err, "no location")
+	assert.Equal(t, 1, listCalls)
+
+	_, err = (&DynamicProvisioner{}).checkAmlFilesystemQuota(context.Background(), "", expectedLocation, 48)
+	require.NoError(t, err, "no usages client")
+}
+
+func TestDynamicProvisioner_CreateAmlFilesystem_QuotaExceeded(t *testing.T) {
+	listCalls := 0
+	recorder := newMockAmlfsRecorder([]string{})
+	dynamicProvisioner := newTestDynamicProvisioner(t, recorder)
+	dynamicProvisioner.usagesClient = newFakeUsagesClient(t, &listCalls)
+
+	_, err := dynamicProvisioner.CreateAmlFilesystem(context.Background(), &AmlFilesystemProperties{
+		ResourceGroupName:  expectedResourceGroupName,
+		AmlFilesystemName:  expectedAmlFilesystemName,
+		Location:           expectedLocation,
+		SKUName:            expectedSku,
+		StorageCapacityTiB: 256,
+		SubnetInfo:         buildExpectedSubnetInfo(),
+	})
+	require.Error(t, err)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.ErrorContains(t, err, "current usage 100, limit 200, required 256 (TiB)")
+	assert.Empty(t, recorder.recordedAmlfsConfigurations)
+	assert.Equal(t, []string{"AmlFilesystemsServerTransport.Get"}, recorder.fakeCallCount)
+}
+
+func TestDynamicProvisioner_CreateAmlFilesystem_QuotaReleased(t *testing.T) {
+	listCalls := 0
+	recorder := newMockAmlfsRecorder([]string{})
+	dynamicProvisioner := newTestDynamicProvisioner(t, recorder)
+	dynamicProvisioner.usagesClient = newFakeUsagesClient(t, &listCalls)
+	subnetInfo := buildExpectedSubnetInfo()
+	subnetInfo.VnetName = fullVnetName
+
+	// The cluster count has room for one more cluster, which is not taken by
+	// a creation that fails before reaching ARM
+	for range 2 {
+		_, err := dynamicProvisioner.CreateAmlFilesystem(context.Background(), &AmlFilesystemProperties{
+			ResourceGroupName:  expectedResourceGroupName,
+			AmlFilesystemName:  expectedAmlFilesystemName,
+			Location:           expectedLocation,
+			SKUName:            expectedSku,
+			StorageCapacityTiB: 48,
+			SubnetInfo:         subnetInfo,
+		})
+		require.Error(t, err)
+		assert.ErrorContains(t, err, "not enough IP addresses available")
+	}
+	assert.Empty(t, recorder.recordedAmlfsConfigurations)
+	assert.Equal(t, 1, listCalls)
+}
@@ -0,0 +1,244 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+// armErrorRetry is what CreateAmlFilesystem does about a failed creation
+// before the external provisioner retries it
+type armErrorRetry int
+
+const (
+	// armErrorRetryNone leaves the cluster as it is
+	armErrorRetryNone armErrorRetry = iota
+	// armErrorRetryRecreate deletes the cluster so the retry creates it anew
+	armErrorRetryRecreate
+	// armErrorRetryRecreateIfFailed deletes the cluster when it is in the
+	// Failed state
+	armErrorRetryRecreateIfFailed
+
+	// armErrorsDocURL is the page of the Help links of the ARM errors, their
+	// sections tell how to fix them
+	armErrorsDocURL = "https://github.com/kubernetes-sigs/azurelustre-csi-driver/blob/main/docs/errors.md"
+
+	// armErrorReasonRequestFailed is the ErrorInfo reason of the ARM errors
+	// that are not classified
+	armErrorReasonRequestFailed    = "ARM_REQUEST_FAILED"
+	armErrorReasonDeploymentFailed = "AMLFS_DEPLOYMENT_FAILED"
+	armErrorReasonQuotaExceeded    = "AMLFS_QUOTA_EXCEEDED"
+
+	// amlfsQuotaExceededMessage is the message of the quota errors that do
+	// not have the QuotaExceeded error code
+	amlfsQuotaExceededMessage = "Operation results in exceeding quota limits of resource type AmlFilesystem"
+)
+
+// armErrorClass is how the driver reports and handles the ARM errors of some
+// error codes
+type armErrorClass struct {
+	errorCodes []string
+	code       codes.Code
+	// reason is the ErrorInfo reason of the errors
+	reason string
+	// section is the anchor of the docs/errors.md section telling how to fix
+	// the errors, none when empty
+	section string
+	retry   armErrorRetry
+}
+
+// armErrorClasses classifies the ARM errors by their error code, the others
+// get the gRPC code of their HTTP status code. A failed long-running
+// operation, such as a cluster creation, has the status code of its last
+// poll, usually 200, so only its error code tells what happened; those with
+// an error code not listed are handled as failed deployments.
+var armErrorClasses = []armErrorClass{
+	{
+		errorCodes: []string{"CreateTimeout", "OperationTimeout"},
+		code:       codes.DeadlineExceeded,
+		reason:     "AMLFS_CREATION_TIMED_OUT",
+		section:    "error-amlfs-cluster-creation-timed-out",
+		retry:      armErrorRetryRecreate,
+	},
+	{
+		errorCodes: []string{"InternalExecutionError", "AscInternalError", "DeploymentFailed", "DeploymentFailure"},
+		code:       codes.DeadlineExceeded,
+		reason:     armErrorReasonDeploymentFailed,
+		section:    "error-amlfs-cluster-creation-timed-out",
+		retry:      armErrorRetryRecreateIfFailed,
+	},
+	{
+		errorCodes: []string{"QuotaExceeded"},
+		code:       codes.ResourceExhausted,
+		reason:     armErrorReasonQuotaExceeded,
+		section:    "error-reached-azure-subscription-quota-limit-for-amlfs-clusters",
+	},
+	{
+		errorCodes: authorizationErrorCodes,
+		code:       codes.PermissionDenied,
+		reason:     "AUTHORIZATION_FAILED",
+		section:    "authentication-and-authorization-errors",
+	},
+	{
+		errorCodes: []string{"AuthenticationFailed", "InvalidAuthenticationToken", "InvalidAuthenticationTokenTenant", "ExpiredAuthenticationToken"},
+		code:       codes.Unauthenticated,
+		reason:     "AUTHENTICATION_FAILED",
+		section:    "authentication-and-authorization-errors",
+	},
+	{
+		errorCodes: []string{"ResourceNotFound", "ResourceGroupNotFound", "ParentResourceNotFound", "NotFound"},
+		code:       codes.NotFound,
+		reason:     "RESOURCE_NOT_FOUND",
+		section:    "error-resource-not-found",
+	},
+	{
+		errorCodes: []string{"TooManyRequests", "SubscriptionRequestsThrottled", "ResourceRequestsThrottled"},
+		code:       codes.Unavailable,
+		reason:     "ARM_THROTTLED",
+		section:    "error-azure-resource-manager-is-throttling-the-requests-of-the-driver",
+	},
+}
+
+// classifyARMError returns the class of an ARM error from its error code,
+// falling back to its message for the quota errors and to its status code for
+// the failed long-running operations
+func classifyARMError(err error) (armErrorClass, bool) {
+	var respErr *azcore.ResponseError
+	if !errors.As(err, &respErr) {
+		return armErrorClass{}, false
+	}
+	for _, class := range armErrorClasses {
+		if hasARMErrorCode(respErr, class.errorCodes...) {
+			return class, true
+		}
+	}
+	switch {
+	case strings.Contains(respErr.Error(), amlfsQuotaExceededMessage):
+		return armErrorClassOf(armErrorReasonQuotaExceeded)
+	case respErr.StatusCode < http.StatusBadRequest:
+		return armErrorClassOf(armErrorReasonDeploymentFailed)
+	}
+	return armErrorClass{}, false
+}
+
+// armErrorClassOf returns the class of armErrorClasses with the reason
+func armErrorClassOf(reason string) (armErrorClass, bool) {
+	for _, class := range armErrorClasses {
+		if class.reason == reason {
+			return class, true
+		}
+	}
+	return armErrorClass{}, false
+}
+
+// hasARMErrorCode reports whether err is an ARM error with one of the error
+// codes
+func hasARMErrorCode(err error, errorCodes ...string) bool {
+	var respErr *azcore.ResponseError
+	if !errors.As(err, &respErr) {
+		return false
+	}
+	for _, errorCode := range errorCodes {
+		if strings.EqualFold(respErr.ErrorCode, errorCode) {
+			return true
+		}
+	}
+	return false
+}
+
+// grpcCodeForHTTPStatus returns the gRPC code of the ARM errors that are not
+// classified by their error code
+func grpcCodeForHTTPStatus(statusCode int) codes.Code {
+	switch statusCode {
+	case http.StatusBadRequest, http.StatusConflict:
+		return codes.InvalidArgument
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusTooManyRequests:
+		return codes.Unavailable
+	case http.StatusInternalServerError:
+		return codes.Internal
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	if statusCode >= 400 && statusCode < 500 {
+		return codes.InvalidArgument
+	}
+	// Prefer to default to Unknown rather than Internal so provisioner will retry
+	return codes.Unknown
+}
+
+// armErrorStatus returns the gRPC status of an ARM error, with an ErrorInfo
+// detail naming its class and error code and, for the classes documented, a
+// Help detail linking to the docs/errors.md section telling how to fix it
+func armErrorStatus(respErr *azcore.ResponseError) error {
+	class, ok := classifyARMError(respErr)
+	if !ok {
+		class = armErrorClass{code: grpcCodeForHTTPStatus(respErr.StatusCode), reason: armErrorReasonRequestFailed}
+	}
+
+	message := "error occurred calling API: " + respErr.Error()
+	if respErr.ErrorCode != "" {
+		message = respErr.ErrorCode + ": " + message
+	}
+	var help *errdetails.Help
+	if class.section != "" {
+		link := armErrorsDocURL + "#" + class.section
+		message += "\nSee " + link + " for how to fix it"
+		help = &errdetails.Help{Links: []*errdetails.Help_Link{{
+			Description: "Resolving Azure Lustre CSI driver errors",
+			Url:         link,
+		}}}
+	}
+
+	st := status.New(class.code, message)
+	errorInfo := &errdetails.ErrorInfo{
+		Reason: class.reason,
+		Domain: DefaultDriverName,
+		Metadata: map[string]string{
+			"armErrorCode":   respErr.ErrorCode,
+			"httpStatusCode": strconv.Itoa(respErr.StatusCode),
+		},
+	}
+	var detailed *status.Status
+	var err error
+	if help != nil {
+		detailed, err = st.WithDetails(errorInfo, help)
+	} else {
+		detailed, err = st.WithDetails(errorInfo)
+	}
+	if err != nil {
+		klog.Warningf("failed to add the details of ARM error %s: %v", respErr.ErrorCode, err)
+		return st.Err()
+	}
+	return detailed.Err()
+}
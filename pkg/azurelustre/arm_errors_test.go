@@ -0,0 +1,175 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func armErrorDetails(t *testing.T, err error) (*errdetails.ErrorInfo, *errdetails.Help) {
+	t.Helper()
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+	var errorInfo *errdetails.ErrorInfo
+	var help *errdetails.Help
+	for _, detail := range st.Details() {
+		switch detail := detail.(type) {
+		case *errdetails.ErrorInfo:
+			errorInfo = detail
+		case *errdetails.Help:
+			help = detail
+		}
+	}
+	return errorInfo, help
+}
+
+func TestARMErrorStatus(t *testing.T) {
+	cases := []struct {
+		desc            string
+		err             error
+		expectedCode    codes.Code
+		expectedReason  string
+		expectedSection string
+		expectedRetry   armErrorRetry
+	}{
+		{
+			desc:            "creation timeout",
+			err:             &azcore.ResponseError{StatusCode: http.StatusOK, ErrorCode: "CreateTimeout"},
+			expectedCode:    codes.DeadlineExceeded,
+			expectedReason:  "AMLFS_CREATION_TIMED_OUT",
+			expectedSection: "error-amlfs-cluster-creation-timed-out",
+			expectedRetry:   armErrorRetryRecreate,
+		},
+		{
+			desc:            "failed deployment",
+			err:             &azcore.ResponseError{StatusCode: http.StatusOK, ErrorCode: "deploymentfailed"},
+			expectedCode:    codes.DeadlineExceeded,
+			expectedReason:  "AMLFS_DEPLOYMENT_FAILED",
+			expectedSection: "error-amlfs-cluster-creation-timed-out",
+			expectedRetry:   armErrorRetryRecreateIfFailed,
+		},
+		{
+			desc:            "quota",
+			err:             &azcore.ResponseError{StatusCode: http.StatusConflict, ErrorCode: "QuotaExceeded"},
+			expectedCode:    codes.ResourceExhausted,
+			expectedReason:  "AMLFS_QUOTA_EXCEEDED",
+			expectedSection: "error-reached-azure-subscription-quota-limit-for-amlfs-clusters",
+		},
+		{
+			desc:            "authorization",
+			err:             fmt.Errorf("wrapped: %w", &azcore.ResponseError{StatusCode: http.StatusForbidden, ErrorCode: "LinkedAuthorizationFailed"}),
+			expectedCode:    codes.PermissionDenied,
+			expectedReason:  "AUTHORIZATION_FAILED",
+			expectedSection: "authentication-and-authorization-errors",
+		},
+		{
+			desc:            "resource group not found",
+			err:             &azcore.ResponseError{StatusCode: http.StatusNotFound, ErrorCode: "ResourceGroupNotFound"},
+			expectedCode:    codes.NotFound,
+			expectedReason:  "RESOURCE_NOT_FOUND",
+			expectedSection: "error-resource-not-found",
+		},
+		{
+			desc:            "throttled",
+			err:             &azcore.ResponseError{StatusCode: http.StatusTooManyRequests, ErrorCode: "SubscriptionRequestsThrottled"},
+			expectedCode:    codes.Unavailable,
+			expectedReason:  "ARM_THROTTLED",
+			expectedSection: "error-azure-resource-manager-is-throttling-the-requests-of-the-driver",
+		},
+		{
+			desc:           "gateway timeout is not a creation timeout",
+			err:            &azcore.ResponseError{StatusCode: http.StatusGatewayTimeout},
+			expectedCode:   codes.DeadlineExceeded,
+			expectedReason: armErrorReasonRequestFailed,
+		},
+		{
+			desc:           "unclassified error code",
+			err:            &azcore.ResponseError{StatusCode: http.StatusConflict, ErrorCode: "Conflict"},
+			expectedCode:   codes.InvalidArgument,
+			expectedReason: armErrorReasonRequestFailed,
+		},
+		{
+			desc:            "quota message",
+			err:             &azcore.ResponseError{StatusCode: http.StatusConflict, ErrorCode: amlfsQuotaExceededMessage},
+			expectedCode:    codes.ResourceExhausted,
+			expectedReason:  "AMLFS_QUOTA_EXCEEDED",
+			expectedSection: "error-reached-azure-subscription-quota-limit-for-amlfs-clusters",
+		},
+		{
+			desc:            "unclassified long-running operation failure",
+			err:             &azcore.ResponseError{StatusCode: http.StatusOK, ErrorCode: "SomethingFailed"},
+			expectedCode:    codes.DeadlineExceeded,
+			expectedReason:  "AMLFS_DEPLOYMENT_FAILED",
+			expectedSection: "error-amlfs-cluster-creation-timed-out",
+			expectedRetry:   armErrorRetryRecreateIfFailed,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			class, _ := classifyARMError(c.err)
+			assert.Equal(t, c.expectedRetry, class.retry)
+
+			err := convertHTTPResponseErrorToGrpcCodeError(c.err)
+			assert.Equal(t, c.expectedCode, status.Code(err))
+			errorInfo, help := armErrorDetails(t, err)
+			require.NotNil(t, errorInfo)
+			assert.Equal(t, c.expectedReason, errorInfo.GetReason())
+			assert.Equal(t, DefaultDriverName, errorInfo.GetDomain())
+			var respErr *azcore.ResponseError
+			require.ErrorAs(t, c.err, &respErr)
+			assert.Equal(t, respErr.ErrorCode, errorInfo.GetMetadata()["armErrorCode"])
+			assert.Equal(t, fmt.Sprint(respErr.StatusCode), errorInfo.GetMetadata()["httpStatusCode"])
+
+			if c.expectedSection == "" {
+				assert.Nil(t, help)
+				return
+			}
+			require.NotNil(t, help)
+			require.Len(t, help.GetLinks(), 1)
+			link := armErrorsDocURL + "#" + c.expectedSection
+			assert.Equal(t, link, help.GetLinks()[0].GetUrl())
+			// The events of the persistent volume claim only show the message
+			assert.Contains(t, status.Convert(err).Message(), link)
+		})
+	}
+
+	_, ok := classifyARMError(errors.New("CreateTimeout"))
+	assert.False(t, ok)
+}
+
+func TestARMErrorClassSections(t *testing.T) {
+	doc, err := os.ReadFile("../../docs/errors.md")
+	require.NoError(t, err)
+	for _, class := range armErrorClasses {
+		if class.section != "" {
+			assert.Contains(t, string(doc), "(#"+class.section+")",
+				"docs/errors.md has no section %s for the ARM errors %v", class.section, class.errorCodes)
+		}
+	}
+}
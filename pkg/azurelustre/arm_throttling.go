@@ -0,0 +1,283 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// armThrottleMaxRetries is how many times a throttled ARM request is
+	// retried before its 429 response is returned
+	armThrottleMaxRetries = 3
+	// armThrottleMaxRetryAfter is the longest Retry-After waited for, a
+	// longer one is left to the retries of the external provisioner
+	armThrottleMaxRetryAfter = 2 * time.Minute
+	// armThrottleDefaultRetryAfter is waited for when a 429 response has no
+	// Retry-After
+	armThrottleDefaultRetryAfter = 10 * time.Second
+	// armThrottleJitterFactor spreads the retries of the requests throttled
+	// together over up to this fraction of their Retry-After
+	armThrottleJitterFactor = 0.2
+	// armRetryBudgetBurst and armRetryBudgetRate bound the retries of all
+	// the ARM requests of the driver together, whatever their subscription
+	armRetryBudgetBurst = 10
+	armRetryBudgetRate  = rate.Limit(0.2)
+	// armCircuitBreakerThreshold consecutive throttled responses of a
+	// subscription and client open their circuit breaker for at least
+	// armCircuitBreakerCooldown
+	armCircuitBreakerThreshold = 5
+	armCircuitBreakerCooldown  = time.Minute
+
+	armThrottleRetryResultRetried  = "retried"
+	armThrottleRetryResultGaveUp   = "gave_up"
+	armThrottleRetryResultRejected = "rejected"
+)
+
+// armThrottle is the retry and throttling layer of the ARM requests of the
+// driver. It is shared by all of them, so a throttled request makes the
+// concurrent ones of the same subscription and client wait for the same
+// Retry-After, and the retries of all of them draw from one budget.
+type armThrottle struct {
+	lock   sync.Mutex
+	states map[armThrottleKey]*armThrottleState
+	// retryBudget is shared by all the subscriptions and clients
+	retryBudget *rate.Limiter
+
+	now    func() time.Time
+	jitter func(time.Duration) time.Duration
+}
+
+// armThrottleKey is what ARM throttles separately: the subscription of a
+// request and the client of its credential, empty for that of the driver
+type armThrottleKey struct {
+	subscriptionID string
+	clientID       string
+}
+
+func (k armThrottleKey) String() string {
+	if k.clientID == "" {
+		return "subscription " + k.subscriptionID
+	}
+	return "subscription " + k.subscriptionID + " and client " + k.clientID
+}
+
+// armThrottleState is how ARM throttles the requests of a key
+type armThrottleState struct {
+	// throttledUntil is when ARM accepts requests again, no request is sent
+	// before
+	throttledUntil time.Time
+	// consecutiveThrottled counts the throttled responses since the last
+	// response that was not, openUntil is when the circuit breaker they
+	// opened closes again
+	consecutiveThrottled int
+	openUntil            time.Time
+}
+
+func newARMThrottle() *armThrottle {
+	return &armThrottle{
+		states:      map[armThrottleKey]*armThrottleState{},
+		retryBudget: rate.NewLimiter(armRetryBudgetRate, armRetryBudgetBurst),
+		now:         time.Now,
+		jitter: func(delay time.Duration) time.Duration {
+			return wait.Jitter(delay, armThrottleJitterFactor)
+		},
+	}
+}
+
+// Do sends the request once ARM is no longer throttling its subscription and
+// client, and again after the Retry-After of its 429 responses while the
+// retry budget lasts. It fails right away with Unavailable while their
+// circuit breaker is open.
+func (t *armThrottle) Do(req *policy.Request) (*http.Response, error) {
+	ctx := req.Raw().Context()
+	resourceProvider := armResourceProvider(req.Raw().URL.Path)
+	key := armThrottleKey{
+		subscriptionID: armSubscriptionID(req.Raw().URL.Path),
+		clientID:       strings.ToLower(requestClientID(ctx)),
+	}
+	for attempt := 0; ; attempt++ {
+		if err := t.waitTurn(ctx, key); err != nil {
+			return nil, err
+		}
+		if err := req.RewindBody(); err != nil {
+			return nil, err
+		}
+		resp, err := req.Clone(ctx).Next()
+		if err != nil {
+			return resp, err
+		}
+		if resp.StatusCode != http.StatusTooManyRequests {
+			t.recordAccepted(key)
+			return resp, nil
+		}
+
+		armThrottledRequests.WithLabelValues(resourceProvider).Inc()
+		retryAfter := armRetryAfter(resp)
+		breakerOpened := t.recordThrottled(key, retryAfter)
+		switch {
+		case breakerOpened, attempt >= armThrottleMaxRetries, retryAfter > armThrottleMaxRetryAfter, !t.retryBudget.Allow():
+			armThrottleRetries.WithLabelValues(armThrottleRetryResultGaveUp).Inc()
+			klog.V(2).Infof("ARM throttled %s %s in %s after %d retries, retry after %v", req.Raw().Method, resourceProvider, key, attempt, retryAfter)
+			return resp, nil
+		}
+		armThrottleRetries.WithLabelValues(armThrottleRetryResultRetried).Inc()
+		klog.V(4).Infof("ARM throttled %s %s in %s, retrying after %v", req.Raw().Method, resourceProvider, key, retryAfter)
+		runtime.Drain(resp)
+	}
+}
+
+// waitTurn waits until ARM is no longer throttling the key, or fails when
+// its circuit breaker is open
+func (t *armThrottle) waitTurn(ctx context.Context, key armThrottleKey) error {
+	t.lock.Lock()
+	state := t.states[key]
+	if state == nil {
+		t.lock.Unlock()
+		return nil
+	}
+	now := t.now()
+	if now.Before(state.openUntil) {
+		openUntil := state.openUntil
+		t.lock.Unlock()
+		armThrottleRetries.WithLabelValues(armThrottleRetryResultRejected).Inc()
+		return status.Errorf(codes.Unavailable,
+			"Azure Resource Manager is throttling the requests of the driver in %s, not sending any before %s", key, openUntil.UTC().Format(time.RFC3339))
+	}
+	delay := state.throttledUntil.Sub(now)
+	t.lock.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// recordAccepted resets the throttling of the key after a response that was
+// not throttled
+func (t *armThrottle) recordAccepted(key armThrottleKey) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	state := t.states[key]
+	if state == nil {
+		return
+	}
+	if state.consecutiveThrottled >= armCircuitBreakerThreshold {
+		klog.Infof("ARM no longer throttles the driver in %s, closing the circuit breaker", key)
+		armCircuitBreakersOpen.Dec()
+	}
+	state.consecutiveThrottled = 0
+	if !t.now().Before(state.throttledUntil) {
+		delete(t.states, key)
+	}
+}
+
+// recordThrottled makes the next requests of the key wait for the
+// Retry-After of a throttled response, and returns whether it opened the
+// circuit breaker
+func (t *armThrottle) recordThrottled(key armThrottleKey, retryAfter time.Duration) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	state := t.states[key]
+	if state == nil {
+		state = &armThrottleState{}
+		t.states[key] = state
+	}
+	now := t.now()
+	if throttledUntil := now.Add(t.jitter(retryAfter)); throttledUntil.After(state.throttledUntil) {
+		state.throttledUntil = throttledUntil
+	}
+	state.consecutiveThrottled++
+	if state.consecutiveThrottled < armCircuitBreakerThreshold {
+		return false
+	}
+	// Opened again by the first request after it closes while ARM still
+	// throttles the key
+	if state.consecutiveThrottled == armCircuitBreakerThreshold {
+		armCircuitBreakersOpen.Inc()
+	}
+	state.openUntil = now.Add(max(armCircuitBreakerCooldown, retryAfter))
+	klog.Warningf("ARM throttled %d consecutive requests of the driver in %s, opening the circuit breaker until %s",
+		state.consecutiveThrottled, key, state.openUntil.UTC().Format(time.RFC3339))
+	return true
+}
+
+// armRetryAfter returns how long a throttled response asks to wait before
+// retrying, armThrottleDefaultRetryAfter when it does not say
+func armRetryAfter(resp *http.Response) time.Duration {
+	for _, header := range []string{"retry-after-ms", "x-ms-retry-after-ms"} {
+		if milliseconds, err := strconv.ParseInt(resp.Header.Get(header), 10, 64); err == nil && milliseconds > 0 {
+			return time.Duration(milliseconds) * time.Millisecond
+		}
+	}
+	retryAfter := resp.Header.Get("Retry-After")
+	if seconds, err := strconv.ParseInt(retryAfter, 10, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(retryAfter); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+	return armThrottleDefaultRetryAfter
+}
+
+// armResourceProvider returns the resource provider of an ARM request path,
+// e.g. Microsoft.StorageCache, a low cardinality metric label
+func armResourceProvider(path string) string {
+	segments := strings.Split(path, "/")
+	// The last provider of a nested resource, e.g. Microsoft.Authorization
+	// for the permissions of a subnet
+	for i := len(segments) - 2; i >= 0; i-- {
+		if strings.EqualFold(segments[i], "providers") {
+			return segments[i+1]
+		}
+	}
+	return "unknown"
+}
+
+// armSubscriptionID returns the subscription of an ARM request path in lower
+// case, empty for a request outside of a subscription
+func armSubscriptionID(path string) string {
+	segments := strings.Split(path, "/")
+	for i := 0; i < len(segments)-1; i++ {
+		if strings.EqualFold(segments[i], "subscriptions") {
+			return strings.ToLower(segments[i+1])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,247 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storagecache/armstoragecache/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+// throttlingTransport answers the ARM requests with the status codes given,
+// one per request, and 200 once they run out
+type throttlingTransport struct {
+	lock        sync.Mutex
+	statusCodes []int
+	headers     http.Header
+	requests    int
+}
+
+func (f *throttlingTransport) Do(req *http.Request) (*http.Response, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.requests++
+	statusCode := http.StatusOK
+	if len(f.statusCodes) > 0 {
+		statusCode, f.statusCodes = f.statusCodes[0], f.statusCodes[1:]
+	}
+	header := http.Header{"Content-Type": []string{"application/json"}}
+	body := `{"name": "amlfs"}`
+	if statusCode == http.StatusTooManyRequests {
+		for key, values := range f.headers {
+			header[key] = values
+		}
+		body = `{"error": {"code": "TooManyRequests", "message": "throttled"}}`
+	}
+	return &http.Response{
+		StatusCode: statusCode,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+// testThrottleKey is the throttling key of the requests of the test clients
+var testThrottleKey = armThrottleKey{subscriptionID: testSubscriptionID}
+
+func newThrottledTestClient(t *testing.T, throttle *armThrottle, transport *throttlingTransport) *armstoragecache.AmlFilesystemsClient {
+	t.Helper()
+	return newThrottledTestClientForSubscription(t, throttle, transport, testSubscriptionID)
+}
+
+func newThrottledTestClientForSubscription(t *testing.T, throttle *armThrottle, transport *throttlingTransport, subscriptionID string) *armstoragecache.AmlFilesystemsClient {
+	t.Helper()
+	options := armClientOptions(cloud.AzurePublic, throttle)
+	options.Transport = transport
+	client, err := armstoragecache.NewAmlFilesystemsClient(subscriptionID, &azfake.TokenCredential{}, options)
+	require.NoError(t, err)
+	return client
+}
+
+func getThrottledTestAmlFilesystem(client *armstoragecache.AmlFilesystemsClient) error {
+	_, err := client.Get(context.Background(), "rg", "amlfs", nil)
+	return convertHTTPResponseErrorToGrpcCodeError(err)
+}
+
+func TestARMThrottle(t *testing.T) {
+	retryAfterHeader := http.Header{"Retry-After-Ms": []string{"10"}}
+	throttledBefore := gatherMetricValues(t, legacyregistry.DefaultGatherer, "azurelustre_csi_arm_throttled_requests_total")
+	retriesBefore := gatherMetricValues(t, legacyregistry.DefaultGatherer, "azurelustre_csi_arm_throttle_retries_total")
+
+	// Throttled requests are retried after their Retry-After
+	throttle := newARMThrottle()
+	transport := &throttlingTransport{statusCodes: []int{http.StatusTooManyRequests, http.StatusTooManyRequests}, headers: retryAfterHeader}
+	client := newThrottledTestClient(t, throttle, transport)
+	start := time.Now()
+	require.NoError(t, getThrottledTestAmlFilesystem(client))
+	assert.Equal(t, 3, transport.requests)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	assert.Empty(t, throttle.states)
+
+	// Until the retries run out
+	transport = &throttlingTransport{statusCodes: []int{429, 429, 429, 429, 429}, headers: retryAfterHeader}
+	client = newThrottledTestClient(t, throttle, transport)
+	err := getThrottledTestAmlFilesystem(client)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, armThrottleMaxRetries+1, transport.requests)
+
+	// A Retry-After too long is left to the caller
+	transport = &throttlingTransport{statusCodes: []int{429}, headers: http.Header{"Retry-After": []string{"600"}}}
+	client = newThrottledTestClient(t, newARMThrottle(), transport)
+	assert.Equal(t, codes.Unavailable, status.Code(getThrottledTestAmlFilesystem(client)))
+	assert.Equal(t, 1, transport.requests)
+
+	// As are the retries beyond the shared budget
+	throttle = newARMThrottle()
+	throttle.retryBudget = rate.NewLimiter(0, 0)
+	transport = &throttlingTransport{statusCodes: []int{429}, headers: retryAfterHeader}
+	client = newThrottledTestClient(t, throttle, transport)
+	assert.Equal(t, codes.Unavailable, status.Code(getThrottledTestAmlFilesystem(client)))
+	assert.Equal(t, 1, transport.requests)
+
+	throttledAfter := gatherMetricValues(t, legacyregistry.DefaultGatherer, "azurelustre_csi_arm_throttled_requests_total")
+	retriesAfter := gatherMetricValues(t, legacyregistry.DefaultGatherer, "azurelustre_csi_arm_throttle_retries_total")
+	assert.InDelta(t, 8, throttledAfter["resource_provider=Microsoft.StorageCache"]-throttledBefore["resource_provider=Microsoft.StorageCache"], 0)
+	assert.InDelta(t, 5, retriesAfter["result=retried"]-retriesBefore["result=retried"], 0)
+	assert.InDelta(t, 3, retriesAfter["result=gave_up"]-retriesBefore["result=gave_up"], 0)
+}
+
+func TestARMThrottle_CircuitBreaker(t *testing.T) {
+	now := time.Now()
+	throttle := newARMThrottle()
+	throttle.now = func() time.Time { return now }
+	throttle.jitter = func(delay time.Duration) time.Duration { return delay }
+	transport := &throttlingTransport{statusCodes: []int{429, 429, 429, 429, 429}, headers: http.Header{"Retry-After-Ms": []string{"1"}}}
+	client := newThrottledTestClient(t, throttle, transport)
+
+	// Sustained throttling opens the circuit breaker
+	assert.Equal(t, codes.Unavailable, status.Code(getThrottledTestAmlFilesystem(client)))
+	assert.Equal(t, codes.Unavailable, status.Code(getThrottledTestAmlFilesystem(client)))
+	assert.Equal(t, armCircuitBreakerThreshold, transport.requests)
+	assert.Equal(t, now.Add(armCircuitBreakerCooldown), throttle.states[testThrottleKey].openUntil)
+	assert.Equal(t, map[string]float64{"": 1}, gatherMetricValues(t, legacyregistry.DefaultGatherer, "azurelustre_csi_arm_circuit_breaker_open"))
+
+	// No request is sent while it is open
+	err := getThrottledTestAmlFilesystem(client)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	require.ErrorContains(t, err, "Azure Resource Manager is throttling the requests of the driver in subscription "+testSubscriptionID)
+	assert.Equal(t, armCircuitBreakerThreshold, transport.requests)
+
+	// But the requests of other subscriptions are
+	otherTransport := &throttlingTransport{}
+	require.NoError(t, getThrottledTestAmlFilesystem(newThrottledTestClientForSubscription(t, throttle, otherTransport, testVnetSubscriptionID)))
+	assert.Equal(t, 1, otherTransport.requests)
+
+	// It closes after the first accepted request once the cooldown is over
+	now = now.Add(armCircuitBreakerCooldown)
+	require.NoError(t, getThrottledTestAmlFilesystem(client))
+	assert.Equal(t, armCircuitBreakerThreshold+1, transport.requests)
+	assert.Equal(t, map[string]float64{"": 0}, gatherMetricValues(t, legacyregistry.DefaultGatherer, "azurelustre_csi_arm_circuit_breaker_open"))
+}
+
+func TestARMThrottle_SharedRetryAfter(t *testing.T) {
+	throttle := newARMThrottle()
+	throttle.jitter = func(delay time.Duration) time.Duration { return delay }
+	assert.False(t, throttle.recordThrottled(testThrottleKey, time.Hour))
+
+	// Every request of the subscription waits for the Retry-After of another
+	// one
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, throttle.waitTurn(ctx, testThrottleKey), context.DeadlineExceeded)
+	throttle.recordAccepted(testThrottleKey)
+	require.ErrorIs(t, throttle.waitTurn(ctx, testThrottleKey), context.DeadlineExceeded)
+
+	// Those of other subscriptions and clients do not
+	require.NoError(t, throttle.waitTurn(ctx, armThrottleKey{subscriptionID: testVnetSubscriptionID}))
+	require.NoError(t, throttle.waitTurn(ctx, armThrottleKey{subscriptionID: testSubscriptionID, clientID: "client"}))
+
+	throttle = newARMThrottle()
+	require.NoError(t, throttle.waitTurn(context.Background(), testThrottleKey))
+}
+
+func TestARMThrottle_RequestCredential(t *testing.T) {
+	throttle := newARMThrottle()
+	transport := &throttlingTransport{statusCodes: []int{429}, headers: http.Header{"Retry-After": []string{"600"}}}
+	client := newThrottledTestClient(t, throttle, transport)
+
+	// The requests made with the credential of a provisioner secret are
+	// throttled apart from those of the driver
+	ctx := withRequestCredential(context.Background(), &azfake.TokenCredential{}, "Client")
+	_, err := client.Get(ctx, "rg", "amlfs", nil)
+	assert.Equal(t, codes.Unavailable, status.Code(convertHTTPResponseErrorToGrpcCodeError(err)))
+	assert.Equal(t, []armThrottleKey{{subscriptionID: testSubscriptionID, clientID: "client"}}, slices.Collect(maps.Keys(throttle.states)))
+	require.NoError(t, getThrottledTestAmlFilesystem(client))
+	assert.Equal(t, 2, transport.requests)
+}
+
+func TestARMSubscriptionID(t *testing.T) {
+	assert.Equal(t, "sub", armSubscriptionID("/subscriptions/SUB/resourceGroups/rg/providers/Microsoft.StorageCache/amlFilesystems/amlfs"))
+	assert.Equal(t, "sub", armSubscriptionID("/subscriptions/sub/providers/Microsoft.StorageCache/skus"))
+	assert.Empty(t, armSubscriptionID("/subscriptions"))
+	assert.Empty(t, armSubscriptionID("/metadata/endpoints"))
+}
+
+func TestARMRetryAfter(t *testing.T) {
+	cases := []struct {
+		desc     string
+		header   http.Header
+		expected time.Duration
+	}{
+		{desc: "milliseconds", header: http.Header{"Retry-After-Ms": []string{"1500"}}, expected: 1500 * time.Millisecond},
+		{desc: "ARM milliseconds", header: http.Header{"X-Ms-Retry-After-Ms": []string{"250"}}, expected: 250 * time.Millisecond},
+		{desc: "seconds", header: http.Header{"Retry-After": []string{"17"}}, expected: 17 * time.Second},
+		{desc: "date in the past", header: http.Header{"Retry-After": []string{"Wed, 21 Oct 2015 07:28:00 GMT"}}, expected: armThrottleDefaultRetryAfter},
+		{desc: "invalid", header: http.Header{"Retry-After": []string{"soon"}}, expected: armThrottleDefaultRetryAfter},
+		{desc: "none", header: http.Header{}, expected: armThrottleDefaultRetryAfter},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			assert.Equal(t, c.expected, armRetryAfter(&http.Response{Header: c.header}))
+		})
+	}
+
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	retryAfter := armRetryAfter(&http.Response{Header: http.Header{"Retry-After": []string{date}}})
+	assert.Greater(t, retryAfter, 58*time.Second)
+	assert.LessOrEqual(t, retryAfter, time.Minute)
+}
+
+func TestARMResourceProvider(t *testing.T) {
+	assert.Equal(t, "Microsoft.StorageCache",
+		armResourceProvider("/subscriptions/sub/resourceGroups/rg/providers/Microsoft.StorageCache/amlFilesystems/amlfs"))
+	assert.Equal(t, "Microsoft.Authorization",
+		armResourceProvider("/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/virtualNetworks/vnet/providers/Microsoft.Authorization/permissions"))
+	assert.Equal(t, "unknown", armResourceProvider("/subscriptions/sub/providers"))
+	assert.Equal(t, "unknown", armResourceProvider("/metadata/endpoints"))
+}
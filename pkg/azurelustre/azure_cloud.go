@@ -0,0 +1,72 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"sigs.k8s.io/cloud-provider-azure/pkg/azclient"
+	"sigs.k8s.io/cloud-provider-azure/pkg/azclient/utils"
+	azureconfig "sigs.k8s.io/cloud-provider-azure/pkg/provider/config"
+)
+
+// azureCloudConfiguration returns the ARM endpoint and the Microsoft Entra
+// authority of the cloud of the config, as the cloud provider resolves them:
+// the public cloud by default, a known cloud such as AzureChinaCloud or
+// AzureUSGovernmentCloud by name, or the endpoints of the environment file
+// named by AZURE_ENVIRONMENT_FILEPATH or of resourceManagerEndpoint for
+// AzureStackCloud and other custom clouds
+func azureCloudConfiguration(config *azureconfig.Config) (cloud.Configuration, error) {
+	if config == nil {
+		return cloud.AzurePublic, nil
+	}
+	// The endpoints of custom clouds are set on copies, the known clouds and
+	// environments are shared by every client of the process
+	cloudConfig := *utils.AzureCloudConfigFromName(config.Cloud)
+	cloudConfig.Services = maps.Clone(cloudConfig.Services)
+	env := *azclient.EnvironmentFromName(config.Cloud)
+	if err := azclient.OverrideAzureCloudConfigAndEnvConfigFromMetadataService(config.ResourceManagerEndpoint, config.Cloud, &cloudConfig, &env); err != nil {
+		return cloud.Configuration{}, fmt.Errorf("failed to get the endpoints of cloud %q from %s: %w", config.Cloud, config.ResourceManagerEndpoint, err)
+	}
+	if err := azclient.OverrideAzureCloudConfigFromEnv(config.Cloud, &cloudConfig, &env); err != nil {
+		return cloud.Configuration{}, fmt.Errorf("failed to get the endpoints of cloud %q from the file of %s: %w", config.Cloud, azclient.EnvironmentFilepathName, err)
+	}
+	return cloudConfig, nil
+}
+
+// armClientOptions returns the options of the ARM clients of the cloud,
+// whose throttled requests are retried by throttle alone when not nil
+func armClientOptions(cloudConfig cloud.Configuration, throttle *armThrottle) *arm.ClientOptions {
+	options := &arm.ClientOptions{ClientOptions: azcore.ClientOptions{Cloud: cloudConfig}}
+	if throttle != nil {
+		options.PerCallPolicies = []policy.Policy{throttle}
+		options.Retry.StatusCodes = []int{
+			http.StatusRequestTimeout,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		}
+	}
+	return options
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/cloud-provider-azure/pkg/azclient"
+	azureconfig "sigs.k8s.io/cloud-provider-azure/pkg/provider/config"
+)
+
+func cloudConfigFor(cloudName, resourceManagerEndpoint string) *azureconfig.Config {
+	config := &azureconfig.Config{}
+	config.Cloud = cloudName
+	config.ResourceManagerEndpoint = resourceManagerEndpoint
+	return config
+}
+
+func TestAzureCloudConfiguration(t *testing.T) {
+	envFile := filepath.Join(t.TempDir(), "azurestackcloud.json")
+	require.NoError(t, os.WriteFile(envFile, []byte(`{
+		"name": "AzureStackCloud",
+		"resourceManagerEndpoint": "https://management.local.azurestack.external/",
+		"activeDirectoryEndpoint": "https://login.local.azurestack.external/",
+		"tokenAudience": "https://management.azurestack.external/"
+	}`), 0o600))
+	metadataServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, err := w.Write([]byte(`[{
+			"name": "CustomCloud",
+			"resourceManager": "https://management.custom.example/",
+			"authentication": {"audiences": ["https://management.core.custom.example/"], "loginEndpoint": "https://login.custom.example/"}
+		}]`))
+		assert.NoError(t, err)
+	}))
+	defer metadataServer.Close()
+	publicResourceManager := cloud.AzurePublic.Services[cloud.ResourceManager]
+
+	cases := []struct {
+		desc              string
+		config            *azureconfig.Config
+		envFile           string
+		expectedAuthority string
+		expectedEndpoint  string
+		expectedAudience  string
+		expectedError     string
+	}{
+		{
+			desc:              "no config",
+			expectedAuthority: cloud.AzurePublic.ActiveDirectoryAuthorityHost,
+			expectedEndpoint:  publicResourceManager.Endpoint,
+		},
+		{
+			desc:              "default cloud",
+			config:            cloudConfigFor("", ""),
+			expectedAuthority: cloud.AzurePublic.ActiveDirectoryAuthorityHost,
+			expectedEndpoint:  publicResourceManager.Endpoint,
+		},
+		{
+			desc:              "Azure China",
+			config:            cloudConfigFor("AzureChinaCloud", ""),
+			expectedAuthority: "https://login.chinacloudapi.cn/",
+			expectedEndpoint:  "https://management.chinacloudapi.cn",
+		},
+		{
+			desc:              "Azure US Government",
+			config:            cloudConfigFor("azureusgovernmentcloud", ""),
+			expectedAuthority: "https://login.microsoftonline.us/",
+			expectedEndpoint:  "https://management.usgovcloudapi.net",
+		},
+		{
+			desc:              "environment file",
+			config:            cloudConfigFor("AzureStackCloud", ""),
+			envFile:           envFile,
+			expectedAuthority: "https://login.local.azurestack.external/",
+			expectedEndpoint:  "https://management.local.azurestack.external/",
+			expectedAudience:  "https://management.azurestack.external/",
+		},
+		{
+			desc:          "missing environment file",
+			config:        cloudConfigFor("AzureStackCloud", ""),
+			envFile:       filepath.Join(t.TempDir(), "missing.json"),
+			expectedError: `failed to get the endpoints of cloud "AzureStackCloud" from the file of AZURE_ENVIRONMENT_FILEPATH`,
+		},
+		{
+			desc:              "resource manager endpoint",
+			config:            cloudConfigFor("CustomCloud", metadataServer.URL),
+			expectedAuthority: "https://login.custom.example/",
+			expectedEndpoint:  "https://management.custom.example/",
+			expectedAudience:  "https://management.core.custom.example/",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			if c.envFile != "" {
+				t.Setenv(azclient.EnvironmentFilepathName, c.envFile)
+			}
+
+			cloudConfig, err := azureCloudConfiguration(c.config)
+			if c.expectedError != "" {
+				require.ErrorContains(t, err, c.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.expectedAuthority, cloudConfig.ActiveDirectoryAuthorityHost)
+			assert.Equal(t, c.expectedEndpoint, cloudConfig.Services[cloud.ResourceManager].Endpoint)
+			if c.expectedAudience != "" {
+				assert.Equal(t, c.expectedAudience, cloudConfig.Services[cloud.ResourceManager].Audience)
+			}
+			assert.Equal(t, cloudConfig, armClientOptions(cloudConfig, nil).Cloud)
+		})
+	}
+
+	// Custom clouds leave the endpoints of the public cloud untouched
+	assert.Equal(t, publicResourceManager, cloud.AzurePublic.Services[cloud.ResourceManager])
+	assert.Equal(t, "https://login.microsoftonline.com/", cloud.AzurePublic.ActiveDirectoryAuthorityHost)
+}
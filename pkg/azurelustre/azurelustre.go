@@ -25,24 +25,25 @@ import (
 	"sync"
 	"time"
 
-	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
-	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v6"
-	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storagecache/armstoragecache/v4"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8stypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/component-base/metrics/legacyregistry"
 	"k8s.io/klog/v2"
 	mount "k8s.io/mount-utils"
 	utilexec "k8s.io/utils/exec"
 	csicommon "sigs.k8s.io/azurelustre-csi-driver/pkg/csi-common"
+	"sigs.k8s.io/azurelustre-csi-driver/pkg/lnet"
 	"sigs.k8s.io/azurelustre-csi-driver/pkg/util"
-	"sigs.k8s.io/cloud-provider-azure/pkg/azclient/configloader"
 	azure "sigs.k8s.io/cloud-provider-azure/pkg/provider"
-	azureconfig "sigs.k8s.io/cloud-provider-azure/pkg/provider/config"
 )
 
 const (
@@ -108,6 +109,9 @@ type lustreVolume struct {
 	subDir                       string
 	createdByDynamicProvisioning bool
 	resourceGroupName            string
+	// subscriptionID is the subscription of a dynamically created cluster,
+	// that of the driver when empty
+	subscriptionID string
 }
 
 // DriverOptions defines driver parameters specified in driver deployment
@@ -118,6 +122,21 @@ type DriverOptions struct {
 	EnableAzureLustreMockDynProv bool
 	WorkingMountDir              string
 	RemoveNotReadyTaint          bool
+	LustreJobIDVar               string
+	LustreJobIDName              string
+	LNetNetworks                 string
+	LNetConfigFile               string
+	LNetCheckInterval            time.Duration
+	UnloadLustreModules          bool
+	MountReconcileInterval       time.Duration
+	MountReconcileDryRun         bool
+	AmlfsGCInterval              time.Duration
+	AmlfsGCDeleteGracePeriod     time.Duration
+	LeaderElectionNamespace      string
+	VerifyPermissions            bool
+	CandidateSubnets             string
+	NetworkPreflight             string
+	CloudConfigReloadInterval    time.Duration
 }
 
 // LustreSkuValue describes the increment and maximum size of a given Lustre sku
@@ -147,10 +166,25 @@ type Driver struct {
 	volumeLocks      *volumeLocks
 	kernelModuleLock sync.Mutex
 
-	cloud              *azure.Cloud
+	// The Azure clients and the defaults of the cloud config, replaced
+	// together under azureClientsLock when the cloud config file changes.
+	// Requests read them with azureClientsFor.
+	cloud *azure.Cloud
+	// cloudConfig holds the ARM endpoint and Microsoft Entra authority of the
+	// cloud of the config, those of the public cloud when empty
+	cloudConfig        cloud.Configuration
 	resourceGroup      string
 	location           string
 	dynamicProvisioner DynamicProvisionerInterface
+	azureClientsLock   sync.RWMutex
+	// cloudConfigFile is checked for changes every cloudConfigReloadInterval,
+	// never when zero. cloudConfigDigest is that of its last loaded content.
+	cloudConfigFile           string
+	cloudConfigReloadInterval time.Duration
+	cloudConfigDigest         string
+	// azureClientID is the AZURE_CLIENT_ID of the driver process, read once
+	// so that it never hides a client ID changed in the cloud config
+	azureClientID string
 
 	removeNotReadyTaint bool
 	kubeClient          kubernetes.Interface
@@ -158,6 +192,69 @@ type Driver struct {
 	taintRemovalInitialDelay time.Duration
 	// taintRemovalBackoff is the exponential backoff configuration for node taint removal
 	taintRemovalBackoff wait.Backoff
+
+	// lustreJobIDVar and lustreJobIDName are the node-wide Lustre jobid_var and
+	// jobid_name settings, left untouched when lustreJobIDVar is empty
+	lustreJobIDVar  string
+	lustreJobIDName string
+
+	// sysModuleDir is where loaded kernel modules are listed, /sys/module
+	sysModuleDir string
+	// checkReadiness and checkLiveness run the driver health checks, replaced in tests
+	checkReadiness func(context.Context) *driverReadiness
+	checkLiveness  func(context.Context) *driverReadiness
+	// readinessPollInterval is how often taint removal checks whether the driver became ready
+	readinessPollInterval time.Duration
+
+	// lnetNetworks and lnetConfigFile describe the LNet networks to keep
+	// configured on the node, LNet is left untouched when both are empty
+	lnetNetworks      string
+	lnetConfigFile    string
+	lnetCheckInterval time.Duration
+	eventRecorder     record.EventRecorder
+	eventRecorderLock sync.Mutex
+
+	// unloadLustreModules unloads the Lustre kernel modules on shutdown when
+	// no Lustre file system remains mounted on the node
+	unloadLustreModules bool
+
+	// mountReconcileInterval is how often orphaned and corrupted Lustre mounts
+	// are cleaned up after startup, zero only cleans up at startup
+	mountReconcileInterval time.Duration
+	// mountReconcileDryRun only logs the Lustre mounts that would be cleaned up
+	mountReconcileDryRun bool
+
+	// clusterID identifies this Kubernetes cluster in the tags of the AMLFS
+	// clusters it creates, the UID of the kube-system namespace
+	clusterID string
+	// amlfsGCInterval is how often the leader controller looks for AMLFS
+	// clusters whose persistent volume no longer exists, zero disables it
+	amlfsGCInterval time.Duration
+	// amlfsGCDeleteGracePeriod is how long an AMLFS cluster stays orphaned
+	// before it is deleted, zero only reports orphaned clusters
+	amlfsGCDeleteGracePeriod time.Duration
+	leaderElectionNamespace  string
+	// amlfsOrphanedSince maps resource group/name of the orphaned AMLFS
+	// clusters to when they were first found orphaned
+	amlfsOrphanedSince map[string]time.Time
+	amlfsGCLock        sync.Mutex
+
+	// permissionVerifier runs the startup permission self-test of the
+	// controller identity, nil when it is disabled
+	permissionVerifier   *permissionVerifier
+	permissionReport     *permissionReport
+	permissionReportLock sync.Mutex
+
+	// candidateSubnets are the subnets AMLFS clusters are created in when
+	// the storage class names neither a subnet nor candidate subnets
+	candidateSubnets []SubnetProperties
+	// networkPreflight and permissionSelfTest are the options of the Azure
+	// clients built from the cloud config
+	networkPreflight   string
+	permissionSelfTest bool
+	// armThrottle retries the throttled ARM requests of all the Azure
+	// clients, kept when they are rebuilt
+	armThrottle *armThrottle
 }
 
 // NewDriver Creates a NewCSIDriver object. Assumes vendor version is equal to driver version &
@@ -170,7 +267,40 @@ func NewDriver(options *DriverOptions) *Driver {
 		enableAzureLustreMockDynProv: options.EnableAzureLustreMockDynProv,
 		workingMountDir:              options.WorkingMountDir,
 		removeNotReadyTaint:          options.RemoveNotReadyTaint,
+		lustreJobIDVar:               options.LustreJobIDVar,
+		lustreJobIDName:              options.LustreJobIDName,
+		sysModuleDir:                 defaultSysModuleDir,
+		readinessPollInterval:        10 * time.Second,
+		lnetNetworks:                 options.LNetNetworks,
+		lnetConfigFile:               options.LNetConfigFile,
+		lnetCheckInterval:            options.LNetCheckInterval,
+		unloadLustreModules:          options.UnloadLustreModules,
+		mountReconcileInterval:       options.MountReconcileInterval,
+		mountReconcileDryRun:         options.MountReconcileDryRun,
+		amlfsGCInterval:              options.AmlfsGCInterval,
+		amlfsGCDeleteGracePeriod:     options.AmlfsGCDeleteGracePeriod,
+		leaderElectionNamespace:      options.LeaderElectionNamespace,
+		permissionSelfTest:           options.VerifyPermissions,
+		networkPreflight:             options.NetworkPreflight,
+		cloudConfigReloadInterval:    options.CloudConfigReloadInterval,
+		armThrottle:                  newARMThrottle(),
+		azureClientID:                os.Getenv("AZURE_CLIENT_ID"),
+	}
+	switch options.NetworkPreflight {
+	case "", NetworkPreflightOff, NetworkPreflightWarn, NetworkPreflightFail:
+	default:
+		klog.Fatalf("invalid network preflight %q, must be one of: %s, %s, %s",
+			options.NetworkPreflight, NetworkPreflightOff, NetworkPreflightWarn, NetworkPreflightFail)
+	}
+	if options.CandidateSubnets != "" {
+		candidateSubnets, err := parseCandidateSubnets(options.CandidateSubnets)
+		if err != nil {
+			klog.Fatalf("invalid candidate subnets %q: %v", options.CandidateSubnets, err)
+		}
+		d.candidateSubnets = candidateSubnets
 	}
+	d.checkReadiness = d.checkLustreReadiness
+	d.checkLiveness = d.checkLustreLiveness
 	d.Name = options.DriverName
 	d.Version = driverVersion
 	d.NodeID = options.NodeID
@@ -179,9 +309,19 @@ func NewDriver(options *DriverOptions) *Driver {
 	d.DefaultIdentityServer.Driver = &d.CSIDriver
 	d.DefaultNodeServer.Driver = &d.CSIDriver
 
-	ctx := context.Background()
+	d.mounter = &mount.SafeFormatAndMount{
+		Interface: mount.New(""),
+		Exec:      utilexec.New(),
+	}
+	forceUnmounter, ok := d.mounter.Interface.(mount.MounterForceUnmounter)
+	if ok {
+		klog.V(4).Infof("Using force unmounter interface")
+		d.forceMounter = &forceUnmounter
+	} else {
+		klog.Fatalf("Mounter does not support force unmount")
+	}
 
-	az := &azure.Cloud{}
+	ctx := context.Background()
 
 	credFile, ok := os.LookupEnv(DefaultAzureConfigFileEnv)
 	if ok && strings.TrimSpace(credFile) != "" {
@@ -190,39 +330,21 @@ func NewDriver(options *DriverOptions) *Driver {
 		credFile = DefaultConfigFilePathLinux
 		klog.V(2).Infof("use default %s env var: %v", DefaultAzureConfigFileEnv, credFile)
 	}
+	d.cloudConfigFile = credFile
 
-	config, err := configloader.Load[azureconfig.Config](ctx, nil, &configloader.FileLoaderConfig{
-		FilePath: credFile,
-	})
+	config, digest, err := loadCloudConfig(ctx, credFile)
 	if err != nil {
-		klog.V(2).Infof("failed to get cloud config from file %s: %v", credFile, err)
+		klog.V(2).Infof("%v", err)
 	}
 
 	if config == nil {
 		if d.enableAzureLustreMockDynProv {
 			klog.V(2).Infof("no cloud config provided, driver running with mock dynamic provisioning")
-			d.dynamicProvisioner = &DynamicProvisioner{}
-			d.cloud = az
+			d.setAzureClients(newMockAzureClients())
 		} else {
 			klog.Fatalf("no cloud config provided, error")
 		}
 	} else {
-		config.UserAgent = GetUserAgent(d.Name, "", "")
-		if clientID := os.Getenv("AZURE_CLIENT_ID"); clientID != "" {
-			config.AADClientID = clientID
-		} else if config.UseManagedIdentityExtension && config.UserAssignedIdentityID != "" {
-			err = os.Setenv("AZURE_CLIENT_ID", config.UserAssignedIdentityID)
-			if err != nil {
-				klog.Warningf("failed to set AZURE_CLIENT_ID env var: %v", err)
-			}
-			config.AADClientID = config.UserAssignedIdentityID
-		}
-		if err = az.InitializeCloudFromConfig(ctx, config, false, false); err != nil {
-			klog.Warningf("InitializeCloudFromConfig failed with error: %v", err)
-		}
-		d.cloud = az
-		d.resourceGroup = config.ResourceGroup
-		d.location = config.Location
 		// Get kubernetes client for taint removal functionality
 		kubeClient, err := getKubeClient()
 		if err != nil {
@@ -235,63 +357,19 @@ func NewDriver(options *DriverOptions) *Driver {
 			Factor:   2,
 			Steps:    10, // Max delay = 0.5 * 2^9 = ~4 minutes
 		}
-		cred, err := azidentity.NewDefaultAzureCredential(nil)
-		if err != nil {
-			klog.Warningf("failed to obtain a credential: %v", err)
-		}
-		storageClientFactory, err := armstoragecache.NewClientFactory(config.SubscriptionID, cred, nil)
-		if err != nil {
-			klog.Warningf("failed to create storage client factory: %v", err)
-		}
-		subsID := d.cloud.SubscriptionID
-		if len(d.cloud.NetworkResourceSubscriptionID) > 0 {
-			subsID = d.cloud.NetworkResourceSubscriptionID
-		}
-		networkClientFactory, err := armnetwork.NewClientFactory(subsID, cred, nil)
+		clients, err := d.newAzureClients(ctx, config)
 		if err != nil {
-			klog.Warningf("failed to create network client factory: %v", err)
-		}
-		vnetClient := networkClientFactory.NewVirtualNetworksClient()
-		skusClient := storageClientFactory.NewSKUsClient()
-		mgmtClient := storageClientFactory.NewManagementClient()
-		amlFilesystemsClient := storageClientFactory.NewAmlFilesystemsClient()
-		d.dynamicProvisioner = &DynamicProvisioner{
-			amlFilesystemsClient: amlFilesystemsClient,
-			mgmtClient:           mgmtClient,
-			vnetClient:           vnetClient,
-			skusClient:           skusClient,
+			klog.Fatalf("%v", err)
 		}
+		d.setAzureClients(clients)
+		d.cloudConfigDigest = digest
 	}
 
 	return &d
 }
 
 func (d *Driver) populateSubnetPropertiesFromCloudConfig(subnetInfo SubnetProperties) SubnetProperties {
-	subnetProperties := subnetInfo
-	subsID := d.cloud.SubscriptionID
-	if len(d.cloud.NetworkResourceSubscriptionID) > 0 {
-		subsID = d.cloud.NetworkResourceSubscriptionID
-	}
-
-	if len(subnetInfo.VnetResourceGroup) == 0 {
-		subnetProperties.VnetResourceGroup = d.cloud.ResourceGroup
-		if len(d.cloud.VnetResourceGroup) > 0 {
-			subnetProperties.VnetResourceGroup = d.cloud.VnetResourceGroup
-		}
-	}
-
-	if len(subnetInfo.VnetName) == 0 {
-		subnetProperties.VnetName = d.cloud.VnetName
-	}
-
-	if len(subnetInfo.SubnetName) == 0 {
-		subnetProperties.SubnetName = d.cloud.SubnetName
-	}
-	subnetID := fmt.Sprintf(subnetTemplate, subsID, subnetProperties.VnetResourceGroup, subnetProperties.VnetName, subnetProperties.SubnetName)
-
-	subnetProperties.SubnetID = subnetID
-
-	return subnetProperties
+	return d.currentAzureClients().populateSubnetProperties(subnetInfo, "")
 }
 
 // Run driver initialization
@@ -302,18 +380,6 @@ func (d *Driver) Run(endpoint string, testBool bool) {
 	}
 	klog.Infof("\nDRIVER INFORMATION:\n-------------------\n%s\n\nStreaming logs below:", versionMeta)
 
-	d.mounter = &mount.SafeFormatAndMount{
-		Interface: mount.New(""),
-		Exec:      utilexec.New(),
-	}
-	forceUnmounter, ok := d.mounter.Interface.(mount.MounterForceUnmounter)
-	if ok {
-		klog.V(4).Infof("Using force unmounter interface")
-		d.forceMounter = &forceUnmounter
-	} else {
-		klog.Fatalf("Mounter does not support force unmount")
-	}
-
 	// TODO_JUSJIN: revisit these caps
 	// Initialize default library driver
 	// TODO_CHYIN: move this to {service}.go
@@ -321,12 +387,79 @@ func (d *Driver) Run(endpoint string, testBool bool) {
 	d.AddVolumeCapabilityAccessModes(volumeCapabilities)
 	d.AddNodeServiceCapabilities(nodeServiceCapabilities)
 
+	if d.NodeID != "" && !d.enableAzureLustreMockMount {
+		if err := validateLustreJobIDOptions(d.lustreJobIDVar, d.lustreJobIDName); err != nil {
+			klog.Fatalf("%v", err)
+		}
+		if err := d.configureLustreJobID(); err != nil {
+			klog.Errorf("failed to configure Lustre jobid: %v", err)
+		}
+		if err := legacyregistry.CustomRegister(newLustreClientStatsCollector(d)); err != nil {
+			klog.Warningf("failed to register Lustre client stats collector: %v", err)
+		}
+		lnetNetworks, err := d.getLNetNetworks()
+		if err != nil {
+			klog.Fatalf("%v", err)
+		}
+		if lnetNetworks != nil {
+			lnetManager := lnet.NewManager(d.mounter.Exec, lnetNetworks, d.getEventRecorder(), d.NodeID)
+			go lnetManager.Run(context.Background(), d.lnetCheckInterval)
+		}
+		// Runs before the gRPC server starts, so no internal mount is in use yet
+		d.runMountReconciler(context.Background())
+	}
+
+	if d.NodeID == "" && d.kubeClient != nil {
+		if d.clusterID, err = d.getClusterID(context.Background()); err != nil {
+			klog.Warningf("not tagging AMLFS clusters with the cluster ID, orphaned clusters will not be collected: %v", err)
+		} else if d.amlfsGCInterval > 0 {
+			d.runAmlFilesystemGC(context.Background())
+		}
+	}
+	d.verifyPermissions(context.Background())
+	if d.cloudConfigReloadInterval > 0 && d.cloudConfigDigest != "" {
+		d.runCloudConfigReloader(context.Background())
+	}
+
 	d.removeNotReadyTaintIfNeeded()
 
 	s := csicommon.NewNonBlockingGRPCServer()
 	// Driver d act as IdentityServer, ControllerServer and NodeServer
 	s.Start(endpoint, d, d, d, testBool)
+	if testBool {
+		s.Wait()
+		return
+	}
+	shutdownDone := d.handleShutdownSignals(s)
 	s.Wait()
+	// The modules are unloaded after the server stopped, wait for it
+	<-shutdownDone
+}
+
+// getLNetNetworks returns the LNet networks to manage, from the config file
+// when one is set, or nil when LNet is not managed by the driver
+func (d *Driver) getLNetNetworks() ([]lnet.Network, error) {
+	if d.lnetConfigFile != "" {
+		return lnet.LoadConfigFile(d.lnetConfigFile)
+	}
+	if d.lnetNetworks != "" {
+		return lnet.ParseNetworks(d.lnetNetworks)
+	}
+	return nil, nil
+}
+
+// getEventRecorder returns the recorder for events of this driver instance,
+// nil when there is no kubernetes client to publish them with
+func (d *Driver) getEventRecorder() record.EventRecorder {
+	d.eventRecorderLock.Lock()
+	defer d.eventRecorderLock.Unlock()
+	if d.eventRecorder == nil && d.kubeClient != nil {
+		broadcaster := record.NewBroadcaster()
+		broadcaster.StartStructuredLogging(4)
+		broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: d.kubeClient.CoreV1().Events("")})
+		d.eventRecorder = broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: d.Name, Host: d.NodeID})
+	}
+	return d.eventRecorder
 }
 
 func IsCorruptedDir(dir string) bool {
@@ -360,6 +493,10 @@ func getLustreVolFromID(id string) (*lustreVolume, error) {
 		vol.resourceGroupName = segments[5]
 	}
 
+	if len(segments) >= 7 {
+		vol.subscriptionID = segments[6]
+	}
+
 	return vol, nil
 }
 
@@ -385,6 +522,8 @@ func (d *Driver) removeNotReadyTaintIfNeeded() {
 	// This is done at the last possible moment to prevent race conditions or false positive removals
 	if d.kubeClient != nil && d.removeNotReadyTaint && d.NodeID != "" {
 		time.AfterFunc(d.taintRemovalInitialDelay, func() {
+			// Only advertise the driver as ready once Lustre can actually be mounted
+			d.waitForLustreReadiness(d.readinessPollInterval)
 			removeTaintInBackground(d.kubeClient, d.NodeID, d.Name, d.taintRemovalBackoff, removeNotReadyTaint)
 		})
 	}
@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"math"
 	"os"
+	"path/filepath"
 	"reflect"
 	"slices"
 	"strings"
@@ -29,6 +30,7 @@ import (
 	"testing/synctest"
 	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storagecache/armstoragecache/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc/codes"
@@ -39,6 +41,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
 	kubefake "k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/azurelustre-csi-driver/pkg/lnet"
 	azure "sigs.k8s.io/cloud-provider-azure/pkg/provider"
 )
 
@@ -72,10 +75,23 @@ func NewFakeDriver() *Driver {
 	driver.location = driverDefaultLocation
 	driver.resourceGroup = "defaultFakeResourceGroup"
 	driver.dynamicProvisioner = &FakeDynamicProvisioner{}
+	driver.checkReadiness = fakeReadiness(true)
+	driver.checkLiveness = fakeReadiness(true)
 
 	return driver
 }
 
+// fakeReadiness returns a health check that always reports the given state
+func fakeReadiness(ready bool) func(context.Context) *driverReadiness {
+	return func(context.Context) *driverReadiness {
+		readiness := &driverReadiness{Ready: ready}
+		if !ready {
+			readiness.Reason = "fake not ready"
+		}
+		return readiness
+	}
+}
+
 type FakeDynamicProvisioner struct {
 	DynamicProvisionerInterface
 	Filesystems   []*AmlFilesystemProperties
@@ -89,27 +105,63 @@ func (f *FakeDynamicProvisioner) recordFakeCall(name string) {
 	f.fakeCallCount[name]++
 }
 
-func (f *FakeDynamicProvisioner) CreateAmlFilesystem(_ context.Context, amlFilesystemProperties *AmlFilesystemProperties) (string, error) {
+func (f *FakeDynamicProvisioner) CreateAmlFilesystem(ctx context.Context, amlFilesystemProperties *AmlFilesystemProperties) (string, error) {
 	f.recordFakeCall("CreateAmlFilesystem")
 	if strings.HasSuffix(amlFilesystemProperties.AmlFilesystemName, clusterRequestFailureName) {
 		return "", status.Errorf(codes.InvalidArgument, "error occurred calling API: %s", clusterRequestFailureName)
 	}
+	if len(amlFilesystemProperties.candidateSubnets) > 0 {
+		subnetInfo, _, err := chooseSubnet(ctx, amlFilesystemProperties, f.GetSubnetCapacity)
+		if err != nil {
+			return "", err
+		}
+		amlFilesystemProperties.SubnetInfo = subnetInfo
+	}
 	f.Filesystems = append(f.Filesystems, amlFilesystemProperties)
 	return "127.0.0.2", nil
 }
 
-func (f *FakeDynamicProvisioner) DeleteAmlFilesystem(_ context.Context, _, amlFilesystemName string) error {
+func (f *FakeDynamicProvisioner) DeleteAmlFilesystem(_ context.Context, subscriptionID, _, amlFilesystemName string) error {
 	f.recordFakeCall("DeleteAmlFilesystem")
 	if amlFilesystemName == clusterRequestFailureName {
 		return status.Errorf(codes.InvalidArgument, "error occurred calling API: %s", clusterRequestFailureName)
 	}
 	f.Filesystems = slices.DeleteFunc(f.Filesystems, func(filesystem *AmlFilesystemProperties) bool {
-		return filesystem.AmlFilesystemName == amlFilesystemName
+		return filesystem.AmlFilesystemName == amlFilesystemName && strings.EqualFold(filesystem.SubscriptionID, subscriptionID)
 	})
 	return nil
 }
 
-func (f *FakeDynamicProvisioner) GetSkuValuesForLocation(_ context.Context, location string) (map[string]*LustreSkuValue, error) {
+func (f *FakeDynamicProvisioner) ListAmlFilesystems(_ context.Context, subscriptionID string) ([]*AmlFilesystemSummary, error) {
+	f.recordFakeCall("ListAmlFilesystems")
+	amlFilesystems := make([]*AmlFilesystemSummary, 0, len(f.Filesystems))
+	for _, filesystem := range f.Filesystems {
+		if !strings.EqualFold(filesystem.SubscriptionID, subscriptionID) {
+			continue
+		}
+		amlFilesystems = append(amlFilesystems, &AmlFilesystemSummary{
+			SubscriptionID:    subscriptionID,
+			ResourceGroupName: filesystem.ResourceGroupName,
+			AmlFilesystemName: filesystem.AmlFilesystemName,
+			ProvisioningState: string(armstoragecache.AmlFilesystemProvisioningStateTypeSucceeded),
+			Tags:              filesystem.Tags,
+		})
+	}
+	return amlFilesystems, nil
+}
+
+func (f *FakeDynamicProvisioner) GetSubnetCapacity(_ context.Context, _ string, subnetInfo SubnetProperties, _ string, _ float32) (*SubnetCapacity, error) {
+	f.recordFakeCall("GetSubnetCapacity")
+	if subnetInfo.VnetName == errorLocation {
+		return nil, status.Errorf(codes.InvalidArgument, "error occurred calling API: %s", errorLocation)
+	}
+	if subnetInfo.VnetName == fullVnetName {
+		return &SubnetCapacity{RequiredIPs: 16, AvailableIPs: 8}, nil
+	}
+	return &SubnetCapacity{RequiredIPs: 16, AvailableIPs: 200}, nil
+}
+
+func (f *FakeDynamicProvisioner) GetSkuValuesForLocation(_ context.Context, _, location string) (map[string]*LustreSkuValue, error) {
 	f.recordFakeCall("GetSkuValuesForLocation")
 	if location == errorLocation {
 		return nil, status.Errorf(codes.InvalidArgument, "error occurred calling API: %s", errorLocation)
@@ -203,8 +255,12 @@ func TestNewDriverInvalidConfigFileLocation(t *testing.T) {
 	}
 	d := NewDriver(&driverOptions)
 	assert.NotNil(t, d)
-	assert.Equal(t, &azure.Cloud{}, d.cloud)
-	assert.Equal(t, &DynamicProvisioner{}, d.dynamicProvisioner)
+	assert.Equal(t, newMockAzureClients().cloud, d.cloud)
+	assert.Equal(t, mockLocation, d.location)
+	assert.Equal(t, mockResourceGroup, d.resourceGroup)
+	assert.Equal(t, fmt.Sprintf(subnetTemplate, mockSubscriptionID, mockVnetResourceGroup, mockVnetName, mockSubnetName),
+		d.populateSubnetPropertiesFromCloudConfig(SubnetProperties{}).SubnetID)
+	assert.IsType(t, &mockDynamicProvisioner{}, d.dynamicProvisioner)
 }
 
 func TestNewDriverInvalidConfigFileContents(t *testing.T) {
@@ -233,8 +289,8 @@ func TestNewDriverInvalidConfigFileContents(t *testing.T) {
 	}
 	d := NewDriver(&driverOptions)
 	assert.NotNil(t, d)
-	assert.Equal(t, &azure.Cloud{}, d.cloud)
-	assert.Equal(t, &DynamicProvisioner{}, d.dynamicProvisioner)
+	assert.Equal(t, newMockAzureClients().cloud, d.cloud)
+	assert.IsType(t, &mockDynamicProvisioner{}, d.dynamicProvisioner)
 }
 
 func TestIsCorruptedDir(t *testing.T) {
@@ -313,6 +369,19 @@ func TestGetLustreVolFromID(t *testing.T) {
 				resourceGroupName:            "testAmlfsRg",
 			},
 		},
+		{
+			desc:     "correct volume id with subscription",
+			volumeID: "vol_1#lustrefs#1.1.1.1##t#testAmlfsRg#12345678-1234-1234-1234-123456789abc",
+			expectedLustreVolume: &lustreVolume{
+				id:                           "vol_1#lustrefs#1.1.1.1##t#testAmlfsRg#12345678-1234-1234-1234-123456789abc",
+				name:                         "vol_1",
+				azureLustreName:              "lustrefs",
+				mgsIPAddress:                 "1.1.1.1",
+				createdByDynamicProvisioning: true,
+				resourceGroupName:            "testAmlfsRg",
+				subscriptionID:               "12345678-1234-1234-1234-123456789abc",
+			},
+		},
 		{
 			desc:     "correct volume id with extra slashes",
 			volumeID: "vol_1#lustrefs/#1.1.1.1#/testSubDir/",
@@ -729,3 +798,26 @@ func TestRemoveNotReadyTaintIfNeeded(t *testing.T) {
 		})
 	}
 }
+
+func TestGetLNetNetworks(t *testing.T) {
+	d := NewFakeDriver()
+	networks, err := d.getLNetNetworks()
+	require.NoError(t, err)
+	assert.Nil(t, networks)
+
+	d.lnetNetworks = "tcp(eth0,eth1)"
+	networks, err = d.getLNetNetworks()
+	require.NoError(t, err)
+	assert.Equal(t, []lnet.Network{{Name: "tcp", Interfaces: []string{"eth0", "eth1"}}}, networks)
+
+	d.lnetNetworks = "tcp(eth0"
+	_, err = d.getLNetNetworks()
+	require.ErrorContains(t, err, "missing closing parenthesis")
+
+	configFile := filepath.Join(t.TempDir(), "lnet.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte("networks:\n- name: tcp1\n  interfaces: [eth2]\n"), 0o600))
+	d.lnetConfigFile = configFile
+	networks, err = d.getLNetNetworks()
+	require.NoError(t, err)
+	assert.Equal(t, []lnet.Network{{Name: "tcp1", Interfaces: []string{"eth2"}}}, networks)
+}
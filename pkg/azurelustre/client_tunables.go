@@ -0,0 +1,196 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/azurelustre-csi-driver/pkg/util"
+)
+
+const (
+	clientTunablesDelimiter         = ","
+	clientTunableKeyValueDelimiter  = "="
+	clientTunableSubsystemDelimiter = "."
+	clientTunableSubsystemLlite     = "llite"
+	clientTunableSubsystemOsc       = "osc"
+)
+
+// clientTunableRange holds the inclusive bounds accepted for an allowlisted
+// Lustre client parameter
+type clientTunableRange struct {
+	minValue int64
+	maxValue int64
+}
+
+// allowedClientTunables lists the llite and osc parameters users may set per
+// volume through the client-tunables volume context parameter
+var allowedClientTunables = map[string]map[string]clientTunableRange{
+	clientTunableSubsystemLlite: {
+		"max_read_ahead_mb":          {minValue: 0, maxValue: math.MaxInt32},
+		"max_read_ahead_per_file_mb": {minValue: 0, maxValue: math.MaxInt32},
+		"max_read_ahead_whole_mb":    {minValue: 0, maxValue: math.MaxInt32},
+		"max_cached_mb":              {minValue: 0, maxValue: math.MaxInt32},
+		"statahead_max":              {minValue: 0, maxValue: 8192},
+		"statahead_agl":              {minValue: 0, maxValue: 1},
+		"checksums":                  {minValue: 0, maxValue: 1},
+	},
+	clientTunableSubsystemOsc: {
+		"max_dirty_mb":       {minValue: 0, maxValue: 2047},
+		"max_rpcs_in_flight": {minValue: 1, maxValue: 256},
+		"max_pages_per_rpc":  {minValue: 1, maxValue: 4096},
+		"checksums":          {minValue: 0, maxValue: 1},
+	},
+}
+
+// clientTunable is a single lctl parameter to set on the llite or osc devices
+// belonging to one Lustre mount
+type clientTunable struct {
+	subsystem string
+	name      string
+	value     string
+}
+
+// parseClientTunables reads the client-tunables volume context parameter, in
+// the form "llite.max_read_ahead_mb=1024,osc.max_dirty_mb=512", and validates
+// every entry against allowedClientTunables
+func parseClientTunables(context map[string]string) ([]clientTunable, error) {
+	rawTunables := strings.TrimSpace(util.GetValueInMap(context, VolumeContextClientTunables))
+	if rawTunables == "" {
+		return nil, nil
+	}
+
+	tunablesByKey := make(map[string]clientTunable)
+	for _, rawTunable := range strings.Split(rawTunables, clientTunablesDelimiter) {
+		kv := strings.Split(rawTunable, clientTunableKeyValueDelimiter)
+		if len(kv) != 2 {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"Context %s '%s' is invalid, the format should be: 'llite.param1=value1,osc.param2=value2'",
+				VolumeContextClientTunables, rawTunables)
+		}
+		key := strings.ToLower(strings.TrimSpace(kv[0]))
+		value := strings.TrimSpace(kv[1])
+
+		subsystem, name, found := strings.Cut(key, clientTunableSubsystemDelimiter)
+		if !found {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"Context %s key %q must be prefixed with %q or %q",
+				VolumeContextClientTunables, key, clientTunableSubsystemLlite, clientTunableSubsystemOsc)
+		}
+		allowedParams, ok := allowedClientTunables[subsystem]
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"Context %s key %q must be prefixed with %q or %q",
+				VolumeContextClientTunables, key, clientTunableSubsystemLlite, clientTunableSubsystemOsc)
+		}
+		valueRange, ok := allowedParams[name]
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"Context %s key %q is not allowed, allowed %s parameters: %v",
+				VolumeContextClientTunables, key, subsystem, slices.Sorted(maps.Keys(allowedParams)))
+		}
+		parsedValue, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsedValue < valueRange.minValue || parsedValue > valueRange.maxValue {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"Context %s value %q for key %q must be an integer between %d and %d",
+				VolumeContextClientTunables, value, key, valueRange.minValue, valueRange.maxValue)
+		}
+		if _, exists := tunablesByKey[key]; exists {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"Context %s key %q must not be provided more than once",
+				VolumeContextClientTunables, key)
+		}
+
+		tunablesByKey[key] = clientTunable{
+			subsystem: subsystem,
+			name:      name,
+			value:     strconv.FormatInt(parsedValue, 10),
+		}
+	}
+
+	tunables := make([]clientTunable, 0, len(tunablesByKey))
+	for _, key := range slices.Sorted(maps.Keys(tunablesByKey)) {
+		tunables = append(tunables, tunablesByKey[key])
+	}
+	return tunables, nil
+}
+
+// getLustreInstance returns the file system name and the llite instance ID
+// for the superblock mounted at target, e.g. "lustrefs" and
+// "ffff8d5c9b3f5000" for the "lustrefs-ffff8d5c9b3f5000" llite device
+func (d *Driver) getLustreInstance(target string) (string, string, error) {
+	output, err := d.mounter.Exec.Command("lfs", "getname", target).CombinedOutput()
+	if err != nil {
+		return "", "", fmt.Errorf("lfs getname %s failed: %w, output: %q", target, err, string(output))
+	}
+
+	fields := strings.Fields(string(output))
+	if len(fields) == 0 {
+		return "", "", fmt.Errorf("lfs getname %s returned no llite instance", target)
+	}
+	separatorIndex := strings.LastIndex(fields[0], "-")
+	if separatorIndex <= 0 || separatorIndex == len(fields[0])-1 {
+		return "", "", fmt.Errorf("could not parse llite instance %q for %s", fields[0], target)
+	}
+
+	return fields[0][:separatorIndex], fields[0][separatorIndex+1:], nil
+}
+
+// getClientTunableParam returns the lctl parameter path for tunable limited
+// to the devices of a single mounted superblock
+func getClientTunableParam(fsName, instance string, tunable clientTunable) string {
+	var device string
+	switch tunable.subsystem {
+	case clientTunableSubsystemOsc:
+		device = fmt.Sprintf("%s-OST*-osc-%s", fsName, instance)
+	default:
+		device = fmt.Sprintf("%s-%s", fsName, instance)
+	}
+	return fmt.Sprintf("%s.%s.%s=%s", tunable.subsystem, device, tunable.name, tunable.value)
+}
+
+// applyClientTunables sets each tunable on the llite and osc devices that
+// belong to the Lustre mount at target, leaving other mounts untouched
+func (d *Driver) applyClientTunables(target string, tunables []clientTunable) error {
+	if len(tunables) == 0 {
+		return nil
+	}
+
+	fsName, instance, err := d.getLustreInstance(target)
+	if err != nil {
+		return err
+	}
+
+	for _, tunable := range tunables {
+		param := getClientTunableParam(fsName, instance, tunable)
+		klog.V(2).Infof("setting Lustre client parameter %s for mount %s", param, target)
+		output, err := d.mounter.Exec.Command("lctl", "set_param", param).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("lctl set_param %s failed: %w, output: %q", param, err, string(output))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,238 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	mount "k8s.io/mount-utils"
+	utilexec "k8s.io/utils/exec"
+	testingexec "k8s.io/utils/exec/testing"
+)
+
+type fakeCommandResult struct {
+	output string
+	err    error
+}
+
+// newScriptedFakeExec returns a FakeExec that answers each command with the
+// matching result, in order, and records the command lines it was given
+func newScriptedFakeExec(results []fakeCommandResult, commandLog *[][]string) *testingexec.FakeExec {
+	fakeExec := &testingexec.FakeExec{ExactOrder: true}
+	for _, result := range results {
+		fakeExec.CommandScript = append(fakeExec.CommandScript, func(cmd string, args ...string) utilexec.Cmd {
+			*commandLog = append(*commandLog, append([]string{cmd}, args...))
+			fakeCmd := &testingexec.FakeCmd{
+				CombinedOutputScript: []testingexec.FakeAction{
+					func() ([]byte, []byte, error) {
+						return []byte(result.output), nil, result.err
+					},
+				},
+			}
+			return testingexec.InitFakeCmd(fakeCmd, cmd, args...)
+		})
+	}
+	return fakeExec
+}
+
+func TestParseClientTunables(t *testing.T) {
+	tests := []struct {
+		desc             string
+		context          map[string]string
+		expectedTunables []clientTunable
+		expectedErrCode  codes.Code
+		expectedErrText  string
+	}{
+		{
+			desc:             "no tunables",
+			context:          map[string]string{"mgs-ip-address": "1.1.1.1"},
+			expectedTunables: nil,
+		},
+		{
+			desc:             "empty tunables",
+			context:          map[string]string{"client-tunables": " "},
+			expectedTunables: nil,
+		},
+		{
+			desc: "valid tunables are sorted",
+			context: map[string]string{
+				"Client-Tunables": "osc.max_dirty_mb=512, llite.max_read_ahead_mb=1024,llite.checksums=0,LLITE.statahead_max=64",
+			},
+			expectedTunables: []clientTunable{
+				{subsystem: "llite", name: "checksums", value: "0"},
+				{subsystem: "llite", name: "max_read_ahead_mb", value: "1024"},
+				{subsystem: "llite", name: "statahead_max", value: "64"},
+				{subsystem: "osc", name: "max_dirty_mb", value: "512"},
+			},
+		},
+		{
+			desc:            "missing value",
+			context:         map[string]string{"client-tunables": "llite.max_read_ahead_mb"},
+			expectedErrCode: codes.InvalidArgument,
+			expectedErrText: "the format should be",
+		},
+		{
+			desc:            "missing subsystem",
+			context:         map[string]string{"client-tunables": "max_read_ahead_mb=1"},
+			expectedErrCode: codes.InvalidArgument,
+			expectedErrText: "must be prefixed with",
+		},
+		{
+			desc:            "unknown subsystem",
+			context:         map[string]string{"client-tunables": "mdc.max_rpcs_in_flight=8"},
+			expectedErrCode: codes.InvalidArgument,
+			expectedErrText: "must be prefixed with",
+		},
+		{
+			desc:            "disallowed parameter",
+			context:         map[string]string{"client-tunables": "llite.xattr_cache=0"},
+			expectedErrCode: codes.InvalidArgument,
+			expectedErrText: "is not allowed",
+		},
+		{
+			desc:            "parameter path injection",
+			context:         map[string]string{"client-tunables": "llite.*.max_read_ahead_mb=1"},
+			expectedErrCode: codes.InvalidArgument,
+			expectedErrText: "is not allowed",
+		},
+		{
+			desc:            "non-integer value",
+			context:         map[string]string{"client-tunables": "osc.max_dirty_mb=lots"},
+			expectedErrCode: codes.InvalidArgument,
+			expectedErrText: "must be an integer between 0 and 2047",
+		},
+		{
+			desc:            "value out of range",
+			context:         map[string]string{"client-tunables": "osc.checksums=2"},
+			expectedErrCode: codes.InvalidArgument,
+			expectedErrText: "must be an integer between 0 and 1",
+		},
+		{
+			desc:            "duplicate key",
+			context:         map[string]string{"client-tunables": "osc.checksums=1,OSC.checksums=0"},
+			expectedErrCode: codes.InvalidArgument,
+			expectedErrText: "must not be provided more than once",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			tunables, err := parseClientTunables(test.context)
+			if test.expectedErrText != "" {
+				require.Error(t, err)
+				assert.Equal(t, test.expectedErrCode, status.Code(err))
+				require.ErrorContains(t, err, test.expectedErrText)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedTunables, tunables)
+		})
+	}
+}
+
+func TestApplyClientTunables(t *testing.T) {
+	tunables := []clientTunable{
+		{subsystem: "llite", name: "max_read_ahead_mb", value: "1024"},
+		{subsystem: "osc", name: "max_dirty_mb", value: "512"},
+	}
+
+	tests := []struct {
+		desc             string
+		tunables         []clientTunable
+		results          []fakeCommandResult
+		expectedCommands [][]string
+		expectedErr      string
+	}{
+		{
+			desc:     "no tunables does not run commands",
+			tunables: nil,
+		},
+		{
+			desc:     "applies tunables to the mount superblock only",
+			tunables: tunables,
+			results: []fakeCommandResult{
+				{output: "lustrefs-ffff8d5c9b3f5000 /target\n"},
+				{},
+				{},
+			},
+			expectedCommands: [][]string{
+				{"lfs", "getname", "/target"},
+				{"lctl", "set_param", "llite.lustrefs-ffff8d5c9b3f5000.max_read_ahead_mb=1024"},
+				{"lctl", "set_param", "osc.lustrefs-OST*-osc-ffff8d5c9b3f5000.max_dirty_mb=512"},
+			},
+		},
+		{
+			desc:     "lfs getname failure",
+			tunables: tunables,
+			results: []fakeCommandResult{
+				{output: "not a lustre mount", err: errors.New("exit status 1")},
+			},
+			expectedCommands: [][]string{
+				{"lfs", "getname", "/target"},
+			},
+			expectedErr: "lfs getname /target failed",
+		},
+		{
+			desc:     "unparsable instance",
+			tunables: tunables,
+			results: []fakeCommandResult{
+				{output: "lustrefs /target\n"},
+			},
+			expectedCommands: [][]string{
+				{"lfs", "getname", "/target"},
+			},
+			expectedErr: "could not parse llite instance",
+		},
+		{
+			desc:     "lctl set_param failure stops further tunables",
+			tunables: tunables,
+			results: []fakeCommandResult{
+				{output: "lustrefs-ffff8d5c9b3f5000 /target\n"},
+				{output: "permission denied", err: errors.New("exit status 1")},
+			},
+			expectedCommands: [][]string{
+				{"lfs", "getname", "/target"},
+				{"lctl", "set_param", "llite.lustrefs-ffff8d5c9b3f5000.max_read_ahead_mb=1024"},
+			},
+			expectedErr: "lctl set_param llite.lustrefs-ffff8d5c9b3f5000.max_read_ahead_mb=1024 failed",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var commandLog [][]string
+			d := NewFakeDriver()
+			d.mounter = &mount.SafeFormatAndMount{
+				Interface: &fakeMounter{},
+				Exec:      newScriptedFakeExec(test.results, &commandLog),
+			}
+
+			err := d.applyClientTunables("/target", test.tunables)
+			if test.expectedErr != "" {
+				require.ErrorContains(t, err, test.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, test.expectedCommands, commandLog)
+		})
+	}
+}
@@ -0,0 +1,299 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v6"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storagecache/armstoragecache/v4"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/cloud-provider-azure/pkg/azclient/configloader"
+	azure "sigs.k8s.io/cloud-provider-azure/pkg/provider"
+	azureconfig "sigs.k8s.io/cloud-provider-azure/pkg/provider/config"
+)
+
+// azureClients are the Azure clients of the driver and the defaults of the
+// cloud config they were built from, replaced together when the cloud config
+// changes
+type azureClients struct {
+	cloud              *azure.Cloud
+	cloudConfig        cloud.Configuration
+	resourceGroup      string
+	location           string
+	dynamicProvisioner DynamicProvisionerInterface
+	permissionVerifier *permissionVerifier
+}
+
+// azureClientsKey is the context key of the Azure clients a request started
+// with
+type azureClientsKey struct{}
+
+// currentAzureClients returns the Azure clients built from the latest cloud
+// config
+func (d *Driver) currentAzureClients() *azureClients {
+	d.azureClientsLock.RLock()
+	defer d.azureClientsLock.RUnlock()
+	return &azureClients{
+		cloud:              d.cloud,
+		cloudConfig:        d.cloudConfig,
+		resourceGroup:      d.resourceGroup,
+		location:           d.location,
+		dynamicProvisioner: d.dynamicProvisioner,
+		permissionVerifier: d.permissionVerifier,
+	}
+}
+
+// setAzureClients replaces the Azure clients of the driver at once
+func (d *Driver) setAzureClients(clients *azureClients) {
+	d.azureClientsLock.Lock()
+	defer d.azureClientsLock.Unlock()
+	d.cloud = clients.cloud
+	d.cloudConfig = clients.cloudConfig
+	d.resourceGroup = clients.resourceGroup
+	d.location = clients.location
+	d.dynamicProvisioner = clients.dynamicProvisioner
+	d.permissionVerifier = clients.permissionVerifier
+}
+
+// withAzureClients returns a context whose request keeps the current Azure
+// clients until it finishes, even when the cloud config is reloaded meanwhile
+func (d *Driver) withAzureClients(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(azureClientsKey{}).(*azureClients); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, azureClientsKey{}, d.currentAzureClients())
+}
+
+// azureClientsFor returns the Azure clients the request of the context
+// started with, the current ones when it has none
+func (d *Driver) azureClientsFor(ctx context.Context) *azureClients {
+	if clients, ok := ctx.Value(azureClientsKey{}).(*azureClients); ok {
+		return clients
+	}
+	return d.currentAzureClients()
+}
+
+// populateSubnetProperties fills in the subnet properties left out from the
+// cloud config, with the subnet ID in the vnet subscription given or that of
+// the cloud config when empty
+func (c *azureClients) populateSubnetProperties(subnetInfo SubnetProperties, vnetSubscriptionID string) SubnetProperties {
+	subnetProperties := subnetInfo
+	subsID := vnetSubscriptionID
+	if len(subsID) == 0 {
+		subsID = c.cloud.SubscriptionID
+		if len(c.cloud.NetworkResourceSubscriptionID) > 0 {
+			subsID = c.cloud.NetworkResourceSubscriptionID
+		}
+	}
+
+	if len(subnetInfo.VnetResourceGroup) == 0 {
+		subnetProperties.VnetResourceGroup = c.cloud.ResourceGroup
+		if len(c.cloud.VnetResourceGroup) > 0 {
+			subnetProperties.VnetResourceGroup = c.cloud.VnetResourceGroup
+		}
+	}
+
+	if len(subnetInfo.VnetName) == 0 {
+		subnetProperties.VnetName = c.cloud.VnetName
+	}
+
+	if len(subnetInfo.SubnetName) == 0 {
+		subnetProperties.SubnetName = c.cloud.SubnetName
+	}
+	subnetID := fmt.Sprintf(subnetTemplate, subsID, subnetProperties.VnetResourceGroup, subnetProperties.VnetName, subnetProperties.SubnetName)
+
+	subnetProperties.SubnetID = subnetID
+
+	return subnetProperties
+}
+
+// cloudConfigDigest returns the digest of the cloud config file, which
+// tells whether it changed since it was loaded
+func cloudConfigDigest(configFile string) (string, error) {
+	content, err := os.ReadFile(configFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read cloud config file %s: %w", configFile, err)
+	}
+	digest := sha256.Sum256(content)
+	return hex.EncodeToString(digest[:]), nil
+}
+
+// loadCloudConfig reads the cloud config file and returns it with its digest
+func loadCloudConfig(ctx context.Context, configFile string) (*azureconfig.Config, string, error) {
+	digest, err := cloudConfigDigest(configFile)
+	if err != nil {
+		return nil, "", err
+	}
+	config, err := configloader.Load[azureconfig.Config](ctx, nil, &configloader.FileLoaderConfig{
+		FilePath: configFile,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get cloud config from file %s: %w", configFile, err)
+	}
+	return config, digest, nil
+}
+
+// newDriverCredential returns the credential of the driver: the service
+// principal of the cloud config when it has a client secret, so rotating the
+// secret only takes a reload, the managed identity of the client ID when it
+// has one, or the default Azure credential otherwise
+func newDriverCredential(config *azureconfig.Config, clientOptions *arm.ClientOptions) (azcore.TokenCredential, error) {
+	if config.AADClientSecret != "" && !config.UseManagedIdentityExtension && !config.UseFederatedWorkloadIdentityExtension {
+		return azidentity.NewClientSecretCredential(config.TenantID, config.AADClientID, config.AADClientSecret,
+			&azidentity.ClientSecretCredentialOptions{ClientOptions: clientOptions.ClientOptions})
+	}
+	if config.UseManagedIdentityExtension && config.UserAssignedIdentityID != "" {
+		return azidentity.NewManagedIdentityCredential(&azidentity.ManagedIdentityCredentialOptions{
+			ClientOptions: clientOptions.ClientOptions,
+			ID:            azidentity.ClientID(config.AADClientID),
+		})
+	}
+	return azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{
+		ClientOptions: clientOptions.ClientOptions,
+	})
+}
+
+// newAzureClients builds the credential and the Azure clients of the cloud
+// config
+func (d *Driver) newAzureClients(ctx context.Context, config *azureconfig.Config) (*azureClients, error) {
+	config.UserAgent = GetUserAgent(d.Name, "", "")
+	if d.azureClientID != "" {
+		config.AADClientID = d.azureClientID
+	} else if config.UseManagedIdentityExtension && config.UserAssignedIdentityID != "" {
+		config.AADClientID = config.UserAssignedIdentityID
+	}
+	az := &azure.Cloud{}
+	if err := az.InitializeCloudFromConfig(ctx, config, false, false); err != nil {
+		klog.Warningf("InitializeCloudFromConfig failed with error: %v", err)
+	}
+	clients := &azureClients{
+		cloud:         az,
+		resourceGroup: config.ResourceGroup,
+		location:      config.Location,
+	}
+
+	cloudConfig, err := azureCloudConfiguration(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure the ARM clients: %w", err)
+	}
+	clients.cloudConfig = cloudConfig
+	clientOptions := armClientOptions(cloudConfig, d.armThrottle)
+	cred, err := newDriverCredential(config, clientOptions)
+	if err != nil {
+		klog.Warningf("failed to obtain a credential: %v", err)
+	}
+	storageClientFactory, err := armstoragecache.NewClientFactory(config.SubscriptionID, cred, clientOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage client factory: %w", err)
+	}
+	subsID := az.SubscriptionID
+	if len(az.NetworkResourceSubscriptionID) > 0 {
+		subsID = az.NetworkResourceSubscriptionID
+	}
+	networkClientFactory, err := armnetwork.NewClientFactory(subsID, cred, clientOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create network client factory: %w", err)
+	}
+	dynamicProvisioner := &DynamicProvisioner{
+		amlFilesystemsClient: storageClientFactory.NewAmlFilesystemsClient(),
+		mgmtClient:           storageClientFactory.NewManagementClient(),
+		vnetClient:           networkClientFactory.NewVirtualNetworksClient(),
+		skusClient:           storageClientFactory.NewSKUsClient(),
+		usagesClient:         storageClientFactory.NewAscUsagesClient(),
+		networkPreflight:     d.networkPreflight,
+		subnetsClient:        networkClientFactory.NewSubnetsClient(),
+		securityGroupsClient: networkClientFactory.NewSecurityGroupsClient(),
+		routeTablesClient:    networkClientFactory.NewRouteTablesClient(),
+		subscriptionID:       config.SubscriptionID,
+		vnetSubscriptionID:   subsID,
+		clientFactories:      newARMClientFactories(cred, clientOptions),
+	}
+	if az.SubnetName != "" {
+		dynamicProvisioner.clientSubnet = clients.populateSubnetProperties(SubnetProperties{}, "")
+	}
+	if d.kubeClient != nil && d.leaderElectionNamespace != "" {
+		dynamicProvisioner.subnetReservations = &configMapSubnetReservationStore{
+			kubeClient: d.kubeClient,
+			namespace:  d.leaderElectionNamespace,
+		}
+	}
+	clients.dynamicProvisioner = dynamicProvisioner
+	if d.permissionSelfTest && d.NodeID == "" {
+		clients.permissionVerifier = newPermissionVerifier(config.SubscriptionID, subsID, cred, clientOptions, storageClientFactory, networkClientFactory)
+	}
+	return clients, nil
+}
+
+// runCloudConfigReloader checks the cloud config file for changes every
+// cloudConfigReloadInterval and replaces the Azure clients with those of the
+// changed config. Requests keep the clients they started with.
+func (d *Driver) runCloudConfigReloader(ctx context.Context) {
+	klog.Infof("checking cloud config file %s for changes every %v", d.cloudConfigFile, d.cloudConfigReloadInterval)
+	go wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := d.reloadCloudConfig(ctx); err != nil {
+			klog.Errorf("failed to reload the cloud config, keeping the previous Azure clients: %v", err)
+		}
+	}, d.cloudConfigReloadInterval)
+}
+
+// reloadCloudConfig rebuilds the Azure clients when the content of the cloud
+// config file changed since it was last loaded
+func (d *Driver) reloadCloudConfig(ctx context.Context) error {
+	digest, err := cloudConfigDigest(d.cloudConfigFile)
+	if err != nil {
+		return err
+	}
+	if digest == d.cloudConfigDigest {
+		return nil
+	}
+
+	config, digest, err := loadCloudConfig(ctx, d.cloudConfigFile)
+	if err != nil {
+		return err
+	}
+	clients, err := d.newAzureClients(ctx, config)
+	if err != nil {
+		return err
+	}
+	previous := d.currentAzureClients()
+	// The IP addresses reserved by the creations still running on the
+	// previous clients stay reserved, and the caches and the subnet lock stay
+	// shared with them
+	if previousProvisioner, ok := previous.dynamicProvisioner.(*DynamicProvisioner); ok {
+		if dynamicProvisioner, ok := clients.dynamicProvisioner.(*DynamicProvisioner); ok {
+			dynamicProvisioner.subnetReservations = previousProvisioner.getSubnetReservationStore()
+			dynamicProvisioner.caches = previousProvisioner.getCaches()
+		}
+	}
+	d.setAzureClients(clients)
+	d.cloudConfigDigest = digest
+	klog.Infof("reloaded cloud config from %s, resource group %s, location %s", d.cloudConfigFile, clients.resourceGroup, clients.location)
+
+	// The identity may have changed along with its permissions
+	d.verifyPermissions(ctx)
+	return nil
+}
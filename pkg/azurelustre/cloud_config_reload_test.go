@@ -0,0 +1,161 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	azureconfig "sigs.k8s.io/cloud-provider-azure/pkg/provider/config"
+)
+
+func writeCloudConfig(t *testing.T, configFile, resourceGroup, clientSecret string) {
+	t.Helper()
+	content := fmt.Sprintf(`{
+    "tenantId": "fake-tenant-id",
+    "subscriptionId": "fake-subscription-id",
+    "aadClientId": "fake-client-id",
+    "aadClientSecret": %q,
+    "resourceGroup": %q,
+    "location": "fake-location",
+    "vnetName": "fake-vnet",
+    "subnetName": "fake-subnet"
+}`, clientSecret, resourceGroup)
+	require.NoError(t, os.WriteFile(configFile, []byte(content), 0o600))
+}
+
+func TestReloadCloudConfig(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "azure.json")
+	writeCloudConfig(t, configFile, "fake-resource-group", "fake-client-secret")
+	t.Setenv(DefaultAzureConfigFileEnv, configFile)
+
+	d := NewDriver(&DriverOptions{
+		NodeID:                       fakeNodeID,
+		DriverName:                   fakeDriverName,
+		EnableAzureLustreMockDynProv: true,
+		WorkingMountDir:              "/tmp",
+	})
+	require.NotNil(t, d)
+	assert.Equal(t, configFile, d.cloudConfigFile)
+	assert.NotEmpty(t, d.cloudConfigDigest)
+	previous := d.currentAzureClients()
+	previousProvisioner, ok := previous.dynamicProvisioner.(*DynamicProvisioner)
+	require.True(t, ok)
+	assert.Equal(t, "fake-subnet", previousProvisioner.clientSubnet.SubnetName)
+	// A request started before the reload
+	ctx := d.withAzureClients(context.Background())
+
+	// An unchanged file keeps the clients
+	require.NoError(t, d.reloadCloudConfig(context.Background()))
+	assert.Same(t, previous.dynamicProvisioner, d.currentAzureClients().dynamicProvisioner)
+
+	writeCloudConfig(t, configFile, "rotated-resource-group", "rotated-client-secret")
+	require.NoError(t, d.reloadCloudConfig(context.Background()))
+	current := d.currentAzureClients()
+	assert.Equal(t, "rotated-resource-group", current.resourceGroup)
+	assert.Equal(t, "rotated-client-secret", current.cloud.AADClientSecret)
+	assert.NotSame(t, previous.dynamicProvisioner, current.dynamicProvisioner)
+	currentProvisioner, ok := current.dynamicProvisioner.(*DynamicProvisioner)
+	require.True(t, ok)
+	assert.Same(t, previousProvisioner.getSubnetReservationStore(), currentProvisioner.subnetReservations)
+	assert.Same(t, previousProvisioner.getCaches(), currentProvisioner.caches)
+
+	// The request keeps the clients it started with until it finishes
+	assert.Same(t, previous.dynamicProvisioner, d.azureClientsFor(ctx).dynamicProvisioner)
+	assert.Equal(t, "fake-resource-group", d.azureClientsFor(ctx).resourceGroup)
+	assert.Same(t, d.azureClientsFor(ctx), d.azureClientsFor(d.withAzureClients(ctx)))
+
+	// A broken file keeps the clients until it is fixed
+	digest := d.cloudConfigDigest
+	require.NoError(t, os.WriteFile(configFile, []byte(`;;;....invalid########`), 0o600))
+	require.ErrorContains(t, d.reloadCloudConfig(context.Background()), "failed to get cloud config from file "+configFile)
+	assert.Same(t, current.dynamicProvisioner, d.currentAzureClients().dynamicProvisioner)
+	assert.Equal(t, digest, d.cloudConfigDigest)
+	require.NoError(t, os.Remove(configFile))
+	require.ErrorContains(t, d.reloadCloudConfig(context.Background()), "failed to read cloud config file "+configFile)
+	assert.Same(t, current.dynamicProvisioner, d.currentAzureClients().dynamicProvisioner)
+}
+
+func TestReloadCloudConfig_ManagedIdentity(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "azure.json")
+	writeManagedIdentityConfig := func(identityID string) {
+		content := fmt.Sprintf(`{
+    "tenantId": "fake-tenant-id",
+    "subscriptionId": "fake-subscription-id",
+    "useManagedIdentityExtension": true,
+    "userAssignedIdentityID": %q,
+    "resourceGroup": "fake-resource-group",
+    "location": "fake-location"
+}`, identityID)
+		require.NoError(t, os.WriteFile(configFile, []byte(content), 0o600))
+	}
+	writeManagedIdentityConfig("fake-identity-id")
+	t.Setenv(DefaultAzureConfigFileEnv, configFile)
+	t.Setenv("AZURE_CLIENT_ID", "")
+
+	d := NewDriver(&DriverOptions{
+		NodeID:                       fakeNodeID,
+		DriverName:                   fakeDriverName,
+		EnableAzureLustreMockDynProv: true,
+		WorkingMountDir:              "/tmp",
+	})
+	require.NotNil(t, d)
+	assert.Equal(t, "fake-identity-id", d.currentAzureClients().cloud.AADClientID)
+
+	// A rotated identity is used after the reload, the environment is left
+	// alone
+	writeManagedIdentityConfig("rotated-identity-id")
+	require.NoError(t, d.reloadCloudConfig(context.Background()))
+	assert.Equal(t, "rotated-identity-id", d.currentAzureClients().cloud.AADClientID)
+	assert.Empty(t, os.Getenv("AZURE_CLIENT_ID"))
+
+	// The client ID of the environment the driver started with wins
+	d.azureClientID = "fake-env-client-id"
+	writeManagedIdentityConfig("other-identity-id")
+	require.NoError(t, d.reloadCloudConfig(context.Background()))
+	assert.Equal(t, "fake-env-client-id", d.currentAzureClients().cloud.AADClientID)
+}
+
+func TestNewDriverCredential(t *testing.T) {
+	servicePrincipal := &azureconfig.Config{}
+	servicePrincipal.TenantID = "fake-tenant-id"
+	servicePrincipal.AADClientID = "fake-client-id"
+	servicePrincipal.AADClientSecret = "fake-client-secret"
+	managedIdentity := &azureconfig.Config{}
+	managedIdentity.AADClientSecret = "fake-client-secret"
+	managedIdentity.UseManagedIdentityExtension = true
+
+	credential, err := newDriverCredential(servicePrincipal, armClientOptions(cloud.AzurePublic, nil))
+	require.NoError(t, err)
+	assert.IsType(t, &azidentity.ClientSecretCredential{}, credential)
+	credential, err = newDriverCredential(managedIdentity, armClientOptions(cloud.AzurePublic, nil))
+	require.NoError(t, err)
+	assert.IsType(t, &azidentity.DefaultAzureCredential{}, credential)
+
+	managedIdentity.UserAssignedIdentityID = "fake-identity-id"
+	managedIdentity.AADClientID = "fake-identity-id"
+	credential, err = newDriverCredential(managedIdentity, armClientOptions(cloud.AzurePublic, nil))
+	require.NoError(t, err)
+	assert.IsType(t, &azidentity.ManagedIdentityCredential{}, credential)
+}
@@ -23,6 +23,7 @@ import (
 	"regexp"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storagecache/armstoragecache/v4"
 	"github.com/container-storage-interface/spec/lib/go/csi"
@@ -39,9 +40,13 @@ const (
 	VolumeContextSubDir                     = "sub-dir"
 	VolumeContextLocation                   = "location"
 	VolumeContextResourceGroupName          = "resource-group-name"
+	VolumeContextSubscriptionID             = "subscription-id"
+	VolumeContextVnetSubscriptionID         = "vnet-subscription-id"
 	VolumeContextVnetResourceGroup          = "vnet-resource-group"
 	VolumeContextVnetName                   = "vnet-name"
 	VolumeContextSubnetName                 = "subnet-name"
+	VolumeContextCandidateSubnets           = "candidate-subnets"
+	VolumeContextSubnetSelection            = "subnet-selection"
 	VolumeContextMaintenanceDayOfWeek       = "maintenance-day-of-week"
 	VolumeContextMaintenanceTimeOfDayUtc    = "maintenance-time-of-day-utc"
 	VolumeContextSkuName                    = "sku-name"
@@ -50,12 +55,15 @@ const (
 	VolumeContextTags                       = "tags"
 	VolumeContextIdentities                 = "identities"
 	VolumeContextInternalDynamicallyCreated = "created-by-dynamic-provisioning"
+	VolumeContextClientTunables             = "client-tunables"
+	VolumeContextTagFromPVCLabels           = "tag-from-pvc-labels"
 	defaultSizeInBytes                      = 4 * util.TiB
 	defaultLaaSOBlockSizeInTib              = 4
 	pvcNamespaceTag                         = "kubernetes.io-created-for-pvc-namespace"
 	pvcNameTag                              = "kubernetes.io-created-for-pvc-name"
 	pvNameTag                               = "kubernetes.io-created-for-pv-name"
 	createdByTag                            = "k8s-azure-created-by"
+	clusterIDTag                            = "k8s-azure-cluster-id"
 	azureLustreDriverTag                    = "kubernetes-azurelustre-csi-driver"
 )
 
@@ -72,6 +80,10 @@ type SubnetProperties struct {
 }
 
 type AmlFilesystemProperties struct {
+	// SubscriptionID is the subscription of the cluster and
+	// VnetSubscriptionID that of its vnet, those of the driver when empty
+	SubscriptionID       string
+	VnetSubscriptionID   string
 	ResourceGroupName    string
 	AmlFilesystemName    string
 	Location             string
@@ -83,6 +95,14 @@ type AmlFilesystemProperties struct {
 	StorageCapacityTiB   float32
 	SKUName              string
 	Zone                 string
+	// progress records the progress of the creation as events, may be nil
+	progress *provisioningProgress
+	// tagFromPVCLabels are the PVC labels and annotations to copy into Tags
+	tagFromPVCLabels []string
+	// candidateSubnets are the subnets SubnetInfo is chosen from when the
+	// cluster is created, following subnetSelection
+	candidateSubnets []SubnetProperties
+	subnetSelection  string
 }
 
 func parseAmlFilesystemProperties(properties map[string]string) (*AmlFilesystemProperties, error) {
@@ -100,6 +120,16 @@ func parseAmlFilesystemProperties(properties map[string]string) (*AmlFilesystemP
 			amlFilesystemProperties.ResourceGroupName = propertyValue
 		case VolumeContextMGSIPAddress:
 			shouldCreateAmlfsCluster = false
+		case VolumeContextSubscriptionID, VolumeContextVnetSubscriptionID:
+			if !subscriptionIDRegex.MatchString(propertyValue) {
+				return nil, status.Errorf(codes.InvalidArgument,
+					"CreateVolume Parameter %s must be a subscription ID, was: '%s'", strings.ToLower(propertyName), propertyValue)
+			}
+			if strings.EqualFold(propertyName, VolumeContextSubscriptionID) {
+				amlFilesystemProperties.SubscriptionID = propertyValue
+			} else {
+				amlFilesystemProperties.VnetSubscriptionID = propertyValue
+			}
 		case VolumeContextLocation:
 			amlFilesystemProperties.Location = propertyValue
 		case VolumeContextVnetName:
@@ -108,6 +138,20 @@ func parseAmlFilesystemProperties(properties map[string]string) (*AmlFilesystemP
 			amlFilesystemProperties.SubnetInfo.VnetResourceGroup = propertyValue
 		case VolumeContextSubnetName:
 			amlFilesystemProperties.SubnetInfo.SubnetName = propertyValue
+		case VolumeContextCandidateSubnets:
+			candidateSubnets, err := parseCandidateSubnets(propertyValue)
+			if err != nil {
+				return nil, err
+			}
+			amlFilesystemProperties.candidateSubnets = candidateSubnets
+		case VolumeContextSubnetSelection:
+			subnetSelection := strings.ToLower(propertyValue)
+			if subnetSelection != subnetSelectionFirst && subnetSelection != subnetSelectionEmptiest {
+				return nil, status.Errorf(codes.InvalidArgument,
+					"CreateVolume Parameter %s must be one of: [%s %s], was: '%s'",
+					VolumeContextSubnetSelection, subnetSelectionFirst, subnetSelectionEmptiest, propertyValue)
+			}
+			amlFilesystemProperties.subnetSelection = subnetSelection
 		case VolumeContextMaintenanceDayOfWeek:
 			possibleDayValues := armstoragecache.PossibleMaintenanceDayOfWeekTypeValues()
 			for _, dayOfWeekValue := range possibleDayValues {
@@ -145,7 +189,7 @@ func parseAmlFilesystemProperties(properties map[string]string) (*AmlFilesystemP
 			}
 			if len(tags) > 0 {
 				for tag, value := range tags {
-					if tag == pvcNameTag || tag == pvcNamespaceTag || tag == pvNameTag || tag == createdByTag {
+					if isReservedTag(tag) {
 						return nil, status.Errorf(codes.InvalidArgument, "CreateVolume Parameter %s must not contain %s as a tag", VolumeContextTags, tag)
 					}
 					amlFilesystemProperties.Tags[tag] = value
@@ -157,11 +201,21 @@ func parseAmlFilesystemProperties(properties map[string]string) (*AmlFilesystemP
 			amlFilesystemProperties.Tags[pvcNamespaceTag] = propertyValue
 		case pvNameKey:
 			amlFilesystemProperties.Tags[pvNameTag] = propertyValue
+		case VolumeContextTagFromPVCLabels:
+			tagFromPVCLabels, err := parseTagFromPVCLabels(propertyValue)
+			if err != nil {
+				return nil, err
+			}
+			amlFilesystemProperties.tagFromPVCLabels = tagFromPVCLabels
 		case VolumeContextIdentities:
 			amlFilesystemProperties.Identities = strings.Split(propertyValue, ",")
 			// These will be used by the node methods
 		case VolumeContextFSName, VolumeContextSubDir:
 			continue
+		case VolumeContextClientTunables:
+			if _, err := parseClientTunables(map[string]string{VolumeContextClientTunables: propertyValue}); err != nil {
+				return nil, err
+			}
 		default:
 			errorParameters = append(
 				errorParameters,
@@ -178,6 +232,15 @@ func parseAmlFilesystemProperties(properties map[string]string) (*AmlFilesystemP
 		)
 	}
 
+	if len(amlFilesystemProperties.candidateSubnets) > 0 {
+		if len(amlFilesystemProperties.SubnetInfo.SubnetName) > 0 {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"CreateVolume Parameters %s and %s cannot be used together",
+				VolumeContextSubnetName, VolumeContextCandidateSubnets)
+		}
+		amlFilesystemProperties.candidateSubnets = setCandidateSubnetDefaults(amlFilesystemProperties.candidateSubnets, amlFilesystemProperties.SubnetInfo)
+	}
+
 	if shouldCreateAmlfsCluster {
 		if len(amlFilesystemProperties.MaintenanceDayOfWeek) == 0 {
 			return nil, status.Errorf(codes.InvalidArgument,
@@ -232,11 +295,14 @@ func (d *Driver) CreateVolume(
 	ctx context.Context,
 	req *csi.CreateVolumeRequest,
 ) (*csi.CreateVolumeResponse, error) {
+	// The whole creation uses the Azure clients it started with
+	ctx = d.withAzureClients(ctx)
+	azureClients := d.azureClientsFor(ctx)
 	mc := metrics.NewMetricContext(
 		azureLustreCSIDriverName,
 		"controller_create_volume",
-		d.resourceGroup,
-		d.cloud.SubscriptionID,
+		azureClients.resourceGroup,
+		azureClients.cloud.SubscriptionID,
 		d.Name,
 	)
 
@@ -251,6 +317,13 @@ func (d *Driver) CreateVolume(
 		return nil, err
 	}
 
+	// The ARM calls are made with the credential of the provisioner secret
+	// if any, that of the driver otherwise
+	ctx, err = d.contextWithProvisionerSecrets(ctx, req.GetSecrets())
+	if err != nil {
+		return nil, err
+	}
+
 	if acquired := d.volumeLocks.TryAcquire(volName); !acquired {
 		return nil, status.Errorf(codes.Aborted,
 			volumeOperationAlreadyExistsFmt,
@@ -300,18 +373,22 @@ func (d *Driver) CreateVolume(
 	if shouldCreateAmlfsCluster {
 		createdByDynamicProvisioningStringValue = "t"
 
-		if len(amlFilesystemProperties.Location) == 0 {
-			amlFilesystemProperties.Location = d.location
-		}
+		d.setAmlFilesystemDefaults(ctx, amlFilesystemProperties)
 
-		if len(amlFilesystemProperties.ResourceGroupName) == 0 {
-			amlFilesystemProperties.ResourceGroupName = d.resourceGroup
+		// Lets garbage collection tell the AMLFS clusters of this Kubernetes
+		// cluster apart from those of others in the subscription
+		if len(d.clusterID) > 0 {
+			amlFilesystemProperties.Tags[clusterIDTag] = d.clusterID
 		}
 
-		amlFilesystemProperties.SubnetInfo = d.populateSubnetPropertiesFromCloudConfig(amlFilesystemProperties.SubnetInfo)
+		amlFilesystemProperties.progress = d.newProvisioningProgress(ctx, parameters)
+
+		if err := d.addTagsFromPVC(ctx, amlFilesystemProperties, parameters); err != nil {
+			return nil, err
+		}
 
 		klog.V(2).Infof("finding capacity based on SKU %s for location %s", amlFilesystemProperties.SKUName, amlFilesystemProperties.Location)
-		lustreSkuValue, err := d.getSkuValuesForLocation(ctx, amlFilesystemProperties.SKUName, amlFilesystemProperties.Location)
+		lustreSkuValue, err := d.getSkuValuesForLocation(ctx, amlFilesystemProperties.SubscriptionID, amlFilesystemProperties.SKUName, amlFilesystemProperties.Location)
 		if err != nil {
 			klog.Errorf("failed to get SKU values for %s in location %s, error: %v", amlFilesystemProperties.SKUName, amlFilesystemProperties.Location, err)
 			return nil, err
@@ -319,6 +396,9 @@ func (d *Driver) CreateVolume(
 		blockSizeInBytes = lustreSkuValue.IncrementInTib * util.TiB
 		maxCapacityInBytes = lustreSkuValue.MaximumInTib * util.TiB
 		availableZones = lustreSkuValue.AvailableZones
+		amlFilesystemProperties.progress.record(eventReasonAmlFilesystemSkuResolved,
+			"Resolved SKU %s in location %s: capacity in increments of %d TiB up to %d TiB",
+			amlFilesystemProperties.SKUName, amlFilesystemProperties.Location, lustreSkuValue.IncrementInTib, lustreSkuValue.MaximumInTib)
 	}
 
 	capacityInBytes, err = d.roundToAmlfsBlockSize(capacityInBytes, blockSizeInBytes, maxCapacityInBytes)
@@ -341,25 +421,8 @@ func (d *Driver) CreateVolume(
 	if shouldCreateAmlfsCluster {
 		amlFilesystemProperties.StorageCapacityTiB = storageCapacityTib
 
-		if len(availableZones) > 0 {
-			klog.V(2).Infof("available zones for SKU %s in location %s: %v", amlFilesystemProperties.SKUName, amlFilesystemProperties.Location, availableZones)
-			if len(amlFilesystemProperties.Zone) == 0 {
-				return nil, status.Errorf(codes.InvalidArgument,
-					"CreateVolume Parameter %s must be provided for dynamically provisioned AMLFS in location %s, available zones: %v",
-					VolumeContextZone, amlFilesystemProperties.Location, availableZones)
-			}
-			if !slices.Contains(availableZones, amlFilesystemProperties.Zone) {
-				return nil, status.Errorf(codes.InvalidArgument,
-					"CreateVolume Parameter %s %s must be one of: %v",
-					VolumeContextZone, amlFilesystemProperties.Zone, availableZones)
-			}
-		} else {
-			klog.Warningf("no zones available for SKU %s in location %s", amlFilesystemProperties.SKUName, amlFilesystemProperties.Location)
-			if len(amlFilesystemProperties.Zone) > 0 {
-				return nil, status.Errorf(codes.InvalidArgument,
-					"CreateVolume Parameter %s cannot be used in location %s, no zones available for SKU %s",
-					VolumeContextZone, amlFilesystemProperties.Location, amlFilesystemProperties.SKUName)
-			}
+		if err := validateAmlFilesystemZone(amlFilesystemProperties, availableZones); err != nil {
+			return nil, err
 		}
 
 		if !isValidVolumeName(volName) {
@@ -374,7 +437,9 @@ func (d *Driver) CreateVolume(
 			amlFilesystemProperties,
 		)
 
-		mgsIPAddress, err = d.dynamicProvisioner.CreateAmlFilesystem(ctx, amlFilesystemProperties)
+		provisioningStart := time.Now()
+		mgsIPAddress, err = azureClients.dynamicProvisioner.CreateAmlFilesystem(ctx, amlFilesystemProperties)
+		observeAmlFilesystemProvisioning(amlFilesystemProperties.SKUName, provisioningStart, err)
 		if err != nil {
 			errCode := status.Code(err)
 			if errCode == codes.Unknown {
@@ -385,9 +450,22 @@ func (d *Driver) CreateVolume(
 			return nil, status.Errorf(errCode, "CreateVolume error when creating AMLFS %s: %v", amlFilesystemProperties.AmlFilesystemName, err)
 		}
 
+		amlFilesystemProperties.progress.record(eventReasonAmlFilesystemCreated,
+			"Created AMLFS cluster %s in resource group %s with MGS address %s after %v",
+			amlFilesystemProperties.AmlFilesystemName, amlFilesystemProperties.ResourceGroupName, mgsIPAddress, amlFilesystemProperties.progress.elapsed())
+
 		util.SetKeyValueInMap(parameters, VolumeContextResourceGroupName, amlFilesystemProperties.ResourceGroupName)
 		util.SetKeyValueInMap(parameters, VolumeContextMGSIPAddress, mgsIPAddress)
 		util.SetKeyValueInMap(parameters, VolumeContextFSName, DefaultLustreFsName)
+		if len(amlFilesystemProperties.candidateSubnets) > 0 {
+			// The chosen subnet replaces the candidates in the volume context
+			maps.DeleteFunc(parameters, func(key, _ string) bool {
+				return strings.EqualFold(key, VolumeContextCandidateSubnets) || strings.EqualFold(key, VolumeContextSubnetSelection)
+			})
+			util.SetKeyValueInMap(parameters, VolumeContextVnetResourceGroup, amlFilesystemProperties.SubnetInfo.VnetResourceGroup)
+			util.SetKeyValueInMap(parameters, VolumeContextVnetName, amlFilesystemProperties.SubnetInfo.VnetName)
+			util.SetKeyValueInMap(parameters, VolumeContextSubnetName, amlFilesystemProperties.SubnetInfo.SubnetName)
+		}
 	}
 
 	util.SetKeyValueInMap(parameters, VolumeContextInternalDynamicallyCreated, createdByDynamicProvisioningStringValue)
@@ -410,8 +488,63 @@ func (d *Driver) CreateVolume(
 	}, nil
 }
 
-func (d *Driver) getSkuValuesForLocation(ctx context.Context, skuName, location string) (*LustreSkuValue, error) {
-	skus, err := d.dynamicProvisioner.GetSkuValuesForLocation(ctx, location)
+// setAmlFilesystemDefaults fills in the location, resource group and subnet
+// the storage class left out from the cloud config of the driver, or the
+// candidate subnets of the driver when the storage class names no subnet
+func (d *Driver) setAmlFilesystemDefaults(ctx context.Context, amlFilesystemProperties *AmlFilesystemProperties) {
+	azureClients := d.azureClientsFor(ctx)
+	if len(amlFilesystemProperties.Location) == 0 {
+		amlFilesystemProperties.Location = azureClients.location
+	}
+
+	if len(amlFilesystemProperties.ResourceGroupName) == 0 {
+		amlFilesystemProperties.ResourceGroupName = azureClients.resourceGroup
+	}
+
+	if len(amlFilesystemProperties.candidateSubnets) == 0 && len(amlFilesystemProperties.SubnetInfo.SubnetName) == 0 && len(d.candidateSubnets) > 0 {
+		amlFilesystemProperties.candidateSubnets = setCandidateSubnetDefaults(d.candidateSubnets, amlFilesystemProperties.SubnetInfo)
+	}
+	if len(amlFilesystemProperties.candidateSubnets) > 0 {
+		for i, candidate := range amlFilesystemProperties.candidateSubnets {
+			amlFilesystemProperties.candidateSubnets[i] = azureClients.populateSubnetProperties(candidate, amlFilesystemProperties.VnetSubscriptionID)
+		}
+		// The subnet is chosen among the candidates when the cluster is created
+		amlFilesystemProperties.SubnetInfo = SubnetProperties{}
+		return
+	}
+
+	amlFilesystemProperties.SubnetInfo = azureClients.populateSubnetProperties(amlFilesystemProperties.SubnetInfo, amlFilesystemProperties.VnetSubscriptionID)
+}
+
+// validateAmlFilesystemZone checks the requested zone against the zones the
+// SKU is available in at the location
+func validateAmlFilesystemZone(amlFilesystemProperties *AmlFilesystemProperties, availableZones []string) error {
+	if len(availableZones) > 0 {
+		klog.V(2).Infof("available zones for SKU %s in location %s: %v", amlFilesystemProperties.SKUName, amlFilesystemProperties.Location, availableZones)
+		if len(amlFilesystemProperties.Zone) == 0 {
+			return status.Errorf(codes.InvalidArgument,
+				"CreateVolume Parameter %s must be provided for dynamically provisioned AMLFS in location %s, available zones: %v",
+				VolumeContextZone, amlFilesystemProperties.Location, availableZones)
+		}
+		if !slices.Contains(availableZones, amlFilesystemProperties.Zone) {
+			return status.Errorf(codes.InvalidArgument,
+				"CreateVolume Parameter %s %s must be one of: %v",
+				VolumeContextZone, amlFilesystemProperties.Zone, availableZones)
+		}
+		return nil
+	}
+
+	klog.Warningf("no zones available for SKU %s in location %s", amlFilesystemProperties.SKUName, amlFilesystemProperties.Location)
+	if len(amlFilesystemProperties.Zone) > 0 {
+		return status.Errorf(codes.InvalidArgument,
+			"CreateVolume Parameter %s cannot be used in location %s, no zones available for SKU %s",
+			VolumeContextZone, amlFilesystemProperties.Location, amlFilesystemProperties.SKUName)
+	}
+	return nil
+}
+
+func (d *Driver) getSkuValuesForLocation(ctx context.Context, subscriptionID, skuName, location string) (*LustreSkuValue, error) {
+	skus, err := d.azureClientsFor(ctx).dynamicProvisioner.GetSkuValuesForLocation(ctx, subscriptionID, location)
 	if err != nil {
 		return nil, err
 	}
@@ -465,12 +598,6 @@ func checkVolumeRequest(req *csi.CreateVolumeRequest) error {
 			"CreateVolume doesn't support being created from an existing volume",
 		)
 	}
-	if req.GetSecrets() != nil {
-		return status.Error(
-			codes.InvalidArgument,
-			"CreateVolume doesn't support secrets",
-		)
-	}
 	if req.GetAccessibilityRequirements() != nil {
 		return status.Error(
 			codes.InvalidArgument,
@@ -488,10 +615,13 @@ func checkVolumeRequest(req *csi.CreateVolumeRequest) error {
 func (d *Driver) DeleteVolume(
 	ctx context.Context, req *csi.DeleteVolumeRequest,
 ) (*csi.DeleteVolumeResponse, error) {
+	// The whole deletion uses the Azure clients it started with
+	ctx = d.withAzureClients(ctx)
+	azureClients := d.azureClientsFor(ctx)
 	mc := metrics.NewMetricContext(azureLustreCSIDriverName,
 		"controller_delete_volume",
-		d.resourceGroup,
-		d.cloud.SubscriptionID,
+		azureClients.resourceGroup,
+		azureClients.cloud.SubscriptionID,
 		d.Name)
 
 	volumeID := req.GetVolumeId()
@@ -499,11 +629,11 @@ func (d *Driver) DeleteVolume(
 		return nil, status.Error(codes.InvalidArgument,
 			"Volume ID missing in request")
 	}
-	if req.GetSecrets() != nil {
-		return nil, status.Error(
-			codes.InvalidArgument,
-			"CreateVolume doesn't support secrets",
-		)
+	// The AMLFS cluster is deleted with the credential of the provisioner
+	// secret if any, like it was created
+	ctx, err := d.contextWithProvisionerSecrets(ctx, req.GetSecrets())
+	if err != nil {
+		return nil, err
 	}
 
 	lustreVolume, err := getLustreVolFromID(volumeID)
@@ -533,7 +663,7 @@ func (d *Driver) DeleteVolume(
 			return nil, status.Errorf(codes.InvalidArgument, "volume was dynamically created but associated resource group is not specified. AMLFS cluster may need to be deleted manually")
 		}
 
-		err := d.dynamicProvisioner.DeleteAmlFilesystem(ctx, resourceGroupName, amlFilesystemName)
+		err := azureClients.dynamicProvisioner.DeleteAmlFilesystem(ctx, lustreVolume.subscriptionID, resourceGroupName, amlFilesystemName)
 		if err != nil {
 			errCode := status.Code(err)
 			if errCode == codes.Unknown {
@@ -600,7 +730,7 @@ func (d *Driver) ControllerGetCapabilities(
 
 // Convert VolumeCreate parameters to a volume id
 func createVolumeIDFromParams(volName string, params map[string]string) (string, error) {
-	var mgsIPAddress, createdByDynamicProvisioningStringValue, resourceGroupName, subDir string
+	var mgsIPAddress, createdByDynamicProvisioningStringValue, resourceGroupName, subDir, subscriptionID string
 
 	// validate parameters (case-insensitive).
 	for k, v := range params {
@@ -611,6 +741,8 @@ func createVolumeIDFromParams(volName string, params map[string]string) (string,
 			createdByDynamicProvisioningStringValue = v
 		case VolumeContextResourceGroupName:
 			resourceGroupName = v
+		case VolumeContextSubscriptionID:
+			subscriptionID = v
 		case VolumeContextSubDir:
 			subDir = v
 			subDir = strings.Trim(subDir, "/")
@@ -625,6 +757,11 @@ func createVolumeIDFromParams(volName string, params map[string]string) (string,
 	}
 
 	volumeID := fmt.Sprintf(volumeIDTemplate, volName, DefaultLustreFsName, mgsIPAddress, subDir, createdByDynamicProvisioningStringValue, resourceGroupName)
+	// The subscription is only recorded when the storage class names one, so
+	// the volumes in the subscription of the driver keep their IDs
+	if subscriptionID != "" {
+		volumeID += separator + subscriptionID
+	}
 
 	return volumeID, nil
 }
@@ -548,6 +548,27 @@ func TestCreateVolume_Err_ParametersEmptySubDir(t *testing.T) {
 	require.ErrorContains(t, err, "sub-dir")
 }
 
+func TestCreateVolume_Success_ClientTunables(t *testing.T) {
+	d := NewFakeDriver()
+	req := buildCreateVolumeRequest()
+	req.Parameters[VolumeContextClientTunables] = "llite.max_read_ahead_mb=1024,osc.max_dirty_mb=512"
+	rep, err := d.CreateVolume(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, "llite.max_read_ahead_mb=1024,osc.max_dirty_mb=512", rep.GetVolume().GetVolumeContext()[VolumeContextClientTunables])
+}
+
+func TestCreateVolume_Err_InvalidClientTunables(t *testing.T) {
+	d := NewFakeDriver()
+	req := buildCreateVolumeRequest()
+	req.Parameters[VolumeContextClientTunables] = "llite.max_read_ahead_mb=-1"
+	_, err := d.CreateVolume(context.Background(), req)
+	require.Error(t, err)
+	grpcStatus, ok := status.FromError(err)
+	assert.True(t, ok)
+	assert.Equal(t, codes.InvalidArgument, grpcStatus.Code())
+	require.ErrorContains(t, err, "client-tunables")
+}
+
 func TestCreateVolume_Err_UnknownParameters(t *testing.T) {
 	d := NewFakeDriver()
 	req := buildCreateVolumeRequest()
@@ -590,16 +611,12 @@ func TestCreateVolume_Err_HasVolumeContentSource(t *testing.T) {
 	require.ErrorContains(t, err, "existing volume")
 }
 
-func TestCreateVolume_Err_HasSecrets(t *testing.T) {
+func TestCreateVolume_Success_EmptySecrets(t *testing.T) {
 	d := NewFakeDriver()
 	req := buildCreateVolumeRequest()
 	req.Secrets = map[string]string{}
 	_, err := d.CreateVolume(context.Background(), req)
-	require.Error(t, err)
-	grpcStatus, ok := status.FromError(err)
-	assert.True(t, ok)
-	assert.Equal(t, codes.InvalidArgument, grpcStatus.Code())
-	require.ErrorContains(t, err, "secrets")
+	require.NoError(t, err)
 }
 
 func TestCreateVolume_Err_HasSecretsValue(t *testing.T) {
@@ -611,7 +628,7 @@ func TestCreateVolume_Err_HasSecretsValue(t *testing.T) {
 	grpcStatus, ok := status.FromError(err)
 	assert.True(t, ok)
 	assert.Equal(t, codes.InvalidArgument, grpcStatus.Code())
-	require.ErrorContains(t, err, "secrets")
+	require.ErrorContains(t, err, "provisioner secret has unknown key(s) [test]")
 }
 
 func TestCreateVolume_Err_HasAccessibilityRequirements(t *testing.T) {
@@ -836,7 +853,7 @@ func TestDeleteVolume_Success_InvalidVolumeID(t *testing.T) {
 	require.NoError(t, err)
 }
 
-func TestDeleteVolume_Err_HasSecrets(t *testing.T) {
+func TestDeleteVolume_Success_EmptySecrets(t *testing.T) {
 	d := NewFakeDriver()
 	req := &csi.DeleteVolumeRequest{
 		VolumeId: fmt.Sprintf(volumeIDTemplate,
@@ -844,11 +861,7 @@ func TestDeleteVolume_Err_HasSecrets(t *testing.T) {
 		Secrets: map[string]string{},
 	}
 	_, err := d.DeleteVolume(context.Background(), req)
-	require.Error(t, err)
-	grpcStatus, ok := status.FromError(err)
-	assert.True(t, ok)
-	assert.Equal(t, codes.InvalidArgument, grpcStatus.Code())
-	require.ErrorContains(t, err, "secrets")
+	require.NoError(t, err)
 }
 
 func TestDynamicDeleteVolume_Err_NoResourceGroup(t *testing.T) {
@@ -886,7 +899,7 @@ func TestDeleteVolume_Err_HasSecretsValue(t *testing.T) {
 	grpcStatus, ok := status.FromError(err)
 	assert.True(t, ok)
 	assert.Equal(t, codes.InvalidArgument, grpcStatus.Code())
-	require.ErrorContains(t, err, "secrets")
+	require.ErrorContains(t, err, "provisioner secret has unknown key(s) [test]")
 }
 
 func TestDeleteVolume_Err_OperationExists(t *testing.T) {
@@ -1333,6 +1346,9 @@ func TestParseAmlfilesystemProperties_Err_ReservedTags(t *testing.T) {
 		{
 			reservedTag: pvNameTag,
 		},
+		{
+			reservedTag: clusterIDTag,
+		},
 		{
 			reservedTag: pvcNameTag,
 		},
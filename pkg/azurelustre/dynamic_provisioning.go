@@ -3,17 +3,19 @@ package azurelustre
 import (
 	"context"
 	"errors"
-	"net/http"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v6"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storagecache/armstoragecache/v4"
+	"golang.org/x/sync/singleflight"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"k8s.io/klog/v2"
@@ -32,9 +34,31 @@ const (
 )
 
 type DynamicProvisionerInterface interface {
-	DeleteAmlFilesystem(ctx context.Context, resourceGroupName, amlFilesystemName string) error
+	DeleteAmlFilesystem(ctx context.Context, subscriptionID, resourceGroupName, amlFilesystemName string) error
 	CreateAmlFilesystem(ctx context.Context, amlFilesystemProperties *AmlFilesystemProperties) (string, error)
-	GetSkuValuesForLocation(ctx context.Context, location string) (map[string]*LustreSkuValue, error)
+	GetSkuValuesForLocation(ctx context.Context, subscriptionID, location string) (map[string]*LustreSkuValue, error)
+	ListAmlFilesystems(ctx context.Context, subscriptionID string) ([]*AmlFilesystemSummary, error)
+	GetSubnetCapacity(ctx context.Context, subscriptionID string, subnetInfo SubnetProperties, sku string, clusterSize float32) (*SubnetCapacity, error)
+}
+
+// SubnetCapacity is the number of IP addresses an AMLFS cluster needs in a
+// subnet and the number still available there, net of those reserved by the
+// AMLFS creations in flight
+type SubnetCapacity struct {
+	RequiredIPs  int
+	AvailableIPs int
+	ReservedIPs  int
+}
+
+// AmlFilesystemSummary describes an existing AMLFS cluster of the subscription
+type AmlFilesystemSummary struct {
+	// SubscriptionID is the subscription the cluster was listed in, empty
+	// for that of the driver
+	SubscriptionID    string
+	ResourceGroupName string
+	AmlFilesystemName string
+	ProvisioningState string
+	Tags              map[string]string
 }
 
 type DynamicProvisioner struct {
@@ -43,7 +67,70 @@ type DynamicProvisioner struct {
 	mgmtClient           *armstoragecache.ManagementClient
 	skusClient           *armstoragecache.SKUsClient
 	vnetClient           *armnetwork.VirtualNetworksClient
-	pollFrequency        time.Duration
+	// usagesClient lists the StorageCache quotas, the quota check is skipped
+	// when it is nil
+	usagesClient  *armstoragecache.AscUsagesClient
+	pollFrequency time.Duration
+
+	// caches are shared with the provisioners built from the previous cloud
+	// configs, new ones when nil
+	caches     *provisionerCaches
+	cachesInit sync.Once
+
+	// subnetReservations holds the subnet IP addresses reserved by in-flight
+	// AMLFS creations, in memory when nil
+	subnetReservations     subnetReservationStore
+	subnetReservationsInit sync.Once
+
+	// networkPreflight is what happens when the network security group or
+	// route table of the AMLFS subnet blocks the traffic the cluster needs,
+	// the check is skipped when subnetsClient is nil. clientSubnet is the
+	// subnet of the Lustre clients, the AKS nodes, empty when unknown.
+	networkPreflight     string
+	subnetsClient        *armnetwork.SubnetsClient
+	securityGroupsClient *armnetwork.SecurityGroupsClient
+	routeTablesClient    *armnetwork.RouteTablesClient
+	clientSubnet         SubnetProperties
+
+	// subscriptionID and vnetSubscriptionID are the subscriptions of the
+	// storage and network clients above. clientFactories builds the clients
+	// of the other subscriptions storage classes name, which are rejected
+	// when it is nil.
+	subscriptionID     string
+	vnetSubscriptionID string
+	clientFactories    *armClientFactories
+}
+
+// provisionerCaches are the caches of the dynamic provisioner, kept when its
+// clients are rebuilt from a changed cloud config. subnetReservationLock
+// serializes the subnet capacity checks with the reservations they lead to.
+type provisionerCaches struct {
+	quotaLock  sync.Mutex
+	quotaCache map[string]*amlfsQuotaCacheEntry
+
+	skuCacheLock sync.Mutex
+	skuCache     map[string]*skuCacheEntry
+	skuGroup     singleflight.Group
+
+	subnetReservationLock sync.Mutex
+}
+
+func (d *DynamicProvisioner) getCaches() *provisionerCaches {
+	d.cachesInit.Do(func() {
+		if d.caches == nil {
+			d.caches = &provisionerCaches{}
+		}
+	})
+	return d.caches
+}
+
+// cacheKey returns the key of the cached values of the location in the
+// subscription, the subscription of the driver standing for an empty one
+func (d *DynamicProvisioner) cacheKey(subscriptionID, location string) string {
+	if isDefaultSubscription(d.subscriptionID, subscriptionID) {
+		subscriptionID = d.subscriptionID
+	}
+	return strings.ToLower(subscriptionID + "/" + location)
 }
 
 func convertHTTPResponseErrorToGrpcCodeError(err error) error {
@@ -67,62 +154,21 @@ func convertHTTPResponseErrorToGrpcCodeError(err error) error {
 		return status.Errorf(codes.Unknown, "error occurred calling API: %v", err)
 	}
 
-	statusCode := httpError.StatusCode
-
-	grpcErrorCode := codes.Unknown
-	if statusCode >= 400 && statusCode < 500 {
-		switch statusCode {
-		case http.StatusBadRequest:
-			grpcErrorCode = codes.InvalidArgument
-		case http.StatusConflict:
-			if strings.Contains(err.Error(), "Operation results in exceeding quota limits of resource type AmlFilesystem") {
-				grpcErrorCode = codes.ResourceExhausted
-			} else {
-				grpcErrorCode = codes.InvalidArgument
-			}
-		case http.StatusNotFound:
-			grpcErrorCode = codes.NotFound
-		case http.StatusForbidden:
-			grpcErrorCode = codes.PermissionDenied
-		case http.StatusUnauthorized:
-			grpcErrorCode = codes.Unauthenticated
-		case http.StatusTooManyRequests:
-			grpcErrorCode = codes.Unavailable
-		default:
-			grpcErrorCode = codes.InvalidArgument
-		}
-	} else if statusCode >= 500 {
-		switch statusCode {
-		case http.StatusInternalServerError:
-			grpcErrorCode = codes.Internal
-		case http.StatusBadGateway:
-			grpcErrorCode = codes.Unavailable
-		case http.StatusServiceUnavailable:
-			grpcErrorCode = codes.Unavailable
-		case http.StatusGatewayTimeout:
-			grpcErrorCode = codes.DeadlineExceeded
-		default:
-			// Prefer to default to Unknown rather than Internal so provisioner will retry
-			grpcErrorCode = codes.Unknown
-		}
-	} else if strings.Contains(httpError.ErrorCode, "Error") ||
-		strings.Contains(httpError.ErrorCode, "Timeout") ||
-		strings.Contains(httpError.ErrorCode, "Fail") {
-		// Special case for 200 status errors to ensure preserve the reason
-		return status.Errorf(codes.DeadlineExceeded, "%s: %v", httpError.ErrorCode, httpError)
-	}
-
-	return status.Errorf(grpcErrorCode, "error occurred calling API: %v", httpError)
+	return armErrorStatus(httpError)
 }
 
-func (d *DynamicProvisioner) currentClusterState(ctx context.Context, resourceGroupName, amlFilesystemName string) (ClusterState, error) {
-	if d.amlFilesystemsClient == nil {
+func (d *DynamicProvisioner) currentClusterState(ctx context.Context, subscriptionID, resourceGroupName, amlFilesystemName string) (ClusterState, error) {
+	clients, err := d.storageClientsFor(ctx, subscriptionID)
+	if err != nil {
+		return "", err
+	}
+	if clients.amlFilesystemsClient == nil {
 		return "", status.Error(codes.Internal, "aml filesystem client is nil")
 	}
 
-	resp, err := d.amlFilesystemsClient.Get(ctx, resourceGroupName, amlFilesystemName, nil)
+	resp, err := clients.amlFilesystemsClient.Get(ctx, resourceGroupName, amlFilesystemName, nil)
 	if err != nil {
-		if strings.Contains(err.Error(), "ResourceNotFound") {
+		if hasARMErrorCode(err, "ResourceNotFound") {
 			klog.V(2).Infof("Cluster %s not found!", amlFilesystemName)
 			return ClusterStateNotFound, nil
 		}
@@ -143,12 +189,16 @@ func (d *DynamicProvisioner) currentClusterState(ctx context.Context, resourceGr
 	return ClusterStateExists, nil
 }
 
-func (d *DynamicProvisioner) DeleteAmlFilesystem(ctx context.Context, resourceGroupName, amlFilesystemName string) error {
-	if d.amlFilesystemsClient == nil {
+func (d *DynamicProvisioner) DeleteAmlFilesystem(ctx context.Context, subscriptionID, resourceGroupName, amlFilesystemName string) error {
+	clients, err := d.storageClientsFor(ctx, subscriptionID)
+	if err != nil {
+		return err
+	}
+	if clients.amlFilesystemsClient == nil {
 		return status.Error(codes.Internal, "aml filesystem client is nil")
 	}
 
-	poller, err := d.amlFilesystemsClient.BeginDelete(ctx, resourceGroupName, amlFilesystemName, nil)
+	poller, err := clients.amlFilesystemsClient.BeginDelete(ctx, resourceGroupName, amlFilesystemName, nil)
 	if err != nil {
 		klog.Warningf("failed to finish the request: %v", err)
 		return convertHTTPResponseErrorToGrpcCodeError(err)
@@ -164,15 +214,74 @@ func (d *DynamicProvisioner) DeleteAmlFilesystem(ctx context.Context, resourceGr
 	}
 
 	klog.V(2).Infof("Successfully deleted AML filesystem: %s", amlFilesystemName)
+	d.releaseSubnetIPs(ctx, d.subnetReservationKey(subscriptionID, resourceGroupName, amlFilesystemName))
 	return nil
 }
 
+// ListAmlFilesystems lists the AMLFS clusters of the subscription, that of
+// the driver when empty
+func (d *DynamicProvisioner) ListAmlFilesystems(ctx context.Context, subscriptionID string) ([]*AmlFilesystemSummary, error) {
+	clients, err := d.storageClientsFor(ctx, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	if clients.amlFilesystemsClient == nil {
+		return nil, status.Error(codes.Internal, "aml filesystem client is nil")
+	}
+
+	var amlFilesystems []*AmlFilesystemSummary
+	pager := clients.amlFilesystemsClient.NewListPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			klog.Warningf("error listing aml filesystems: %v", err)
+			return nil, convertHTTPResponseErrorToGrpcCodeError(err)
+		}
+		for _, amlFilesystem := range page.Value {
+			if amlFilesystem.ID == nil || amlFilesystem.Name == nil {
+				continue
+			}
+			resourceID, err := arm.ParseResourceID(*amlFilesystem.ID)
+			if err != nil {
+				klog.Warningf("could not parse aml filesystem ID %s: %v", *amlFilesystem.ID, err)
+				continue
+			}
+			summary := &AmlFilesystemSummary{
+				SubscriptionID:    subscriptionID,
+				ResourceGroupName: resourceID.ResourceGroupName,
+				AmlFilesystemName: *amlFilesystem.Name,
+				Tags:              make(map[string]string, len(amlFilesystem.Tags)),
+			}
+			if amlFilesystem.Properties != nil && amlFilesystem.Properties.ProvisioningState != nil {
+				summary.ProvisioningState = string(*amlFilesystem.Properties.ProvisioningState)
+			}
+			for key, value := range amlFilesystem.Tags {
+				if value != nil {
+					summary.Tags[key] = *value
+				}
+			}
+			amlFilesystems = append(amlFilesystems, summary)
+		}
+	}
+	return amlFilesystems, nil
+}
+
 func (d *DynamicProvisioner) CreateAmlFilesystem(ctx context.Context, amlFilesystemProperties *AmlFilesystemProperties) (string, error) {
-	if d.amlFilesystemsClient == nil {
+	clients, err := d.storageClientsFor(ctx, amlFilesystemProperties.SubscriptionID)
+	if err != nil {
+		return "", err
+	}
+	if clients.amlFilesystemsClient == nil {
 		return "", status.Error(codes.Internal, "aml filesystem client is nil")
 	}
-	if amlFilesystemProperties.SubnetInfo.SubnetID == "" || amlFilesystemProperties.SubnetInfo.SubnetName == "" || amlFilesystemProperties.SubnetInfo.VnetName == "" || amlFilesystemProperties.SubnetInfo.VnetResourceGroup == "" {
-		return "", status.Error(codes.InvalidArgument, "invalid subnet info, must have valid subnet ID, subnet name, vnet name, and vnet resource group")
+	subnets := amlFilesystemProperties.candidateSubnets
+	if len(subnets) == 0 {
+		subnets = []SubnetProperties{amlFilesystemProperties.SubnetInfo}
+	}
+	for _, subnetInfo := range subnets {
+		if subnetInfo.SubnetID == "" || subnetInfo.SubnetName == "" || subnetInfo.VnetName == "" || subnetInfo.VnetResourceGroup == "" {
+			return "", status.Error(codes.InvalidArgument, "invalid subnet info, must have valid subnet ID, subnet name, vnet name, and vnet resource group")
+		}
 	}
 
 	tags := make(map[string]*string, len(amlFilesystemProperties.Tags))
@@ -180,7 +289,6 @@ func (d *DynamicProvisioner) CreateAmlFilesystem(ctx context.Context, amlFilesys
 		tags[key] = to.Ptr(value)
 	}
 	properties := &armstoragecache.AmlFilesystemProperties{
-		FilesystemSubnet: to.Ptr(amlFilesystemProperties.SubnetInfo.SubnetID),
 		MaintenanceWindow: &armstoragecache.AmlFilesystemPropertiesMaintenanceWindow{
 			DayOfWeek:    to.Ptr(amlFilesystemProperties.MaintenanceDayOfWeek),
 			TimeOfDayUTC: to.Ptr(amlFilesystemProperties.TimeOfDayUTC),
@@ -207,24 +315,34 @@ func (d *DynamicProvisioner) CreateAmlFilesystem(ctx context.Context, amlFilesys
 		}
 	}
 
-	currentClusterState, err := d.currentClusterState(ctx, amlFilesystemProperties.ResourceGroupName, amlFilesystemProperties.AmlFilesystemName)
+	currentClusterState, err := d.currentClusterState(ctx, amlFilesystemProperties.SubscriptionID,
+		amlFilesystemProperties.ResourceGroupName, amlFilesystemProperties.AmlFilesystemName)
 	if err != nil {
 		return "", convertHTTPResponseErrorToGrpcCodeError(err)
 	}
 
 	switch currentClusterState {
 	case ClusterStateNotFound:
-		hasSufficientCapacity, err := d.CheckSubnetCapacity(ctx, amlFilesystemProperties.SubnetInfo, amlFilesystemProperties.SKUName, amlFilesystemProperties.StorageCapacityTiB)
+		releaseQuota, err := d.checkAmlFilesystemQuota(ctx, amlFilesystemProperties.SubscriptionID,
+			amlFilesystemProperties.Location, amlFilesystemProperties.StorageCapacityTiB)
 		if err != nil {
-			return "", convertHTTPResponseErrorToGrpcCodeError(err)
+			return "", err
+		}
+		if err := d.reserveSubnetCapacity(ctx, amlFilesystemProperties); err != nil {
+			releaseQuota()
+			return "", err
 		}
-		if !hasSufficientCapacity {
-			return "", status.Errorf(codes.ResourceExhausted, "cannot create AMLFS cluster %s in subnet %s, not enough IP addresses available",
-				amlFilesystemProperties.AmlFilesystemName,
-				amlFilesystemProperties.SubnetInfo.SubnetID,
-			)
+		if err := d.checkNetworkSecurity(ctx, amlFilesystemProperties); err != nil {
+			d.releaseSubnetIPs(ctx, d.subnetReservationKey(amlFilesystemProperties.SubscriptionID, amlFilesystemProperties.ResourceGroupName, amlFilesystemProperties.AmlFilesystemName))
+			releaseQuota()
+			return "", err
 		}
+		amlFilesystemProperties.progress.record(eventReasonAmlFilesystemSubnetChecked,
+			"Subnet %s has enough IP addresses available for AMLFS cluster %s",
+			amlFilesystemProperties.SubnetInfo.SubnetName, amlFilesystemProperties.AmlFilesystemName)
 	case ClusterStateDeleting:
+		amlFilesystemProperties.progress.record(eventReasonAmlFilesystemRetrying,
+			"Waiting for the deletion of AMLFS cluster %s to complete before retrying its creation", amlFilesystemProperties.AmlFilesystemName)
 		return "", status.Errorf(codes.Aborted, "AMLFS cluster %s creation did not complete correctly, waiting for deletion to complete before retrying cluster creation",
 			amlFilesystemProperties.AmlFilesystemName)
 	case ClusterStateFailed:
@@ -233,15 +351,25 @@ func (d *DynamicProvisioner) CreateAmlFilesystem(ctx context.Context, amlFilesys
 		// TODO: if we allow reusing AMLFS clusters, we should check  the existing cluster's properties
 		klog.V(2).Infof("AMLFS cluster %s already exists, will attempt update request", amlFilesystemProperties.AmlFilesystemName)
 	}
+	if currentClusterState != ClusterStateNotFound && len(amlFilesystemProperties.candidateSubnets) > 0 {
+		subnetInfo, err := existingAmlFilesystemSubnet(ctx, clients.amlFilesystemsClient, amlFilesystemProperties)
+		if err != nil {
+			return "", err
+		}
+		amlFilesystemProperties.SubnetInfo = subnetInfo
+	}
+	properties.FilesystemSubnet = to.Ptr(amlFilesystemProperties.SubnetInfo.SubnetID)
 
+	reservationKey := d.subnetReservationKey(amlFilesystemProperties.SubscriptionID, amlFilesystemProperties.ResourceGroupName, amlFilesystemProperties.AmlFilesystemName)
 	klog.V(2).Infof("creating AMLFS cluster: %#v", amlFilesystemProperties)
-	poller, err := d.amlFilesystemsClient.BeginCreateOrUpdate(
+	poller, err := clients.amlFilesystemsClient.BeginCreateOrUpdate(
 		ctx,
 		amlFilesystemProperties.ResourceGroupName,
 		amlFilesystemProperties.AmlFilesystemName,
 		amlFilesystem,
 		nil)
 	if err != nil {
+		d.releaseSubnetIPs(ctx, reservationKey)
 		retry, retryErr := d.checkErrorForRetry(ctx, err, amlFilesystemProperties)
 		if retryErr != nil {
 			return "", convertHTTPResponseErrorToGrpcCodeError(retryErr)
@@ -252,10 +380,23 @@ func (d *DynamicProvisioner) CreateAmlFilesystem(ctx context.Context, amlFilesys
 		return "", convertHTTPResponseErrorToGrpcCodeError(err)
 	}
 
+	amlFilesystemProperties.progress.record(eventReasonAmlFilesystemCreating,
+		"Started creating AMLFS cluster %s with SKU %s and %v TiB in resource group %s",
+		amlFilesystemProperties.AmlFilesystemName, amlFilesystemProperties.SKUName,
+		amlFilesystemProperties.StorageCapacityTiB, amlFilesystemProperties.ResourceGroupName)
+
 	pollerOptions := &runtime.PollUntilDoneOptions{
 		Frequency: d.pollFrequency,
 	}
+	stopHeartbeat := amlFilesystemProperties.progress.startHeartbeat(amlFilesystemProperties.AmlFilesystemName)
 	res, err := poller.PollUntilDone(ctx, pollerOptions)
+	stopHeartbeat()
+	// The reservation is kept while the cluster may still be being created,
+	// when this request gave up or polling failed, until the retried request
+	// sees the creation complete
+	if poller.Done() {
+		d.releaseSubnetIPs(ctx, reservationKey)
+	}
 	if err != nil {
 		retry, retryErr := d.checkErrorForRetry(ctx, err, amlFilesystemProperties)
 		if retryErr != nil {
@@ -273,10 +414,50 @@ func (d *DynamicProvisioner) CreateAmlFilesystem(ctx context.Context, amlFilesys
 	return mgsAddress, nil
 }
 
+// reserveSubnetCapacity checks that the subnet has enough IP addresses for
+// the AMLFS cluster once those reserved by the other creations in flight are
+// subtracted, and reserves them until the creation completes or fails. With
+// candidate subnets, the subnet is chosen among them first.
+func (d *DynamicProvisioner) reserveSubnetCapacity(ctx context.Context, amlFilesystemProperties *AmlFilesystemProperties) error {
+	caches := d.getCaches()
+	caches.subnetReservationLock.Lock()
+	defer caches.subnetReservationLock.Unlock()
+
+	if len(amlFilesystemProperties.candidateSubnets) > 0 {
+		subnetInfo, subnetCapacity, err := chooseSubnet(ctx, amlFilesystemProperties, d.GetSubnetCapacity)
+		if err != nil {
+			return err
+		}
+		amlFilesystemProperties.SubnetInfo = subnetInfo
+		d.reserveSubnetIPs(ctx, d.subnetReservationKey(amlFilesystemProperties.SubscriptionID, amlFilesystemProperties.ResourceGroupName, amlFilesystemProperties.AmlFilesystemName),
+			subnetInfo.SubnetID, subnetCapacity.RequiredIPs, subnetCapacity.AvailableIPs+subnetCapacity.ReservedIPs)
+		return nil
+	}
+
+	subnetInfo := amlFilesystemProperties.SubnetInfo
+	subnetCapacity, err := d.GetSubnetCapacity(ctx, amlFilesystemProperties.SubscriptionID, subnetInfo, amlFilesystemProperties.SKUName, amlFilesystemProperties.StorageCapacityTiB)
+	if err != nil {
+		return convertHTTPResponseErrorToGrpcCodeError(err)
+	}
+	if subnetCapacity.RequiredIPs > subnetCapacity.AvailableIPs {
+		klog.Warningf("There is not enough room in the %s subnet to fit a %s SKU cluster: %v needed, %v available, %v reserved by other AMLFS creations",
+			subnetInfo.SubnetID, amlFilesystemProperties.SKUName, subnetCapacity.RequiredIPs, subnetCapacity.AvailableIPs, subnetCapacity.ReservedIPs)
+		return status.Errorf(codes.ResourceExhausted,
+			"cannot create AMLFS cluster %s in subnet %s, not enough IP addresses available: %d needed, %d available, %d reserved by other AMLFS creations",
+			amlFilesystemProperties.AmlFilesystemName, subnetInfo.SubnetID,
+			subnetCapacity.RequiredIPs, subnetCapacity.AvailableIPs, subnetCapacity.ReservedIPs)
+	}
+	d.reserveSubnetIPs(ctx, d.subnetReservationKey(amlFilesystemProperties.SubscriptionID, amlFilesystemProperties.ResourceGroupName, amlFilesystemProperties.AmlFilesystemName),
+		subnetInfo.SubnetID, subnetCapacity.RequiredIPs, subnetCapacity.AvailableIPs+subnetCapacity.ReservedIPs)
+	return nil
+}
+
 func (d *DynamicProvisioner) tryDeleteBeforeRetry(ctx context.Context, amlFilesystemProperties *AmlFilesystemProperties) error {
 	resourceGroupName := amlFilesystemProperties.ResourceGroupName
 	amlFilesystemName := amlFilesystemProperties.AmlFilesystemName
-	err := d.DeleteAmlFilesystem(ctx, resourceGroupName, amlFilesystemName)
+	amlFilesystemProperties.progress.record(eventReasonAmlFilesystemRetrying,
+		"AMLFS cluster %s failed to create after %v, deleting it before retrying", amlFilesystemName, amlFilesystemProperties.progress.elapsed())
+	err := d.DeleteAmlFilesystem(ctx, amlFilesystemProperties.SubscriptionID, resourceGroupName, amlFilesystemName)
 	if err != nil {
 		klog.Errorf("error attempting to delete AMLFS cluster %s for creation retry: %v", amlFilesystemProperties.AmlFilesystemName, err)
 		return convertHTTPResponseErrorToGrpcCodeError(err)
@@ -285,16 +466,18 @@ func (d *DynamicProvisioner) tryDeleteBeforeRetry(ctx context.Context, amlFilesy
 }
 
 func (d *DynamicProvisioner) checkErrorForRetry(ctx context.Context, err error, amlFilesystemProperties *AmlFilesystemProperties) (bool, error) {
-	err = convertHTTPResponseErrorToGrpcCodeError(err)
-	errCode := status.Code(err)
+	class, ok := classifyARMError(err)
+	if !ok {
+		return false, nil
+	}
 
-	if errCode == codes.DeadlineExceeded && strings.Contains(err.Error(), "Timeout") {
+	switch class.retry {
+	case armErrorRetryRecreate:
 		klog.Warningf("AMLFS creation failed due to a creation timeout error, deleting and recreating AMLFS cluster: %v", err)
 		return true, nil
-	}
-
-	if errCode == codes.DeadlineExceeded && (strings.Contains(err.Error(), "Error") || strings.Contains(err.Error(), "Fail")) {
-		currentClusterState, err := d.currentClusterState(ctx, amlFilesystemProperties.ResourceGroupName, amlFilesystemProperties.AmlFilesystemName)
+	case armErrorRetryRecreateIfFailed:
+		currentClusterState, err := d.currentClusterState(ctx, amlFilesystemProperties.SubscriptionID,
+			amlFilesystemProperties.ResourceGroupName, amlFilesystemProperties.AmlFilesystemName)
 		if err != nil {
 			klog.Errorf("error getting current cluster state for cluster %s: %v", amlFilesystemProperties.AmlFilesystemName, err)
 			return false, err
@@ -303,19 +486,28 @@ func (d *DynamicProvisioner) checkErrorForRetry(ctx context.Context, err error,
 			klog.Warningf("AMLFS creation failed due to a failed deployment, deleting and recreating AMLFS cluster: %v", err)
 			return true, nil
 		}
+	case armErrorRetryNone:
 	}
 	return false, nil
 }
 
-func (d *DynamicProvisioner) GetSkuValuesForLocation(ctx context.Context, location string) (map[string]*LustreSkuValue, error) {
-	if d.skusClient == nil {
+// listSkuValuesForLocation lists the AMLFS SKUs available in the location to
+// the subscription. It also reports whether a SKU capability could not be
+// parsed, in which case the SKU values should not be cached.
+func (d *DynamicProvisioner) listSkuValuesForLocation(ctx context.Context, subscriptionID, location string) (map[string]*LustreSkuValue, bool, error) {
+	clients, err := d.storageClientsFor(ctx, subscriptionID)
+	if err != nil {
+		return nil, false, err
+	}
+	if clients.skusClient == nil {
 		klog.Error("skus client is nil")
-		return nil, status.Error(codes.Internal, "skus client is nil")
+		return nil, false, status.Error(codes.Internal, "skus client is nil")
 	}
 
-	skusPager := d.skusClient.NewListPager(nil)
+	skusPager := clients.skusClient.NewListPager(nil)
 	skuValues := make(map[string]*LustreSkuValue)
 
+	parseFailed := false
 	var amlfsSkus []*armstoragecache.ResourceSKU
 	var skusForLocation []*armstoragecache.ResourceSKU
 
@@ -323,7 +515,7 @@ func (d *DynamicProvisioner) GetSkuValuesForLocation(ctx context.Context, locati
 		page, err := skusPager.NextPage(ctx)
 		if err != nil {
 			klog.Errorf("error retrieving SKUs for location %s: %v", location, err)
-			return nil, status.Errorf(codes.Internal, "error retrieving SKUs: %v", err)
+			return nil, false, status.Errorf(codes.Internal, "error retrieving SKUs: %v", err)
 		}
 
 		for _, sku := range page.Value {
@@ -335,7 +527,7 @@ func (d *DynamicProvisioner) GetSkuValuesForLocation(ctx context.Context, locati
 
 	if len(amlfsSkus) == 0 {
 		klog.Errorf("found no AMLFS SKUs for location %s", location)
-		return nil, status.Errorf(codes.Internal, "found no AMLFS SKUs for location %s", location)
+		return nil, false, status.Errorf(codes.Internal, "found no AMLFS SKUs for location %s", location)
 	}
 
 	for _, sku := range amlfsSkus {
@@ -348,7 +540,7 @@ func (d *DynamicProvisioner) GetSkuValuesForLocation(ctx context.Context, locati
 
 	if len(skusForLocation) == 0 {
 		klog.Errorf("found no AMLFS SKUs for location %s", location)
-		return nil, status.Errorf(codes.Internal, "found no AMLFS SKUs for location %s", location)
+		return nil, false, status.Errorf(codes.Internal, "found no AMLFS SKUs for location %s", location)
 	}
 
 	for _, sku := range skusForLocation {
@@ -372,13 +564,14 @@ func (d *DynamicProvisioner) GetSkuValuesForLocation(ctx context.Context, locati
 		}
 		if !foundLocation {
 			klog.Errorf("could not find location info for sku %s in location %s", *sku.Name, location)
-			return nil, status.Errorf(codes.Internal, "could not find location info for sku %s in location %s", *sku.Name, location)
+			return nil, false, status.Errorf(codes.Internal, "could not find location info for sku %s in location %s", *sku.Name, location)
 		}
 		for _, capability := range sku.Capabilities {
 			if *capability.Name == AmlfsSkuCapacityIncrementName {
 				parsedValue, err := strconv.ParseInt(*capability.Value, 10, 64)
 				if err != nil {
 					klog.Errorf("failed to parse capability value: %v", err)
+					parseFailed = true
 					continue
 				}
 				incrementInTib = parsedValue
@@ -386,6 +579,7 @@ func (d *DynamicProvisioner) GetSkuValuesForLocation(ctx context.Context, locati
 				parsedValue, err := strconv.ParseInt(*capability.Value, 10, 64)
 				if err != nil {
 					klog.Errorf("failed to parse capability value: %v", err)
+					parseFailed = true
 					continue
 				}
 				maximumInTib = parsedValue
@@ -403,18 +597,22 @@ func (d *DynamicProvisioner) GetSkuValuesForLocation(ctx context.Context, locati
 
 	if len(skuValues) == 0 {
 		klog.Errorf("found no AMLFS SKUs for location %s", location)
-		return nil, status.Errorf(codes.Internal, "found no AMLFS SKUs for location %s", location)
+		return nil, false, status.Errorf(codes.Internal, "found no AMLFS SKUs for location %s", location)
 	}
 
-	return skuValues, nil
+	return skuValues, parseFailed, nil
 }
 
-func (d *DynamicProvisioner) getAmlfsSubnetSize(ctx context.Context, sku string, clusterSize float32) (int, error) {
-	if d.mgmtClient == nil {
+func (d *DynamicProvisioner) getAmlfsSubnetSize(ctx context.Context, subscriptionID, sku string, clusterSize float32) (int, error) {
+	clients, err := d.storageClientsFor(ctx, subscriptionID)
+	if err != nil {
+		return 0, err
+	}
+	if clients.mgmtClient == nil {
 		return 0, status.Error(codes.Internal, "storage management client is nil")
 	}
 
-	reqSize, err := d.mgmtClient.GetRequiredAmlFSSubnetsSize(ctx, &armstoragecache.ManagementClientGetRequiredAmlFSSubnetsSizeOptions{
+	reqSize, err := clients.mgmtClient.GetRequiredAmlFSSubnetsSize(ctx, &armstoragecache.ManagementClientGetRequiredAmlFSSubnetsSizeOptions{
 		RequiredAMLFilesystemSubnetsSizeInfo: &armstoragecache.RequiredAmlFilesystemSubnetsSizeInfo{
 			SKU: &armstoragecache.SKUName{
 				Name: to.Ptr(sku),
@@ -433,11 +631,16 @@ func (d *DynamicProvisioner) getAmlfsSubnetSize(ctx context.Context, sku string,
 	return int(*reqSize.FilesystemSubnetSize), nil
 }
 
-func (d *DynamicProvisioner) checkSubnetAddresses(ctx context.Context, vnetResourceGroup, vnetName, subnetID string) (int, error) {
-	if d.vnetClient == nil {
+func (d *DynamicProvisioner) checkSubnetAddresses(ctx context.Context, subnetInfo SubnetProperties) (int, error) {
+	clients, err := d.networkClientsFor(ctx, subnetInfo)
+	if err != nil {
+		return 0, err
+	}
+	if clients.vnetClient == nil {
 		return 0, status.Error(codes.Internal, "vnet client is nil")
 	}
-	usagesPager := d.vnetClient.NewListUsagePager(vnetResourceGroup, vnetName, nil)
+	vnetResourceGroup, vnetName, subnetID := subnetInfo.VnetResourceGroup, subnetInfo.VnetName, subnetInfo.SubnetID
+	usagesPager := clients.vnetClient.NewListUsagePager(vnetResourceGroup, vnetName, nil)
 
 	for usagesPager.More() {
 		page, err := usagesPager.NextPage(ctx)
@@ -459,23 +662,38 @@ func (d *DynamicProvisioner) checkSubnetAddresses(ctx context.Context, vnetResou
 	return 0, status.Errorf(codes.FailedPrecondition, "subnet %s not found in vnet %s, resource group %s. Ensure permissions are correct for configuration.", subnetID, vnetName, vnetResourceGroup)
 }
 
-func (d *DynamicProvisioner) CheckSubnetCapacity(ctx context.Context, subnetInfo SubnetProperties, sku string, clusterSize float32) (bool, error) {
-	requiredSubnetIPSize, err := d.getAmlfsSubnetSize(ctx, sku, clusterSize)
+// GetSubnetCapacity returns the number of IP addresses an AMLFS cluster of
+// the given SKU and size needs and the number available in the subnet, not
+// counting those reserved by the AMLFS creations in flight. The size the
+// cluster needs is looked up in its subscription, that of the driver when
+// empty.
+func (d *DynamicProvisioner) GetSubnetCapacity(ctx context.Context, subscriptionID string, subnetInfo SubnetProperties, sku string, clusterSize float32) (*SubnetCapacity, error) {
+	requiredSubnetIPSize, err := d.getAmlfsSubnetSize(ctx, subscriptionID, sku, clusterSize)
 	if err != nil {
 		klog.Errorf("error getting required subnet size: %v", err)
-		return false, convertHTTPResponseErrorToGrpcCodeError(err)
+		return nil, convertHTTPResponseErrorToGrpcCodeError(err)
 	}
 
-	availableIPs, err := d.checkSubnetAddresses(ctx, subnetInfo.VnetResourceGroup, subnetInfo.VnetName, subnetInfo.SubnetID)
+	availableIPs, err := d.checkSubnetAddresses(ctx, subnetInfo)
 	if err != nil {
 		klog.Errorf("error getting available IPs: %v", err)
-		return false, convertHTTPResponseErrorToGrpcCodeError(err)
+		return nil, convertHTTPResponseErrorToGrpcCodeError(err)
+	}
+
+	reservedIPs := d.reservedSubnetIPs(ctx, subnetInfo.SubnetID, availableIPs)
+	return &SubnetCapacity{RequiredIPs: requiredSubnetIPSize, AvailableIPs: availableIPs - reservedIPs, ReservedIPs: reservedIPs}, nil
+}
+
+func (d *DynamicProvisioner) CheckSubnetCapacity(ctx context.Context, subscriptionID string, subnetInfo SubnetProperties, sku string, clusterSize float32) (bool, error) {
+	subnetCapacity, err := d.GetSubnetCapacity(ctx, subscriptionID, subnetInfo, sku, clusterSize)
+	if err != nil {
+		return false, err
 	}
 
-	if requiredSubnetIPSize > availableIPs {
-		klog.Warningf("There is not enough room in the %s subnet to fit a %s SKU cluster: %v needed, %v available", subnetInfo.SubnetID, sku, requiredSubnetIPSize, availableIPs)
+	if subnetCapacity.RequiredIPs > subnetCapacity.AvailableIPs {
+		klog.Warningf("There is not enough room in the %s subnet to fit a %s SKU cluster: %v needed, %v available", subnetInfo.SubnetID, sku, subnetCapacity.RequiredIPs, subnetCapacity.AvailableIPs)
 		return false, nil
 	}
-	klog.V(2).Infof("There is enough room in the %s subnet to fit a %s SKU cluster: %v needed, %v available", subnetInfo.SubnetID, sku, requiredSubnetIPSize, availableIPs)
+	klog.V(2).Infof("There is enough room in the %s subnet to fit a %s SKU cluster: %v needed, %v available", subnetInfo.SubnetID, sku, subnetCapacity.RequiredIPs, subnetCapacity.AvailableIPs)
 	return true, nil
 }
@@ -3,6 +3,7 @@ package azurelustre
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"regexp"
 	"runtime"
@@ -31,6 +32,13 @@ type mockAmlfsRecorder struct {
 	fakeCallCount               []string
 }
 
+// nonRetriableError fails a request without the retries of the pipeline
+type nonRetriableError struct {
+	error
+}
+
+func (nonRetriableError) NonRetriable() {}
+
 const (
 	expectedMgsAddress                          = "127.0.0.3"
 	expectedResourceGroupName                   = "fake-resource-group"
@@ -58,6 +66,7 @@ const (
 	eventualInternalExecutionCreateFailureName  = "internal-execution-with-200-create-failure"
 	eventualAscInternalErrorCreateFailureName   = "asc-with-200-create-failure"
 	immediateDeleteFailureName                  = "immediate-delete-failure"
+	clusterListFailureName                      = "cluster-list-failure"
 	eventualDeleteFailureName                   = "eventual-delete-failure"
 	clusterGetImmediateFailureName              = "cluster-get-failure"
 	clusterGetRetryCheckFailureName             = "cluster-get-retry-check-failure"
@@ -74,6 +83,7 @@ const (
 	clusterIsFailed                             = "testClusterGetImmediatelyInternalError"
 	eventualClusterCreateTimeoutFailureName     = "testClusterShouldEventuallyTimeout"
 	clusterRequestRetryDeleteFailureName        = "testClusterShouldFailRetryDelete"
+	clusterPollingFailureName                   = "testClusterPollingFails"
 	clusterIsDeleting                           = "testClusterDeleting"
 
 	quickPollFrequency = 1 * time.Millisecond
@@ -568,6 +578,10 @@ func newFakeAmlFilesystemsServer(_ *testing.T, recorder *mockAmlfsRecorder) *fak
 			resp.SetTerminalError(http.StatusRequestTimeout, eventualCreateFailureName)
 			return resp, errResp
 		}
+		if nextFailureBehavior == clusterPollingFailureName {
+			resp.AddPollingError(nonRetriableError{errors.New("service unavailable")})
+			recorder.recordedAmlfsConfigurations[amlFilesystemName] = amlFilesystem
+		}
 		if nextFailureBehavior == eventualInternalExecutionCreateFailureName {
 			resp.SetTerminalError(http.StatusOK, "InternalExecutionError")
 			recorder.recordedAmlfsConfigurations[amlFilesystemName] = amlFilesystem
@@ -607,7 +621,7 @@ func newFakeAmlFilesystemsServer(_ *testing.T, recorder *mockAmlfsRecorder) *fak
 			}
 		}
 		if amlFilesystem == nil {
-			errResp.SetError(errors.New("ResourceNotFound"))
+			errResp.SetResponseError(http.StatusNotFound, "ResourceNotFound")
 			return resp, errResp
 		}
 		amlFilesystem.Properties.ProvisioningState = to.Ptr(armstoragecache.AmlFilesystemProvisioningStateTypeSucceeded)
@@ -631,6 +645,27 @@ func newFakeAmlFilesystemsServer(_ *testing.T, recorder *mockAmlfsRecorder) *fak
 			}, nil)
 		return resp, errResp
 	}
+
+	fakeAmlfsServer.NewListPager = func(_ *armstoragecache.AmlFilesystemsClientListOptions) azfake.PagerResponder[armstoragecache.AmlFilesystemsClientListResponse] {
+		recorder.recordFakeCall()
+		resp := azfake.PagerResponder[armstoragecache.AmlFilesystemsClientListResponse]{}
+		if getNextFailureBehavior(recorder) == clusterListFailureName {
+			resp.AddResponseError(http.StatusForbidden, "AuthorizationFailed")
+			return resp
+		}
+		for _, amlfs := range recorder.recordedAmlfsConfigurations {
+			amlfs.ID = to.Ptr(fmt.Sprintf("/subscriptions/fake-subscription-id/resourceGroups/%s/providers/Microsoft.StorageCache/amlFilesystems/%s",
+				expectedResourceGroupName, *amlfs.Name))
+			amlfs.Properties.ProvisioningState = to.Ptr(armstoragecache.AmlFilesystemProvisioningStateTypeSucceeded)
+			// One page per cluster
+			resp.AddPage(http.StatusOK, armstoragecache.AmlFilesystemsClientListResponse{
+				AmlFilesystemsListResult: armstoragecache.AmlFilesystemsListResult{
+					Value: []*armstoragecache.AmlFilesystem{&amlfs},
+				},
+			}, nil)
+		}
+		return resp
+	}
 	return &fakeAmlfsServer
 }
 
@@ -1193,7 +1228,7 @@ func TestDynamicProvisioner_CreateAmlFilesystem_Success_NoCapacityCheckIfCurrent
 	require.NoError(t, err)
 	require.Len(t, recorder.recordedAmlfsConfigurations, 1)
 
-	currentClusterState, err := dynamicProvisioner.currentClusterState(context.Background(), expectedResourceGroupName, expectedAmlFilesystemName)
+	currentClusterState, err := dynamicProvisioner.currentClusterState(context.Background(), "", expectedResourceGroupName, expectedAmlFilesystemName)
 	require.NoError(t, err)
 	require.Equal(t, ClusterStateExists, currentClusterState)
 
@@ -1226,7 +1261,7 @@ func TestDynamicProvisioner_DeleteAmlFilesystem_Success(t *testing.T) {
 	require.NoError(t, err)
 	require.Len(t, recorder.recordedAmlfsConfigurations, 1)
 
-	err = dynamicProvisioner.DeleteAmlFilesystem(context.Background(), expectedResourceGroupName, expectedAmlFilesystemName)
+	err = dynamicProvisioner.DeleteAmlFilesystem(context.Background(), "", expectedResourceGroupName, expectedAmlFilesystemName)
 
 	require.NoError(t, err)
 	assert.Empty(t, recorder.recordedAmlfsConfigurations)
@@ -1240,7 +1275,7 @@ func TestDynamicProvisioner_DeleteAmlFilesystem_Err_NilCLient(t *testing.T) {
 	dynamicProvisioner := newTestDynamicProvisioner(t, recorder)
 	dynamicProvisioner.amlFilesystemsClient = nil
 
-	err := dynamicProvisioner.DeleteAmlFilesystem(context.Background(), expectedResourceGroupName, expectedAmlFilesystemName)
+	err := dynamicProvisioner.DeleteAmlFilesystem(context.Background(), "", expectedResourceGroupName, expectedAmlFilesystemName)
 	require.ErrorContains(t, err, "aml filesystem client is nil")
 }
 
@@ -1253,7 +1288,7 @@ func TestDynamicProvisioner_DeleteAmlFilesystem_Err_Timeout(t *testing.T) {
 
 	ctx, cancel := context.WithDeadline(context.Background(), time.Now())
 	cancel()
-	err := dynamicProvisioner.DeleteAmlFilesystem(ctx, expectedResourceGroupName, expectedAmlFilesystemName)
+	err := dynamicProvisioner.DeleteAmlFilesystem(ctx, "", expectedResourceGroupName, expectedAmlFilesystemName)
 	require.Error(t, err)
 	grpcStatus, ok := status.FromError(err)
 	require.True(t, ok)
@@ -1279,7 +1314,7 @@ func TestDynamicProvisioner_DeleteAmlFilesystem_Err_ImmediateFailure(t *testing.
 	require.NoError(t, err)
 	require.Len(t, recorder.recordedAmlfsConfigurations, 1)
 
-	err = dynamicProvisioner.DeleteAmlFilesystem(context.Background(), expectedResourceGroupName, amlFilesystemName)
+	err = dynamicProvisioner.DeleteAmlFilesystem(context.Background(), "", expectedResourceGroupName, amlFilesystemName)
 	require.ErrorContains(t, err, immediateDeleteFailureName)
 	assert.Len(t, recorder.recordedAmlfsConfigurations, 1)
 }
@@ -1302,7 +1337,7 @@ func TestDynamicProvisioner_DeleteAmlFilesystem_Err_EventualFailure(t *testing.T
 	require.NoError(t, err)
 	require.Len(t, recorder.recordedAmlfsConfigurations, 1)
 
-	err = dynamicProvisioner.DeleteAmlFilesystem(context.Background(), expectedResourceGroupName, amlFilesystemName)
+	err = dynamicProvisioner.DeleteAmlFilesystem(context.Background(), "", expectedResourceGroupName, amlFilesystemName)
 	require.ErrorContains(t, err, eventualDeleteFailureName)
 	assert.Len(t, recorder.recordedAmlfsConfigurations, 1)
 }
@@ -1330,12 +1365,56 @@ func TestDynamicProvisioner_DeleteAmlFilesystem_Success_DeletesCorrectCluster(t
 	require.NoError(t, err)
 	require.Len(t, recorder.recordedAmlfsConfigurations, 2)
 
-	err = dynamicProvisioner.DeleteAmlFilesystem(context.Background(), expectedResourceGroupName, expectedAmlFilesystemName)
+	err = dynamicProvisioner.DeleteAmlFilesystem(context.Background(), "", expectedResourceGroupName, expectedAmlFilesystemName)
 	require.NoError(t, err)
 	require.Len(t, recorder.recordedAmlfsConfigurations, 1)
 	assert.Equal(t, otherAmlFilesystemName, *recorder.recordedAmlfsConfigurations[otherAmlFilesystemName].Name)
 }
 
+func TestDynamicProvisioner_ListAmlFilesystems_Success(t *testing.T) {
+	recorder := newMockAmlfsRecorder([]string{})
+	dynamicProvisioner := newTestDynamicProvisioner(t, recorder)
+	for _, amlFilesystemName := range []string{expectedAmlFilesystemName, expectedAmlFilesystemName + "2"} {
+		_, err := dynamicProvisioner.CreateAmlFilesystem(context.Background(), &AmlFilesystemProperties{
+			ResourceGroupName: expectedResourceGroupName,
+			AmlFilesystemName: amlFilesystemName,
+			SubnetInfo:        buildExpectedSubnetInfo(),
+			Tags:              map[string]string{createdByTag: azureLustreDriverTag},
+		})
+		require.NoError(t, err)
+	}
+
+	amlFilesystems, err := dynamicProvisioner.ListAmlFilesystems(context.Background(), "")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []*AmlFilesystemSummary{
+		{
+			ResourceGroupName: expectedResourceGroupName,
+			AmlFilesystemName: expectedAmlFilesystemName,
+			ProvisioningState: string(armstoragecache.AmlFilesystemProvisioningStateTypeSucceeded),
+			Tags:              map[string]string{createdByTag: azureLustreDriverTag},
+		},
+		{
+			ResourceGroupName: expectedResourceGroupName,
+			AmlFilesystemName: expectedAmlFilesystemName + "2",
+			ProvisioningState: string(armstoragecache.AmlFilesystemProvisioningStateTypeSucceeded),
+			Tags:              map[string]string{createdByTag: azureLustreDriverTag},
+		},
+	}, amlFilesystems)
+}
+
+func TestDynamicProvisioner_ListAmlFilesystems_Err(t *testing.T) {
+	recorder := newMockAmlfsRecorder([]string{clusterListFailureName})
+	dynamicProvisioner := newTestDynamicProvisioner(t, recorder)
+
+	_, err := dynamicProvisioner.ListAmlFilesystems(context.Background(), "")
+	require.Error(t, err)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	dynamicProvisioner.amlFilesystemsClient = nil
+	_, err = dynamicProvisioner.ListAmlFilesystems(context.Background(), "")
+	require.ErrorContains(t, err, "aml filesystem client is nil")
+}
+
 func TestDynamicProvisioner_CurrentClusterState_Success(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -1351,7 +1430,7 @@ func TestDynamicProvisioner_CurrentClusterState_Success(t *testing.T) {
 	require.NoError(t, err)
 	require.Len(t, recorder.recordedAmlfsConfigurations, 1)
 
-	currentClusterState, err := dynamicProvisioner.currentClusterState(context.Background(), expectedResourceGroupName, expectedAmlFilesystemName)
+	currentClusterState, err := dynamicProvisioner.currentClusterState(context.Background(), "", expectedResourceGroupName, expectedAmlFilesystemName)
 	require.NoError(t, err)
 	require.Equal(t, ClusterStateExists, currentClusterState)
 }
@@ -1364,7 +1443,7 @@ func TestDynamicProvisioner_CurrentClusterState_SuccessNotFound(t *testing.T) {
 	dynamicProvisioner := newTestDynamicProvisioner(t, recorder)
 	require.Empty(t, recorder.recordedAmlfsConfigurations)
 
-	currentClusterState, err := dynamicProvisioner.currentClusterState(context.Background(), expectedResourceGroupName, expectedAmlFilesystemName)
+	currentClusterState, err := dynamicProvisioner.currentClusterState(context.Background(), "", expectedResourceGroupName, expectedAmlFilesystemName)
 	require.NoError(t, err)
 	require.Equal(t, ClusterStateNotFound, currentClusterState)
 }
@@ -1378,7 +1457,7 @@ func TestDynamicProvisioner_CurrentClusterState_Err(t *testing.T) {
 	require.Empty(t, recorder.recordedAmlfsConfigurations)
 
 	amlFilesystemName := clusterGetImmediateFailureName
-	_, err := dynamicProvisioner.currentClusterState(context.Background(), expectedResourceGroupName, amlFilesystemName)
+	_, err := dynamicProvisioner.currentClusterState(context.Background(), "", expectedResourceGroupName, amlFilesystemName)
 	assert.ErrorContains(t, err, clusterGetImmediateFailureName)
 }
 
@@ -1390,7 +1469,7 @@ func TestDynamicProvisioner_CurrentClusterState_ErrNilClient(t *testing.T) {
 	dynamicProvisioner := newTestDynamicProvisioner(t, recorder)
 	dynamicProvisioner.amlFilesystemsClient = nil
 
-	_, err := dynamicProvisioner.currentClusterState(context.Background(), expectedResourceGroupName, expectedAmlFilesystemName)
+	_, err := dynamicProvisioner.currentClusterState(context.Background(), "", expectedResourceGroupName, expectedAmlFilesystemName)
 	assert.ErrorContains(t, err, "aml filesystem client is nil")
 }
 
@@ -1401,7 +1480,7 @@ func TestDynamicProvisioner_CheckSubnetCapacity_Success(t *testing.T) {
 	recorder := newMockAmlfsRecorder([]string{})
 	dynamicProvisioner := newTestDynamicProvisioner(t, recorder)
 
-	hasSufficientCapacity, err := dynamicProvisioner.CheckSubnetCapacity(context.Background(), buildExpectedSubnetInfo(), expectedSku, expectedClusterSize)
+	hasSufficientCapacity, err := dynamicProvisioner.CheckSubnetCapacity(context.Background(), "", buildExpectedSubnetInfo(), expectedSku, expectedClusterSize)
 	require.NoError(t, err)
 	assert.True(t, hasSufficientCapacity)
 }
@@ -1415,7 +1494,7 @@ func TestDynamicProvisioner_CheckSubnetCapacity_FullVnet(t *testing.T) {
 
 	subnetInfo := buildExpectedSubnetInfo()
 	subnetInfo.VnetName = fullVnetName
-	hasSufficientCapacity, err := dynamicProvisioner.CheckSubnetCapacity(context.Background(), subnetInfo, expectedSku, expectedClusterSize)
+	hasSufficientCapacity, err := dynamicProvisioner.CheckSubnetCapacity(context.Background(), "", subnetInfo, expectedSku, expectedClusterSize)
 	require.NoError(t, err)
 	assert.False(t, hasSufficientCapacity)
 }
@@ -1428,7 +1507,7 @@ func TestDynamicProvisioner_CheckSubnetCapacity_Err_NilMgmtClient(t *testing.T)
 	dynamicProvisioner := newTestDynamicProvisioner(t, recorder)
 	dynamicProvisioner.mgmtClient = nil
 
-	_, err := dynamicProvisioner.CheckSubnetCapacity(context.Background(), buildExpectedSubnetInfo(), expectedSku, expectedClusterSize)
+	_, err := dynamicProvisioner.CheckSubnetCapacity(context.Background(), "", buildExpectedSubnetInfo(), expectedSku, expectedClusterSize)
 	assert.ErrorContains(t, err, "storage management client is nil")
 }
 
@@ -1440,7 +1519,7 @@ func TestDynamicProvisioner_CheckSubnetCapacity_Err_NilVnetClient(t *testing.T)
 	dynamicProvisioner := newTestDynamicProvisioner(t, recorder)
 	dynamicProvisioner.vnetClient = nil
 
-	_, err := dynamicProvisioner.CheckSubnetCapacity(context.Background(), buildExpectedSubnetInfo(), expectedSku, expectedClusterSize)
+	_, err := dynamicProvisioner.CheckSubnetCapacity(context.Background(), "", buildExpectedSubnetInfo(), expectedSku, expectedClusterSize)
 	assert.ErrorContains(t, err, "vnet client is nil")
 }
 
@@ -1485,7 +1564,7 @@ func TestDynamicProvisioner_CheckSubnetCapacity_Err(t *testing.T) {
 			dynamicProvisioner := newTestDynamicProvisioner(t, recorder)
 			require.Empty(t, recorder.recordedAmlfsConfigurations)
 
-			_, err := dynamicProvisioner.CheckSubnetCapacity(context.Background(), tC.subnetProperties, tC.sku, expectedClusterSize)
+			_, err := dynamicProvisioner.CheckSubnetCapacity(context.Background(), "", tC.subnetProperties, tC.sku, expectedClusterSize)
 			assert.ErrorContains(t, err, tC.expectedError)
 		})
 	}
@@ -1504,7 +1583,7 @@ func TestDynamicProvisioner_GetSkuValuesForLocation_Success(t *testing.T) {
 		otherSkuForLocation: {IncrementInTib: 4, MaximumInTib: 128, AvailableZones: expectedZones},
 	}
 
-	skuValues, err := dynamicProvisioner.GetSkuValuesForLocation(context.Background(), expectedLocation)
+	skuValues, err := dynamicProvisioner.GetSkuValuesForLocation(context.Background(), "", expectedLocation)
 	t.Logf("SKU values: %#v", skuValues)
 	require.NoError(t, err)
 	require.Len(t, skuValues, 2)
@@ -1519,7 +1598,7 @@ func TestDynamicProvisioner_GetSkuValuesForLocation_Err_NilClient(t *testing.T)
 	dynamicProvisioner := newTestDynamicProvisioner(t, recorder)
 	dynamicProvisioner.skusClient = nil
 
-	skuValues, err := dynamicProvisioner.GetSkuValuesForLocation(context.Background(), expectedLocation)
+	skuValues, err := dynamicProvisioner.GetSkuValuesForLocation(context.Background(), "", expectedLocation)
 	t.Log(err)
 	require.Nil(t, skuValues)
 	require.Error(t, err)
@@ -1537,7 +1616,7 @@ func TestDynamicProvisioner_GetSkuValuesForLocation_NoZonesAvailable(t *testing.
 	recorder := newMockAmlfsRecorder([]string{noZonesForLocation})
 	dynamicProvisioner := newTestDynamicProvisioner(t, recorder)
 
-	skuValues, err := dynamicProvisioner.GetSkuValuesForLocation(context.Background(), expectedLocation)
+	skuValues, err := dynamicProvisioner.GetSkuValuesForLocation(context.Background(), "", expectedLocation)
 	t.Log(skuValues)
 	require.NoError(t, err)
 	require.Len(t, skuValues, 1)
@@ -1595,7 +1674,7 @@ func TestDynamicProvisioner_GetSkuValuesForLocation_Errors(t *testing.T) {
 			recorder := newMockAmlfsRecorder(tC.failureBehaviors)
 			dynamicProvisioner := newTestDynamicProvisioner(t, recorder)
 
-			skuValues, err := dynamicProvisioner.GetSkuValuesForLocation(context.Background(), expectedLocation)
+			skuValues, err := dynamicProvisioner.GetSkuValuesForLocation(context.Background(), "", expectedLocation)
 			require.Nil(t, skuValues)
 			t.Log(err)
 			require.Error(t, err)
@@ -1624,6 +1703,11 @@ func TestConvertStatusCodeErrorToGrpcCodeError(t *testing.T) {
 			inputError:   &azcore.ResponseError{StatusCode: http.StatusConflict, ErrorCode: "Operation results in exceeding quota limits of resource type AmlFilesystem"},
 			expectedCode: codes.ResourceExhausted,
 		},
+		{
+			name:         "Conflict with QuotaExceeded error code",
+			inputError:   &azcore.ResponseError{StatusCode: http.StatusConflict, ErrorCode: "QuotaExceeded"},
+			expectedCode: codes.ResourceExhausted,
+		},
 		{
 			name:         "Conflict without quota limit exceeded",
 			inputError:   &azcore.ResponseError{StatusCode: http.StatusConflict},
@@ -0,0 +1,412 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"maps"
+	"math"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storagecache/armstoragecache/v4"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+	azure "sigs.k8s.io/cloud-provider-azure/pkg/provider"
+)
+
+const (
+	// mockAmlFilesystemCreateLatency and mockAmlFilesystemDeleteLatency are
+	// how long the mock AMLFS clusters take to be created and deleted
+	mockAmlFilesystemCreateLatency = 5 * time.Second
+	mockAmlFilesystemDeleteLatency = 2 * time.Second
+	// mockAmlFilesystemQuotaLimit is the quota of AMLFS clusters of each
+	// subscription in each location
+	mockAmlFilesystemQuotaLimit = 10
+	// mockFailCreationTag makes the first creation of the AMLFS clusters
+	// tagged with it end in the Failed state, e.g. with the storage class
+	// parameter tags: azurelustre-mock-fail-creation=true
+	mockFailCreationTag = "azurelustre-mock-fail-creation"
+
+	// The mock subnets are /24 address blocks with the first four and the
+	// last addresses reserved, as in Azure
+	mockSubnetFirstIP = 4
+	mockSubnetLastIP  = 254
+	// A mock AMLFS cluster needs mockRequiredIPsBase IP addresses, and
+	// mockRequiredIPsPerIncrement more for each capacity increment of its SKU
+	mockRequiredIPsBase         = 8
+	mockRequiredIPsPerIncrement = 2
+
+	// The defaults of the mock cloud config, used by the storage classes
+	// leaving out the location, resource groups, vnet or subnet
+	mockSubscriptionID    = "00000000-0000-0000-0000-000000000000"
+	mockLocation          = "mock-location"
+	mockResourceGroup     = "mock-rg"
+	mockVnetResourceGroup = "mock-vnet-rg"
+	mockVnetName          = "mock-vnet"
+	mockSubnetName        = "mock-subnet"
+)
+
+// mockDynamicProvisioner is the in-memory AMLFS backend of the mock dynamic
+// provisioning, used when the driver has no cloud config. It simulates the
+// SKUs and zones of the locations, the IP addresses of the subnets, the quota
+// of AMLFS clusters and the latency and failures of their creation and
+// deletion, so that dynamic provisioning can be exercised without Azure.
+type mockDynamicProvisioner struct {
+	lock sync.Mutex
+	// amlFilesystems are the clusters by subscription, resource group and
+	// name, subnets the address block index of the subnets by ID
+	amlFilesystems map[string]*mockAmlFilesystem
+	subnets        map[string]int
+	// failedCreations are the clusters whose creation already failed once
+	failedCreations map[string]bool
+
+	createLatency time.Duration
+	deleteLatency time.Duration
+	quotaLimit    int
+}
+
+// mockAmlFilesystem is a mock AMLFS cluster, which holds the IP addresses
+// [firstIP, firstIP+requiredIPs) of its subnet
+type mockAmlFilesystem struct {
+	subscriptionID    string
+	resourceGroupName string
+	name              string
+	location          string
+	tags              map[string]string
+	subnetInfo        SubnetProperties
+	firstIP           int
+	requiredIPs       int
+	mgsAddress        string
+	state             armstoragecache.AmlFilesystemProvisioningStateType
+	failCreation      bool
+	// transitionAt is when the creation or deletion in progress completes
+	transitionAt time.Time
+}
+
+// newMockAzureClients returns the Azure clients of the driver without a cloud
+// config, the mock dynamic provisioner and the defaults of a mock cloud
+// config
+func newMockAzureClients() *azureClients {
+	az := &azure.Cloud{}
+	az.SubscriptionID = mockSubscriptionID
+	az.Location = mockLocation
+	az.ResourceGroup = mockResourceGroup
+	az.VnetResourceGroup = mockVnetResourceGroup
+	az.VnetName = mockVnetName
+	az.SubnetName = mockSubnetName
+	return &azureClients{
+		cloud:              az,
+		resourceGroup:      mockResourceGroup,
+		location:           mockLocation,
+		dynamicProvisioner: newMockDynamicProvisioner(),
+	}
+}
+
+func newMockDynamicProvisioner() *mockDynamicProvisioner {
+	return &mockDynamicProvisioner{
+		amlFilesystems:  map[string]*mockAmlFilesystem{},
+		subnets:         map[string]int{},
+		failedCreations: map[string]bool{},
+		createLatency:   mockAmlFilesystemCreateLatency,
+		deleteLatency:   mockAmlFilesystemDeleteLatency,
+		quotaLimit:      mockAmlFilesystemQuotaLimit,
+	}
+}
+
+func mockAmlFilesystemKey(subscriptionID, resourceGroupName, amlFilesystemName string) string {
+	return strings.ToLower(subscriptionID + "/" + resourceGroupName + "/" + amlFilesystemName)
+}
+
+// mockSkuValues are the AMLFS SKUs of every location
+func mockSkuValues() map[string]*LustreSkuValue {
+	zones := []string{"1", "2", "3"}
+	return map[string]*LustreSkuValue{
+		"AMLFS-Durable-Premium-40":  {IncrementInTib: 48, MaximumInTib: 768, AvailableZones: slices.Clone(zones)},
+		"AMLFS-Durable-Premium-125": {IncrementInTib: 16, MaximumInTib: 128, AvailableZones: slices.Clone(zones)},
+		"AMLFS-Durable-Premium-250": {IncrementInTib: 8, MaximumInTib: 128, AvailableZones: slices.Clone(zones)},
+		"AMLFS-Durable-Premium-500": {IncrementInTib: 4, MaximumInTib: 128, AvailableZones: slices.Clone(zones)},
+	}
+}
+
+func (m *mockDynamicProvisioner) GetSkuValuesForLocation(_ context.Context, _, _ string) (map[string]*LustreSkuValue, error) {
+	return mockSkuValues(), nil
+}
+
+func (m *mockDynamicProvisioner) GetSubnetCapacity(_ context.Context, _ string, subnetInfo SubnetProperties, sku string, clusterSize float32) (*SubnetCapacity, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.refreshLocked()
+	return m.subnetCapacityLocked(subnetInfo, sku, clusterSize)
+}
+
+func (m *mockDynamicProvisioner) ListAmlFilesystems(_ context.Context, subscriptionID string) ([]*AmlFilesystemSummary, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.refreshLocked()
+	amlFilesystems := make([]*AmlFilesystemSummary, 0, len(m.amlFilesystems))
+	for _, key := range slices.Sorted(maps.Keys(m.amlFilesystems)) {
+		amlFilesystem := m.amlFilesystems[key]
+		if !strings.EqualFold(amlFilesystem.subscriptionID, subscriptionID) {
+			continue
+		}
+		amlFilesystems = append(amlFilesystems, &AmlFilesystemSummary{
+			SubscriptionID:    subscriptionID,
+			ResourceGroupName: amlFilesystem.resourceGroupName,
+			AmlFilesystemName: amlFilesystem.name,
+			ProvisioningState: string(amlFilesystem.state),
+			Tags:              maps.Clone(amlFilesystem.tags),
+		})
+	}
+	return amlFilesystems, nil
+}
+
+func (m *mockDynamicProvisioner) CreateAmlFilesystem(ctx context.Context, amlFilesystemProperties *AmlFilesystemProperties) (string, error) {
+	subnets := amlFilesystemProperties.candidateSubnets
+	if len(subnets) == 0 {
+		subnets = []SubnetProperties{amlFilesystemProperties.SubnetInfo}
+	}
+	for _, subnetInfo := range subnets {
+		if subnetInfo.SubnetID == "" || subnetInfo.SubnetName == "" || subnetInfo.VnetName == "" || subnetInfo.VnetResourceGroup == "" {
+			return "", status.Error(codes.InvalidArgument, "invalid subnet info, must have valid subnet ID, subnet name, vnet name, and vnet resource group")
+		}
+	}
+
+	key := mockAmlFilesystemKey(amlFilesystemProperties.SubscriptionID, amlFilesystemProperties.ResourceGroupName, amlFilesystemProperties.AmlFilesystemName)
+	for {
+		m.lock.Lock()
+		m.refreshLocked()
+		amlFilesystem := m.amlFilesystems[key]
+		if amlFilesystem == nil {
+			var err error
+			amlFilesystem, err = m.startCreationLocked(ctx, key, amlFilesystemProperties)
+			if err != nil {
+				m.lock.Unlock()
+				return "", err
+			}
+		}
+		state, transitionAt := amlFilesystem.state, amlFilesystem.transitionAt
+		subnetInfo, mgsAddress := amlFilesystem.subnetInfo, amlFilesystem.mgsAddress
+		m.lock.Unlock()
+
+		switch state { //nolint:exhaustive // The mock clusters are only in these states
+		case armstoragecache.AmlFilesystemProvisioningStateTypeCreating:
+			if err := waitUntil(ctx, transitionAt); err != nil {
+				return "", err
+			}
+		case armstoragecache.AmlFilesystemProvisioningStateTypeDeleting:
+			return "", status.Errorf(codes.Aborted, "AMLFS cluster %s creation did not complete correctly, waiting for deletion to complete before retrying cluster creation",
+				amlFilesystemProperties.AmlFilesystemName)
+		case armstoragecache.AmlFilesystemProvisioningStateTypeFailed:
+			amlFilesystemProperties.progress.record(eventReasonAmlFilesystemRetrying,
+				"AMLFS cluster %s failed to create after %v, deleting it before retrying", amlFilesystemProperties.AmlFilesystemName, amlFilesystemProperties.progress.elapsed())
+			if err := m.DeleteAmlFilesystem(ctx, amlFilesystemProperties.SubscriptionID,
+				amlFilesystemProperties.ResourceGroupName, amlFilesystemProperties.AmlFilesystemName); err != nil {
+				return "", err
+			}
+			return "", status.Errorf(codes.Aborted, "AMLFS cluster %s creation timed out. Deleted failed cluster, retrying cluster creation", amlFilesystemProperties.AmlFilesystemName)
+		default:
+			amlFilesystemProperties.SubnetInfo = subnetInfo
+			return mgsAddress, nil
+		}
+	}
+}
+
+// startCreationLocked checks the quota and the subnet of a new cluster and
+// starts creating it
+func (m *mockDynamicProvisioner) startCreationLocked(ctx context.Context, key string, amlFilesystemProperties *AmlFilesystemProperties) (*mockAmlFilesystem, error) {
+	current := 0
+	for _, amlFilesystem := range m.amlFilesystems {
+		if strings.EqualFold(amlFilesystem.subscriptionID, amlFilesystemProperties.SubscriptionID) &&
+			strings.EqualFold(amlFilesystem.location, amlFilesystemProperties.Location) {
+			current++
+		}
+	}
+	if current+1 > m.quotaLimit {
+		return nil, status.Errorf(codes.ResourceExhausted,
+			"cannot create AMLFS cluster in location %s, quota AmlFilesystem would be exceeded: current usage %d, limit %d, required 1 (Count)",
+			amlFilesystemProperties.Location, current, m.quotaLimit)
+	}
+
+	if len(amlFilesystemProperties.candidateSubnets) > 0 {
+		subnetInfo, _, err := chooseSubnet(ctx, amlFilesystemProperties,
+			func(_ context.Context, _ string, subnetInfo SubnetProperties, sku string, clusterSize float32) (*SubnetCapacity, error) {
+				return m.subnetCapacityLocked(subnetInfo, sku, clusterSize)
+			})
+		if err != nil {
+			return nil, err
+		}
+		amlFilesystemProperties.SubnetInfo = subnetInfo
+	}
+	subnetInfo := amlFilesystemProperties.SubnetInfo
+	subnetCapacity, err := m.subnetCapacityLocked(subnetInfo, amlFilesystemProperties.SKUName, amlFilesystemProperties.StorageCapacityTiB)
+	if err != nil {
+		return nil, err
+	}
+	firstIP, ok := m.allocateLocked(subnetInfo.SubnetID, subnetCapacity.RequiredIPs)
+	if !ok {
+		return nil, status.Errorf(codes.ResourceExhausted,
+			"cannot create AMLFS cluster %s in subnet %s, not enough IP addresses available: %d needed, %d available, %d reserved by other AMLFS creations",
+			amlFilesystemProperties.AmlFilesystemName, subnetInfo.SubnetID,
+			subnetCapacity.RequiredIPs, subnetCapacity.AvailableIPs, subnetCapacity.ReservedIPs)
+	}
+
+	failCreation := strings.EqualFold(amlFilesystemProperties.Tags[mockFailCreationTag], "true") && !m.failedCreations[key]
+	if failCreation {
+		m.failedCreations[key] = true
+	}
+	amlFilesystem := &mockAmlFilesystem{
+		subscriptionID:    amlFilesystemProperties.SubscriptionID,
+		resourceGroupName: amlFilesystemProperties.ResourceGroupName,
+		name:              amlFilesystemProperties.AmlFilesystemName,
+		location:          amlFilesystemProperties.Location,
+		tags:              maps.Clone(amlFilesystemProperties.Tags),
+		subnetInfo:        subnetInfo,
+		firstIP:           firstIP,
+		requiredIPs:       subnetCapacity.RequiredIPs,
+		mgsAddress:        m.subnetAddressLocked(subnetInfo.SubnetID, firstIP).String(),
+		state:             armstoragecache.AmlFilesystemProvisioningStateTypeCreating,
+		failCreation:      failCreation,
+		transitionAt:      time.Now().Add(m.createLatency),
+	}
+	m.amlFilesystems[key] = amlFilesystem
+	amlFilesystemProperties.progress.record(eventReasonAmlFilesystemCreating,
+		"Started creating AMLFS cluster %s with SKU %s and %v TiB in resource group %s",
+		amlFilesystemProperties.AmlFilesystemName, amlFilesystemProperties.SKUName,
+		amlFilesystemProperties.StorageCapacityTiB, amlFilesystemProperties.ResourceGroupName)
+	klog.V(2).Infof("creating mock AMLFS cluster %s with MGS address %s in subnet %s", amlFilesystem.name, amlFilesystem.mgsAddress, subnetInfo.SubnetID)
+	return amlFilesystem, nil
+}
+
+func (m *mockDynamicProvisioner) DeleteAmlFilesystem(ctx context.Context, subscriptionID, resourceGroupName, amlFilesystemName string) error {
+	m.lock.Lock()
+	m.refreshLocked()
+	amlFilesystem := m.amlFilesystems[mockAmlFilesystemKey(subscriptionID, resourceGroupName, amlFilesystemName)]
+	if amlFilesystem == nil {
+		m.lock.Unlock()
+		klog.V(2).Infof("mock AMLFS cluster %s not found, nothing to delete", amlFilesystemName)
+		return nil
+	}
+	if amlFilesystem.state != armstoragecache.AmlFilesystemProvisioningStateTypeDeleting {
+		amlFilesystem.state = armstoragecache.AmlFilesystemProvisioningStateTypeDeleting
+		amlFilesystem.transitionAt = time.Now().Add(m.deleteLatency)
+	}
+	transitionAt := amlFilesystem.transitionAt
+	m.lock.Unlock()
+	return waitUntil(ctx, transitionAt)
+}
+
+// refreshLocked completes the creations and deletions whose time has come
+func (m *mockDynamicProvisioner) refreshLocked() {
+	now := time.Now()
+	for key, amlFilesystem := range m.amlFilesystems {
+		if now.Before(amlFilesystem.transitionAt) {
+			continue
+		}
+		switch amlFilesystem.state { //nolint:exhaustive // Only creations and deletions complete
+		case armstoragecache.AmlFilesystemProvisioningStateTypeCreating:
+			amlFilesystem.state = armstoragecache.AmlFilesystemProvisioningStateTypeSucceeded
+			if amlFilesystem.failCreation {
+				amlFilesystem.state = armstoragecache.AmlFilesystemProvisioningStateTypeFailed
+			}
+		case armstoragecache.AmlFilesystemProvisioningStateTypeDeleting:
+			delete(m.amlFilesystems, key)
+		}
+	}
+}
+
+// subnetCapacityLocked returns the IP addresses a cluster needs and those
+// free in the subnet, the addresses of the clusters being created counting
+// as reserved
+func (m *mockDynamicProvisioner) subnetCapacityLocked(subnetInfo SubnetProperties, sku string, clusterSize float32) (*SubnetCapacity, error) {
+	skuValue, ok := mockSkuValues()[sku]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "error occurred calling API: AMLFS SKU %s is not available", sku)
+	}
+	increments := max(1, int(math.Ceil(float64(clusterSize)/float64(skuValue.IncrementInTib))))
+	requiredIPs := mockRequiredIPsBase + mockRequiredIPsPerIncrement*increments
+
+	usedIPs, reservedIPs := 0, 0
+	for _, amlFilesystem := range m.amlFilesystems {
+		if !strings.EqualFold(amlFilesystem.subnetInfo.SubnetID, subnetInfo.SubnetID) {
+			continue
+		}
+		if amlFilesystem.state == armstoragecache.AmlFilesystemProvisioningStateTypeCreating {
+			reservedIPs += amlFilesystem.requiredIPs
+		} else {
+			usedIPs += amlFilesystem.requiredIPs
+		}
+	}
+	availableIPs := mockSubnetLastIP - mockSubnetFirstIP + 1 - usedIPs - reservedIPs
+	return &SubnetCapacity{RequiredIPs: requiredIPs, AvailableIPs: availableIPs, ReservedIPs: reservedIPs}, nil
+}
+
+// allocateLocked returns the first of the lowest free range of count IP
+// addresses in the subnet
+func (m *mockDynamicProvisioner) allocateLocked(subnetID string, count int) (int, bool) {
+	var used [][2]int
+	for _, amlFilesystem := range m.amlFilesystems {
+		if strings.EqualFold(amlFilesystem.subnetInfo.SubnetID, subnetID) {
+			used = append(used, [2]int{amlFilesystem.firstIP, amlFilesystem.firstIP + amlFilesystem.requiredIPs})
+		}
+	}
+	slices.SortFunc(used, func(a, b [2]int) int { return a[0] - b[0] })
+	firstIP := mockSubnetFirstIP
+	for _, ips := range used {
+		if ips[0]-firstIP >= count {
+			break
+		}
+		firstIP = max(firstIP, ips[1])
+	}
+	if firstIP+count-1 > mockSubnetLastIP {
+		return 0, false
+	}
+	return firstIP, true
+}
+
+// subnetAddressLocked returns an IP address of the subnet, whose address
+// block is assigned in the order the subnets are first used
+func (m *mockDynamicProvisioner) subnetAddressLocked(subnetID string, ip int) netip.Addr {
+	subnetKey := strings.ToLower(subnetID)
+	block, ok := m.subnets[subnetKey]
+	if !ok {
+		block = len(m.subnets) + 1
+		m.subnets[subnetKey] = block
+	}
+	return netip.AddrFrom4([4]byte{10, byte(block >> 8), byte(block), byte(ip)})
+}
+
+// waitUntil waits for the time given, or fails like an ARM poller when the
+// context is done first
+func waitUntil(ctx context.Context, until time.Time) error {
+	delay := time.Until(until)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return convertHTTPResponseErrorToGrpcCodeError(ctx.Err())
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storagecache/armstoragecache/v4"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newTestMockDynamicProvisioner() *mockDynamicProvisioner {
+	m := newMockDynamicProvisioner()
+	m.createLatency = 20 * time.Millisecond
+	m.deleteLatency = 10 * time.Millisecond
+	return m
+}
+
+func mockSubnet(name string) SubnetProperties {
+	return SubnetProperties{
+		VnetResourceGroup: "vnet-rg",
+		VnetName:          "vnet",
+		SubnetName:        name,
+		SubnetID:          fmt.Sprintf(subnetTemplate, "sub", "vnet-rg", "vnet", name),
+	}
+}
+
+func mockAmlFilesystemProperties(name string, subnetInfo SubnetProperties) *AmlFilesystemProperties {
+	return &AmlFilesystemProperties{
+		ResourceGroupName:  "rg",
+		AmlFilesystemName:  name,
+		Location:           "mock-location",
+		SKUName:            "AMLFS-Durable-Premium-40",
+		StorageCapacityTiB: 48,
+		SubnetInfo:         subnetInfo,
+		Tags:               map[string]string{},
+	}
+}
+
+func TestMockDynamicProvisioner(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMockDynamicProvisioner()
+	subnet := mockSubnet("subnet")
+
+	skus, err := m.GetSkuValuesForLocation(ctx, "", "mock-location")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2", "3"}, skus["AMLFS-Durable-Premium-40"].AvailableZones)
+	capacity, err := m.GetSubnetCapacity(ctx, "", subnet, "AMLFS-Durable-Premium-40", 96)
+	require.NoError(t, err)
+	assert.Equal(t, &SubnetCapacity{RequiredIPs: 12, AvailableIPs: 251}, capacity)
+	_, err = m.GetSubnetCapacity(ctx, "", subnet, "AMLFS-Unknown", 96)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// The IP addresses of a cluster being created are reserved
+	creationCtx, cancel := context.WithTimeout(ctx, time.Millisecond)
+	defer cancel()
+	_, err = m.CreateAmlFilesystem(creationCtx, mockAmlFilesystemProperties("amlfs-1", subnet))
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	amlFilesystems, err := m.ListAmlFilesystems(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, amlFilesystems, 1)
+	assert.Equal(t, string(armstoragecache.AmlFilesystemProvisioningStateTypeCreating), amlFilesystems[0].ProvisioningState)
+	capacity, err = m.GetSubnetCapacity(ctx, "", subnet, "AMLFS-Durable-Premium-40", 48)
+	require.NoError(t, err)
+	assert.Equal(t, &SubnetCapacity{RequiredIPs: 10, AvailableIPs: 241, ReservedIPs: 10}, capacity)
+
+	// The retried creation waits for the one in progress
+	mgsAddress, err := m.CreateAmlFilesystem(ctx, mockAmlFilesystemProperties("amlfs-1", subnet))
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.1.4", mgsAddress)
+	mgsAddress, err = m.CreateAmlFilesystem(ctx, mockAmlFilesystemProperties("amlfs-2", subnet))
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.1.14", mgsAddress)
+	mgsAddress, err = m.CreateAmlFilesystem(ctx, mockAmlFilesystemProperties("amlfs-3", mockSubnet("other-subnet")))
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.2.4", mgsAddress)
+	capacity, err = m.GetSubnetCapacity(ctx, "", subnet, "AMLFS-Durable-Premium-40", 48)
+	require.NoError(t, err)
+	assert.Equal(t, &SubnetCapacity{RequiredIPs: 10, AvailableIPs: 231}, capacity)
+
+	// The IP addresses of a deleted cluster are handed out again
+	require.NoError(t, m.DeleteAmlFilesystem(ctx, "", "rg", "amlfs-1"))
+	require.NoError(t, m.DeleteAmlFilesystem(ctx, "", "rg", "amlfs-1"))
+	amlFilesystems, err = m.ListAmlFilesystems(ctx, "")
+	require.NoError(t, err)
+	assert.Len(t, amlFilesystems, 2)
+	mgsAddress, err = m.CreateAmlFilesystem(ctx, mockAmlFilesystemProperties("amlfs-4", subnet))
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.1.4", mgsAddress)
+
+	// Until the subnet is full
+	properties := mockAmlFilesystemProperties("amlfs-5", subnet)
+	properties.SKUName = "AMLFS-Durable-Premium-500"
+	properties.StorageCapacityTiB = 128
+	_, err = m.CreateAmlFilesystem(ctx, properties)
+	require.NoError(t, err)
+	properties.AmlFilesystemName = "amlfs-6"
+	_, err = m.CreateAmlFilesystem(ctx, properties)
+	require.NoError(t, err)
+	properties.AmlFilesystemName = "amlfs-7"
+	_, err = m.CreateAmlFilesystem(ctx, properties)
+	require.NoError(t, err)
+	properties.AmlFilesystemName = "amlfs-8"
+	_, err = m.CreateAmlFilesystem(ctx, properties)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	require.ErrorContains(t, err, "not enough IP addresses available: 72 needed, 15 available")
+}
+
+func TestMockDynamicProvisioner_CandidateSubnets(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMockDynamicProvisioner()
+	properties := mockAmlFilesystemProperties("amlfs", SubnetProperties{})
+	properties.candidateSubnets = []SubnetProperties{mockSubnet("subnet-a"), mockSubnet("subnet-b")}
+
+	mgsAddress, err := m.CreateAmlFilesystem(ctx, properties)
+	require.NoError(t, err)
+	assert.Equal(t, "subnet-a", properties.SubnetInfo.SubnetName)
+	assert.Equal(t, "10.0.1.4", mgsAddress)
+
+	_, err = m.CreateAmlFilesystem(ctx, mockAmlFilesystemProperties("invalid", SubnetProperties{SubnetName: "subnet"}))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestMockDynamicProvisioner_Quota(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMockDynamicProvisioner()
+	m.quotaLimit = 1
+
+	_, err := m.CreateAmlFilesystem(ctx, mockAmlFilesystemProperties("amlfs-1", mockSubnet("subnet")))
+	require.NoError(t, err)
+	_, err = m.CreateAmlFilesystem(ctx, mockAmlFilesystemProperties("amlfs-2", mockSubnet("subnet")))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	require.ErrorContains(t, err, "quota AmlFilesystem would be exceeded: current usage 1, limit 1, required 1 (Count)")
+
+	properties := mockAmlFilesystemProperties("amlfs-2", mockSubnet("subnet"))
+	properties.Location = "other-location"
+	_, err = m.CreateAmlFilesystem(ctx, properties)
+	require.NoError(t, err)
+}
+
+func TestMockDynamicProvisioner_FailedCreation(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMockDynamicProvisioner()
+	properties := mockAmlFilesystemProperties("amlfs", mockSubnet("subnet"))
+	properties.Tags[mockFailCreationTag] = "true"
+
+	// The failed cluster is deleted before the creation is retried
+	_, err := m.CreateAmlFilesystem(ctx, properties)
+	assert.Equal(t, codes.Aborted, status.Code(err))
+	require.ErrorContains(t, err, "Deleted failed cluster, retrying cluster creation")
+	amlFilesystems, err := m.ListAmlFilesystems(ctx, "")
+	require.NoError(t, err)
+	assert.Empty(t, amlFilesystems)
+
+	mgsAddress, err := m.CreateAmlFilesystem(ctx, properties)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.1.4", mgsAddress)
+
+	// A creation retried while the cluster is being deleted waits for it
+	m.deleteLatency = time.Hour
+	deleteCtx, cancel := context.WithTimeout(ctx, time.Millisecond)
+	defer cancel()
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(m.DeleteAmlFilesystem(deleteCtx, "", "rg", "amlfs")))
+	_, err = m.CreateAmlFilesystem(ctx, properties)
+	assert.Equal(t, codes.Aborted, status.Code(err))
+	require.ErrorContains(t, err, "waiting for deletion to complete")
+	amlFilesystems, err = m.ListAmlFilesystems(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, amlFilesystems, 1)
+	assert.Equal(t, string(armstoragecache.AmlFilesystemProvisioningStateTypeDeleting), amlFilesystems[0].ProvisioningState)
+	assert.Equal(t, "true", amlFilesystems[0].Tags[mockFailCreationTag])
+}
+
+func TestMockDynamicProvisioner_CreateVolume(t *testing.T) {
+	t.Setenv(DefaultAzureConfigFileEnv, "missing-cred-file.json")
+	d := NewDriver(&DriverOptions{
+		NodeID:                       fakeNodeID,
+		DriverName:                   fakeDriverName,
+		EnableAzureLustreMockDynProv: true,
+	})
+	m, ok := d.dynamicProvisioner.(*mockDynamicProvisioner)
+	require.True(t, ok)
+	m.createLatency = 0
+	m.deleteLatency = 0
+
+	req := buildDynamicProvCreateVolumeRequest()
+	req.Parameters["zone"] = "1"
+	resp, err := d.CreateVolume(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.1.4", resp.GetVolume().GetVolumeContext()["mgs-ip-address"])
+	amlFilesystems, err := m.ListAmlFilesystems(context.Background(), "")
+	require.NoError(t, err)
+	require.Len(t, amlFilesystems, 1)
+	assert.Equal(t, "test-resource-group", amlFilesystems[0].ResourceGroupName)
+	assert.Equal(t, "value1", amlFilesystems[0].Tags["key1"])
+
+	_, err = d.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: resp.GetVolume().GetVolumeId()})
+	require.NoError(t, err)
+	amlFilesystems, err = m.ListAmlFilesystems(context.Background(), "")
+	require.NoError(t, err)
+	assert.Empty(t, amlFilesystems)
+}
@@ -23,6 +23,7 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/wrapperspb"
+	"k8s.io/klog/v2"
 )
 
 // GetPluginInfo return the version and name of the plugin
@@ -41,12 +42,16 @@ func (d *Driver) GetPluginInfo(_ context.Context, _ *csi.GetPluginInfoRequest) (
 	}, nil
 }
 
-// Probe check whether the plugin is running or not.
-// This method does not need to return anything.
-// Currently the spec does not dictate what you should return either.
-// Hence, return an empty response
-func (d *Driver) Probe(_ context.Context, _ *csi.ProbeRequest) (*csi.ProbeResponse, error) {
-	return &csi.ProbeResponse{Ready: &wrapperspb.BoolValue{Value: true}}, nil
+// Probe checks whether the plugin is alive. The livenessprobe sidecar calls
+// it, so it only runs the liveness checks, i.e. on nodes the Lustre kernel
+// modules being loaded. LNet and the other readiness checks are served on
+// /readyz, the reason is logged when the plugin is not alive
+func (d *Driver) Probe(ctx context.Context, _ *csi.ProbeRequest) (*csi.ProbeResponse, error) {
+	liveness := d.checkLiveness(ctx)
+	if !liveness.Ready {
+		klog.Warningf("Probe: driver is not alive: %s", liveness.Reason)
+	}
+	return &csi.ProbeResponse{Ready: &wrapperspb.BoolValue{Value: liveness.Ready}}, nil
 }
 
 // GetPluginCapabilities returns the capabilities of the plugin
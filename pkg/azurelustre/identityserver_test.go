@@ -74,6 +74,27 @@ func TestProbe(t *testing.T) {
 	assert.True(t, resp.GetReady().GetValue())
 }
 
+func TestProbe_NotAlive(t *testing.T) {
+	d := NewFakeDriver()
+	d.checkLiveness = fakeReadiness(false)
+	req := csi.ProbeRequest{}
+	resp, err := d.Probe(context.Background(), &req)
+	require.NoError(t, err)
+	assert.NotNil(t, resp)
+	assert.False(t, resp.GetReady().GetValue())
+}
+
+func TestProbe_NotReady(t *testing.T) {
+	// Failed readiness checks, e.g. LNet, must not restart the plugin
+	d := NewFakeDriver()
+	d.checkReadiness = fakeReadiness(false)
+	req := csi.ProbeRequest{}
+	resp, err := d.Probe(context.Background(), &req)
+	require.NoError(t, err)
+	assert.NotNil(t, resp)
+	assert.True(t, resp.GetReady().GetValue())
+}
+
 func TestGetPluginCapabilities(t *testing.T) {
 	d := NewFakeDriver()
 	req := csi.GetPluginCapabilitiesRequest{}
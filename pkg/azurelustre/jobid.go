@@ -0,0 +1,81 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// lustreJobIDMaxLength is LUSTRE_JOBID_SIZE without the trailing NUL
+	lustreJobIDMaxLength = 31
+)
+
+var (
+	// lustreJobIDVarKeywords are the jobid_var values with a special meaning
+	// to the Lustre client, any other value names an environment variable
+	lustreJobIDVarKeywords = []string{"disable", "procname_uid", "nodelocal", "session"}
+	lustreJobIDEnvVarRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// validateLustreJobIDOptions checks the node-wide jobid_var and jobid_name
+// values before they are handed to lctl
+func validateLustreJobIDOptions(jobIDVar, jobIDName string) error {
+	if jobIDVar == "" {
+		if jobIDName != "" {
+			return fmt.Errorf("lustre jobid name %q requires a jobid var to be set", jobIDName)
+		}
+		return nil
+	}
+	if !slices.Contains(lustreJobIDVarKeywords, jobIDVar) && !lustreJobIDEnvVarRegex.MatchString(jobIDVar) {
+		return fmt.Errorf("lustre jobid var %q must be one of %v or an environment variable name",
+			jobIDVar, lustreJobIDVarKeywords)
+	}
+	if len(jobIDName) > lustreJobIDMaxLength || strings.ContainsAny(jobIDName, " \t\n") {
+		return fmt.Errorf("lustre jobid name %q must be at most %d characters without whitespace",
+			jobIDName, lustreJobIDMaxLength)
+	}
+	return nil
+}
+
+// configureLustreJobID sets the node-wide Lustre jobid_var, and jobid_name if
+// provided, so that the MDS and OSS job_stats can attribute I/O from this node
+func (d *Driver) configureLustreJobID() error {
+	if d.lustreJobIDVar == "" {
+		return nil
+	}
+
+	params := []string{"jobid_var=" + d.lustreJobIDVar}
+	if d.lustreJobIDName != "" {
+		params = append(params, "jobid_name="+d.lustreJobIDName)
+	}
+
+	for _, param := range params {
+		klog.V(2).Infof("setting Lustre client parameter %s", param)
+		output, err := d.mounter.Exec.Command("lctl", "set_param", param).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("lctl set_param %s failed: %w, output: %q", param, err, string(output))
+		}
+	}
+
+	return nil
+}
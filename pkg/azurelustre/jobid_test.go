@@ -0,0 +1,149 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	mount "k8s.io/mount-utils"
+)
+
+func TestValidateLustreJobIDOptions(t *testing.T) {
+	tests := []struct {
+		desc        string
+		jobIDVar    string
+		jobIDName   string
+		expectedErr string
+	}{
+		{
+			desc: "not configured",
+		},
+		{
+			desc:     "keyword",
+			jobIDVar: "procname_uid",
+		},
+		{
+			desc:      "nodelocal with template",
+			jobIDVar:  "nodelocal",
+			jobIDName: "%e.%u.%H",
+		},
+		{
+			desc:     "environment variable",
+			jobIDVar: "SLURM_JOB_ID",
+		},
+		{
+			desc:        "name without var",
+			jobIDName:   "%e.%u",
+			expectedErr: "requires a jobid var",
+		},
+		{
+			desc:        "invalid var",
+			jobIDVar:    "procname uid",
+			expectedErr: "must be one of",
+		},
+		{
+			desc:        "name too long",
+			jobIDVar:    "nodelocal",
+			jobIDName:   "a-very-long-jobid-name-template-value",
+			expectedErr: "at most 31 characters",
+		},
+		{
+			desc:        "name with whitespace",
+			jobIDVar:    "nodelocal",
+			jobIDName:   "%e %u",
+			expectedErr: "without whitespace",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			err := validateLustreJobIDOptions(test.jobIDVar, test.jobIDName)
+			if test.expectedErr != "" {
+				require.ErrorContains(t, err, test.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestConfigureLustreJobID(t *testing.T) {
+	tests := []struct {
+		desc             string
+		jobIDVar         string
+		jobIDName        string
+		results          []fakeCommandResult
+		expectedCommands [][]string
+		expectedErr      string
+	}{
+		{
+			desc: "not configured does not run commands",
+		},
+		{
+			desc:     "jobid var only",
+			jobIDVar: "procname_uid",
+			results:  []fakeCommandResult{{}},
+			expectedCommands: [][]string{
+				{"lctl", "set_param", "jobid_var=procname_uid"},
+			},
+		},
+		{
+			desc:      "jobid var and name",
+			jobIDVar:  "nodelocal",
+			jobIDName: "%e.%u",
+			results:   []fakeCommandResult{{}, {}},
+			expectedCommands: [][]string{
+				{"lctl", "set_param", "jobid_var=nodelocal"},
+				{"lctl", "set_param", "jobid_name=%e.%u"},
+			},
+		},
+		{
+			desc:      "lctl failure",
+			jobIDVar:  "nodelocal",
+			jobIDName: "%e.%u",
+			results:   []fakeCommandResult{{output: "error", err: errors.New("exit status 1")}},
+			expectedCommands: [][]string{
+				{"lctl", "set_param", "jobid_var=nodelocal"},
+			},
+			expectedErr: "lctl set_param jobid_var=nodelocal failed",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var commandLog [][]string
+			d := NewFakeDriver()
+			d.lustreJobIDVar = test.jobIDVar
+			d.lustreJobIDName = test.jobIDName
+			d.mounter = &mount.SafeFormatAndMount{
+				Interface: &fakeMounter{},
+				Exec:      newScriptedFakeExec(test.results, &commandLog),
+			}
+
+			err := d.configureLustreJobID()
+			if test.expectedErr != "" {
+				require.ErrorContains(t, err, test.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, test.expectedCommands, commandLog)
+		})
+	}
+}
@@ -0,0 +1,208 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/klog/v2"
+)
+
+const (
+	lustreStatSamples        = "samples"
+	lustreStatReadBytes      = "read_bytes"
+	lustreStatWriteBytes     = "write_bytes"
+	lustreStatRPCWaitTime    = "req_waittime"
+	kubeletCSIVolumesDirName = "kubernetes.io~csi"
+	kubeletMountDirName      = "mount"
+	microsecondsPerSecond    = 1e6
+)
+
+var (
+	lustreClientReadBytesDesc = metrics.NewDesc(
+		metrics.BuildFQName(metricsNamespace, metricsSubsystem, "lustre_client_read_bytes_total"),
+		"Bytes read through the Lustre client mount of a persistent volume",
+		[]string{"pv", "pod_uid"}, nil, metrics.ALPHA, "",
+	)
+	lustreClientWriteBytesDesc = metrics.NewDesc(
+		metrics.BuildFQName(metricsNamespace, metricsSubsystem, "lustre_client_write_bytes_total"),
+		"Bytes written through the Lustre client mount of a persistent volume",
+		[]string{"pv", "pod_uid"}, nil, metrics.ALPHA, "",
+	)
+	lustreClientOperationsDesc = metrics.NewDesc(
+		metrics.BuildFQName(metricsNamespace, metricsSubsystem, "lustre_client_operations_total"),
+		"Operations issued through the Lustre client mount of a persistent volume, from llite stats",
+		[]string{"pv", "pod_uid", "operation"}, nil, metrics.ALPHA, "",
+	)
+	lustreClientRPCsDesc = metrics.NewDesc(
+		metrics.BuildFQName(metricsNamespace, metricsSubsystem, "lustre_client_rpcs_total"),
+		"RPCs sent to the OSTs for the Lustre client mount of a persistent volume",
+		[]string{"pv", "pod_uid"}, nil, metrics.ALPHA, "",
+	)
+	lustreClientRPCWaitSecondsDesc = metrics.NewDesc(
+		metrics.BuildFQName(metricsNamespace, metricsSubsystem, "lustre_client_rpc_wait_seconds_total"),
+		"Total time RPCs to the OSTs waited for a reply for the Lustre client mount of a persistent volume",
+		[]string{"pv", "pod_uid"}, nil, metrics.ALPHA, "",
+	)
+)
+
+// lustreStat is a single counter line of a Lustre stats file, e.g.
+// "read_bytes 12 samples [bytes] 4096 1048576 8392704"
+type lustreStat struct {
+	count float64
+	sum   float64
+}
+
+// lustreClientStats holds the llite and osc counters of one Lustre mount
+type lustreClientStats struct {
+	llite map[string]lustreStat
+	osc   map[string]lustreStat
+}
+
+// parseLustreStats parses the output of "lctl get_param -n <device>.stats",
+// adding up counters with the same name when several devices are listed
+func parseLustreStats(output string) map[string]lustreStat {
+	stats := make(map[string]lustreStat)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[2] != lustreStatSamples {
+			continue
+		}
+		count, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+		stat := stats[fields[0]]
+		stat.count += count
+		if len(fields) >= 7 {
+			if sum, err := strconv.ParseFloat(fields[6], 64); err == nil {
+				stat.sum += sum
+			}
+		}
+		stats[fields[0]] = stat
+	}
+	return stats
+}
+
+// getLustreClientStats reads the llite stats and the stats of every osc
+// device belonging to the Lustre mount at target
+func (d *Driver) getLustreClientStats(target string) (*lustreClientStats, error) {
+	fsName, instance, err := d.getLustreInstance(target)
+	if err != nil {
+		return nil, err
+	}
+
+	lliteParam := fmt.Sprintf("llite.%s-%s.stats", fsName, instance)
+	lliteOutput, err := d.mounter.Exec.Command("lctl", "get_param", "-n", lliteParam).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("lctl get_param %s failed: %w, output: %q", lliteParam, err, string(lliteOutput))
+	}
+
+	oscParam := fmt.Sprintf("osc.%s-OST*-osc-%s.stats", fsName, instance)
+	oscOutput, err := d.mounter.Exec.Command("lctl", "get_param", "-n", oscParam).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("lctl get_param %s failed: %w, output: %q", oscParam, err, string(oscOutput))
+	}
+
+	return &lustreClientStats{
+		llite: parseLustreStats(string(lliteOutput)),
+		osc:   parseLustreStats(string(oscOutput)),
+	}, nil
+}
+
+// getPVNameFromTargetPath returns the persistent volume name from a kubelet
+// publish path, i.e. /var/lib/kubelet/pods/<uid>/volumes/kubernetes.io~csi/<pv>/mount
+func getPVNameFromTargetPath(target string) (string, bool) {
+	if filepath.Base(target) != kubeletMountDirName {
+		return "", false
+	}
+	pvDir := filepath.Dir(target)
+	if filepath.Base(filepath.Dir(pvDir)) != kubeletCSIVolumesDirName {
+		return "", false
+	}
+	return filepath.Base(pvDir), true
+}
+
+// lustreClientStatsCollector exposes the Lustre client stats of every volume
+// published on this node, labeled by persistent volume and pod UID
+type lustreClientStatsCollector struct {
+	metrics.BaseStableCollector
+
+	driver *Driver
+}
+
+func newLustreClientStatsCollector(d *Driver) metrics.StableCollector {
+	return &lustreClientStatsCollector{driver: d}
+}
+
+// DescribeWithStability implements the metrics.StableCollector interface
+func (c *lustreClientStatsCollector) DescribeWithStability(ch chan<- *metrics.Desc) {
+	ch <- lustreClientReadBytesDesc
+	ch <- lustreClientWriteBytesDesc
+	ch <- lustreClientOperationsDesc
+	ch <- lustreClientRPCsDesc
+	ch <- lustreClientRPCWaitSecondsDesc
+}
+
+// CollectWithStability implements the metrics.StableCollector interface
+func (c *lustreClientStatsCollector) CollectWithStability(ch chan<- metrics.Metric) {
+	mountPoints, err := c.driver.mounter.List()
+	if err != nil {
+		klog.Warningf("failed to list mount points for Lustre client stats: %v", err)
+		return
+	}
+
+	for _, mountPoint := range mountPoints {
+		if mountPoint.Type != "lustre" {
+			continue
+		}
+		pvName, ok := getPVNameFromTargetPath(mountPoint.Path)
+		if !ok {
+			continue
+		}
+		podUID, _ := getPodUIDFromTargetPath(mountPoint.Path)
+
+		stats, err := c.driver.getLustreClientStats(mountPoint.Path)
+		if err != nil {
+			klog.Warningf("failed to get Lustre client stats for %s: %v", mountPoint.Path, err)
+			continue
+		}
+
+		ch <- metrics.NewLazyConstMetric(lustreClientReadBytesDesc, metrics.CounterValue,
+			stats.llite[lustreStatReadBytes].sum, pvName, string(podUID))
+		ch <- metrics.NewLazyConstMetric(lustreClientWriteBytesDesc, metrics.CounterValue,
+			stats.llite[lustreStatWriteBytes].sum, pvName, string(podUID))
+		for name, stat := range stats.llite {
+			operation := strings.TrimSuffix(name, "_bytes")
+			if _, ok := stats.llite[operation]; ok && operation != name {
+				// Newer clients report read and write next to read_bytes
+				// and write_bytes; exporting both would duplicate a series.
+				continue
+			}
+			ch <- metrics.NewLazyConstMetric(lustreClientOperationsDesc, metrics.CounterValue,
+				stat.count, pvName, string(podUID), operation)
+		}
+		ch <- metrics.NewLazyConstMetric(lustreClientRPCsDesc, metrics.CounterValue,
+			stats.osc[lustreStatRPCWaitTime].count, pvName, string(podUID))
+		ch <- metrics.NewLazyConstMetric(lustreClientRPCWaitSecondsDesc, metrics.CounterValue,
+			stats.osc[lustreStatRPCWaitTime].sum/microsecondsPerSecond, pvName, string(podUID))
+	}
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/component-base/metrics"
+	mount "k8s.io/mount-utils"
+)
+
+const (
+	fakeLliteStats = `snapshot_time             1700000100.000000000 secs.nsecs
+start_time                1700000000.000000000 secs.nsecs
+elapsed_time              100.000000000 secs.nsecs
+read_bytes                4 samples [bytes] 4096 1048576 2101248 1099528404992
+write_bytes               2 samples [bytes] 4096 4096 8192 33554432
+open                      3 samples [usecs] 10 30 60 1400
+getattr                   5 samples [usecs] 1 2 7 11
+`
+	fakeOscStats = `snapshot_time             1700000100.000000000 secs.nsecs
+req_waittime              10 samples [usecs] 100 300 2000 440000
+req_active                10 samples [reqs] 1 1 10 10
+snapshot_time             1700000100.000000000 secs.nsecs
+req_waittime              5 samples [usecs] 100 500 1000 300000
+`
+)
+
+func TestParseLustreStats(t *testing.T) {
+	stats := parseLustreStats(fakeOscStats)
+	assert.Equal(t, map[string]lustreStat{
+		"req_waittime": {count: 15, sum: 3000},
+		"req_active":   {count: 10, sum: 10},
+	}, stats)
+
+	stats = parseLustreStats(fakeLliteStats)
+	assert.Equal(t, lustreStat{count: 4, sum: 2101248}, stats["read_bytes"])
+	assert.Equal(t, lustreStat{count: 2, sum: 8192}, stats["write_bytes"])
+	assert.NotContains(t, stats, "snapshot_time")
+}
+
+func TestGetPVNameFromTargetPath(t *testing.T) {
+	tests := []struct {
+		desc         string
+		target       string
+		expectedName string
+		expectedOK   bool
+	}{
+		{
+			desc:         "kubelet publish path",
+			target:       "/var/lib/kubelet/pods/6b3c1d2e/volumes/kubernetes.io~csi/pv-lustre/mount",
+			expectedName: "pv-lustre",
+			expectedOK:   true,
+		},
+		{
+			desc:   "working mount dir",
+			target: "/tmp/vol_1#lustrefs#1.1.1.1#",
+		},
+		{
+			desc:   "other volume plugin",
+			target: "/var/lib/kubelet/pods/6b3c1d2e/volumes/kubernetes.io~empty-dir/cache/mount",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			name, ok := getPVNameFromTargetPath(test.target)
+			assert.Equal(t, test.expectedName, name)
+			assert.Equal(t, test.expectedOK, ok)
+		})
+	}
+}
+
+func TestLustreClientStatsCollector(t *testing.T) {
+	publishedTarget := "/var/lib/kubelet/pods/6b3c1d2e/volumes/kubernetes.io~csi/pv-lustre/mount"
+	failingTarget := "/var/lib/kubelet/pods/8a7f9e0d/volumes/kubernetes.io~csi/pv-failing/mount"
+
+	var commandLog [][]string
+	d := NewFakeDriver()
+	d.mounter = &mount.SafeFormatAndMount{
+		Interface: &fakeMounter{
+			FakeMounter: mount.FakeMounter{
+				MountPoints: []mount.MountPoint{
+					{Device: "1.1.1.1@tcp:/lustrefs", Path: "/tmp/vol_1#lustrefs#1.1.1.1#", Type: "lustre"},
+					{Device: "tmpfs", Path: "/var/lib/kubelet/pods/6b3c1d2e/volumes/kubernetes.io~csi/pv-other/mount", Type: "tmpfs"},
+					{Device: "1.1.1.1@tcp:/lustrefs", Path: publishedTarget, Type: "lustre"},
+					{Device: "1.1.1.1@tcp:/lustrefs", Path: failingTarget, Type: "lustre"},
+				},
+			},
+		},
+		Exec: newScriptedFakeExec([]fakeCommandResult{
+			{output: "lustrefs-ffff8d5c9b3f5000 " + publishedTarget},
+			{output: fakeLliteStats},
+			{output: fakeOscStats},
+			{output: "not a lustre mount", err: errors.New("exit status 1")},
+		}, &commandLog),
+	}
+
+	registry := metrics.NewKubeRegistry()
+	registry.CustomMustRegister(newLustreClientStatsCollector(d))
+
+	assert.Equal(t, map[string]float64{"pod_uid=6b3c1d2e,pv=pv-lustre": 2101248},
+		gatherMetricValues(t, registry, "azurelustre_csi_lustre_client_read_bytes_total"))
+	assert.Equal(t, [][]string{
+		{"lfs", "getname", publishedTarget},
+		{"lctl", "get_param", "-n", "llite.lustrefs-ffff8d5c9b3f5000.stats"},
+		{"lctl", "get_param", "-n", "osc.lustrefs-OST*-osc-ffff8d5c9b3f5000.stats"},
+		{"lfs", "getname", failingTarget},
+	}, commandLog)
+}
+
+func TestLustreClientStatsCollectorMetrics(t *testing.T) {
+	publishedTarget := "/var/lib/kubelet/pods/6b3c1d2e/volumes/kubernetes.io~csi/pv-lustre/mount"
+
+	var commandLog [][]string
+	d := NewFakeDriver()
+	d.mounter = &mount.SafeFormatAndMount{
+		Interface: &fakeMounter{
+			FakeMounter: mount.FakeMounter{
+				MountPoints: []mount.MountPoint{
+					{Device: "1.1.1.1@tcp:/lustrefs", Path: publishedTarget, Type: "lustre"},
+				},
+			},
+		},
+		Exec: newScriptedFakeExec([]fakeCommandResult{
+			{output: "lustrefs-ffff8d5c9b3f5000 " + publishedTarget},
+			{output: fakeLliteStats},
+			{output: fakeOscStats},
+		}, &commandLog),
+	}
+
+	registry := metrics.NewKubeRegistry()
+	registry.CustomMustRegister(newLustreClientStatsCollector(d))
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]float64{"pod_uid=6b3c1d2e,pv=pv-lustre": 8192},
+		getMetricValues(families, "azurelustre_csi_lustre_client_write_bytes_total"))
+	assert.Equal(t, map[string]float64{
+		"operation=getattr,pod_uid=6b3c1d2e,pv=pv-lustre": 5,
+		"operation=open,pod_uid=6b3c1d2e,pv=pv-lustre":    3,
+		"operation=read,pod_uid=6b3c1d2e,pv=pv-lustre":    4,
+		"operation=write,pod_uid=6b3c1d2e,pv=pv-lustre":   2,
+	}, getMetricValues(families, "azurelustre_csi_lustre_client_operations_total"))
+	assert.Equal(t, map[string]float64{"pod_uid=6b3c1d2e,pv=pv-lustre": 15},
+		getMetricValues(families, "azurelustre_csi_lustre_client_rpcs_total"))
+	assert.Equal(t, map[string]float64{"pod_uid=6b3c1d2e,pv=pv-lustre": 0.003},
+		getMetricValues(families, "azurelustre_csi_lustre_client_rpc_wait_seconds_total"))
+}
+
+func TestLustreClientStatsCollectorDuplicateOperations(t *testing.T) {
+	publishedTarget := "/var/lib/kubelet/pods/6b3c1d2e/volumes/kubernetes.io~csi/pv-lustre/mount"
+	lliteStats := fakeLliteStats +
+		"read                      4 samples [usecs] 5 20 40 600\n" +
+		"write                     2 samples [usecs] 10 10 20 200\n"
+
+	var commandLog [][]string
+	d := NewFakeDriver()
+	d.mounter = &mount.SafeFormatAndMount{
+		Interface: &fakeMounter{
+			FakeMounter: mount.FakeMounter{
+				MountPoints: []mount.MountPoint{
+					{Device: "1.1.1.1@tcp:/lustrefs", Path: publishedTarget, Type: "lustre"},
+				},
+			},
+		},
+		Exec: newScriptedFakeExec([]fakeCommandResult{
+			{output: "lustrefs-ffff8d5c9b3f5000 " + publishedTarget},
+			{output: lliteStats},
+			{output: fakeOscStats},
+		}, &commandLog),
+	}
+
+	registry := metrics.NewKubeRegistry()
+	registry.CustomMustRegister(newLustreClientStatsCollector(d))
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]float64{"pod_uid=6b3c1d2e,pv=pv-lustre": 2101248},
+		getMetricValues(families, "azurelustre_csi_lustre_client_read_bytes_total"))
+	assert.Equal(t, map[string]float64{
+		"operation=getattr,pod_uid=6b3c1d2e,pv=pv-lustre": 5,
+		"operation=open,pod_uid=6b3c1d2e,pv=pv-lustre":    3,
+		"operation=read,pod_uid=6b3c1d2e,pv=pv-lustre":    4,
+		"operation=write,pod_uid=6b3c1d2e,pv=pv-lustre":   2,
+	}, getMetricValues(families, "azurelustre_csi_lustre_client_operations_total"))
+}
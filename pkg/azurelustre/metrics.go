@@ -0,0 +1,235 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"errors"
+	"strings"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc/status"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+const (
+	metricsNamespace = "azurelustre"
+	metricsSubsystem = "csi"
+
+	mountOperationMount   = "mount"
+	mountOperationUnmount = "unmount"
+
+	metricsResultSuccess = "success"
+	metricsResultFailure = "failure"
+
+	mountErrorClassTimeout          = "timeout"
+	mountErrorClassNotFound         = "not_found"
+	mountErrorClassPermissionDenied = "permission_denied"
+	mountErrorClassBusy             = "busy"
+	mountErrorClassNetwork          = "network"
+	mountErrorClassIO               = "io"
+	mountErrorClassOther            = "other"
+)
+
+// mountErrorClasses maps fragments of mount and umount error output to a
+// bounded set of error classes, checked in order
+var mountErrorClasses = []struct {
+	fragment   string
+	errorClass string
+}{
+	{fragment: "timed out", errorClass: mountErrorClassTimeout},
+	{fragment: "timeout", errorClass: mountErrorClassTimeout},
+	{fragment: "no such file or directory", errorClass: mountErrorClassNotFound},
+	{fragment: "no such device", errorClass: mountErrorClassNotFound},
+	{fragment: "permission denied", errorClass: mountErrorClassPermissionDenied},
+	{fragment: "operation not permitted", errorClass: mountErrorClassPermissionDenied},
+	{fragment: "busy", errorClass: mountErrorClassBusy},
+	{fragment: "network is unreachable", errorClass: mountErrorClassNetwork},
+	{fragment: "connection refused", errorClass: mountErrorClassNetwork},
+	{fragment: "transport endpoint", errorClass: mountErrorClassNetwork},
+	{fragment: "input/output error", errorClass: mountErrorClassIO},
+}
+
+var (
+	amlFilesystemProvisioningDuration = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "amlfs_provisioning_duration_seconds",
+			Help:           "Time taken to create an AMLFS cluster during dynamic provisioning, by SKU and gRPC result code",
+			Buckets:        []float64{60, 300, 600, 900, 1200, 1800, 2400, 3600, 5400, 7200, 10800},
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"sku", "outcome"},
+	)
+
+	skuCacheRequests = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "amlfs_sku_cache_requests_total",
+			Help:           "Number of AMLFS SKU lookups by result: hit when served from the cache, miss when the SKUs were listed and shared when waiting for another lookup listing them",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"result"},
+	)
+
+	mountOperationDuration = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "mount_operation_duration_seconds",
+			Help:           "Time taken to mount or unmount a Lustre file system on the node",
+			Buckets:        metrics.ExponentialBuckets(0.05, 2, 12),
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"operation", "result"},
+	)
+
+	mountOperationErrors = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "mount_operation_errors_total",
+			Help:           "Number of failed Lustre mount and unmount operations on the node, by error class",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"operation", "error_class"},
+	)
+
+	orphanedAmlFilesystems = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "orphaned_amlfs_clusters",
+			Help:           "Number of driver-created AMLFS clusters whose persistent volume no longer exists, as of the last garbage collection",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+
+	azurePermissionMissing = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "azure_permission_missing",
+			Help:           "Whether the controller identity is missing an ARM action, 1 when missing and 0 when granted, as of the startup permission self-test",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"action"},
+	)
+
+	orphanedAmlFilesystemDeletions = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "orphaned_amlfs_deletions_total",
+			Help:           "Number of orphaned AMLFS clusters deleted by garbage collection, by result",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"result"},
+	)
+
+	armThrottledRequests = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "arm_throttled_requests_total",
+			Help:           "Number of ARM responses with status 429 Too Many Requests, by resource provider",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"resource_provider"},
+	)
+
+	armThrottleRetries = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "arm_throttle_retries_total",
+			Help:           "Number of throttled ARM requests by result: retried after their Retry-After, gave_up when returned as Unavailable and rejected when not sent while the circuit breaker was open",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"result"},
+	)
+
+	armCircuitBreakersOpen = metrics.NewGauge(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      metricsSubsystem,
+			Name:           "arm_circuit_breaker_open",
+			Help:           "Number of subscription and client pairs whose ARM requests are stopped by an open circuit breaker after sustained throttling",
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+)
+
+func init() {
+	legacyregistry.MustRegister(
+		amlFilesystemProvisioningDuration,
+		skuCacheRequests,
+		mountOperationDuration,
+		mountOperationErrors,
+		orphanedAmlFilesystems,
+		azurePermissionMissing,
+		orphanedAmlFilesystemDeletions,
+		armThrottledRequests,
+		armThrottleRetries,
+		armCircuitBreakersOpen,
+	)
+}
+
+// observeAmlFilesystemProvisioning records how long an AMLFS creation took
+func observeAmlFilesystemProvisioning(skuName string, start time.Time, err error) {
+	amlFilesystemProvisioningDuration.WithLabelValues(skuName, status.Code(err).String()).
+		Observe(time.Since(start).Seconds())
+}
+
+// observeMountOperation records the duration of a mount or unmount and, on
+// failure, the class of error it returned
+func observeMountOperation(operation string, start time.Time, err error) {
+	result := metricsResultSuccess
+	if err != nil {
+		result = metricsResultFailure
+		mountOperationErrors.WithLabelValues(operation, classifyMountError(err)).Inc()
+	}
+	mountOperationDuration.WithLabelValues(operation, result).Observe(time.Since(start).Seconds())
+}
+
+// classifyMountError reduces a mount or unmount error to a low cardinality
+// class suitable for a metric label
+func classifyMountError(err error) string {
+	switch {
+	case errors.Is(err, syscall.ETIMEDOUT):
+		return mountErrorClassTimeout
+	case errors.Is(err, syscall.ENOENT), errors.Is(err, syscall.ENODEV):
+		return mountErrorClassNotFound
+	case errors.Is(err, syscall.EACCES), errors.Is(err, syscall.EPERM):
+		return mountErrorClassPermissionDenied
+	case errors.Is(err, syscall.EBUSY):
+		return mountErrorClassBusy
+	case errors.Is(err, syscall.EIO):
+		return mountErrorClassIO
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, mountErrorClass := range mountErrorClasses {
+		if strings.Contains(message, mountErrorClass.fragment) {
+			return mountErrorClass.errorClass
+		}
+	}
+	return mountErrorClassOther
+}
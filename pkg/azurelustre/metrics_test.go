@@ -0,0 +1,151 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+// gatherMetricValues returns the value of every sample of the named metric,
+// keyed by its label pairs, e.g. "operation=mount,result=success". Counters
+// and gauges report their value, histograms their sample count
+func gatherMetricValues(t *testing.T, gatherer metrics.Gatherer, name string) map[string]float64 {
+	t.Helper()
+	families, err := gatherer.Gather()
+	require.NoError(t, err)
+	return getMetricValues(families, name)
+}
+
+// getMetricValues is gatherMetricValues for already gathered metric families
+func getMetricValues(families []*dto.MetricFamily, name string) map[string]float64 {
+	values := make(map[string]float64)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := make([]string, 0, len(metric.GetLabel()))
+			for _, label := range metric.GetLabel() {
+				labels = append(labels, fmt.Sprintf("%s=%s", label.GetName(), label.GetValue()))
+			}
+			slices.Sort(labels)
+			values[strings.Join(labels, ",")] = getMetricValue(family.GetType(), metric)
+		}
+	}
+	return values
+}
+
+func getMetricValue(metricType dto.MetricType, metric *dto.Metric) float64 {
+	switch metricType {
+	case dto.MetricType_COUNTER:
+		return metric.GetCounter().GetValue()
+	case dto.MetricType_GAUGE:
+		return metric.GetGauge().GetValue()
+	case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+		return float64(metric.GetHistogram().GetSampleCount())
+	case dto.MetricType_SUMMARY:
+		return float64(metric.GetSummary().GetSampleCount())
+	case dto.MetricType_UNTYPED:
+		return metric.GetUntyped().GetValue()
+	}
+	return 0
+}
+
+func TestClassifyMountError(t *testing.T) {
+	tests := []struct {
+		desc          string
+		err           error
+		expectedClass string
+	}{
+		{
+			desc:          "wrapped errno",
+			err:           fmt.Errorf("mount failed: %w", syscall.ETIMEDOUT),
+			expectedClass: mountErrorClassTimeout,
+		},
+		{
+			desc:          "missing file system",
+			err:           errors.New("mount.lustre: mount 1.1.1.1@tcp:/lustrefs at /target failed: No such file or directory"),
+			expectedClass: mountErrorClassNotFound,
+		},
+		{
+			desc:          "unreachable MGS",
+			err:           errors.New("mount.lustre: mount 1.1.1.1@tcp:/lustrefs at /target failed: Connection timed out"),
+			expectedClass: mountErrorClassTimeout,
+		},
+		{
+			desc:          "busy target",
+			err:           errors.New("umount: /target: target is busy"),
+			expectedClass: mountErrorClassBusy,
+		},
+		{
+			desc:          "transport shutdown",
+			err:           errors.New("Cannot send after transport endpoint shutdown"),
+			expectedClass: mountErrorClassNetwork,
+		},
+		{
+			desc:          "unknown error",
+			err:           errors.New("fake MountSensitiveWithoutSystemdWithMountFlags: target error"),
+			expectedClass: mountErrorClassOther,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			assert.Equal(t, test.expectedClass, classifyMountError(test.err))
+		})
+	}
+}
+
+func TestObserveMountOperation(t *testing.T) {
+	durationBefore := gatherMetricValues(t, legacyregistry.DefaultGatherer, "azurelustre_csi_mount_operation_duration_seconds")
+	errorsBefore := gatherMetricValues(t, legacyregistry.DefaultGatherer, "azurelustre_csi_mount_operation_errors_total")
+
+	observeMountOperation(mountOperationMount, time.Now(), nil)
+	observeMountOperation(mountOperationUnmount, time.Now(), errors.New("umount: /target: target is busy"))
+
+	durationAfter := gatherMetricValues(t, legacyregistry.DefaultGatherer, "azurelustre_csi_mount_operation_duration_seconds")
+	errorsAfter := gatherMetricValues(t, legacyregistry.DefaultGatherer, "azurelustre_csi_mount_operation_errors_total")
+
+	assert.InDelta(t, 1, durationAfter["operation=mount,result=success"]-durationBefore["operation=mount,result=success"], 0)
+	assert.InDelta(t, 1, durationAfter["operation=unmount,result=failure"]-durationBefore["operation=unmount,result=failure"], 0)
+	assert.InDelta(t, 1, errorsAfter["error_class=busy,operation=unmount"]-errorsBefore["error_class=busy,operation=unmount"], 0)
+}
+
+func TestObserveAmlFilesystemProvisioning(t *testing.T) {
+	before := gatherMetricValues(t, legacyregistry.DefaultGatherer, "azurelustre_csi_amlfs_provisioning_duration_seconds")
+
+	observeAmlFilesystemProvisioning("AMLFS-Durable-Premium-40", time.Now(), nil)
+	observeAmlFilesystemProvisioning("AMLFS-Durable-Premium-40", time.Now(), status.Error(codes.ResourceExhausted, "quota"))
+
+	after := gatherMetricValues(t, legacyregistry.DefaultGatherer, "azurelustre_csi_amlfs_provisioning_duration_seconds")
+	assert.InDelta(t, 1, after["outcome=OK,sku=AMLFS-Durable-Premium-40"]-before["outcome=OK,sku=AMLFS-Durable-Premium-40"], 0)
+	assert.InDelta(t, 1, after["outcome=ResourceExhausted,sku=AMLFS-Durable-Premium-40"]-before["outcome=ResourceExhausted,sku=AMLFS-Durable-Premium-40"], 0)
+}
@@ -0,0 +1,250 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+)
+
+const (
+	kubeletPodsDirName       = "pods"
+	kubeletPodVolumesDirName = "volumes"
+	// kubeletVolumeDataFileName is the file kubelet saves the volume handle
+	// of a CSI volume in, next to its publish path
+	kubeletVolumeDataFileName = "vol_data.json"
+
+	staleMountReasonPodGone   = "pod no longer exists on this node"
+	staleMountReasonCorrupted = "mount is corrupted"
+	staleMountReasonLeftover  = "internal mount left over from a previous run"
+)
+
+// staleMount is a Lustre mount the reconciler cleans up
+type staleMount struct {
+	path string
+	// target is the kubelet publish path the mount was made for, the path
+	// itself unless it is an internal mount under workingMountDir
+	target string
+	reason string
+}
+
+// getPodUIDFromTargetPath returns the pod UID from a kubelet publish path,
+// i.e. /var/lib/kubelet/pods/<uid>/volumes/kubernetes.io~csi/<pv>/mount
+func getPodUIDFromTargetPath(target string) (k8stypes.UID, bool) {
+	if _, ok := getPVNameFromTargetPath(target); !ok {
+		return "", false
+	}
+	volumesDir := filepath.Dir(filepath.Dir(filepath.Dir(target)))
+	podDir := filepath.Dir(volumesDir)
+	if filepath.Base(volumesDir) != kubeletPodVolumesDirName || filepath.Base(filepath.Dir(podDir)) != kubeletPodsDirName {
+		return "", false
+	}
+	return k8stypes.UID(filepath.Base(podDir)), true
+}
+
+// getVolumeIDFromTargetPath returns the volume ID kubelet saved next to a
+// publish path before calling NodePublishVolume for it
+func getVolumeIDFromTargetPath(target string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(target), kubeletVolumeDataFileName))
+	if err != nil {
+		return "", err
+	}
+	var volumeData struct {
+		VolumeHandle string `json:"volumeHandle"`
+	}
+	if err := json.Unmarshal(data, &volumeData); err != nil {
+		return "", fmt.Errorf("failed to parse %s of %s: %w", kubeletVolumeDataFileName, target, err)
+	}
+	if volumeData.VolumeHandle == "" {
+		return "", fmt.Errorf("no volume handle in %s of %s", kubeletVolumeDataFileName, target)
+	}
+	return volumeData.VolumeHandle, nil
+}
+
+// getNodePodUIDs returns the UIDs of the pods scheduled to this node
+func (d *Driver) getNodePodUIDs(ctx context.Context) (map[k8stypes.UID]bool, error) {
+	pods, err := d.kubeClient.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", d.NodeID).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods on node %s: %w", d.NodeID, err)
+	}
+	podUIDs := make(map[k8stypes.UID]bool, len(pods.Items))
+	for _, pod := range pods.Items {
+		podUIDs[pod.UID] = true
+	}
+	return podUIDs, nil
+}
+
+// findStaleMounts returns the Lustre mounts of pods that no longer exist,
+// corrupted Lustre mounts and internal mounts under workingMountDir that are
+// not in use. At startup every internal mount is left over, later ones that
+// belong to an existing pod may be in use by NodePublishVolume
+func (d *Driver) findStaleMounts(ctx context.Context, startup bool) ([]staleMount, error) {
+	// Mounts are listed before pods, so that a mount cannot belong to a pod
+	// created after the pods were listed
+	lustreMounts, err := d.getLustreMounts()
+	if err != nil {
+		return nil, err
+	}
+	if len(lustreMounts) == 0 {
+		return nil, nil
+	}
+
+	var podUIDs map[k8stypes.UID]bool
+	if d.kubeClient != nil {
+		if podUIDs, err = d.getNodePodUIDs(ctx); err != nil {
+			return nil, err
+		}
+	} else {
+		klog.V(2).Infof("no kubernetes client, not checking Lustre mounts for pods that no longer exist")
+	}
+	podExists := func(target string) bool {
+		podUID, ok := getPodUIDFromTargetPath(target)
+		return !ok || podUIDs == nil || podUIDs[podUID]
+	}
+
+	workingMountDir := filepath.Clean(d.workingMountDir) + string(filepath.Separator)
+	var stale []staleMount
+	for _, mountPoint := range lustreMounts {
+		switch {
+		case strings.HasPrefix(mountPoint.Path, workingMountDir):
+			target := string(filepath.Separator) + strings.TrimPrefix(mountPoint.Path, workingMountDir)
+			if startup {
+				stale = append(stale, staleMount{path: mountPoint.Path, target: target, reason: staleMountReasonLeftover})
+			} else if !podExists(target) {
+				stale = append(stale, staleMount{path: mountPoint.Path, target: target, reason: staleMountReasonPodGone})
+			}
+		case isKubeletPublishPath(mountPoint.Path):
+			if !podExists(mountPoint.Path) {
+				stale = append(stale, staleMount{path: mountPoint.Path, target: mountPoint.Path, reason: staleMountReasonPodGone})
+			} else if IsCorruptedDir(mountPoint.Path) {
+				stale = append(stale, staleMount{path: mountPoint.Path, target: mountPoint.Path, reason: staleMountReasonCorrupted})
+			}
+		}
+	}
+	return stale, nil
+}
+
+func isKubeletPublishPath(target string) bool {
+	_, ok := getPodUIDFromTargetPath(target)
+	return ok
+}
+
+// reconcileMounts cleans up stale Lustre mounts, or only logs them in dry-run
+// mode
+func (d *Driver) reconcileMounts(ctx context.Context, startup bool) error {
+	stale, err := d.findStaleMounts(ctx, startup)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, mount := range stale {
+		if d.mountReconcileDryRun {
+			klog.Infof("dry run: would clean up Lustre mount %s: %s", mount.path, mount.reason)
+			continue
+		}
+		if err := d.cleanUpStaleMount(ctx, mount, startup); err != nil {
+			klog.Errorf("failed to clean up Lustre mount %s: %v", mount.path, err)
+			failed = append(failed, mount.path)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to clean up Lustre mounts: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// cleanUpStaleMount unmounts a stale Lustre mount while holding the lock
+// NodePublishVolume and NodeUnpublishVolume take for its volume and target,
+// leaving it to the next run when an operation on the volume is in progress.
+// At startup the gRPC server is not serving yet, so no lock is needed
+func (d *Driver) cleanUpStaleMount(ctx context.Context, mount staleMount, startup bool) error {
+	if !startup {
+		// kubelet removes vol_data.json with the pod directory, the target
+		// path alone still keeps other cleanups of the mount out
+		lockKey := mount.target
+		volumeID, err := getVolumeIDFromTargetPath(mount.target)
+		switch {
+		case err == nil:
+			lockKey = getNodeVolumeLockKey(volumeID, mount.target)
+		case errors.Is(err, os.ErrNotExist):
+			klog.V(2).Infof("no %s for Lustre mount %s, locking its target path", kubeletVolumeDataFileName, mount.path)
+		default:
+			return fmt.Errorf("failed to get the volume ID: %w", err)
+		}
+		if acquired := d.volumeLocks.TryAcquire(lockKey); !acquired {
+			klog.V(2).Infof("not cleaning up Lustre mount %s, an operation on its volume is in progress", mount.path)
+			return nil
+		}
+		defer d.volumeLocks.Release(lockKey)
+	}
+
+	// The pod may have been created again with the same UID, e.g. a static
+	// pod, while the mounts were checked
+	if mount.reason == staleMountReasonPodGone {
+		podUIDs, err := d.getNodePodUIDs(ctx)
+		if err != nil {
+			return err
+		}
+		if podUID, _ := getPodUIDFromTargetPath(mount.target); podUIDs[podUID] {
+			klog.V(2).Infof("not cleaning up Lustre mount %s, pod %s exists again", mount.path, podUID)
+			return nil
+		}
+	}
+
+	klog.Infof("cleaning up Lustre mount %s: %s", mount.path, mount.reason)
+	return unmountVolumeAtPath(d, mount.path)
+}
+
+// runMountReconciler cleans up stale Lustre mounts once at startup and then
+// every mountReconcileInterval, when it is not zero
+func (d *Driver) runMountReconciler(ctx context.Context) {
+	if err := d.reconcileMounts(ctx, true); err != nil {
+		klog.Errorf("failed to reconcile Lustre mounts at startup: %v", err)
+	}
+	if d.mountReconcileInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(d.mountReconcileInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := d.reconcileMounts(ctx, false); err != nil {
+					klog.Errorf("failed to reconcile Lustre mounts: %v", err)
+				}
+			}
+		}
+	}()
+}
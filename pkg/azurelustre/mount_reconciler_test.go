@@ -0,0 +1,187 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	mount "k8s.io/mount-utils"
+)
+
+func TestGetPodUIDFromTargetPath(t *testing.T) {
+	podUID, ok := getPodUIDFromTargetPath("/var/lib/kubelet/pods/6b3c1d2e/volumes/kubernetes.io~csi/pv-lustre/mount")
+	assert.True(t, ok)
+	assert.Equal(t, k8stypes.UID("6b3c1d2e"), podUID)
+
+	_, ok = getPodUIDFromTargetPath("/var/lib/kubelet/plugins/kubernetes.io/csi/azurelustre.csi.azure.com/0123/globalmount")
+	assert.False(t, ok)
+	_, ok = getPodUIDFromTargetPath("/mnt/volumes/kubernetes.io~csi/pv-lustre/mount")
+	assert.False(t, ok)
+}
+
+func TestReconcileMounts(t *testing.T) {
+	kubeletDir := t.TempDir()
+	workingMountDir := t.TempDir()
+	publishPath := func(podUID string) string {
+		return filepath.Join(kubeletDir, "pods", podUID, "volumes", "kubernetes.io~csi", "pv-lustre", "mount")
+	}
+	runningTarget := publishPath("running-pod")
+	orphanedTarget := publishPath("deleted-pod")
+	runningInternal := filepath.Join(workingMountDir, runningTarget)
+	orphanedInternal := filepath.Join(workingMountDir, orphanedTarget)
+	runningPod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "running-pod", Namespace: "default", UID: "running-pod"},
+		Spec:       v1.PodSpec{NodeName: "node-1"},
+	}
+	lustreTargets := []string{runningTarget, orphanedTarget, runningInternal, orphanedInternal}
+
+	tests := []struct {
+		desc            string
+		startup         bool
+		dryRun          bool
+		noKubeClient    bool
+		busyTargets     []string
+		noVolumeData    []string
+		recreatedPod    bool
+		expectedRemoved []string
+	}{
+		{
+			desc:            "startup",
+			startup:         true,
+			expectedRemoved: []string{orphanedTarget, runningInternal, orphanedInternal},
+		},
+		{
+			desc:            "periodic",
+			expectedRemoved: []string{orphanedTarget, orphanedInternal},
+		},
+		{
+			desc:    "dry run",
+			startup: true,
+			dryRun:  true,
+		},
+		{
+			desc:            "no kube client",
+			startup:         true,
+			noKubeClient:    true,
+			expectedRemoved: []string{runningInternal, orphanedInternal},
+		},
+		{
+			desc:        "volume operation in progress",
+			busyTargets: []string{orphanedTarget},
+		},
+		{
+			desc:            "startup without volume data",
+			startup:         true,
+			noVolumeData:    lustreTargets,
+			busyTargets:     []string{orphanedTarget},
+			expectedRemoved: []string{orphanedTarget, runningInternal, orphanedInternal},
+		},
+		{
+			desc:            "leftover internal mount without volume data",
+			noVolumeData:    []string{orphanedTarget},
+			expectedRemoved: []string{orphanedTarget, orphanedInternal},
+		},
+		{
+			desc:         "volume operation in progress without volume data",
+			noVolumeData: []string{orphanedTarget},
+			busyTargets:  []string{orphanedTarget},
+		},
+		{
+			desc:         "pod recreated",
+			recreatedPod: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			mountPoints := []mount.MountPoint{
+				{Device: "tmpfs", Path: filepath.Join(kubeletDir, "pods", "deleted-pod", "volumes", "kubernetes.io~empty-dir", "cache"), Type: "tmpfs"},
+			}
+			for _, path := range lustreTargets {
+				require.NoError(t, os.MkdirAll(path, 0o750))
+				volumeDataPath := filepath.Join(filepath.Dir(path), "vol_data.json")
+				if slices.Contains(test.noVolumeData, path) {
+					require.NoError(t, os.RemoveAll(volumeDataPath))
+				} else {
+					require.NoError(t, os.WriteFile(volumeDataPath,
+						[]byte(`{"driverName":"azurelustre.csi.azure.com","volumeHandle":"vol_1#lustrefs#1.1.1.1#"}`), 0o600))
+				}
+				mountPoints = append(mountPoints, mount.MountPoint{Device: "1.1.1.1@tcp:/lustrefs", Path: path, Type: "lustre"})
+			}
+			d := NewFakeDriver()
+			d.NodeID = "node-1"
+			d.workingMountDir = workingMountDir
+			d.mountReconcileDryRun = test.dryRun
+			d.mounter = &mount.SafeFormatAndMount{Interface: &fakeMounter{FakeMounter: mount.FakeMounter{MountPoints: mountPoints}}}
+			forceMounter, ok := d.mounter.Interface.(mount.MounterForceUnmounter)
+			require.True(t, ok)
+			d.forceMounter = &forceMounter
+			if !test.noKubeClient {
+				kubeClient := kubefake.NewClientset(runningPod)
+				if test.recreatedPod {
+					// The pod shows up again after the stale mounts were found
+					podLists := 0
+					kubeClient.PrependReactor("list", "pods", func(_ k8stesting.Action) (bool, runtime.Object, error) {
+						if podLists++; podLists == 1 {
+							return false, nil, nil
+						}
+						recreatedPod := runningPod.DeepCopy()
+						recreatedPod.Name, recreatedPod.UID = "deleted-pod", "deleted-pod"
+						if err := kubeClient.Tracker().Add(recreatedPod); err != nil && !apierrors.IsAlreadyExists(err) {
+							return true, nil, err
+						}
+						return false, nil, nil
+					})
+				}
+				d.kubeClient = kubeClient
+			}
+			for _, target := range test.busyTargets {
+				lockKey := getNodeVolumeLockKey("vol_1#lustrefs#1.1.1.1#", target)
+				if slices.Contains(test.noVolumeData, target) {
+					lockKey = target
+				}
+				require.True(t, d.volumeLocks.TryAcquire(lockKey))
+			}
+
+			require.NoError(t, d.reconcileMounts(context.Background(), test.startup))
+
+			remaining, err := d.getLustreMounts()
+			require.NoError(t, err)
+			var remainingPaths []string
+			for _, mountPoint := range remaining {
+				remainingPaths = append(remainingPaths, mountPoint.Path)
+			}
+			for _, path := range test.expectedRemoved {
+				assert.NotContains(t, remainingPaths, path)
+			}
+			assert.Len(t, remainingPaths, len(lustreTargets)-len(test.expectedRemoved))
+		})
+	}
+}
@@ -0,0 +1,449 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v6"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// NetworkPreflightOff, NetworkPreflightWarn and NetworkPreflightFail are
+	// what CreateVolume does when the network security group or route table
+	// of the AMLFS subnet blocks Lustre traffic: nothing, log and record a
+	// warning event, or fail the creation
+	NetworkPreflightOff  = "off"
+	NetworkPreflightWarn = "warn"
+	NetworkPreflightFail = "fail"
+
+	// lustrePort is the LNet port of the Lustre servers and clients, which
+	// connect from the privileged ports lustreClientPortFrom-lustreClientPortTo
+	lustrePort           = 988
+	lustreClientPortFrom = 1019
+	lustreClientPortTo   = 1023
+	// httpsPort is the port the AMLFS cluster reaches the Azure services on
+	httpsPort = 443
+
+	serviceTagAny            = "*"
+	serviceTagVirtualNetwork = "VirtualNetwork"
+	serviceTagInternet       = "Internet"
+	serviceTagAzureCloud     = "AzureCloud"
+)
+
+// defaultSecurityRules are the rules Azure adds to every network security
+// group, evaluated after its own rules when the group lists none
+var defaultSecurityRules = []*armnetwork.SecurityRule{
+	newSecurityRule("AllowVnetInBound", 65000, armnetwork.SecurityRuleDirectionInbound, armnetwork.SecurityRuleAccessAllow, serviceTagVirtualNetwork, serviceTagVirtualNetwork),
+	newSecurityRule("AllowAzureLoadBalancerInBound", 65001, armnetwork.SecurityRuleDirectionInbound, armnetwork.SecurityRuleAccessAllow, "AzureLoadBalancer", serviceTagAny),
+	newSecurityRule("DenyAllInBound", 65500, armnetwork.SecurityRuleDirectionInbound, armnetwork.SecurityRuleAccessDeny, serviceTagAny, serviceTagAny),
+	newSecurityRule("AllowVnetOutBound", 65000, armnetwork.SecurityRuleDirectionOutbound, armnetwork.SecurityRuleAccessAllow, serviceTagVirtualNetwork, serviceTagVirtualNetwork),
+	newSecurityRule("AllowInternetOutBound", 65001, armnetwork.SecurityRuleDirectionOutbound, armnetwork.SecurityRuleAccessAllow, serviceTagAny, serviceTagInternet),
+	newSecurityRule("DenyAllOutBound", 65500, armnetwork.SecurityRuleDirectionOutbound, armnetwork.SecurityRuleAccessDeny, serviceTagAny, serviceTagAny),
+}
+
+func newSecurityRule(name string, priority int32, direction armnetwork.SecurityRuleDirection, access armnetwork.SecurityRuleAccess, source, destination string) *armnetwork.SecurityRule {
+	return &armnetwork.SecurityRule{
+		Name: to.Ptr(name),
+		Properties: &armnetwork.SecurityRulePropertiesFormat{
+			Access:                   to.Ptr(access),
+			Direction:                to.Ptr(direction),
+			Priority:                 to.Ptr(priority),
+			Protocol:                 to.Ptr(armnetwork.SecurityRuleProtocolAsterisk),
+			SourceAddressPrefix:      to.Ptr(source),
+			SourcePortRange:          to.Ptr(serviceTagAny),
+			DestinationAddressPrefix: to.Ptr(destination),
+			DestinationPortRange:     to.Ptr(serviceTagAny),
+		},
+	}
+}
+
+// networkEndpoint is one end of the traffic an AMLFS cluster needs, either
+// the address prefixes of a subnet or a service tag
+type networkEndpoint struct {
+	name       string
+	prefixes   []netip.Prefix
+	serviceTag string
+}
+
+func (e *networkEndpoint) String() string {
+	if e.serviceTag != "" {
+		return e.serviceTag
+	}
+	prefixes := make([]string, 0, len(e.prefixes))
+	for _, prefix := range e.prefixes {
+		prefixes = append(prefixes, prefix.String())
+	}
+	return fmt.Sprintf("the %s (%s)", e.name, strings.Join(prefixes, ", "))
+}
+
+// networkFlow is TCP traffic to the ports portFrom-portTo the network
+// security group of the AMLFS subnet must allow in direction
+type networkFlow struct {
+	direction   armnetwork.SecurityRuleDirection
+	source      *networkEndpoint
+	destination *networkEndpoint
+	portFrom    int
+	portTo      int
+}
+
+func (f networkFlow) String() string {
+	ports := strconv.Itoa(f.portFrom)
+	if f.portTo != f.portFrom {
+		ports = fmt.Sprintf("%d-%d", f.portFrom, f.portTo)
+	}
+	return fmt.Sprintf("%s TCP %s from %s to %s", strings.ToLower(string(f.direction)), ports, f.source, f.destination)
+}
+
+// amlFilesystemNetworkFlows returns the traffic the AMLFS cluster needs: Lustre
+// within its subnet and with the clients, if known, and HTTPS to Azure
+func amlFilesystemNetworkFlows(amlfsSubnet, clientSubnet *networkEndpoint) []networkFlow {
+	peers := []*networkEndpoint{amlfsSubnet}
+	if clientSubnet != nil {
+		peers = append(peers, clientSubnet)
+	}
+	var flows []networkFlow
+	for _, peer := range peers {
+		for _, ports := range [][2]int{{lustrePort, lustrePort}, {lustreClientPortFrom, lustreClientPortTo}} {
+			flows = append(flows,
+				networkFlow{direction: armnetwork.SecurityRuleDirectionInbound, source: peer, destination: amlfsSubnet, portFrom: ports[0], portTo: ports[1]},
+				networkFlow{direction: armnetwork.SecurityRuleDirectionOutbound, source: amlfsSubnet, destination: peer, portFrom: ports[0], portTo: ports[1]})
+		}
+	}
+	return append(flows, networkFlow{
+		direction:   armnetwork.SecurityRuleDirectionOutbound,
+		source:      amlfsSubnet,
+		destination: &networkEndpoint{serviceTag: serviceTagAzureCloud},
+		portFrom:    httpsPort,
+		portTo:      httpsPort,
+	})
+}
+
+// securityGroupProblems returns the flows the network security group denies,
+// evaluating its rules by priority as Azure does. An allow rule only applies
+// when it allows the whole flow and a deny rule as soon as it denies part of
+// it, so the flows are reported as denied when in doubt. Rules with
+// application security groups are ignored.
+func securityGroupProblems(securityGroup *armnetwork.SecurityGroup, flows []networkFlow) []string {
+	if securityGroup.Properties == nil {
+		return nil
+	}
+	defaultRules := securityGroup.Properties.DefaultSecurityRules
+	if len(defaultRules) == 0 {
+		defaultRules = defaultSecurityRules
+	}
+	rules := slices.Concat(securityGroup.Properties.SecurityRules, defaultRules)
+	slices.SortStableFunc(rules, func(a, b *armnetwork.SecurityRule) int {
+		return cmp.Compare(securityRulePriority(a), securityRulePriority(b))
+	})
+
+	var problems []string
+	for _, flow := range flows {
+		for _, rule := range rules {
+			if !securityRuleMatches(rule, flow) {
+				continue
+			}
+			if *rule.Properties.Access == armnetwork.SecurityRuleAccessDeny {
+				problems = append(problems, fmt.Sprintf("%s is denied by security rule %s (priority %d) of network security group %s",
+					flow, stringValue(rule.Name), securityRulePriority(rule), stringValue(securityGroup.Name)))
+			}
+			break
+		}
+	}
+	return problems
+}
+
+func securityRulePriority(rule *armnetwork.SecurityRule) int32 {
+	if rule.Properties == nil || rule.Properties.Priority == nil {
+		return math.MaxInt32
+	}
+	return *rule.Properties.Priority
+}
+
+func securityRuleMatches(rule *armnetwork.SecurityRule, flow networkFlow) bool {
+	properties := rule.Properties
+	if properties == nil || properties.Access == nil || properties.Direction == nil || *properties.Direction != flow.direction {
+		return false
+	}
+	if len(properties.SourceApplicationSecurityGroups) > 0 || len(properties.DestinationApplicationSecurityGroups) > 0 {
+		return false
+	}
+	if properties.Protocol != nil && *properties.Protocol != armnetwork.SecurityRuleProtocolAsterisk && *properties.Protocol != armnetwork.SecurityRuleProtocolTCP {
+		return false
+	}
+	deny := *properties.Access == armnetwork.SecurityRuleAccessDeny
+	return portsMatch(ruleValues(properties.DestinationPortRange, properties.DestinationPortRanges), flow.portFrom, flow.portTo, deny) &&
+		addressesMatch(ruleValues(properties.SourceAddressPrefix, properties.SourceAddressPrefixes), flow.source, deny) &&
+		addressesMatch(ruleValues(properties.DestinationAddressPrefix, properties.DestinationAddressPrefixes), flow.destination, deny)
+}
+
+// ruleValues returns the single value of a rule property if set, its list
+// of values otherwise
+func ruleValues(value *string, values []*string) []string {
+	if value != nil && *value != "" {
+		return []string{*value}
+	}
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if value != nil {
+			result = append(result, *value)
+		}
+	}
+	return result
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+// portsMatch reports whether the port ranges of a rule cover the ports
+// from-to or, for a deny rule, overlap them
+func portsMatch(ranges []string, from, to int, deny bool) bool {
+	for _, portRange := range ranges {
+		if portRange == serviceTagAny {
+			return true
+		}
+		first, last, isRange := strings.Cut(portRange, "-")
+		if !isRange {
+			last = first
+		}
+		rangeFrom, errFrom := strconv.Atoi(strings.TrimSpace(first))
+		rangeTo, errTo := strconv.Atoi(strings.TrimSpace(last))
+		if errFrom != nil || errTo != nil {
+			continue
+		}
+		if (deny && rangeFrom <= to && rangeTo >= from) || (rangeFrom <= from && rangeTo >= to) {
+			return true
+		}
+	}
+	return false
+}
+
+// addressesMatch reports whether the address prefixes or service tags of a
+// rule cover the endpoint or, for a deny rule, overlap it
+func addressesMatch(addresses []string, endpoint *networkEndpoint, deny bool) bool {
+	for _, address := range addresses {
+		switch {
+		case address == serviceTagAny || address == "0.0.0.0/0":
+			return true
+		case endpoint.serviceTag != "":
+			if strings.EqualFold(address, serviceTagInternet) || strings.EqualFold(address, endpoint.serviceTag) ||
+				strings.HasPrefix(strings.ToLower(address), strings.ToLower(endpoint.serviceTag)+".") {
+				return true
+			}
+		case strings.EqualFold(address, serviceTagVirtualNetwork):
+			return true
+		default:
+			prefix, ok := parsePrefix(address)
+			if !ok {
+				continue
+			}
+			if deny && slices.ContainsFunc(endpoint.prefixes, prefix.Overlaps) {
+				return true
+			}
+			if !deny && len(endpoint.prefixes) > 0 && prefixContainsAll(prefix, endpoint.prefixes) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parsePrefix parses an address prefix or a single IP address
+func parsePrefix(address string) (netip.Prefix, bool) {
+	if prefix, err := netip.ParsePrefix(address); err == nil {
+		return prefix.Masked(), true
+	}
+	if addr, err := netip.ParseAddr(address); err == nil {
+		return netip.PrefixFrom(addr, addr.BitLen()), true
+	}
+	return netip.Prefix{}, false
+}
+
+func prefixContains(outer, inner netip.Prefix) bool {
+	return outer.Bits() <= inner.Bits() && outer.Contains(inner.Addr())
+}
+
+func prefixContainsAll(outer netip.Prefix, prefixes []netip.Prefix) bool {
+	for _, prefix := range prefixes {
+		if !prefixContains(outer, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// routeTableProblems returns the outbound flows the route table drops, those
+// whose most specific route has no next hop
+func routeTableProblems(routeTable *armnetwork.RouteTable, flows []networkFlow) []string {
+	if routeTable.Properties == nil {
+		return nil
+	}
+	var problems []string
+	reported := map[*networkEndpoint]bool{}
+	for _, flow := range flows {
+		if flow.direction != armnetwork.SecurityRuleDirectionOutbound || reported[flow.destination] {
+			continue
+		}
+		destinations := flow.destination.prefixes
+		if flow.destination.serviceTag != "" {
+			destinations = []netip.Prefix{netip.MustParsePrefix("0.0.0.0/0")}
+		}
+		for _, destination := range destinations {
+			route := mostSpecificRoute(routeTable.Properties.Routes, destination)
+			if route != nil && route.Properties.NextHopType != nil && *route.Properties.NextHopType == armnetwork.RouteNextHopTypeNone {
+				problems = append(problems, fmt.Sprintf("traffic to %s is dropped by route %s (%s, next hop None) of route table %s",
+					flow.destination, stringValue(route.Name), stringValue(route.Properties.AddressPrefix), stringValue(routeTable.Name)))
+				reported[flow.destination] = true
+				break
+			}
+		}
+	}
+	return problems
+}
+
+// mostSpecificRoute returns the route with the longest address prefix
+// containing destination, nil when none does
+func mostSpecificRoute(routes []*armnetwork.Route, destination netip.Prefix) *armnetwork.Route {
+	var best *armnetwork.Route
+	bestBits := -1
+	for _, route := range routes {
+		if route.Properties == nil || route.Properties.AddressPrefix == nil {
+			continue
+		}
+		prefix, ok := parsePrefix(*route.Properties.AddressPrefix)
+		if ok && prefixContains(prefix, destination) && prefix.Bits() > bestBits {
+			best, bestBits = route, prefix.Bits()
+		}
+	}
+	return best
+}
+
+// subnetEndpoint returns the subnet with its address prefixes
+func subnetEndpoint(ctx context.Context, clients *networkClients, name string, subnetInfo SubnetProperties) (*armnetwork.Subnet, *networkEndpoint, error) {
+	resp, err := clients.subnetsClient.Get(ctx, subnetInfo.VnetResourceGroup, subnetInfo.VnetName, subnetInfo.SubnetName, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	endpoint := &networkEndpoint{name: name}
+	if resp.Properties != nil {
+		for _, address := range ruleValues(resp.Properties.AddressPrefix, resp.Properties.AddressPrefixes) {
+			if prefix, ok := parsePrefix(address); ok {
+				endpoint.prefixes = append(endpoint.prefixes, prefix)
+			}
+		}
+	}
+	if len(endpoint.prefixes) == 0 {
+		return nil, nil, fmt.Errorf("subnet %s has no address prefix", subnetInfo.SubnetID)
+	}
+	return &resp.Subnet, endpoint, nil
+}
+
+// findNetworkSecurityProblems returns the traffic of the AMLFS cluster the
+// network security group and the route table of its subnet block
+func (d *DynamicProvisioner) findNetworkSecurityProblems(ctx context.Context, subnetInfo SubnetProperties) ([]string, error) {
+	// The network security group and route table of a subnet are in the
+	// subscription of its vnet
+	clients, err := d.networkClientsFor(ctx, subnetInfo)
+	if err != nil {
+		return nil, err
+	}
+	subnet, amlfsSubnet, err := subnetEndpoint(ctx, clients, "AMLFS subnet", subnetInfo)
+	if err != nil {
+		return nil, err
+	}
+	var clientSubnet *networkEndpoint
+	if d.clientSubnet.SubnetName != "" {
+		clientSubnetClients, err := d.networkClientsFor(ctx, d.clientSubnet)
+		if err != nil {
+			return nil, err
+		}
+		if _, clientSubnet, err = subnetEndpoint(ctx, clientSubnetClients, "AKS subnet", d.clientSubnet); err != nil {
+			return nil, err
+		}
+	}
+	flows := amlFilesystemNetworkFlows(amlfsSubnet, clientSubnet)
+
+	var problems []string
+	if subnet.Properties.NetworkSecurityGroup != nil && subnet.Properties.NetworkSecurityGroup.ID != nil {
+		resourceID, err := arm.ParseResourceID(*subnet.Properties.NetworkSecurityGroup.ID)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := clients.securityGroupsClient.Get(ctx, resourceID.ResourceGroupName, resourceID.Name, nil)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, securityGroupProblems(&resp.SecurityGroup, flows)...)
+	}
+	if subnet.Properties.RouteTable != nil && subnet.Properties.RouteTable.ID != nil {
+		resourceID, err := arm.ParseResourceID(*subnet.Properties.RouteTable.ID)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := clients.routeTablesClient.Get(ctx, resourceID.ResourceGroupName, resourceID.Name, nil)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, routeTableProblems(&resp.RouteTable, flows)...)
+	}
+	return problems, nil
+}
+
+// checkNetworkSecurity checks that the network security group and the route
+// table of the AMLFS subnet let the Lustre clients mount the cluster, so a
+// cluster that can never be mounted is not created unnoticed. The check is
+// best effort: when the network resources cannot be read, the creation goes
+// ahead.
+func (d *DynamicProvisioner) checkNetworkSecurity(ctx context.Context, amlFilesystemProperties *AmlFilesystemProperties) error {
+	if d.networkPreflight == "" || d.networkPreflight == NetworkPreflightOff || d.subnetsClient == nil {
+		return nil
+	}
+	subnetInfo := amlFilesystemProperties.SubnetInfo
+	problems, err := d.findNetworkSecurityProblems(ctx, subnetInfo)
+	if err != nil {
+		klog.Warningf("failed to read the network security of subnet %s, skipping network preflight: %v", subnetInfo.SubnetID, err)
+		return nil
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+
+	message := fmt.Sprintf("the network of subnet %s blocks traffic AMLFS cluster %s needs to be mounted: %s",
+		subnetInfo.SubnetID, amlFilesystemProperties.AmlFilesystemName, strings.Join(problems, "; "))
+	if d.networkPreflight == NetworkPreflightFail {
+		return status.Error(codes.FailedPrecondition, message)
+	}
+	klog.Warning(message)
+	amlFilesystemProperties.progress.warn(eventReasonAmlFilesystemNetworkBlocked, "%s", message)
+	return nil
+}
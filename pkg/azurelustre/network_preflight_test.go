@@ -0,0 +1,270 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v6"
+	networkfake "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v6/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	testAmlfsSubnetPrefix  = "10.0.1.0/24"
+	testClientSubnetPrefix = "10.224.0.0/16"
+)
+
+func newTestNetworkFlows() []networkFlow {
+	return amlFilesystemNetworkFlows(
+		&networkEndpoint{name: "AMLFS subnet", prefixes: []netip.Prefix{netip.MustParsePrefix(testAmlfsSubnetPrefix)}},
+		&networkEndpoint{name: "AKS subnet", prefixes: []netip.Prefix{netip.MustParsePrefix(testClientSubnetPrefix)}},
+	)
+}
+
+func newTestSecurityRule(name string, priority int32, direction armnetwork.SecurityRuleDirection, access armnetwork.SecurityRuleAccess, source, destination, ports string) *armnetwork.SecurityRule {
+	rule := newSecurityRule(name, priority, direction, access, source, destination)
+	rule.Properties.Protocol = to.Ptr(armnetwork.SecurityRuleProtocolTCP)
+	rule.Properties.DestinationPortRange = to.Ptr(ports)
+	return rule
+}
+
+func TestSecurityGroupProblems(t *testing.T) {
+	inbound, outbound := armnetwork.SecurityRuleDirectionInbound, armnetwork.SecurityRuleDirectionOutbound
+	allow, deny := armnetwork.SecurityRuleAccessAllow, armnetwork.SecurityRuleAccessDeny
+	cases := []struct {
+		desc     string
+		rules    []*armnetwork.SecurityRule
+		expected []string
+	}{
+		{
+			desc: "default rules",
+		},
+		{
+			desc:  "Lustre port denied",
+			rules: []*armnetwork.SecurityRule{newTestSecurityRule("deny-lustre", 100, inbound, deny, "*", "*", "988")},
+			expected: []string{
+				"inbound TCP 988 from the AMLFS subnet (10.0.1.0/24) to the AMLFS subnet (10.0.1.0/24) is denied by security rule deny-lustre (priority 100) of network security group amlfs-nsg",
+				"inbound TCP 988 from the AKS subnet (10.224.0.0/16) to the AMLFS subnet (10.0.1.0/24) is denied by security rule deny-lustre (priority 100) of network security group amlfs-nsg",
+			},
+		},
+		{
+			desc: "only Lustre port allowed from clients",
+			rules: []*armnetwork.SecurityRule{
+				newTestSecurityRule("allow-lustre", 100, inbound, allow, testClientSubnetPrefix, "VirtualNetwork", "988"),
+				newTestSecurityRule("deny-all", 200, inbound, deny, "*", "*", "*"),
+			},
+			expected: []string{
+				"inbound TCP 988 from the AMLFS subnet (10.0.1.0/24) to the AMLFS subnet (10.0.1.0/24) is denied by security rule deny-all (priority 200) of network security group amlfs-nsg",
+				"inbound TCP 1019-1023 from the AMLFS subnet (10.0.1.0/24) to the AMLFS subnet (10.0.1.0/24) is denied by security rule deny-all (priority 200) of network security group amlfs-nsg",
+				"inbound TCP 1019-1023 from the AKS subnet (10.224.0.0/16) to the AMLFS subnet (10.0.1.0/24) is denied by security rule deny-all (priority 200) of network security group amlfs-nsg",
+			},
+		},
+		{
+			desc: "allow rule covering part of the flow",
+			rules: []*armnetwork.SecurityRule{
+				newTestSecurityRule("allow-part", 100, outbound, allow, "*", "10.224.0.0/17", "*"),
+				newTestSecurityRule("deny-overlap", 200, outbound, deny, "*", "10.224.128.0/24", "1000-1020"),
+			},
+			expected: []string{
+				"outbound TCP 1019-1023 from the AMLFS subnet (10.0.1.0/24) to the AKS subnet (10.224.0.0/16) is denied by security rule deny-overlap (priority 200) of network security group amlfs-nsg",
+			},
+		},
+		{
+			desc:  "Azure denied",
+			rules: []*armnetwork.SecurityRule{newTestSecurityRule("deny-internet", 4000, outbound, deny, "*", "Internet", "*")},
+			expected: []string{
+				"outbound TCP 443 from the AMLFS subnet (10.0.1.0/24) to AzureCloud is denied by security rule deny-internet (priority 4000) of network security group amlfs-nsg",
+			},
+		},
+		{
+			desc: "Azure allowed by service tag",
+			rules: []*armnetwork.SecurityRule{
+				newTestSecurityRule("allow-azure", 100, outbound, allow, "*", "AzureCloud.westus", "443"),
+				newTestSecurityRule("deny-internet", 4000, outbound, deny, "*", "Internet", "*"),
+			},
+		},
+		{
+			desc: "application security groups ignored",
+			rules: func() []*armnetwork.SecurityRule {
+				rule := newTestSecurityRule("deny-asg", 100, inbound, deny, "", "*", "*")
+				rule.Properties.SourceApplicationSecurityGroups = []*armnetwork.ApplicationSecurityGroup{{ID: to.Ptr("asg")}}
+				return []*armnetwork.SecurityRule{rule}
+			}(),
+		},
+		{
+			desc:  "UDP rule ignored",
+			rules: []*armnetwork.SecurityRule{newTestSecurityRule("deny-udp", 100, inbound, deny, "*", "*", "*")},
+		},
+	}
+	cases[len(cases)-1].rules[0].Properties.Protocol = to.Ptr(armnetwork.SecurityRuleProtocolUDP)
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			securityGroup := &armnetwork.SecurityGroup{
+				Name:       to.Ptr("amlfs-nsg"),
+				Properties: &armnetwork.SecurityGroupPropertiesFormat{SecurityRules: c.rules},
+			}
+			assert.Equal(t, c.expected, securityGroupProblems(securityGroup, newTestNetworkFlows()))
+		})
+	}
+}
+
+func TestRouteTableProblems(t *testing.T) {
+	newRoute := func(name, prefix string, nextHop armnetwork.RouteNextHopType) *armnetwork.Route {
+		return &armnetwork.Route{
+			Name:       to.Ptr(name),
+			Properties: &armnetwork.RoutePropertiesFormat{AddressPrefix: to.Ptr(prefix), NextHopType: to.Ptr(nextHop)},
+		}
+	}
+	cases := []struct {
+		desc     string
+		routes   []*armnetwork.Route
+		expected []string
+	}{
+		{
+			desc: "firewall",
+			routes: []*armnetwork.Route{
+				newRoute("default", "0.0.0.0/0", armnetwork.RouteNextHopTypeVirtualAppliance),
+				newRoute("part-of-clients", "10.224.1.0/24", armnetwork.RouteNextHopTypeNone),
+			},
+		},
+		{
+			desc: "blackholes",
+			routes: []*armnetwork.Route{
+				newRoute("default", "0.0.0.0/0", armnetwork.RouteNextHopTypeNone),
+				newRoute("vnet", "10.0.0.0/16", armnetwork.RouteNextHopTypeVnetLocal),
+			},
+			expected: []string{
+				"traffic to the AKS subnet (10.224.0.0/16) is dropped by route default (0.0.0.0/0, next hop None) of route table amlfs-routes",
+				"traffic to AzureCloud is dropped by route default (0.0.0.0/0, next hop None) of route table amlfs-routes",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			routeTable := &armnetwork.RouteTable{
+				Name:       to.Ptr("amlfs-routes"),
+				Properties: &armnetwork.RouteTablePropertiesFormat{Routes: c.routes},
+			}
+			assert.Equal(t, c.expected, routeTableProblems(routeTable, newTestNetworkFlows()))
+		})
+	}
+}
+
+func newTestNetworkPreflightProvisioner(t *testing.T, networkPreflight string) *DynamicProvisioner {
+	subnets := map[string]armnetwork.Subnet{
+		"amlfs-subnet": {Properties: &armnetwork.SubnetPropertiesFormat{
+			AddressPrefix:        to.Ptr(testAmlfsSubnetPrefix),
+			NetworkSecurityGroup: &armnetwork.SecurityGroup{ID: to.Ptr("/subscriptions/sub/resourceGroups/nsg-rg/providers/Microsoft.Network/networkSecurityGroups/amlfs-nsg")},
+			RouteTable:           &armnetwork.RouteTable{ID: to.Ptr("/subscriptions/sub/resourceGroups/nsg-rg/providers/Microsoft.Network/routeTables/amlfs-routes")},
+		}},
+		"aks-subnet": {Properties: &armnetwork.SubnetPropertiesFormat{AddressPrefixes: to.SliceOfPtrs(testClientSubnetPrefix)}},
+	}
+	serverFactory := networkfake.ServerFactory{
+		SubnetsServer: networkfake.SubnetsServer{
+			Get: func(_ context.Context, _, _, subnetName string, _ *armnetwork.SubnetsClientGetOptions) (azfake.Responder[armnetwork.SubnetsClientGetResponse], azfake.ErrorResponder) {
+				resp := azfake.Responder[armnetwork.SubnetsClientGetResponse]{}
+				errResp := azfake.ErrorResponder{}
+				subnet, ok := subnets[subnetName]
+				if !ok {
+					errResp.SetResponseError(http.StatusNotFound, "NotFound")
+					return resp, errResp
+				}
+				resp.SetResponse(http.StatusOK, armnetwork.SubnetsClientGetResponse{Subnet: subnet}, nil)
+				return resp, errResp
+			},
+		},
+		SecurityGroupsServer: networkfake.SecurityGroupsServer{
+			Get: func(_ context.Context, _, name string, _ *armnetwork.SecurityGroupsClientGetOptions) (azfake.Responder[armnetwork.SecurityGroupsClientGetResponse], azfake.ErrorResponder) {
+				resp := azfake.Responder[armnetwork.SecurityGroupsClientGetResponse]{}
+				resp.SetResponse(http.StatusOK, armnetwork.SecurityGroupsClientGetResponse{SecurityGroup: armnetwork.SecurityGroup{
+					Name: to.Ptr(name),
+					Properties: &armnetwork.SecurityGroupPropertiesFormat{SecurityRules: []*armnetwork.SecurityRule{
+						newTestSecurityRule("deny-lustre", 100, armnetwork.SecurityRuleDirectionInbound, armnetwork.SecurityRuleAccessDeny, testClientSubnetPrefix, "*", "988"),
+					}},
+				}}, nil)
+				return resp, azfake.ErrorResponder{}
+			},
+		},
+		RouteTablesServer: networkfake.RouteTablesServer{
+			Get: func(_ context.Context, _, name string, _ *armnetwork.RouteTablesClientGetOptions) (azfake.Responder[armnetwork.RouteTablesClientGetResponse], azfake.ErrorResponder) {
+				resp := azfake.Responder[armnetwork.RouteTablesClientGetResponse]{}
+				resp.SetResponse(http.StatusOK, armnetwork.RouteTablesClientGetResponse{RouteTable: armnetwork.RouteTable{
+					Name:       to.Ptr(name),
+					Properties: &armnetwork.RouteTablePropertiesFormat{},
+				}}, nil)
+				return resp, azfake.ErrorResponder{}
+			},
+		},
+	}
+	clientFactory, err := armnetwork.NewClientFactory("fake-subscription-id", &azfake.TokenCredential{},
+		&arm.ClientOptions{
+			ClientOptions: azcore.ClientOptions{
+				Transport: networkfake.NewServerFactoryTransport(&serverFactory),
+			},
+		},
+	)
+	require.NoError(t, err)
+	return &DynamicProvisioner{
+		networkPreflight:     networkPreflight,
+		subnetsClient:        clientFactory.NewSubnetsClient(),
+		securityGroupsClient: clientFactory.NewSecurityGroupsClient(),
+		routeTablesClient:    clientFactory.NewRouteTablesClient(),
+		clientSubnet:         SubnetProperties{VnetResourceGroup: "aks-rg", VnetName: "aks-vnet", SubnetName: "aks-subnet"},
+	}
+}
+
+func TestCheckNetworkSecurity(t *testing.T) {
+	newProperties := func(recorder record.EventRecorder) *AmlFilesystemProperties {
+		return &AmlFilesystemProperties{
+			AmlFilesystemName: expectedAmlFilesystemName,
+			SubnetInfo:        SubnetProperties{VnetResourceGroup: "vnet-rg", VnetName: "vnet", SubnetName: "amlfs-subnet", SubnetID: "amlfs-subnet-id"},
+			progress:          newTestProvisioningProgress(recorder),
+		}
+	}
+	expectedProblem := "inbound TCP 988 from the AKS subnet (10.224.0.0/16) to the AMLFS subnet (10.0.1.0/24) is denied by security rule deny-lustre (priority 100) of network security group amlfs-nsg"
+
+	recorder := record.NewFakeRecorder(10)
+	require.NoError(t, newTestNetworkPreflightProvisioner(t, NetworkPreflightOff).checkNetworkSecurity(context.Background(), newProperties(recorder)))
+	require.NoError(t, newTestNetworkPreflightProvisioner(t, NetworkPreflightWarn).checkNetworkSecurity(context.Background(), newProperties(recorder)))
+	events := getRecordedEvents(recorder)
+	require.Len(t, events, 1)
+	assert.Contains(t, events[0], "Warning AmlFilesystemNetworkBlocked the network of subnet amlfs-subnet-id blocks traffic AMLFS cluster "+expectedAmlFilesystemName+" needs to be mounted")
+	assert.Contains(t, events[0], expectedProblem)
+
+	err := newTestNetworkPreflightProvisioner(t, NetworkPreflightFail).checkNetworkSecurity(context.Background(), newProperties(recorder))
+	require.ErrorContains(t, err, expectedProblem)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	// The check is skipped when the network cannot be read
+	properties := newProperties(recorder)
+	properties.SubnetInfo.SubnetName = "missing-subnet"
+	require.NoError(t, newTestNetworkPreflightProvisioner(t, NetworkPreflightFail).checkNetworkSecurity(context.Background(), properties))
+}
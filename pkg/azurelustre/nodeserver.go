@@ -36,13 +36,14 @@ import (
 
 // NodePublishVolume mount the volume from staging to target path
 func (d *Driver) NodePublishVolume(
-	_ context.Context,
+	ctx context.Context,
 	req *csi.NodePublishVolumeRequest,
 ) (*csi.NodePublishVolumeResponse, error) {
+	azureClients := d.azureClientsFor(ctx)
 	mc := metrics.NewMetricContext(azureLustreCSIDriverName,
 		"node_publish_volume",
-		d.resourceGroup,
-		d.cloud.SubscriptionID,
+		azureClients.resourceGroup,
+		azureClients.cloud.SubscriptionID,
 		d.Name)
 
 	volCap := req.GetVolumeCapability()
@@ -75,7 +76,12 @@ func (d *Driver) NodePublishVolume(
 		return nil, err
 	}
 
-	lockKey := fmt.Sprintf("%s-%s", volumeID, target)
+	clientTunables, err := parseClientTunables(context)
+	if err != nil {
+		return nil, err
+	}
+
+	lockKey := getNodeVolumeLockKey(volumeID, target)
 	if acquired := d.volumeLocks.TryAcquire(lockKey); !acquired {
 		return nil, status.Errorf(codes.Aborted,
 			volumeOperationAlreadyExistsFmt,
@@ -169,6 +175,15 @@ func (d *Driver) NodePublishVolume(
 			"Could not mount %q at %q: %v", source, target, err)
 	}
 
+	err = d.applyClientTunables(target, clientTunables)
+	if err != nil {
+		if unmountErr := unmountVolumeAtPath(d, target); unmountErr != nil {
+			klog.Warningf("failed to clean up mount %s after client tunables failure: %v", target, unmountErr)
+		}
+		return nil, status.Errorf(codes.Internal,
+			"Could not apply client tunables to %q: %v", target, err)
+	}
+
 	klog.V(2).Infof(
 		"NodePublishVolume: volume %s mount %s at %s successfully",
 		volumeID,
@@ -252,6 +267,7 @@ func getVolume(volumeID string, context map[string]string) (*lustreVolume, error
 func mountVolumeAtPath(d *Driver, source, target string, mountOptions []string) error {
 	d.kernelModuleLock.Lock()
 	defer d.kernelModuleLock.Unlock()
+	start := time.Now()
 	err := d.mounter.MountSensitiveWithoutSystemdWithMountFlags(
 		source,
 		target,
@@ -260,18 +276,20 @@ func mountVolumeAtPath(d *Driver, source, target string, mountOptions []string)
 		nil,
 		[]string{"--no-mtab"},
 	)
+	observeMountOperation(mountOperationMount, start, err)
 	return err
 }
 
 // NodeUnpublishVolume unmount the volume from the target path
 func (d *Driver) NodeUnpublishVolume(
-	_ context.Context,
+	ctx context.Context,
 	req *csi.NodeUnpublishVolumeRequest,
 ) (*csi.NodeUnpublishVolumeResponse, error) {
+	azureClients := d.azureClientsFor(ctx)
 	mc := metrics.NewMetricContext(azureLustreCSIDriverName,
 		"node_unpublish_volume",
-		d.resourceGroup,
-		d.cloud.SubscriptionID,
+		azureClients.resourceGroup,
+		azureClients.cloud.SubscriptionID,
 		d.Name)
 
 	volumeID := req.GetVolumeId()
@@ -286,7 +304,7 @@ func (d *Driver) NodeUnpublishVolume(
 			"Target path missing in request")
 	}
 
-	lockKey := fmt.Sprintf("%s-%s", volumeID, targetPath)
+	lockKey := getNodeVolumeLockKey(volumeID, targetPath)
 	if acquired := d.volumeLocks.TryAcquire(lockKey); !acquired {
 		return nil, status.Errorf(codes.Aborted,
 			volumeOperationAlreadyExistsFmt,
@@ -322,6 +340,7 @@ func unmountVolumeAtPath(d *Driver, targetPath string) error {
 
 	d.kernelModuleLock.Lock()
 	defer d.kernelModuleLock.Unlock()
+	start := time.Now()
 
 	parent := filepath.Dir(targetPath)
 	klog.V(2).Infof("Listing dir: %s", parent)
@@ -356,6 +375,7 @@ func unmountVolumeAtPath(d *Driver, targetPath string) error {
 
 	err = mount.CleanupMountWithForce(targetPath, *d.forceMounter,
 		true /*extensiveMountPointCheck*/, 10*time.Second)
+	observeMountOperation(mountOperationUnmount, start, err)
 	return err
 }
 
@@ -706,7 +726,7 @@ func ensureStrictSubpath(subPath string) bool {
 
 // Convert context parameters to a lustreVolume
 func newLustreVolume(volumeID, volumeName string, params map[string]string) (*lustreVolume, error) {
-	var mgsIPAddress, subDir, resourceGroupName string
+	var mgsIPAddress, subDir, resourceGroupName, subscriptionID string
 	createdByDynamicProvisioning := false
 
 	// validate parameters (case-insensitive).
@@ -733,6 +753,8 @@ func newLustreVolume(volumeID, volumeName string, params map[string]string) (*lu
 			}
 		case VolumeContextResourceGroupName:
 			resourceGroupName = v
+		case VolumeContextSubscriptionID:
+			subscriptionID = v
 		}
 	}
 
@@ -751,6 +773,7 @@ func newLustreVolume(volumeID, volumeName string, params map[string]string) (*lu
 		id:                           volumeID,
 		createdByDynamicProvisioning: createdByDynamicProvisioning,
 		resourceGroupName:            resourceGroupName,
+		subscriptionID:               subscriptionID,
 	}
 
 	return vol, nil
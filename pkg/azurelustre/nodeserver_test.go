@@ -502,6 +502,61 @@ func TestNodePublishVolume(t *testing.T) {
 			expectedMountpoints:  nil,
 			expectedMountActions: []mount.FakeAction{},
 		},
+		{
+			desc: "Invalid client tunables",
+			req: csi.NodePublishVolumeRequest{
+				VolumeCapability: &csi.VolumeCapability{AccessMode: &volumeCap},
+				VolumeId:         "vol_1#lustrefs#1.1.1.1#testSubDir",
+				TargetPath:       targetTest,
+				VolumeContext:    map[string]string{"mgs-ip-address": "1.1.1.1", "fs-name": "lustrefs", "sub-dir": subDir, "client-tunables": "llite.xattr_cache=0"},
+			},
+			expectedErr: status.Error(codes.InvalidArgument,
+				"Context client-tunables key \"llite.xattr_cache\" is not allowed, allowed llite parameters: [checksums max_cached_mb max_read_ahead_mb max_read_ahead_per_file_mb max_read_ahead_whole_mb statahead_agl statahead_max]"),
+			expectedMountpoints:  nil,
+			expectedMountActions: []mount.FakeAction{},
+		},
+		{
+			desc: "Valid client tunables",
+			setup: func(d *Driver) {
+				var commandLog [][]string
+				d.mounter.Exec = newScriptedFakeExec([]fakeCommandResult{
+					{output: "lustrefs-ffff8d5c9b3f5000 " + targetTest},
+					{},
+				}, &commandLog)
+			},
+			req: csi.NodePublishVolumeRequest{
+				VolumeCapability: &csi.VolumeCapability{AccessMode: &volumeCap},
+				VolumeId:         "vol_1#lustrefs#1.1.1.1#",
+				TargetPath:       targetTest,
+				VolumeContext:    map[string]string{"mgs-ip-address": "1.1.1.1", "fs-name": "lustrefs", "client-tunables": "llite.max_read_ahead_mb=1024"},
+			},
+			expectedErr:          nil,
+			expectedMountpoints:  []mount.MountPoint{{Device: "1.1.1.1@tcp:/lustrefs", Path: "target_test", Type: "lustre", Opts: []string{}}},
+			expectedMountActions: []mount.FakeAction{{Action: "mount", Target: "target_test", Source: "1.1.1.1@tcp:/lustrefs", FSType: "lustre"}},
+		},
+		{
+			desc: "Error applying client tunables",
+			setup: func(d *Driver) {
+				var commandLog [][]string
+				d.mounter.Exec = newScriptedFakeExec([]fakeCommandResult{
+					{output: "lustrefs-ffff8d5c9b3f5000 " + targetTest},
+					{output: "error", err: errors.New("exit status 1")},
+				}, &commandLog)
+			},
+			req: csi.NodePublishVolumeRequest{
+				VolumeCapability: &csi.VolumeCapability{AccessMode: &volumeCap},
+				VolumeId:         "vol_1#lustrefs#1.1.1.1#",
+				TargetPath:       targetTest,
+				VolumeContext:    map[string]string{"mgs-ip-address": "1.1.1.1", "fs-name": "lustrefs", "client-tunables": "osc.max_dirty_mb=512"},
+			},
+			expectedErr: status.Error(codes.Internal,
+				"Could not apply client tunables to \"target_test\": lctl set_param osc.lustrefs-OST*-osc-ffff8d5c9b3f5000.max_dirty_mb=512 failed: exit status 1, output: \"error\""),
+			expectedMountpoints: []mount.MountPoint{},
+			expectedMountActions: []mount.FakeAction{
+				{Action: "mount", Target: "target_test", Source: "1.1.1.1@tcp:/lustrefs", FSType: "lustre"},
+				{Action: "unmount", Target: "target_test", Source: "", FSType: ""},
+			},
+		},
 		{
 			desc: "Error volume operation in progress",
 			setup: func(d *Driver) {
@@ -1062,6 +1117,24 @@ func TestNewLustreVolume(t *testing.T) {
 				resourceGroupName: "test-amlfilesystem-rg",
 			},
 		},
+		{
+			desc:    "valid context with subscription",
+			id:      "vol_1#lustrefs#1.1.1.1##t#test-amlfilesystem-rg#12345678-1234-1234-1234-123456789abc",
+			volName: "vol_1",
+			params: map[string]string{
+				"mgs-ip-address":      "1.1.1.1",
+				"resource-group-name": "test-amlfilesystem-rg",
+				"subscription-id":     "12345678-1234-1234-1234-123456789abc",
+			},
+			expectedLustreVolume: &lustreVolume{
+				id:                "vol_1#lustrefs#1.1.1.1##t#test-amlfilesystem-rg#12345678-1234-1234-1234-123456789abc",
+				name:              "vol_1",
+				azureLustreName:   "lustrefs",
+				mgsIPAddress:      "1.1.1.1",
+				resourceGroupName: "test-amlfilesystem-rg",
+				subscriptionID:    "12345678-1234-1234-1234-123456789abc",
+			},
+		},
 		{
 			desc:    "valid context with sub-dir",
 			id:      "vol_1#lustrefs#1.1.1.1#testSubDir",
@@ -1149,3 +1222,19 @@ func TestNewLustreVolume(t *testing.T) {
 		})
 	}
 }
+
+func TestGetVolume(t *testing.T) {
+	volumeID := "vol_1#lustrefs#1.1.1.1##t#test-amlfilesystem-rg#12345678-1234-1234-1234-123456789abc"
+	volFromID, err := getLustreVolFromID(volumeID)
+	require.NoError(t, err)
+
+	// The context of a volume created in another subscription matches its ID
+	vol, err := getVolume(volumeID, map[string]string{
+		VolumeContextMGSIPAddress:               "1.1.1.1",
+		VolumeContextInternalDynamicallyCreated: "t",
+		VolumeContextResourceGroupName:          "test-amlfilesystem-rg",
+		VolumeContextSubscriptionID:             "12345678-1234-1234-1234-123456789abc",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, volFromID, vol)
+}
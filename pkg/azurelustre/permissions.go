@@ -0,0 +1,351 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v2"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v6"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storagecache/armstoragecache/v4"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// permissionCheckTimeout bounds the whole startup permission self-test
+	permissionCheckTimeout = 2 * time.Minute
+	// permissionCheckSKU and permissionCheckCapacityTiB describe the cluster
+	// used to exercise the subnet size and subnet check actions
+	permissionCheckSKU         = "AMLFS-Durable-Premium-250"
+	permissionCheckCapacityTiB = 16
+
+	readinessCheckAzurePermissions = "azure-permissions"
+
+	actionSubnetRead          = "Microsoft.Network/virtualNetworks/subnets/read"
+	actionSubnetJoin          = "Microsoft.Network/virtualNetworks/subnets/join/action"
+	actionRequiredSubnetsSize = "Microsoft.StorageCache/getRequiredAmlFSSubnetsSize/action"
+	actionCheckSubnets        = "Microsoft.StorageCache/checkAmlFSSubnets/action"
+	actionAmlFilesystemRead   = "Microsoft.StorageCache/amlFilesystems/read"
+	actionAmlFilesystemWrite  = "Microsoft.StorageCache/amlFilesystems/write"
+	actionAmlFilesystemDelete = "Microsoft.StorageCache/amlFilesystems/delete"
+	actionUsagesRead          = "Microsoft.StorageCache/locations/usages/read"
+	actionSKUsRead            = "Microsoft.StorageCache/skus/read"
+)
+
+var (
+	// authorizationErrorCodes are the ARM error codes returned when the caller
+	// is not granted an action
+	authorizationErrorCodes = []string{"AuthorizationFailed", "LinkedAuthorizationFailed"}
+
+	// errPermissionMissing is returned by the checks of actions that are
+	// looked up in the granted permissions rather than exercised
+	errPermissionMissing = errors.New("action is not granted")
+)
+
+// permissionCheck exercises a single ARM action the controller needs
+type permissionCheck struct {
+	action string
+	check  func(ctx context.Context) error
+}
+
+// permissionReport is the outcome of the permission self-test. Actions that
+// could not be verified, e.g. because the default subnet is not configured,
+// are neither granted nor missing.
+type permissionReport struct {
+	granted    []string
+	missing    []string
+	unverified map[string]string
+}
+
+// permissionVerifier holds the clients used to exercise the ARM actions of
+// the controller identity against the defaults of the cloud config
+type permissionVerifier struct {
+	subnetsClient        *armnetwork.SubnetsClient
+	mgmtClient           *armstoragecache.ManagementClient
+	amlFilesystemsClient *armstoragecache.AmlFilesystemsClient
+	skusClient           *armstoragecache.SKUsClient
+	usagesClient         *armstoragecache.AscUsagesClient
+	// permissionsClient and networkPermissionsClient list the actions granted
+	// in the storage and network subscriptions, for the actions that cannot be
+	// exercised without side effects
+	permissionsClient        *armauthorization.PermissionsClient
+	networkPermissionsClient *armauthorization.PermissionsClient
+}
+
+func newPermissionVerifier(subscriptionID, networkSubscriptionID string, cred azcore.TokenCredential, clientOptions *arm.ClientOptions,
+	storageClientFactory *armstoragecache.ClientFactory, networkClientFactory *armnetwork.ClientFactory,
+) *permissionVerifier {
+	permissionsClient, err := armauthorization.NewPermissionsClient(subscriptionID, cred, clientOptions)
+	if err != nil {
+		klog.Warningf("failed to create permissions client: %v", err)
+	}
+	networkPermissionsClient, err := armauthorization.NewPermissionsClient(networkSubscriptionID, cred, clientOptions)
+	if err != nil {
+		klog.Warningf("failed to create network permissions client: %v", err)
+	}
+	return &permissionVerifier{
+		subnetsClient:            networkClientFactory.NewSubnetsClient(),
+		mgmtClient:               storageClientFactory.NewManagementClient(),
+		amlFilesystemsClient:     storageClientFactory.NewAmlFilesystemsClient(),
+		skusClient:               storageClientFactory.NewSKUsClient(),
+		usagesClient:             storageClientFactory.NewAscUsagesClient(),
+		permissionsClient:        permissionsClient,
+		networkPermissionsClient: networkPermissionsClient,
+	}
+}
+
+// permissionChecks returns a check for each ARM action listed in the driver
+// documentation. Read and validation actions are exercised directly, the
+// others are looked up in the permissions granted on their scope.
+func (v *permissionVerifier) permissionChecks(resourceGroup, location string, subnetInfo SubnetProperties) []permissionCheck {
+	hasSubnet := subnetInfo.VnetResourceGroup != "" && subnetInfo.VnetName != "" && subnetInfo.SubnetName != ""
+	subnetCheck := func(check func(ctx context.Context) error) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			if !hasSubnet {
+				return errors.New("no default subnet in the cloud config")
+			}
+			return check(ctx)
+		}
+	}
+	resourceGroupCheck := func(check func(ctx context.Context) error) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			if resourceGroup == "" {
+				return errors.New("no default resource group in the cloud config")
+			}
+			return check(ctx)
+		}
+	}
+	locationCheck := func(check func(ctx context.Context) error) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			if location == "" {
+				return errors.New("no default location in the cloud config")
+			}
+			return check(ctx)
+		}
+	}
+
+	return []permissionCheck{
+		{action: actionSubnetRead, check: subnetCheck(func(ctx context.Context) error {
+			_, err := v.subnetsClient.Get(ctx, subnetInfo.VnetResourceGroup, subnetInfo.VnetName, subnetInfo.SubnetName, nil)
+			return err
+		})},
+		{action: actionSubnetJoin, check: subnetCheck(func(ctx context.Context) error {
+			var permissions []*armauthorization.Permission
+			pager := v.networkPermissionsClient.NewListForResourcePager(subnetInfo.VnetResourceGroup, "Microsoft.Network",
+				"virtualNetworks/"+subnetInfo.VnetName, "subnets", subnetInfo.SubnetName, nil)
+			for pager.More() {
+				page, err := pager.NextPage(ctx)
+				if err != nil {
+					return fmt.Errorf("failed to list permissions: %v", err) //nolint:errorlint // a denied list does not mean the action is missing
+				}
+				permissions = append(permissions, page.Value...)
+			}
+			return checkActionGranted(permissions, actionSubnetJoin)
+		})},
+		{action: actionRequiredSubnetsSize, check: func(ctx context.Context) error {
+			_, err := v.mgmtClient.GetRequiredAmlFSSubnetsSize(ctx, &armstoragecache.ManagementClientGetRequiredAmlFSSubnetsSizeOptions{
+				RequiredAMLFilesystemSubnetsSizeInfo: &armstoragecache.RequiredAmlFilesystemSubnetsSizeInfo{
+					SKU:                &armstoragecache.SKUName{Name: to.Ptr(permissionCheckSKU)},
+					StorageCapacityTiB: to.Ptr(float32(permissionCheckCapacityTiB)),
+				},
+			})
+			return err
+		}},
+		{action: actionCheckSubnets, check: subnetCheck(locationCheck(func(ctx context.Context) error {
+			_, err := v.mgmtClient.CheckAmlFSSubnets(ctx, &armstoragecache.ManagementClientCheckAmlFSSubnetsOptions{
+				AmlFilesystemSubnetInfo: &armstoragecache.AmlFilesystemSubnetInfo{
+					FilesystemSubnet:   to.Ptr(subnetInfo.SubnetID),
+					Location:           to.Ptr(location),
+					SKU:                &armstoragecache.SKUName{Name: to.Ptr(permissionCheckSKU)},
+					StorageCapacityTiB: to.Ptr(float32(permissionCheckCapacityTiB)),
+				},
+			})
+			// The subnet failing the check still means the action was allowed
+			var respErr *azcore.ResponseError
+			if errors.As(err, &respErr) && respErr.StatusCode == http.StatusBadRequest {
+				return nil
+			}
+			return err
+		}))},
+		{action: actionAmlFilesystemRead, check: resourceGroupCheck(func(ctx context.Context) error {
+			_, err := v.amlFilesystemsClient.NewListByResourceGroupPager(resourceGroup, nil).NextPage(ctx)
+			return err
+		})},
+		{action: actionAmlFilesystemWrite, check: resourceGroupCheck(func(ctx context.Context) error {
+			return v.checkResourceGroupActionGranted(ctx, resourceGroup, actionAmlFilesystemWrite)
+		})},
+		{action: actionAmlFilesystemDelete, check: resourceGroupCheck(func(ctx context.Context) error {
+			return v.checkResourceGroupActionGranted(ctx, resourceGroup, actionAmlFilesystemDelete)
+		})},
+		{action: actionUsagesRead, check: locationCheck(func(ctx context.Context) error {
+			_, err := v.usagesClient.NewListPager(location, nil).NextPage(ctx)
+			return err
+		})},
+		{action: actionSKUsRead, check: func(ctx context.Context) error {
+			_, err := v.skusClient.NewListPager(nil).NextPage(ctx)
+			return err
+		}},
+	}
+}
+
+func (v *permissionVerifier) checkResourceGroupActionGranted(ctx context.Context, resourceGroup, action string) error {
+	var permissions []*armauthorization.Permission
+	pager := v.permissionsClient.NewListForResourceGroupPager(resourceGroup, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list permissions: %v", err) //nolint:errorlint // a denied list does not mean the action is missing
+		}
+		permissions = append(permissions, page.Value...)
+	}
+	return checkActionGranted(permissions, action)
+}
+
+// checkActionGranted returns errPermissionMissing unless one of the
+// permissions allows action and does not exclude it in its NotActions
+func checkActionGranted(permissions []*armauthorization.Permission, action string) error {
+	for _, permission := range permissions {
+		if permission == nil || !matchesAnyAction(permission.Actions, action) {
+			continue
+		}
+		if !matchesAnyAction(permission.NotActions, action) {
+			return nil
+		}
+	}
+	return errPermissionMissing
+}
+
+func matchesAnyAction(patterns []*string, action string) bool {
+	for _, pattern := range patterns {
+		if pattern != nil && matchesAction(*pattern, action) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAction matches an action against a role definition action, where
+// * matches any sequence of characters and case is ignored
+func matchesAction(pattern, action string) bool {
+	pattern = strings.ToLower(pattern)
+	action = strings.ToLower(action)
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == action
+	}
+	if !strings.HasPrefix(action, parts[0]) {
+		return false
+	}
+	action = action[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		index := strings.Index(action, part)
+		if index < 0 {
+			return false
+		}
+		action = action[index+len(part):]
+	}
+	return strings.HasSuffix(action, parts[len(parts)-1])
+}
+
+// isPermissionMissing reports whether err shows the caller is not granted the
+// action it exercised
+func isPermissionMissing(err error) bool {
+	if errors.Is(err, errPermissionMissing) {
+		return true
+	}
+	var respErr *azcore.ResponseError
+	if !errors.As(err, &respErr) {
+		return false
+	}
+	return hasARMErrorCode(respErr, authorizationErrorCodes...) || respErr.StatusCode == http.StatusForbidden
+}
+
+// runPermissionChecks runs the checks and sorts their actions into granted,
+// missing and unverified, updating the permission metric
+func runPermissionChecks(ctx context.Context, checks []permissionCheck) *permissionReport {
+	report := &permissionReport{unverified: map[string]string{}}
+	for _, check := range checks {
+		err := check.check(ctx)
+		switch {
+		case err == nil:
+			report.granted = append(report.granted, check.action)
+			azurePermissionMissing.WithLabelValues(check.action).Set(0)
+		case isPermissionMissing(err):
+			report.missing = append(report.missing, check.action)
+			azurePermissionMissing.WithLabelValues(check.action).Set(1)
+		default:
+			report.unverified[check.action] = err.Error()
+		}
+	}
+	return report
+}
+
+// verifyPermissions runs the startup permission self-test of the controller
+// identity, logging the missing actions and keeping the report for the
+// readiness checks
+func (d *Driver) verifyPermissions(ctx context.Context) {
+	azureClients := d.currentAzureClients()
+	if azureClients.permissionVerifier == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, permissionCheckTimeout)
+	defer cancel()
+
+	subnetInfo := SubnetProperties{}
+	if azureClients.cloud != nil {
+		subnetInfo = azureClients.populateSubnetProperties(subnetInfo, "")
+	}
+	checks := azureClients.permissionVerifier.permissionChecks(azureClients.resourceGroup, azureClients.location, subnetInfo)
+	report := runPermissionChecks(ctx, checks)
+
+	for action, message := range report.unverified {
+		klog.Warningf("could not verify Azure permission %s: %s", action, message)
+	}
+	if len(report.missing) > 0 {
+		klog.Errorf("controller identity is missing Azure permissions, dynamic provisioning will fail: %s",
+			strings.Join(report.missing, ", "))
+	} else {
+		klog.Infof("controller identity is granted the %d verified Azure permissions", len(report.granted))
+	}
+
+	d.permissionReportLock.Lock()
+	defer d.permissionReportLock.Unlock()
+	d.permissionReport = report
+}
+
+// addPermissionCheck adds the outcome of the permission self-test, if it
+// ran, to the readiness checks
+func (d *Driver) addPermissionCheck(readiness *driverReadiness) {
+	d.permissionReportLock.Lock()
+	defer d.permissionReportLock.Unlock()
+	if d.permissionReport == nil {
+		return
+	}
+	var err error
+	if len(d.permissionReport.missing) > 0 {
+		err = fmt.Errorf("missing Azure permissions: %s", strings.Join(d.permissionReport.missing, ", "))
+	}
+	readiness.addCheck(readinessCheckAzurePermissions, err)
+}
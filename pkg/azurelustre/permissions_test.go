@@ -0,0 +1,224 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v2"
+	authorizationfake "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/authorization/armauthorization/v2/fake"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+func newFakePermissionsClient(t *testing.T, permission *armauthorization.Permission) *armauthorization.PermissionsClient {
+	result := armauthorization.PermissionGetResult{Value: []*armauthorization.Permission{permission}}
+	fakePermissionsServer := authorizationfake.PermissionsServer{
+		NewListForResourceGroupPager: func(_ string, _ *armauthorization.PermissionsClientListForResourceGroupOptions) azfake.PagerResponder[armauthorization.PermissionsClientListForResourceGroupResponse] {
+			resp := azfake.PagerResponder[armauthorization.PermissionsClientListForResourceGroupResponse]{}
+			resp.AddPage(http.StatusOK, armauthorization.PermissionsClientListForResourceGroupResponse{PermissionGetResult: result}, nil)
+			return resp
+		},
+		NewListForResourcePager: func(_, _, _, _, _ string, _ *armauthorization.PermissionsClientListForResourceOptions) azfake.PagerResponder[armauthorization.PermissionsClientListForResourceResponse] {
+			resp := azfake.PagerResponder[armauthorization.PermissionsClientListForResourceResponse]{}
+			resp.AddPage(http.StatusOK, armauthorization.PermissionsClientListForResourceResponse{PermissionGetResult: result}, nil)
+			return resp
+		},
+	}
+	client, err := armauthorization.NewPermissionsClient("fake-subscription-id", &azfake.TokenCredential{},
+		&arm.ClientOptions{
+			ClientOptions: azcore.ClientOptions{
+				Transport: authorizationfake.NewPermissionsServerTransport(&fakePermissionsServer),
+			},
+		},
+	)
+	require.NoError(t, err)
+	return client
+}
+
+func TestMatchesAction(t *testing.T) {
+	cases := []struct {
+		desc     string
+		pattern  string
+		expected bool
+	}{
+		{desc: "exact", pattern: actionAmlFilesystemWrite, expected: true},
+		{desc: "different case", pattern: "microsoft.storagecache/AMLFILESYSTEMS/write", expected: true},
+		{desc: "everything", pattern: "*", expected: true},
+		{desc: "provider wildcard", pattern: "Microsoft.StorageCache/*", expected: true},
+		{desc: "inner wildcard", pattern: "Microsoft.StorageCache/*/write", expected: true},
+		{desc: "read wildcard", pattern: "*/read", expected: false},
+		{desc: "other action", pattern: actionAmlFilesystemRead, expected: false},
+		{desc: "other provider", pattern: "Microsoft.Network/*", expected: false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			assert.Equal(t, c.expected, matchesAction(c.pattern, actionAmlFilesystemWrite))
+		})
+	}
+}
+
+func TestCheckActionGranted(t *testing.T) {
+	cases := []struct {
+		desc        string
+		permissions []*armauthorization.Permission
+		granted     bool
+	}{
+		{
+			desc:        "granted",
+			permissions: []*armauthorization.Permission{{Actions: to.SliceOfPtrs("Microsoft.StorageCache/*")}},
+			granted:     true,
+		},
+		{
+			desc:        "no permissions",
+			permissions: nil,
+		},
+		{
+			desc: "excluded",
+			permissions: []*armauthorization.Permission{{
+				Actions:    to.SliceOfPtrs("*"),
+				NotActions: to.SliceOfPtrs("Microsoft.StorageCache/amlFilesystems/*"),
+			}},
+		},
+		{
+			desc: "excluded in one role and granted in another",
+			permissions: []*armauthorization.Permission{
+				{Actions: to.SliceOfPtrs("*"), NotActions: to.SliceOfPtrs("*/write")},
+				{Actions: to.SliceOfPtrs(actionAmlFilesystemWrite)},
+			},
+			granted: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			err := checkActionGranted(c.permissions, actionAmlFilesystemWrite)
+			if c.granted {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, errPermissionMissing)
+			}
+		})
+	}
+}
+
+func TestRunPermissionChecks(t *testing.T) {
+	checkError := func(err error) func(context.Context) error {
+		return func(context.Context) error {
+			return err
+		}
+	}
+	checks := []permissionCheck{
+		{action: "test/granted", check: checkError(nil)},
+		{action: "test/forbidden", check: checkError(&azcore.ResponseError{StatusCode: http.StatusForbidden})},
+		{action: "test/authorization-failed", check: checkError(&azcore.ResponseError{StatusCode: http.StatusUnauthorized, ErrorCode: "AuthorizationFailed"})},
+		{action: "test/not-granted", check: checkError(errPermissionMissing)},
+		{action: "test/not-found", check: checkError(&azcore.ResponseError{StatusCode: http.StatusNotFound, ErrorCode: "ResourceNotFound"})},
+		{action: "test/unconfigured", check: checkError(errors.New("no default subnet in the cloud config"))},
+	}
+
+	report := runPermissionChecks(context.Background(), checks)
+	assert.Equal(t, []string{"test/granted"}, report.granted)
+	assert.Equal(t, []string{"test/forbidden", "test/authorization-failed", "test/not-granted"}, report.missing)
+	assert.Len(t, report.unverified, 2)
+	assert.Equal(t, "no default subnet in the cloud config", report.unverified["test/unconfigured"])
+
+	values := gatherMetricValues(t, legacyregistry.DefaultGatherer, "azurelustre_csi_azure_permission_missing")
+	assert.InDelta(t, 0, values["action=test/granted"], 0)
+	assert.InDelta(t, 1, values["action=test/forbidden"], 0)
+	assert.InDelta(t, 1, values["action=test/not-granted"], 0)
+	assert.NotContains(t, values, "action=test/not-found")
+}
+
+func TestPermissionChecks_GrantedPermissions(t *testing.T) {
+	verifier := &permissionVerifier{
+		permissionsClient: newFakePermissionsClient(t, &armauthorization.Permission{
+			Actions:    to.SliceOfPtrs("Microsoft.StorageCache/*"),
+			NotActions: to.SliceOfPtrs(actionAmlFilesystemDelete),
+		}),
+		networkPermissionsClient: newFakePermissionsClient(t, &armauthorization.Permission{
+			Actions: to.SliceOfPtrs("*/read"),
+		}),
+	}
+	subnetInfo := SubnetProperties{VnetResourceGroup: "vnet-rg", VnetName: "vnet", SubnetName: "subnet"}
+
+	results := map[string]error{}
+	for _, check := range verifier.permissionChecks("rg", expectedLocation, subnetInfo) {
+		switch check.action {
+		case actionSubnetJoin, actionAmlFilesystemWrite, actionAmlFilesystemDelete:
+			results[check.action] = check.check(context.Background())
+		}
+	}
+	assert.Equal(t, map[string]error{
+		actionSubnetJoin:          errPermissionMissing,
+		actionAmlFilesystemWrite:  nil,
+		actionAmlFilesystemDelete: errPermissionMissing,
+	}, results)
+
+	// Checks against the defaults of the cloud config are not run without them
+	for _, check := range verifier.permissionChecks("", "", SubnetProperties{}) {
+		if check.action != actionRequiredSubnetsSize && check.action != actionSKUsRead {
+			err := check.check(context.Background())
+			require.Error(t, err, check.action)
+			assert.False(t, isPermissionMissing(err), check.action)
+		}
+	}
+}
+
+func TestAddPermissionCheck(t *testing.T) {
+	d := NewFakeDriver()
+	d.NodeID = ""
+	serveReadyz := func() *driverReadiness {
+		recorder := httptest.NewRecorder()
+		d.ServeReadyz(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		readiness := &driverReadiness{}
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), readiness))
+		return readiness
+	}
+	readiness := serveReadyz()
+	assert.True(t, readiness.Ready)
+	assert.Empty(t, readiness.Checks, "self-test disabled")
+
+	d.permissionReport = &permissionReport{granted: []string{actionSKUsRead}}
+	readiness = serveReadyz()
+	assert.True(t, readiness.Ready)
+	assert.Equal(t, []readinessCheck{{Name: readinessCheckAzurePermissions, Passed: true}}, readiness.Checks)
+
+	d.permissionReport = &permissionReport{missing: []string{actionSubnetJoin, actionAmlFilesystemWrite}}
+	readiness = serveReadyz()
+	assert.False(t, readiness.Ready)
+	assert.Equal(t, "missing Azure permissions: "+actionSubnetJoin+", "+actionAmlFilesystemWrite, readiness.Reason)
+
+	// The liveness probe goes through Probe, which must not restart the
+	// controller for a missing role assignment
+	resp, err := d.Probe(context.Background(), &csi.ProbeRequest{})
+	require.NoError(t, err)
+	assert.True(t, resp.GetReady().GetValue())
+	assert.True(t, d.checkReadiness(context.Background()).Ready)
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/azurelustre-csi-driver/pkg/util"
+)
+
+// VolumePlan is the dry-run report of the AMLFS cluster CreateVolume would
+// create for the parameters of a storage class and a requested size
+type VolumePlan struct {
+	Location           string            `json:"location"`
+	ResourceGroupName  string            `json:"resourceGroupName"`
+	SKUName            string            `json:"skuName"`
+	IncrementTiB       int64             `json:"incrementTiB"`
+	MaximumTiB         int64             `json:"maximumTiB"`
+	RequestedBytes     int64             `json:"requestedBytes"`
+	CapacityBytes      int64             `json:"capacityBytes,omitempty"`
+	Zone               string            `json:"zone,omitempty"`
+	AvailableZones     []string          `json:"availableZones"`
+	SubnetID           string            `json:"subnetID"`
+	RequiredSubnetIPs  int               `json:"requiredSubnetIPs"`
+	AvailableSubnetIPs int               `json:"availableSubnetIPs"`
+	Tags               map[string]string `json:"tags"`
+	// Problems are the reasons CreateVolume would fail, empty when it would
+	// create the AMLFS cluster
+	Problems []string `json:"problems"`
+}
+
+// PlanVolume runs the checks CreateVolume makes before creating an AMLFS
+// cluster for the storage class parameters and the requested size without
+// creating anything. Errors that prevent the plan, such as invalid parameters
+// or an unknown SKU, are returned; the other failures CreateVolume would
+// report are collected in the Problems of the plan.
+func (d *Driver) PlanVolume(ctx context.Context, parameters map[string]string, requiredBytes int64) (*VolumePlan, error) {
+	ctx = d.withAzureClients(ctx)
+	dynamicProvisioner := d.azureClientsFor(ctx).dynamicProvisioner
+	parameters = removeCSIParameters(parameters)
+	if util.GetValueInMap(parameters, VolumeContextMGSIPAddress) != "" {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"plan only applies to dynamically provisioned AMLFS, the parameters contain %s", VolumeContextMGSIPAddress)
+	}
+
+	amlFilesystemProperties, err := parseAmlFilesystemProperties(parameters)
+	if err != nil {
+		return nil, err
+	}
+	d.setAmlFilesystemDefaults(ctx, amlFilesystemProperties)
+
+	if requiredBytes == 0 {
+		requiredBytes = defaultSizeInBytes
+	}
+
+	lustreSkuValue, err := d.getSkuValuesForLocation(ctx, amlFilesystemProperties.SubscriptionID, amlFilesystemProperties.SKUName, amlFilesystemProperties.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &VolumePlan{
+		Location:          amlFilesystemProperties.Location,
+		ResourceGroupName: amlFilesystemProperties.ResourceGroupName,
+		SKUName:           amlFilesystemProperties.SKUName,
+		IncrementTiB:      lustreSkuValue.IncrementInTib,
+		MaximumTiB:        lustreSkuValue.MaximumInTib,
+		RequestedBytes:    requiredBytes,
+		Zone:              amlFilesystemProperties.Zone,
+		AvailableZones:    lustreSkuValue.AvailableZones,
+		SubnetID:          amlFilesystemProperties.SubnetInfo.SubnetID,
+		Tags:              amlFilesystemProperties.Tags,
+		Problems:          []string{},
+	}
+
+	if err := validateAmlFilesystemZone(amlFilesystemProperties, lustreSkuValue.AvailableZones); err != nil {
+		plan.Problems = append(plan.Problems, status.Convert(err).Message())
+	}
+
+	capacityInBytes, err := d.roundToAmlfsBlockSize(requiredBytes, lustreSkuValue.IncrementInTib*util.TiB, lustreSkuValue.MaximumInTib*util.TiB)
+	if err != nil {
+		plan.Problems = append(plan.Problems, status.Convert(err).Message())
+		return plan, nil
+	}
+	plan.CapacityBytes = capacityInBytes
+
+	amlFilesystemProperties.StorageCapacityTiB = float32(capacityInBytes) / util.TiB
+	if len(amlFilesystemProperties.candidateSubnets) > 0 {
+		subnetInfo, subnetCapacity, err := chooseSubnet(ctx, amlFilesystemProperties, dynamicProvisioner.GetSubnetCapacity)
+		if err != nil {
+			plan.Problems = append(plan.Problems, status.Convert(err).Message())
+			return plan, nil
+		}
+		plan.SubnetID = subnetInfo.SubnetID
+		plan.RequiredSubnetIPs = subnetCapacity.RequiredIPs
+		plan.AvailableSubnetIPs = subnetCapacity.AvailableIPs
+		return plan, nil
+	}
+
+	subnetCapacity, err := dynamicProvisioner.GetSubnetCapacity(ctx, amlFilesystemProperties.SubscriptionID, amlFilesystemProperties.SubnetInfo, amlFilesystemProperties.SKUName, amlFilesystemProperties.StorageCapacityTiB)
+	if err != nil {
+		plan.Problems = append(plan.Problems, fmt.Sprintf("failed to check subnet capacity: %s", status.Convert(err).Message()))
+		return plan, nil
+	}
+	plan.RequiredSubnetIPs = subnetCapacity.RequiredIPs
+	plan.AvailableSubnetIPs = subnetCapacity.AvailableIPs
+	if subnetCapacity.RequiredIPs > subnetCapacity.AvailableIPs {
+		plan.Problems = append(plan.Problems, fmt.Sprintf("subnet %s does not have enough IP addresses available: %d needed, %d available",
+			plan.SubnetID, subnetCapacity.RequiredIPs, subnetCapacity.AvailableIPs))
+	}
+
+	return plan, nil
+}
+
+// WriteText writes the plan as a human readable report
+func (p *VolumePlan) WriteText(w io.Writer) error {
+	zone := p.Zone
+	if zone == "" {
+		zone = "none"
+	}
+	availableZones := strings.Join(p.AvailableZones, ", ")
+	if availableZones == "" {
+		availableZones = "none"
+	}
+	tags := make([]string, 0, len(p.Tags))
+	for _, key := range slices.Sorted(maps.Keys(p.Tags)) {
+		tags = append(tags, key+"="+p.Tags[key])
+	}
+
+	rows := []string{
+		"Location:\t" + p.Location,
+		"Resource group:\t" + p.ResourceGroupName,
+		fmt.Sprintf("SKU:\t%s, capacity in increments of %d TiB up to %d TiB", p.SKUName, p.IncrementTiB, p.MaximumTiB),
+		"Requested capacity:\t" + formatTiB(p.RequestedBytes),
+	}
+	if p.CapacityBytes > 0 {
+		rows = append(rows, "Capacity:\t"+formatTiB(p.CapacityBytes))
+	}
+	rows = append(rows,
+		fmt.Sprintf("Zone:\t%s, available zones: %s", zone, availableZones),
+		"Subnet:\t"+p.SubnetID,
+	)
+	if p.RequiredSubnetIPs > 0 {
+		rows = append(rows, fmt.Sprintf("Subnet IP addresses:\t%d needed, %d available", p.RequiredSubnetIPs, p.AvailableSubnetIPs))
+	}
+	rows = append(rows, "Tags:\t"+strings.Join(tags, ", "))
+	if len(p.Problems) == 0 {
+		rows = append(rows, "Result:\tCreateVolume would create the AMLFS cluster")
+	} else {
+		rows = append(rows, "Result:\tCreateVolume would fail")
+		for _, problem := range p.Problems {
+			rows = append(rows, "\t- "+problem)
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		if _, err := io.WriteString(tw, row+"\n"); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+func formatTiB(bytes int64) string {
+	return fmt.Sprintf("%g TiB (%d bytes)", float64(bytes)/util.TiB, bytes)
+}
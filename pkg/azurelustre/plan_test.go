@@ -0,0 +1,186 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/azurelustre-csi-driver/pkg/util"
+)
+
+func buildPlanParameters(overrides map[string]string) map[string]string {
+	parameters := map[string]string{
+		"sku-name":                    "AMLFS-Durable-Premium-250",
+		"zone":                        "zone1",
+		"maintenance-day-of-week":     "Monday",
+		"maintenance-time-of-day-utc": "12:00",
+		"vnet-resource-group":         "test-vnet-rg",
+		"vnet-name":                   "test-vnet-name",
+		"subnet-name":                 "test-subnet-name",
+		"tags":                        "team=storage",
+		"csi.storage.k8s.io/provisioner-secret-name": "secret",
+	}
+	maps.Copy(parameters, overrides)
+	return parameters
+}
+
+func TestPlanVolume(t *testing.T) {
+	cases := []struct {
+		desc             string
+		parameters       map[string]string
+		requiredBytes    int64
+		expectedCapacity int64
+		expectedIPs      int
+		expectedProblems []string
+	}{
+		{
+			desc:             "rounded capacity with enough subnet IPs",
+			parameters:       buildPlanParameters(nil),
+			requiredBytes:    20 * util.TiB,
+			expectedCapacity: 32 * util.TiB,
+			expectedIPs:      200,
+			expectedProblems: []string{},
+		},
+		{
+			desc:             "default capacity",
+			parameters:       buildPlanParameters(nil),
+			expectedCapacity: 16 * util.TiB,
+			expectedIPs:      200,
+			expectedProblems: []string{},
+		},
+		{
+			desc:             "invalid zone and full subnet",
+			parameters:       buildPlanParameters(map[string]string{"zone": "zone4", "vnet-name": fullVnetName}),
+			requiredBytes:    16 * util.TiB,
+			expectedCapacity: 16 * util.TiB,
+			expectedIPs:      8,
+			expectedProblems: []string{
+				"CreateVolume Parameter zone zone4 must be one of: [zone1 zone2 zone3]",
+				"subnet /subscriptions/defaultFakeSubID/resourceGroups/test-vnet-rg/providers/Microsoft.Network/virtualNetworks/full-vnet/subnets/test-subnet-name " +
+					"does not have enough IP addresses available: 16 needed, 8 available",
+			},
+		},
+		{
+			desc:          "capacity above SKU maximum",
+			parameters:    buildPlanParameters(nil),
+			requiredBytes: 512 * util.TiB,
+			expectedProblems: []string{
+				"Requested capacity 562949953421312 exceeds maximum capacity 281474976710656 for SKU in this location",
+			},
+		},
+		{
+			desc:             "subnet check failure",
+			parameters:       buildPlanParameters(map[string]string{"vnet-name": errorLocation}),
+			requiredBytes:    16 * util.TiB,
+			expectedCapacity: 16 * util.TiB,
+			expectedProblems: []string{
+				"failed to check subnet capacity: error occurred calling API: sku-error-location",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			d := NewFakeDriver()
+			fakeDynamicProvisioner := &FakeDynamicProvisioner{}
+			d.dynamicProvisioner = fakeDynamicProvisioner
+
+			plan, err := d.PlanVolume(context.Background(), c.parameters, c.requiredBytes)
+			require.NoError(t, err)
+			assert.Equal(t, driverDefaultLocation, plan.Location)
+			assert.Equal(t, "defaultFakeResourceGroup", plan.ResourceGroupName)
+			assert.Equal(t, int64(16), plan.IncrementTiB)
+			assert.Equal(t, int64(256), plan.MaximumTiB)
+			assert.Equal(t, c.expectedCapacity, plan.CapacityBytes)
+			assert.Equal(t, c.expectedIPs, plan.AvailableSubnetIPs)
+			assert.Equal(t, c.expectedProblems, plan.Problems)
+			assert.Equal(t, "storage", plan.Tags["team"])
+			assert.Empty(t, fakeDynamicProvisioner.fakeCallCount["CreateAmlFilesystem"])
+		})
+	}
+}
+
+func TestPlanVolume_Err(t *testing.T) {
+	cases := []struct {
+		desc          string
+		parameters    map[string]string
+		expectedError string
+	}{
+		{
+			desc:          "static storage class",
+			parameters:    map[string]string{"mgs-ip-address": "127.0.0.1"},
+			expectedError: "plan only applies to dynamically provisioned AMLFS",
+		},
+		{
+			desc:          "invalid parameter",
+			parameters:    buildPlanParameters(map[string]string{"maintenance-day-of-week": "monday"}),
+			expectedError: "CreateVolume Parameter maintenance-day-of-week must be one of",
+		},
+		{
+			desc:          "unknown SKU",
+			parameters:    buildPlanParameters(map[string]string{"sku-name": "AMLFS-Unknown"}),
+			expectedError: "CreateVolume Parameter sku-name must be one of",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			d := NewFakeDriver()
+			_, err := d.PlanVolume(context.Background(), c.parameters, 0)
+			require.ErrorContains(t, err, c.expectedError)
+		})
+	}
+}
+
+func TestVolumePlan_WriteText(t *testing.T) {
+	plan := &VolumePlan{
+		Location:           "eastus",
+		ResourceGroupName:  "rg",
+		SKUName:            "AMLFS-Durable-Premium-250",
+		IncrementTiB:       8,
+		MaximumTiB:         128,
+		RequestedBytes:     10 * util.TiB,
+		CapacityBytes:      16 * util.TiB,
+		Zone:               "1",
+		AvailableZones:     []string{"1", "2"},
+		SubnetID:           "subnet-id",
+		RequiredSubnetIPs:  16,
+		AvailableSubnetIPs: 8,
+		Tags:               map[string]string{"team": "storage", "k8s-azure-created-by": "kubernetes-azurelustre-csi-driver"},
+		Problems:           []string{"not enough IP addresses"},
+	}
+
+	out := &strings.Builder{}
+	require.NoError(t, plan.WriteText(out))
+	assert.Equal(t, `Location:             eastus
+Resource group:       rg
+SKU:                  AMLFS-Durable-Premium-250, capacity in increments of 8 TiB up to 128 TiB
+Requested capacity:   10 TiB (10995116277760 bytes)
+Capacity:             16 TiB (17592186044416 bytes)
+Zone:                 1, available zones: 1, 2
+Subnet:               subnet-id
+Subnet IP addresses:  16 needed, 8 available
+Tags:                 k8s-azure-created-by=kubernetes-azurelustre-csi-driver, team=storage
+Result:               CreateVolume would fail
+                      - not enough IP addresses
+`, out.String())
+}
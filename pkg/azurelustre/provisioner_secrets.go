@@ -0,0 +1,148 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// The keys of the provisioner secret. A secret with a client secret
+	// authenticates as a service principal, one without as a workload
+	// identity federated with the service account of the controller.
+	secretClientID     = "clientID"
+	secretClientSecret = "clientSecret"
+	secretTenantID     = "tenantID"
+)
+
+// requestCredentialKey is the context key of the credential the ARM calls
+// of a request are made with
+type requestCredentialKey struct{}
+
+// requestCredentialValue is the credential of a request and the client ID
+// it authenticates as
+type requestCredentialValue struct {
+	credential azcore.TokenCredential
+	clientID   string
+}
+
+// withRequestCredential returns a context whose ARM calls are made with the
+// credential of the client instead of that of the driver
+func withRequestCredential(ctx context.Context, credential azcore.TokenCredential, clientID string) context.Context {
+	return context.WithValue(ctx, requestCredentialKey{}, requestCredentialValue{credential: credential, clientID: clientID})
+}
+
+// requestCredential returns the credential of the request, nil when its ARM
+// calls are made with that of the driver
+func requestCredential(ctx context.Context) azcore.TokenCredential {
+	value, _ := ctx.Value(requestCredentialKey{}).(requestCredentialValue)
+	return value.credential
+}
+
+// requestClientID returns the client ID the credential of the request
+// authenticates as, empty when its ARM calls are made with that of the driver
+func requestClientID(ctx context.Context) string {
+	value, _ := ctx.Value(requestCredentialKey{}).(requestCredentialValue)
+	return value.clientID
+}
+
+// newCredentialFromSecrets returns the credential of the provisioner secret
+// and its client ID, nil when there is none, authenticating with the
+// authority of the cloud. The secret values are never logged nor returned in
+// errors.
+func newCredentialFromSecrets(secrets map[string]string, defaultTenantID string, cloudConfig cloud.Configuration) (azcore.TokenCredential, string, error) {
+	if len(secrets) == 0 {
+		return nil, "", nil
+	}
+
+	var clientID, clientSecret, tenantID string
+	var unknownKeys []string
+	for key, value := range secrets {
+		switch strings.ToLower(key) {
+		case strings.ToLower(secretClientID):
+			clientID = strings.TrimSpace(value)
+		case strings.ToLower(secretClientSecret):
+			clientSecret = value
+		case strings.ToLower(secretTenantID):
+			tenantID = strings.TrimSpace(value)
+		default:
+			unknownKeys = append(unknownKeys, key)
+		}
+	}
+	if len(unknownKeys) > 0 {
+		slices.Sort(unknownKeys)
+		return nil, "", status.Errorf(codes.InvalidArgument,
+			"provisioner secret has unknown key(s) %v, must only have: [%s %s %s]",
+			unknownKeys, secretClientID, secretClientSecret, secretTenantID)
+	}
+	if clientID == "" {
+		return nil, "", status.Errorf(codes.InvalidArgument, "provisioner secret must have a %s", secretClientID)
+	}
+	if tenantID == "" {
+		tenantID = defaultTenantID
+	}
+
+	if clientSecret != "" {
+		if tenantID == "" {
+			return nil, "", status.Errorf(codes.InvalidArgument, "provisioner secret of client %s must have a %s", clientID, secretTenantID)
+		}
+		credential, err := azidentity.NewClientSecretCredential(tenantID, clientID, clientSecret, &azidentity.ClientSecretCredentialOptions{
+			ClientOptions: azcore.ClientOptions{Cloud: cloudConfig},
+		})
+		if err != nil {
+			return nil, "", status.Errorf(codes.InvalidArgument, "failed to create the service principal credential of client %s: %v", clientID, err)
+		}
+		klog.V(4).Infof("using the service principal credential of client %s in tenant %s", clientID, tenantID)
+		return credential, clientID, nil
+	}
+
+	credential, err := azidentity.NewWorkloadIdentityCredential(&azidentity.WorkloadIdentityCredentialOptions{
+		ClientOptions: azcore.ClientOptions{Cloud: cloudConfig},
+		ClientID:      clientID,
+		TenantID:      tenantID,
+	})
+	if err != nil {
+		return nil, "", status.Errorf(codes.FailedPrecondition,
+			"failed to create the workload identity credential of client %s, is workload identity enabled for the controller? %v", clientID, err)
+	}
+	klog.V(4).Infof("using the workload identity credential of client %s", clientID)
+	return credential, clientID, nil
+}
+
+// contextWithProvisionerSecrets returns the context the ARM calls of a
+// request are made in, with the credential of its provisioner secret if any
+func (d *Driver) contextWithProvisionerSecrets(ctx context.Context, secrets map[string]string) (context.Context, error) {
+	azureClients := d.azureClientsFor(ctx)
+	defaultTenantID := ""
+	if azureClients.cloud != nil {
+		defaultTenantID = azureClients.cloud.TenantID
+	}
+	credential, clientID, err := newCredentialFromSecrets(secrets, defaultTenantID, azureClients.cloudConfig)
+	if err != nil || credential == nil {
+		return ctx, err
+	}
+	return withRequestCredential(ctx, credential, clientID), nil
+}
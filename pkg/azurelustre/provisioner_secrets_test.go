@@ -0,0 +1,186 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	azure "sigs.k8s.io/cloud-provider-azure/pkg/provider"
+)
+
+const testClientSecret = "very-secret-value"
+
+func TestNewCredentialFromSecrets(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("token"), 0o600))
+
+	cases := []struct {
+		desc            string
+		secrets         map[string]string
+		defaultTenantID string
+		noTokenFile     bool
+		expectedType    azcore.TokenCredential
+		expectedCode    codes.Code
+		expectedError   string
+	}{
+		{desc: "no secret"},
+		{
+			desc:         "service principal",
+			secrets:      map[string]string{secretClientID: "client", secretClientSecret: testClientSecret, "TENANTID": "tenant"},
+			expectedType: &azidentity.ClientSecretCredential{},
+		},
+		{
+			desc:            "service principal in the tenant of the driver",
+			secrets:         map[string]string{secretClientID: "client", secretClientSecret: testClientSecret},
+			defaultTenantID: "tenant",
+			expectedType:    &azidentity.ClientSecretCredential{},
+		},
+		{
+			desc:          "service principal without tenant",
+			secrets:       map[string]string{secretClientID: "client", secretClientSecret: testClientSecret},
+			expectedCode:  codes.InvalidArgument,
+			expectedError: "provisioner secret of client client must have a tenantID",
+		},
+		{
+			desc:            "workload identity",
+			secrets:         map[string]string{secretClientID: "client"},
+			defaultTenantID: "tenant",
+			expectedType:    &azidentity.WorkloadIdentityCredential{},
+		},
+		{
+			desc:            "workload identity not enabled",
+			secrets:         map[string]string{secretClientID: "client"},
+			defaultTenantID: "tenant",
+			noTokenFile:     true,
+			expectedCode:    codes.FailedPrecondition,
+			expectedError:   "failed to create the workload identity credential of client client",
+		},
+		{
+			desc:          "no client ID",
+			secrets:       map[string]string{secretClientSecret: testClientSecret},
+			expectedCode:  codes.InvalidArgument,
+			expectedError: "provisioner secret must have a clientID",
+		},
+		{
+			desc:          "unknown keys",
+			secrets:       map[string]string{secretClientID: "client", "password": testClientSecret, "azurestorageaccountkey": testClientSecret},
+			expectedCode:  codes.InvalidArgument,
+			expectedError: "provisioner secret has unknown key(s) [azurestorageaccountkey password], must only have: [clientID clientSecret tenantID]",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			t.Setenv("AZURE_FEDERATED_TOKEN_FILE", tokenFile)
+			if c.noTokenFile {
+				require.NoError(t, os.Unsetenv("AZURE_FEDERATED_TOKEN_FILE"))
+			}
+
+			credential, clientID, err := newCredentialFromSecrets(c.secrets, c.defaultTenantID, cloud.AzurePublic)
+			if c.expectedError != "" {
+				require.ErrorContains(t, err, c.expectedError)
+				assert.Equal(t, c.expectedCode, status.Code(err))
+				assert.NotContains(t, err.Error(), testClientSecret)
+				return
+			}
+			require.NoError(t, err)
+			if c.expectedType == nil {
+				assert.Nil(t, credential)
+				return
+			}
+			assert.IsType(t, c.expectedType, credential)
+			assert.Equal(t, "client", clientID)
+		})
+	}
+}
+
+// credentialRecordingProvisioner records the credential of the requests it
+// serves
+type credentialRecordingProvisioner struct {
+	FakeDynamicProvisioner
+	credentials []azcore.TokenCredential
+}
+
+func (p *credentialRecordingProvisioner) CreateAmlFilesystem(ctx context.Context, amlFilesystemProperties *AmlFilesystemProperties) (string, error) {
+	p.credentials = append(p.credentials, requestCredential(ctx))
+	return p.FakeDynamicProvisioner.CreateAmlFilesystem(ctx, amlFilesystemProperties)
+}
+
+func (p *credentialRecordingProvisioner) DeleteAmlFilesystem(ctx context.Context, subscriptionID, resourceGroupName, amlFilesystemName string) error {
+	p.credentials = append(p.credentials, requestCredential(ctx))
+	return p.FakeDynamicProvisioner.DeleteAmlFilesystem(ctx, subscriptionID, resourceGroupName, amlFilesystemName)
+}
+
+func TestDynamicCreateVolume_ProvisionerSecrets(t *testing.T) {
+	d := NewFakeDriver()
+	dynamicProvisioner := &credentialRecordingProvisioner{}
+	d.dynamicProvisioner = dynamicProvisioner
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	d.cloud = azure.GetTestCloud(ctrl)
+	d.cloud.TenantID = "tenant"
+	secrets := map[string]string{secretClientID: "client", secretClientSecret: testClientSecret}
+
+	req := buildDynamicProvCreateVolumeRequest()
+	req.Secrets = secrets
+	rep, err := d.CreateVolume(context.Background(), req)
+	require.NoError(t, err)
+	_, err = d.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: rep.GetVolume().GetVolumeId(), Secrets: secrets})
+	require.NoError(t, err)
+	require.Len(t, dynamicProvisioner.credentials, 2)
+	assert.IsType(t, &azidentity.ClientSecretCredential{}, dynamicProvisioner.credentials[0])
+	assert.IsType(t, &azidentity.ClientSecretCredential{}, dynamicProvisioner.credentials[1])
+
+	// Without secret, the credential of the driver is used
+	req = buildDynamicProvCreateVolumeRequest()
+	req.Name = "test_volume_2"
+	_, err = d.CreateVolume(context.Background(), req)
+	require.NoError(t, err)
+	require.Len(t, dynamicProvisioner.credentials, 3)
+	assert.Nil(t, dynamicProvisioner.credentials[2])
+}
+
+func TestDynamicProvisioner_ClientsFor_RequestCredential(t *testing.T) {
+	recorder := newMockAmlfsRecorder([]string{})
+	dynamicProvisioner := newTestDynamicProvisioner(t, recorder)
+	dynamicProvisioner.subscriptionID = testSubscriptionID
+	ctx := withRequestCredential(context.Background(), &azfake.TokenCredential{}, "client")
+
+	// A request with its own credential never uses the clients of the driver
+	storageClients, err := dynamicProvisioner.storageClientsFor(ctx, "")
+	require.NoError(t, err)
+	assert.NotSame(t, dynamicProvisioner.amlFilesystemsClient, storageClients.amlFilesystemsClient)
+	assert.NotSame(t, dynamicProvisioner.skusClient, storageClients.skusClient)
+	assert.NotSame(t, dynamicProvisioner.mgmtClient, storageClients.mgmtClient)
+	networkClients, err := dynamicProvisioner.networkClientsFor(ctx, buildExpectedSubnetInfo())
+	require.NoError(t, err)
+	assert.NotSame(t, dynamicProvisioner.vnetClient, networkClients.vnetClient)
+	assert.Same(t, dynamicProvisioner.vnetClient, dynamicProvisioner.driverNetworkClients().vnetClient)
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// provisioningHeartbeatInterval is how often a still-provisioning event is
+	// recorded while ARM creates an AMLFS cluster
+	provisioningHeartbeatInterval = 2 * time.Minute
+
+	eventReasonAmlFilesystemSkuResolved    = "AmlFilesystemSkuResolved"
+	eventReasonAmlFilesystemSubnetChecked  = "AmlFilesystemSubnetCapacityChecked"
+	eventReasonAmlFilesystemCreating       = "AmlFilesystemCreating"
+	eventReasonAmlFilesystemProvisioning   = "AmlFilesystemProvisioning"
+	eventReasonAmlFilesystemRetrying       = "AmlFilesystemRetrying"
+	eventReasonAmlFilesystemCreated        = "AmlFilesystemCreated"
+	eventReasonAmlFilesystemNetworkBlocked = "AmlFilesystemNetworkBlocked"
+)
+
+// provisioningProgress records the progress of an AMLFS cluster creation as
+// events on the persistent volume claim it is created for, a nil
+// provisioningProgress records nothing
+type provisioningProgress struct {
+	recorder          record.EventRecorder
+	pvc               *v1.ObjectReference
+	start             time.Time
+	heartbeatInterval time.Duration
+}
+
+// getPVCReference returns the reference to record the events of a persistent
+// volume claim on. kubectl describe only shows the events referencing the UID
+// of the claim, so it is read from the API server, a claim that cannot be read
+// still gets its events by name.
+func (d *Driver) getPVCReference(ctx context.Context, pvcName, pvcNamespace string) *v1.ObjectReference {
+	pvc := &v1.ObjectReference{
+		Kind:       "PersistentVolumeClaim",
+		APIVersion: "v1",
+		Name:       pvcName,
+		Namespace:  pvcNamespace,
+	}
+	if d.kubeClient == nil {
+		return pvc
+	}
+	claim, err := d.kubeClient.CoreV1().PersistentVolumeClaims(pvcNamespace).Get(ctx, pvcName, metav1.GetOptions{})
+	if err != nil {
+		klog.V(2).Infof("failed to get persistent volume claim %s/%s, recording its events without its UID: %v", pvcNamespace, pvcName, err)
+		return pvc
+	}
+	pvc.UID = claim.UID
+	pvc.ResourceVersion = claim.ResourceVersion
+	return pvc
+}
+
+// newProvisioningProgress returns the progress recorder for the claim named by
+// the csi.storage.k8s.io/pvc/* parameters, nil when they are missing, e.g.
+// without --extra-create-metadata, or when events cannot be recorded
+func (d *Driver) newProvisioningProgress(ctx context.Context, parameters map[string]string) *provisioningProgress {
+	pvcName, pvcNamespace := parameters[pvcNameKey], parameters[pvcNamespaceKey]
+	if pvcName == "" || pvcNamespace == "" {
+		return nil
+	}
+	recorder := d.getEventRecorder()
+	if recorder == nil {
+		return nil
+	}
+	return &provisioningProgress{
+		recorder:          recorder,
+		pvc:               d.getPVCReference(ctx, pvcName, pvcNamespace),
+		start:             time.Now(),
+		heartbeatInterval: provisioningHeartbeatInterval,
+	}
+}
+
+func (p *provisioningProgress) record(reason, messageFmt string, args ...any) {
+	if p == nil {
+		return
+	}
+	p.recorder.Eventf(p.pvc, v1.EventTypeNormal, reason, messageFmt, args...)
+}
+
+func (p *provisioningProgress) warn(reason, messageFmt string, args ...any) {
+	if p == nil {
+		return
+	}
+	p.recorder.Eventf(p.pvc, v1.EventTypeWarning, reason, messageFmt, args...)
+}
+
+// elapsed returns the time since provisioning started, rounded to seconds
+func (p *provisioningProgress) elapsed() time.Duration {
+	if p == nil {
+		return 0
+	}
+	return time.Since(p.start).Round(time.Second)
+}
+
+// startHeartbeat records a still-provisioning event with the elapsed time
+// every heartbeatInterval until the returned function is called
+func (p *provisioningProgress) startHeartbeat(amlFilesystemName string) func() {
+	if p == nil {
+		return func() {}
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(p.heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				p.record(eventReasonAmlFilesystemProvisioning, "AMLFS cluster %s is still provisioning, %v elapsed", amlFilesystemName, p.elapsed())
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
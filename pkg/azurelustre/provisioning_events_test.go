@@ -0,0 +1,143 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	azure "sigs.k8s.io/cloud-provider-azure/pkg/provider"
+)
+
+func newTestProvisioningProgress(recorder record.EventRecorder) *provisioningProgress {
+	return &provisioningProgress{
+		recorder:          recorder,
+		pvc:               &v1.ObjectReference{Kind: "PersistentVolumeClaim", APIVersion: "v1", Name: "pvc_name", Namespace: "pvc_namespace"},
+		start:             time.Now(),
+		heartbeatInterval: provisioningHeartbeatInterval,
+	}
+}
+
+func TestNewProvisioningProgress(t *testing.T) {
+	parameters := map[string]string{pvcNameKey: "pvc_name", pvcNamespaceKey: "pvc_namespace"}
+
+	d := NewFakeDriver()
+	assert.Nil(t, d.newProvisioningProgress(context.Background(), parameters), "no event recorder")
+
+	d.eventRecorder = record.NewFakeRecorder(10)
+	assert.Nil(t, d.newProvisioningProgress(context.Background(), map[string]string{pvcNameKey: "pvc_name"}), "no claim namespace")
+	progress := d.newProvisioningProgress(context.Background(), parameters)
+	require.NotNil(t, progress)
+	assert.Equal(t, "pvc_name", progress.pvc.Name)
+	assert.Equal(t, "pvc_namespace", progress.pvc.Namespace)
+	assert.Empty(t, progress.pvc.UID)
+
+	// kubectl describe selects the events of the claim by its UID
+	d.kubeClient = kubefake.NewClientset(&v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc_name", Namespace: "pvc_namespace", UID: "pvc-uid", ResourceVersion: "42"},
+	})
+	progress = d.newProvisioningProgress(context.Background(), parameters)
+	require.NotNil(t, progress)
+	assert.Equal(t, types.UID("pvc-uid"), progress.pvc.UID)
+	assert.Equal(t, "42", progress.pvc.ResourceVersion)
+
+	// A nil progress records nothing
+	var nilProgress *provisioningProgress
+	nilProgress.record(eventReasonAmlFilesystemCreated, "ignored")
+	nilProgress.startHeartbeat("ignored")()
+	assert.Zero(t, nilProgress.elapsed())
+}
+
+func TestProvisioningProgress_Heartbeat(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		recorder := record.NewFakeRecorder(10)
+		progress := newTestProvisioningProgress(recorder)
+
+		stopHeartbeat := progress.startHeartbeat("amlfs")
+		time.Sleep(5 * time.Minute)
+		stopHeartbeat()
+		time.Sleep(5 * time.Minute)
+
+		assert.Equal(t, []string{
+			"Normal AmlFilesystemProvisioning AMLFS cluster amlfs is still provisioning, 2m0s elapsed",
+			"Normal AmlFilesystemProvisioning AMLFS cluster amlfs is still provisioning, 4m0s elapsed",
+		}, getRecordedEvents(recorder))
+	})
+}
+
+func TestDynamicCreateVolume_RecordsProgress(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	d := NewFakeDriver()
+	d.eventRecorder = recorder
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	d.cloud = azure.GetTestCloud(ctrl)
+
+	_, err := d.CreateVolume(context.Background(), buildDynamicProvCreateVolumeRequest())
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"Normal AmlFilesystemSkuResolved Resolved SKU AMLFS-Durable-Premium-250 in location test-location: capacity in increments of 8 TiB up to 128 TiB",
+		"Normal AmlFilesystemCreated Created AMLFS cluster test_volume in resource group test-resource-group with MGS address 127.0.0.2 after 0s",
+	}, getRecordedEvents(recorder))
+}
+
+func TestDynamicProvisioner_CreateAmlFilesystem_RecordsProgress(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	dynamicProvisioner := newTestDynamicProvisioner(t, newMockAmlfsRecorder([]string{}))
+
+	_, err := dynamicProvisioner.CreateAmlFilesystem(context.Background(), &AmlFilesystemProperties{
+		ResourceGroupName:  expectedResourceGroupName,
+		AmlFilesystemName:  expectedAmlFilesystemName,
+		SKUName:            expectedSku,
+		StorageCapacityTiB: expectedClusterSize,
+		SubnetInfo:         buildExpectedSubnetInfo(),
+		progress:           newTestProvisioningProgress(recorder),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"Normal AmlFilesystemSubnetCapacityChecked Subnet fake-subnet-name has enough IP addresses available for AMLFS cluster fake-amlfs",
+		"Normal AmlFilesystemCreating Started creating AMLFS cluster fake-amlfs with SKU fake-sku and 48 TiB in resource group fake-resource-group",
+	}, getRecordedEvents(recorder))
+}
+
+func TestDynamicProvisioner_CreateAmlFilesystem_RecordsRetry(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	dynamicProvisioner := newTestDynamicProvisioner(t, newMockAmlfsRecorder([]string{eventualClusterCreateTimeoutFailureName}))
+
+	_, err := dynamicProvisioner.CreateAmlFilesystem(context.Background(), &AmlFilesystemProperties{
+		ResourceGroupName:  expectedResourceGroupName,
+		AmlFilesystemName:  expectedAmlFilesystemName,
+		SKUName:            expectedSku,
+		StorageCapacityTiB: expectedClusterSize,
+		SubnetInfo:         buildExpectedSubnetInfo(),
+		progress:           newTestProvisioningProgress(recorder),
+	})
+	require.ErrorContains(t, err, "retrying cluster creation")
+	events := getRecordedEvents(recorder)
+	require.NotEmpty(t, events)
+	assert.Equal(t, "Normal AmlFilesystemRetrying AMLFS cluster fake-amlfs failed to create after 0s, deleting it before retrying", events[len(events)-1])
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// Azure limits, see
+	// https://learn.microsoft.com/azure/azure-resource-manager/management/tag-resources#limitations
+	maxTagKeyLength        = 512
+	maxTagValueLength      = 256
+	maxTagsPerResource     = 50
+	invalidTagKeyCharacter = "<>%&\\?/"
+)
+
+// isReservedTag reports whether tag is set by the driver itself and so cannot
+// be provided by the storage class or the claim
+func isReservedTag(tag string) bool {
+	switch tag {
+	case pvcNameTag, pvcNamespaceTag, pvNameTag, createdByTag, clusterIDTag:
+		return true
+	}
+	return false
+}
+
+// pvcLabelTagKey returns the Azure tag key for a PVC label or annotation key,
+// characters Azure does not allow in tag keys, such as the / of a prefixed
+// key, are replaced with _
+func pvcLabelTagKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(invalidTagKeyCharacter, r) {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+// parseTagFromPVCLabels parses the comma separated list of PVC label and
+// annotation keys to copy into the AMLFS cluster tags
+func parseTagFromPVCLabels(value string) ([]string, error) {
+	var keys []string
+	for key := range strings.SplitSeq(value, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		tag := pvcLabelTagKey(key)
+		if isReservedTag(tag) {
+			return nil, status.Errorf(codes.InvalidArgument, "CreateVolume Parameter %s must not contain %s as a tag", VolumeContextTagFromPVCLabels, tag)
+		}
+		if len(tag) > maxTagKeyLength {
+			return nil, status.Errorf(codes.InvalidArgument, "CreateVolume Parameter %s key %s is longer than %d characters", VolumeContextTagFromPVCLabels, key, maxTagKeyLength)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// addTagsFromPVC copies the allowlisted labels and annotations of the claim
+// named by the csi.storage.k8s.io/pvc/* parameters into the AMLFS cluster tags.
+// A label takes precedence over an annotation with the same key, and both
+// take precedence over the tags parameter of the storage class.
+func (d *Driver) addTagsFromPVC(ctx context.Context, amlFilesystemProperties *AmlFilesystemProperties, parameters map[string]string) error {
+	if len(amlFilesystemProperties.tagFromPVCLabels) == 0 {
+		return nil
+	}
+
+	pvcName, pvcNamespace := parameters[pvcNameKey], parameters[pvcNamespaceKey]
+	if pvcName == "" || pvcNamespace == "" || d.kubeClient == nil {
+		klog.Warningf("cannot get the persistent volume claim for %s, not adding tags from its labels", VolumeContextTagFromPVCLabels)
+		return nil
+	}
+
+	pvc, err := d.kubeClient.CoreV1().PersistentVolumeClaims(pvcNamespace).Get(ctx, pvcName, metav1.GetOptions{})
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to get persistent volume claim %s/%s: %v", pvcNamespace, pvcName, err)
+	}
+
+	for _, key := range amlFilesystemProperties.tagFromPVCLabels {
+		value, ok := pvc.Labels[key]
+		if !ok {
+			value, ok = pvc.Annotations[key]
+		}
+		if !ok {
+			continue
+		}
+		if len(value) > maxTagValueLength {
+			klog.Warningf("value of %s on persistent volume claim %s/%s is longer than %d characters, not adding it as a tag", key, pvcNamespace, pvcName, maxTagValueLength)
+			continue
+		}
+		amlFilesystemProperties.Tags[pvcLabelTagKey(key)] = value
+	}
+
+	if len(amlFilesystemProperties.Tags) > maxTagsPerResource {
+		return status.Errorf(codes.InvalidArgument, "CreateVolume AMLFS cluster would have %d tags, more than the %d Azure allows", len(amlFilesystemProperties.Tags), maxTagsPerResource)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	azure "sigs.k8s.io/cloud-provider-azure/pkg/provider"
+)
+
+func newTestPVC(labels, annotations map[string]string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "pvc_name",
+			Namespace:   "pvc_namespace",
+			Labels:      labels,
+			Annotations: annotations,
+		},
+	}
+}
+
+func TestParseTagFromPVCLabels(t *testing.T) {
+	cases := []struct {
+		desc          string
+		value         string
+		expectedKeys  []string
+		expectedError string
+	}{
+		{
+			desc:         "keys are trimmed and empty keys skipped",
+			value:        " team, ,costcenter,example.com/project",
+			expectedKeys: []string{"team", "costcenter", "example.com/project"},
+		},
+		{
+			desc:  "empty value",
+			value: "",
+		},
+		{
+			desc:          "reserved tag",
+			value:         "team," + createdByTag,
+			expectedError: "must not contain k8s-azure-created-by as a tag",
+		},
+		{
+			desc:          "key too long",
+			value:         strings.Repeat("k", maxTagKeyLength+1),
+			expectedError: "is longer than 512 characters",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			keys, err := parseTagFromPVCLabels(c.value)
+			if c.expectedError != "" {
+				require.ErrorContains(t, err, c.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.expectedKeys, keys)
+		})
+	}
+}
+
+func TestAddTagsFromPVC(t *testing.T) {
+	pvcParameters := map[string]string{pvcNameKey: "pvc_name", pvcNamespaceKey: "pvc_namespace"}
+	manyAnnotations := map[string]string{}
+	manyKeys := []string{}
+	for i := range maxTagsPerResource {
+		key := "key" + strings.Repeat("x", i)
+		manyAnnotations[key] = "value"
+		manyKeys = append(manyKeys, key)
+	}
+
+	cases := []struct {
+		desc             string
+		keys             []string
+		storageClassTags map[string]string
+		pvc              *corev1.PersistentVolumeClaim
+		parameters       map[string]string
+		expectedTags     map[string]string
+		expectedError    string
+	}{
+		{
+			desc: "labels and annotations are merged",
+			keys: []string{"team", "costcenter", "example.com/project", "missing"},
+			pvc: newTestPVC(
+				map[string]string{"team": "storage", "example.com/project": "lustre", "other": "ignored"},
+				map[string]string{"team": "annotation", "costcenter": "1234"},
+			),
+			parameters: pvcParameters,
+			expectedTags: map[string]string{
+				createdByTag:          azureLustreDriverTag,
+				"team":                "storage",
+				"costcenter":          "1234",
+				"example.com_project": "lustre",
+			},
+		},
+		{
+			desc:             "claim value overrides storage class tag",
+			keys:             []string{"team"},
+			storageClassTags: map[string]string{"team": "storage-class", "project": "lustre"},
+			pvc:              newTestPVC(map[string]string{"team": "storage"}, nil),
+			parameters:       pvcParameters,
+			expectedTags: map[string]string{
+				createdByTag: azureLustreDriverTag,
+				"team":       "storage",
+				"project":    "lustre",
+			},
+		},
+		{
+			desc:       "value too long is skipped",
+			keys:       []string{"description"},
+			pvc:        newTestPVC(nil, map[string]string{"description": strings.Repeat("v", maxTagValueLength+1)}),
+			parameters: pvcParameters,
+			expectedTags: map[string]string{
+				createdByTag: azureLustreDriverTag,
+			},
+		},
+		{
+			desc:       "no claim parameters",
+			keys:       []string{"team"},
+			pvc:        newTestPVC(map[string]string{"team": "storage"}, nil),
+			parameters: map[string]string{},
+			expectedTags: map[string]string{
+				createdByTag: azureLustreDriverTag,
+			},
+		},
+		{
+			desc:          "claim not found",
+			keys:          []string{"team"},
+			parameters:    pvcParameters,
+			expectedError: "failed to get persistent volume claim pvc_namespace/pvc_name",
+		},
+		{
+			desc:          "too many tags",
+			keys:          manyKeys,
+			pvc:           newTestPVC(nil, manyAnnotations),
+			parameters:    pvcParameters,
+			expectedError: "AMLFS cluster would have 51 tags, more than the 50 Azure allows",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			d := NewFakeDriver()
+			if c.pvc != nil {
+				d.kubeClient = kubefake.NewClientset(c.pvc)
+			} else {
+				d.kubeClient = kubefake.NewClientset()
+			}
+			amlFilesystemProperties := &AmlFilesystemProperties{
+				Tags:             map[string]string{createdByTag: azureLustreDriverTag},
+				tagFromPVCLabels: c.keys,
+			}
+			maps.Copy(amlFilesystemProperties.Tags, c.storageClassTags)
+
+			err := d.addTagsFromPVC(context.Background(), amlFilesystemProperties, c.parameters)
+			if c.expectedError != "" {
+				require.ErrorContains(t, err, c.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.expectedTags, amlFilesystemProperties.Tags)
+		})
+	}
+}
+
+func TestDynamicCreateVolume_TagsFromPVCLabels(t *testing.T) {
+	d := NewFakeDriver()
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	d.cloud = azure.GetTestCloud(ctrl)
+	fakeDynamicProvisioner := &FakeDynamicProvisioner{}
+	d.dynamicProvisioner = fakeDynamicProvisioner
+	d.kubeClient = kubefake.NewClientset(newTestPVC(map[string]string{"team": "storage", "key1": "from-pvc"}, nil))
+	req := buildDynamicProvCreateVolumeRequest()
+	req.Parameters[VolumeContextTagFromPVCLabels] = "team,key1"
+
+	_, err := d.CreateVolume(context.Background(), req)
+	require.NoError(t, err)
+	require.Len(t, fakeDynamicProvisioner.Filesystems, 1)
+	tags := fakeDynamicProvisioner.Filesystems[0].Tags
+	assert.Equal(t, "storage", tags["team"])
+	assert.Equal(t, "from-pvc", tags["key1"])
+	assert.Equal(t, "value2", tags["key2"])
+	assert.Equal(t, "pvc_name", tags[pvcNameTag])
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/azurelustre-csi-driver/pkg/lnet"
+)
+
+const (
+	defaultSysModuleDir = "/sys/module"
+
+	// lnetSelfPingTimeout bounds the self-ping so a readiness check always
+	// returns within the kubelet probe timeout
+	lnetSelfPingTimeout = 5 * time.Second
+
+	readinessCheckKernelModules = "kernel-modules"
+	readinessCheckLNetNIDs      = "lnet-nids"
+	readinessCheckLNetStatus    = "lnet-interfaces"
+	readinessCheckLNetSelfPing  = "lnet-self-ping"
+)
+
+// lustreKernelModules are the kernel modules the node plugin needs loaded
+// before it can mount Lustre file systems
+var lustreKernelModules = []string{"lnet", "lustre"}
+
+// readinessCheck is the result of a single readiness check
+type readinessCheck struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+}
+
+// driverReadiness is the outcome of the driver health checks, served as is
+// by the /healthz and /readyz endpoints
+type driverReadiness struct {
+	Ready  bool             `json:"ready"`
+	Reason string           `json:"reason,omitempty"`
+	Checks []readinessCheck `json:"checks,omitempty"`
+}
+
+// addCheck records the result of a check, the first failed check becomes the
+// reason the driver is not ready
+func (r *driverReadiness) addCheck(name string, err error) {
+	check := readinessCheck{Name: name, Passed: err == nil}
+	if err != nil {
+		check.Message = err.Error()
+		if r.Ready {
+			r.Ready = false
+			r.Reason = check.Message
+		}
+	}
+	r.Checks = append(r.Checks, check)
+}
+
+// skipLustreChecks reports whether this driver instance has no Lustre client
+// to check, i.e. it runs as controller or with mock mounts
+func (d *Driver) skipLustreChecks() bool {
+	return d.NodeID == "" || d.enableAzureLustreMockMount
+}
+
+// checkLustreLiveness checks the state a node plugin can only recover from by
+// restarting, i.e. the Lustre kernel modules being loaded
+func (d *Driver) checkLustreLiveness(_ context.Context) *driverReadiness {
+	readiness := &driverReadiness{Ready: true}
+	if d.skipLustreChecks() {
+		return readiness
+	}
+	readiness.addCheck(readinessCheckKernelModules, d.checkKernelModules())
+	return readiness
+}
+
+// checkLustreReadiness checks that the node can mount Lustre file systems:
+// the kernel modules are loaded, LNet has NIDs configured on interfaces that
+// are up and LNet answers a ping to its own NID
+func (d *Driver) checkLustreReadiness(ctx context.Context) *driverReadiness {
+	readiness := &driverReadiness{Ready: true}
+	if d.skipLustreChecks() {
+		return readiness
+	}
+
+	readiness.addCheck(readinessCheckKernelModules, d.checkKernelModules())
+	if !readiness.Ready {
+		return readiness
+	}
+
+	nis, err := d.getLNetLocalNIs(ctx)
+	readiness.addCheck(readinessCheckLNetNIDs, err)
+	if !readiness.Ready {
+		return readiness
+	}
+
+	var upNIDs []string
+	var downNIDs []string
+	for _, ni := range nis {
+		if ni.Status == lnet.StatusUp {
+			upNIDs = append(upNIDs, ni.NID)
+		} else {
+			downNIDs = append(downNIDs, ni.NID)
+		}
+	}
+	if len(upNIDs) == 0 {
+		readiness.addCheck(readinessCheckLNetStatus,
+			fmt.Errorf("no LNet interface is up, down NIDs: %s", strings.Join(downNIDs, ", ")))
+		return readiness
+	}
+	readiness.addCheck(readinessCheckLNetStatus, nil)
+
+	readiness.addCheck(readinessCheckLNetSelfPing, d.pingLNetNID(ctx, upNIDs[0]))
+	return readiness
+}
+
+// checkKernelModules checks that the Lustre kernel modules are loaded
+func (d *Driver) checkKernelModules() error {
+	var missing []string
+	for _, module := range lustreKernelModules {
+		if _, err := os.Stat(filepath.Join(d.sysModuleDir, module)); err != nil {
+			missing = append(missing, module)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("kernel modules not loaded: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// getLNetLocalNIs returns the non-loopback local network interfaces of LNet
+func (d *Driver) getLNetLocalNIs(ctx context.Context) ([]lnet.LocalNI, error) {
+	nets, err := lnet.ShowNetworks(ctx, d.mounter.Exec)
+	if err != nil {
+		return nil, err
+	}
+
+	var nis []lnet.LocalNI
+	for _, net := range nets {
+		if net.NetType == lnet.LoopbackNetType {
+			continue
+		}
+		for _, ni := range net.LocalNIs {
+			if ni.NID != "" {
+				nis = append(nis, ni)
+			}
+		}
+	}
+	if len(nis) == 0 {
+		return nil, fmt.Errorf("no LNet NIDs configured")
+	}
+	return nis, nil
+}
+
+// pingLNetNID checks that LNet can reach the given local NID
+func (d *Driver) pingLNetNID(ctx context.Context, nid string) error {
+	ctx, cancel := context.WithTimeout(ctx, lnetSelfPingTimeout)
+	defer cancel()
+	output, err := d.mounter.Exec.CommandContext(ctx, "lnetctl", "ping", nid).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("LNet self-ping to %s failed: %w, output: %q", nid, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// waitForLustreReadiness blocks until the readiness checks pass, checking
+// again every interval
+func (d *Driver) waitForLustreReadiness(interval time.Duration) {
+	for {
+		readiness := d.checkReadiness(context.Background())
+		if readiness.Ready {
+			return
+		}
+		klog.Warningf("driver is not ready, checking again in %v: %s", interval, readiness.Reason)
+		time.Sleep(interval)
+	}
+}
+
+// ServeHealthz reports whether the driver is alive, answering with the check
+// results as JSON and status 503 when a check failed
+func (d *Driver) ServeHealthz(w http.ResponseWriter, r *http.Request) {
+	serveReadiness(w, d.checkLiveness(r.Context()))
+}
+
+// ServeReadyz reports whether the driver is ready to mount Lustre file
+// systems and, on the controller, whether the permission self-test passed,
+// answering with the check results as JSON and status 503 when a check failed
+func (d *Driver) ServeReadyz(w http.ResponseWriter, r *http.Request) {
+	readiness := d.checkReadiness(r.Context())
+	// Only served here, since a missing role assignment must not restart
+	// the controller through its liveness probe
+	d.addPermissionCheck(readiness)
+	serveReadiness(w, readiness)
+}
+
+func serveReadiness(w http.ResponseWriter, readiness *driverReadiness) {
+	w.Header().Set("Content-Type", "application/json")
+	if readiness.Ready {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(readiness); err != nil {
+		klog.Warningf("failed to write health check response: %v", err)
+	}
+}
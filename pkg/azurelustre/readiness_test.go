@@ -0,0 +1,259 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	mount "k8s.io/mount-utils"
+)
+
+const (
+	fakeLNetNetShow = `net:
+    - net type: lo
+      local NI(s):
+        - nid: 0@lo
+          status: up
+    - net type: tcp
+      local NI(s):
+        - nid: 10.0.0.4@tcp
+          status: up
+          interfaces:
+              0: eth0
+`
+	fakeLNetNetShowDown = `net:
+    - net type: lo
+      local NI(s):
+        - nid: 0@lo
+          status: up
+    - net type: tcp
+      local NI(s):
+        - nid: 10.0.0.4@tcp
+          status: down
+`
+	fakeLNetNetShowLoopbackOnly = `net:
+    - net type: lo
+      local NI(s):
+        - nid: 0@lo
+          status: up
+`
+)
+
+// newFakeSysModuleDir returns a directory listing the given kernel modules as loaded
+func newFakeSysModuleDir(t *testing.T, modules ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, module := range modules {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, module), 0o755))
+	}
+	return dir
+}
+
+func TestCheckLustreReadiness(t *testing.T) {
+	tests := []struct {
+		desc             string
+		modules          []string
+		results          []fakeCommandResult
+		expectedReady    bool
+		expectedReason   string
+		expectedChecks   []string
+		expectedCommands [][]string
+	}{
+		{
+			desc:          "ready",
+			modules:       []string{"lnet", "lustre"},
+			results:       []fakeCommandResult{{output: fakeLNetNetShow}, {output: "ping:\n    - primary nid: 10.0.0.4@tcp\n"}},
+			expectedReady: true,
+			expectedChecks: []string{
+				readinessCheckKernelModules, readinessCheckLNetNIDs,
+				readinessCheckLNetStatus, readinessCheckLNetSelfPing,
+			},
+			expectedCommands: [][]string{
+				{"lnetctl", "net", "show"},
+				{"lnetctl", "ping", "10.0.0.4@tcp"},
+			},
+		},
+		{
+			desc:           "modules not loaded",
+			modules:        []string{"lnet"},
+			expectedReason: "kernel modules not loaded: lustre",
+			expectedChecks: []string{readinessCheckKernelModules},
+		},
+		{
+			desc:             "lnetctl fails",
+			modules:          []string{"lnet", "lustre"},
+			results:          []fakeCommandResult{{output: "LNet not configured", err: errors.New("exit status 1")}},
+			expectedReason:   "lnetctl net show failed",
+			expectedChecks:   []string{readinessCheckKernelModules, readinessCheckLNetNIDs},
+			expectedCommands: [][]string{{"lnetctl", "net", "show"}},
+		},
+		{
+			desc:             "only loopback NID",
+			modules:          []string{"lnet", "lustre"},
+			results:          []fakeCommandResult{{output: fakeLNetNetShowLoopbackOnly}},
+			expectedReason:   "no LNet NIDs configured",
+			expectedChecks:   []string{readinessCheckKernelModules, readinessCheckLNetNIDs},
+			expectedCommands: [][]string{{"lnetctl", "net", "show"}},
+		},
+		{
+			desc:             "interface down",
+			modules:          []string{"lnet", "lustre"},
+			results:          []fakeCommandResult{{output: fakeLNetNetShowDown}},
+			expectedReason:   "no LNet interface is up, down NIDs: 10.0.0.4@tcp",
+			expectedChecks:   []string{readinessCheckKernelModules, readinessCheckLNetNIDs, readinessCheckLNetStatus},
+			expectedCommands: [][]string{{"lnetctl", "net", "show"}},
+		},
+		{
+			desc:           "self-ping fails",
+			modules:        []string{"lnet", "lustre"},
+			results:        []fakeCommandResult{{output: fakeLNetNetShow}, {output: "failed to ping 10.0.0.4@tcp", err: errors.New("exit status 1")}},
+			expectedReason: "LNet self-ping to 10.0.0.4@tcp failed",
+			expectedChecks: []string{
+				readinessCheckKernelModules, readinessCheckLNetNIDs,
+				readinessCheckLNetStatus, readinessCheckLNetSelfPing,
+			},
+			expectedCommands: [][]string{
+				{"lnetctl", "net", "show"},
+				{"lnetctl", "ping", "10.0.0.4@tcp"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var commandLog [][]string
+			d := NewFakeDriver()
+			d.sysModuleDir = newFakeSysModuleDir(t, test.modules...)
+			d.mounter = &mount.SafeFormatAndMount{
+				Interface: &fakeMounter{},
+				Exec:      newScriptedFakeExec(test.results, &commandLog),
+			}
+
+			readiness := d.checkLustreReadiness(context.Background())
+			assert.Equal(t, test.expectedReady, readiness.Ready)
+			assert.Contains(t, readiness.Reason, test.expectedReason)
+			var checks []string
+			for _, check := range readiness.Checks {
+				checks = append(checks, check.Name)
+			}
+			assert.Equal(t, test.expectedChecks, checks)
+			assert.Equal(t, test.expectedCommands, commandLog)
+		})
+	}
+}
+
+func TestCheckLustreReadiness_Skipped(t *testing.T) {
+	d := NewFakeDriver()
+	d.sysModuleDir = newFakeSysModuleDir(t)
+	d.enableAzureLustreMockMount = true
+	assert.True(t, d.checkLustreReadiness(context.Background()).Ready)
+	assert.True(t, d.checkLustreLiveness(context.Background()).Ready)
+
+	d.enableAzureLustreMockMount = false
+	d.NodeID = ""
+	assert.True(t, d.checkLustreReadiness(context.Background()).Ready)
+	assert.True(t, d.checkLustreLiveness(context.Background()).Ready)
+}
+
+func TestCheckLustreLiveness(t *testing.T) {
+	d := NewFakeDriver()
+	d.sysModuleDir = newFakeSysModuleDir(t, "lnet", "lustre")
+	assert.True(t, d.checkLustreLiveness(context.Background()).Ready)
+
+	d.sysModuleDir = newFakeSysModuleDir(t)
+	readiness := d.checkLustreLiveness(context.Background())
+	assert.False(t, readiness.Ready)
+	assert.Equal(t, "kernel modules not loaded: lnet, lustre", readiness.Reason)
+}
+
+func TestServeHealthEndpoints(t *testing.T) {
+	tests := []struct {
+		desc           string
+		ready          bool
+		serve          func(*Driver) http.HandlerFunc
+		expectedStatus int
+	}{
+		{
+			desc:           "ready",
+			ready:          true,
+			serve:          func(d *Driver) http.HandlerFunc { return d.ServeReadyz },
+			expectedStatus: http.StatusOK,
+		},
+		{
+			desc:           "not ready",
+			serve:          func(d *Driver) http.HandlerFunc { return d.ServeReadyz },
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+		{
+			desc:           "alive",
+			ready:          true,
+			serve:          func(d *Driver) http.HandlerFunc { return d.ServeHealthz },
+			expectedStatus: http.StatusOK,
+		},
+		{
+			desc:           "not alive",
+			serve:          func(d *Driver) http.HandlerFunc { return d.ServeHealthz },
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			d := NewFakeDriver()
+			d.checkReadiness = fakeReadiness(test.ready)
+			d.checkLiveness = fakeReadiness(test.ready)
+
+			recorder := httptest.NewRecorder()
+			test.serve(d)(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, test.expectedStatus, recorder.Code)
+			assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+			readiness := driverReadiness{}
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &readiness))
+			assert.Equal(t, test.ready, readiness.Ready)
+		})
+	}
+}
+
+func TestWaitForLustreReadiness(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		interval := 10 * time.Second
+		checks := 0
+		d := NewFakeDriver()
+		d.checkReadiness = func(ctx context.Context) *driverReadiness {
+			checks++
+			return fakeReadiness(checks == 3)(ctx)
+		}
+
+		start := time.Now()
+		d.waitForLustreReadiness(interval)
+
+		assert.Equal(t, 3, checks)
+		assert.Equal(t, 2*interval, time.Since(start))
+	})
+}
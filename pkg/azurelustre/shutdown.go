@@ -0,0 +1,130 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"k8s.io/klog/v2"
+	mount "k8s.io/mount-utils"
+	csicommon "sigs.k8s.io/azurelustre-csi-driver/pkg/csi-common"
+)
+
+const (
+	// grpcGracefulStopTimeout bounds how long in-flight requests may run
+	// after SIGTERM before the gRPC server is stopped forcefully
+	grpcGracefulStopTimeout = 10 * time.Second
+	// lustreModuleUnloadTimeout bounds lustre_rmmod, which can hang while
+	// the modules are in use
+	lustreModuleUnloadTimeout = 15 * time.Second
+)
+
+// handleShutdownSignals shuts the driver down on SIGTERM or SIGINT, the
+// returned channel is closed once the shutdown completed
+func (d *Driver) handleShutdownSignals(s csicommon.NonBlockingGRPCServer) <-chan struct{} {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sig := <-signals
+		klog.Infof("received signal %v, shutting down", sig)
+		d.shutdown(s)
+	}()
+	return done
+}
+
+// shutdown stops the gRPC server and, on nodes, unloads the Lustre kernel
+// modules unless Lustre file systems are still mounted
+func (d *Driver) shutdown(s csicommon.NonBlockingGRPCServer) {
+	stopGRPCServer(s, grpcGracefulStopTimeout)
+
+	if d.skipLustreChecks() || !d.unloadLustreModules {
+		return
+	}
+	if err := d.unloadLustreModulesIfUnused(); err != nil {
+		klog.Errorf("failed to unload Lustre kernel modules: %v", err)
+	}
+}
+
+// stopGRPCServer lets in-flight requests finish for up to timeout before
+// stopping the server forcefully
+func stopGRPCServer(s csicommon.NonBlockingGRPCServer, timeout time.Duration) {
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		klog.V(2).Infof("gRPC server stopped")
+	case <-time.After(timeout):
+		klog.Warningf("gRPC server did not stop within %v, stopping it forcefully", timeout)
+		s.ForceStop()
+	}
+}
+
+// getLustreMounts returns the Lustre file systems mounted on the node
+func (d *Driver) getLustreMounts() ([]mount.MountPoint, error) {
+	mountPoints, err := d.mounter.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mount points: %w", err)
+	}
+	var lustreMounts []mount.MountPoint
+	for _, mountPoint := range mountPoints {
+		if mountPoint.Type == "lustre" {
+			lustreMounts = append(lustreMounts, mountPoint)
+		}
+	}
+	return lustreMounts, nil
+}
+
+// unloadLustreModulesIfUnused unloads the Lustre and LNet kernel modules when
+// no Lustre file system is mounted, otherwise it logs the mounts holding them
+func (d *Driver) unloadLustreModulesIfUnused() error {
+	d.kernelModuleLock.Lock()
+	defer d.kernelModuleLock.Unlock()
+
+	lustreMounts, err := d.getLustreMounts()
+	if err != nil {
+		return err
+	}
+	if len(lustreMounts) > 0 {
+		holders := make([]string, 0, len(lustreMounts))
+		for _, mountPoint := range lustreMounts {
+			holders = append(holders, fmt.Sprintf("%s (%s)", mountPoint.Path, mountPoint.Device))
+		}
+		klog.Warningf("leaving Lustre kernel modules loaded, %d Lustre mounts remain: %s",
+			len(lustreMounts), strings.Join(holders, ", "))
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lustreModuleUnloadTimeout)
+	defer cancel()
+	output, err := d.mounter.Exec.CommandContext(ctx, "lustre_rmmod").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("lustre_rmmod failed: %w, output: %q", err, strings.TrimSpace(string(output)))
+	}
+	klog.Infof("unloaded Lustre kernel modules, no Lustre mounts remain")
+	return nil
+}
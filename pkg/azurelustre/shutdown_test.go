@@ -0,0 +1,147 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"errors"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	mount "k8s.io/mount-utils"
+)
+
+// fakeGRPCServer records how it was stopped, Stop blocks until unblocked
+type fakeGRPCServer struct {
+	unblockStop chan struct{}
+	stopped     bool
+	forced      bool
+}
+
+func (s *fakeGRPCServer) Start(_ string, _ csi.IdentityServer, _ csi.ControllerServer, _ csi.NodeServer, _ bool) {
+}
+
+func (s *fakeGRPCServer) Wait() {}
+
+func (s *fakeGRPCServer) Stop() {
+	if s.unblockStop != nil {
+		<-s.unblockStop
+	}
+	s.stopped = true
+}
+
+func (s *fakeGRPCServer) ForceStop() {
+	s.forced = true
+	if s.unblockStop != nil {
+		close(s.unblockStop)
+	}
+}
+
+func TestStopGRPCServer(t *testing.T) {
+	s := &fakeGRPCServer{}
+	stopGRPCServer(s, time.Second)
+	assert.True(t, s.stopped)
+	assert.False(t, s.forced)
+}
+
+func TestStopGRPCServer_Timeout(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		s := &fakeGRPCServer{unblockStop: make(chan struct{})}
+		start := time.Now()
+		stopGRPCServer(s, 10*time.Second)
+		assert.True(t, s.forced)
+		assert.Equal(t, 10*time.Second, time.Since(start))
+		synctest.Wait()
+	})
+}
+
+func TestUnloadLustreModulesIfUnused(t *testing.T) {
+	tests := []struct {
+		desc             string
+		mountPoints      []mount.MountPoint
+		results          []fakeCommandResult
+		expectedErr      string
+		expectedCommands [][]string
+	}{
+		{
+			desc: "no Lustre mounts",
+			mountPoints: []mount.MountPoint{
+				{Device: "tmpfs", Path: "/var/lib/kubelet/pods/6b3c1d2e/volumes/kubernetes.io~empty-dir/cache", Type: "tmpfs"},
+			},
+			results:          []fakeCommandResult{{}},
+			expectedCommands: [][]string{{"lustre_rmmod"}},
+		},
+		{
+			desc: "Lustre mounts remain",
+			mountPoints: []mount.MountPoint{
+				{Device: "1.1.1.1@tcp:/lustrefs", Path: "/var/lib/kubelet/pods/6b3c1d2e/volumes/kubernetes.io~csi/pv-lustre/mount", Type: "lustre"},
+			},
+		},
+		{
+			desc:             "lustre_rmmod fails",
+			results:          []fakeCommandResult{{output: "rmmod: ERROR: Module lustre is in use", err: errors.New("exit status 1")}},
+			expectedErr:      "lustre_rmmod failed",
+			expectedCommands: [][]string{{"lustre_rmmod"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			var commandLog [][]string
+			d := NewFakeDriver()
+			d.mounter = &mount.SafeFormatAndMount{
+				Interface: &fakeMounter{FakeMounter: mount.FakeMounter{MountPoints: test.mountPoints}},
+				Exec:      newScriptedFakeExec(test.results, &commandLog),
+			}
+
+			err := d.unloadLustreModulesIfUnused()
+			if test.expectedErr != "" {
+				require.ErrorContains(t, err, test.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, test.expectedCommands, commandLog)
+		})
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	var commandLog [][]string
+	d := NewFakeDriver()
+	d.unloadLustreModules = true
+	d.mounter = &mount.SafeFormatAndMount{
+		Interface: &fakeMounter{},
+		Exec:      newScriptedFakeExec([]fakeCommandResult{{}}, &commandLog),
+	}
+
+	s := &fakeGRPCServer{}
+	d.shutdown(s)
+	assert.True(t, s.stopped)
+	assert.Equal(t, [][]string{{"lustre_rmmod"}}, commandLog)
+
+	// Modules are left alone when unloading is disabled or there is no Lustre client
+	commandLog = nil
+	d.unloadLustreModules = false
+	d.shutdown(&fakeGRPCServer{})
+	d.unloadLustreModules = true
+	d.enableAzureLustreMockMount = true
+	d.shutdown(&fakeGRPCServer{})
+	assert.Empty(t, commandLog)
+}
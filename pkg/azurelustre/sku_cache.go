@@ -0,0 +1,120 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"maps"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// skuCacheTTL is how long the AMLFS SKUs of a location are reused before
+	// they are listed again
+	skuCacheTTL = 15 * time.Minute
+
+	skuCacheResultHit    = "hit"
+	skuCacheResultMiss   = "miss"
+	skuCacheResultShared = "shared"
+)
+
+type skuCacheEntry struct {
+	skuValues map[string]*LustreSkuValue
+	expiresAt time.Time
+}
+
+// GetSkuValuesForLocation returns the AMLFS SKUs available in the location
+// to the subscription, that of the driver when empty. The SKUs are cached by
+// subscription, location and the client of the request credential for
+// skuCacheTTL, and concurrent callers for a location missing from the cache
+// wait for a single listing. SKUs with a capability that could not be parsed
+// are returned but not cached, and drop the SKUs cached for the location, so
+// the next call lists them again.
+func (d *DynamicProvisioner) GetSkuValuesForLocation(ctx context.Context, subscriptionID, location string) (map[string]*LustreSkuValue, error) {
+	key := d.skuCacheKey(ctx, subscriptionID, location)
+	if skuValues := d.getCachedSkuValues(key); skuValues != nil {
+		skuCacheRequests.WithLabelValues(skuCacheResultHit).Inc()
+		return maps.Clone(skuValues), nil
+	}
+
+	listed := false
+	// The listing is shared with the other callers, so it is not cancelled
+	// when the caller that started it gives up
+	result, err, _ := d.getCaches().skuGroup.Do(key, func() (any, error) {
+		listed = true
+		skuValues, parseFailed, err := d.listSkuValuesForLocation(context.WithoutCancel(ctx), subscriptionID, location)
+		if err != nil {
+			return nil, err
+		}
+		d.setCachedSkuValues(key, skuValues, parseFailed)
+		return skuValues, nil
+	})
+	if listed {
+		skuCacheRequests.WithLabelValues(skuCacheResultMiss).Inc()
+	} else {
+		skuCacheRequests.WithLabelValues(skuCacheResultShared).Inc()
+	}
+	if err != nil {
+		return nil, err
+	}
+	skuValues, ok := result.(map[string]*LustreSkuValue)
+	if !ok {
+		return nil, status.Errorf(codes.Internal, "unexpected SKU listing result %T", result)
+	}
+	return maps.Clone(skuValues), nil
+}
+
+// skuCacheKey returns the key the SKUs are cached and listed under, the
+// listing being made with the credential of the request that starts it
+func (d *DynamicProvisioner) skuCacheKey(ctx context.Context, subscriptionID, location string) string {
+	key := d.cacheKey(subscriptionID, location)
+	if clientID := requestClientID(ctx); clientID != "" {
+		key += "/" + strings.ToLower(clientID)
+	}
+	return key
+}
+
+func (d *DynamicProvisioner) getCachedSkuValues(key string) map[string]*LustreSkuValue {
+	caches := d.getCaches()
+	caches.skuCacheLock.Lock()
+	defer caches.skuCacheLock.Unlock()
+	entry := caches.skuCache[key]
+	if entry == nil || time.Now().After(entry.expiresAt) {
+		return nil
+	}
+	return entry.skuValues
+}
+
+func (d *DynamicProvisioner) setCachedSkuValues(key string, skuValues map[string]*LustreSkuValue, parseFailed bool) {
+	caches := d.getCaches()
+	caches.skuCacheLock.Lock()
+	defer caches.skuCacheLock.Unlock()
+	if parseFailed {
+		klog.Warningf("not caching AMLFS SKUs for %s, some SKU capabilities could not be parsed", key)
+		delete(caches.skuCache, key)
+		return
+	}
+	if caches.skuCache == nil {
+		caches.skuCache = map[string]*skuCacheEntry{}
+	}
+	caches.skuCache[key] = &skuCacheEntry{skuValues: skuValues, expiresAt: time.Now().Add(skuCacheTTL)}
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storagecache/armstoragecache/v4"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storagecache/armstoragecache/v4/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+// newCountingSkusClient returns a SKUs client listing skus, counting the
+// listings and waiting for release to be closed before answering, if set
+func newCountingSkusClient(t *testing.T, listCalls *atomic.Int32, release chan struct{}, skus ...*armstoragecache.ResourceSKU) *armstoragecache.SKUsClient {
+	fakeSkusServer := fake.SKUsServer{
+		NewListPager: func(_ *armstoragecache.SKUsClientListOptions) azfake.PagerResponder[armstoragecache.SKUsClientListResponse] {
+			listCalls.Add(1)
+			if release != nil {
+				<-release
+			}
+			resp := azfake.PagerResponder[armstoragecache.SKUsClientListResponse]{}
+			resp.AddPage(http.StatusOK, armstoragecache.SKUsClientListResponse{
+				ResourceSKUsResult: armstoragecache.ResourceSKUsResult{Value: skus},
+			}, nil)
+			return resp
+		},
+	}
+	clientFactory, err := armstoragecache.NewClientFactory("fake-subscription-id", &azfake.TokenCredential{},
+		&arm.ClientOptions{
+			ClientOptions: azcore.ClientOptions{
+				Transport: fake.NewSKUsServerTransport(&fakeSkusServer),
+			},
+		},
+	)
+	require.NoError(t, err)
+	return clientFactory.NewSKUsClient()
+}
+
+func TestGetSkuValuesForLocation_Cache(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		listCalls := &atomic.Int32{}
+		dynamicProvisioner := &DynamicProvisioner{
+			skusClient: newCountingSkusClient(t, listCalls, nil,
+				newResourceSku(AmlfsSkuResourceType, expectedSku, expectedLocation, expectedSkuIncrement, expectedSkuMaximum, expectedZones)),
+		}
+		before := gatherMetricValues(t, legacyregistry.DefaultGatherer, "azurelustre_csi_amlfs_sku_cache_requests_total")
+
+		skuValues, err := dynamicProvisioner.GetSkuValuesForLocation(context.Background(), "", expectedLocation)
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), skuValues[expectedSku].IncrementInTib)
+
+		// Changing the returned map does not change the cache
+		delete(skuValues, expectedSku)
+		skuValues, err = dynamicProvisioner.GetSkuValuesForLocation(context.Background(), "", strings.ToUpper(expectedLocation))
+		require.NoError(t, err)
+		assert.Contains(t, skuValues, expectedSku)
+		assert.Equal(t, int32(1), listCalls.Load())
+
+		time.Sleep(skuCacheTTL + time.Second)
+		_, err = dynamicProvisioner.GetSkuValuesForLocation(context.Background(), "", expectedLocation)
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), listCalls.Load())
+
+		after := gatherMetricValues(t, legacyregistry.DefaultGatherer, "azurelustre_csi_amlfs_sku_cache_requests_total")
+		assert.InDelta(t, 1, after["result=hit"]-before["result=hit"], 0)
+		assert.InDelta(t, 2, after["result=miss"]-before["result=miss"], 0)
+	})
+}
+
+func TestGetSkuValuesForLocation_ConcurrentCallers(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		listCalls := &atomic.Int32{}
+		release := make(chan struct{})
+		dynamicProvisioner := &DynamicProvisioner{
+			skusClient: newCountingSkusClient(t, listCalls, release,
+				newResourceSku(AmlfsSkuResourceType, expectedSku, expectedLocation, expectedSkuIncrement, expectedSkuMaximum, expectedZones)),
+		}
+		before := gatherMetricValues(t, legacyregistry.DefaultGatherer, "azurelustre_csi_amlfs_sku_cache_requests_total")
+
+		var wg sync.WaitGroup
+		for range 5 {
+			wg.Go(func() {
+				skuValues, err := dynamicProvisioner.GetSkuValuesForLocation(context.Background(), "", expectedLocation)
+				assert.NoError(t, err)
+				assert.Contains(t, skuValues, expectedSku)
+			})
+		}
+		synctest.Wait()
+		close(release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), listCalls.Load())
+		after := gatherMetricValues(t, legacyregistry.DefaultGatherer, "azurelustre_csi_amlfs_sku_cache_requests_total")
+		assert.InDelta(t, 1, after["result=miss"]-before["result=miss"], 0)
+		assert.InDelta(t, 4, after["result=shared"]-before["result=shared"], 0)
+	})
+}
+
+func TestGetSkuValuesForLocation_Subscriptions(t *testing.T) {
+	listCalls := &atomic.Int32{}
+	otherSkuValues := map[string]*LustreSkuValue{otherSkuForLocation: {IncrementInTib: 8, MaximumInTib: 64}}
+	dynamicProvisioner := &DynamicProvisioner{
+		subscriptionID: testSubscriptionID,
+		skusClient: newCountingSkusClient(t, listCalls, nil,
+			newResourceSku(AmlfsSkuResourceType, expectedSku, expectedLocation, expectedSkuIncrement, expectedSkuMaximum, expectedZones)),
+		caches: &provisionerCaches{skuCache: map[string]*skuCacheEntry{
+			testVnetSubscriptionID + "/" + expectedLocation: {skuValues: otherSkuValues, expiresAt: time.Now().Add(time.Minute)},
+		}},
+	}
+
+	// The SKUs of another subscription are not those of the driver
+	skuValues, err := dynamicProvisioner.GetSkuValuesForLocation(context.Background(), strings.ToUpper(testVnetSubscriptionID), expectedLocation)
+	require.NoError(t, err)
+	assert.Equal(t, otherSkuValues, skuValues)
+	assert.Equal(t, int32(0), listCalls.Load())
+
+	for _, subscriptionID := range []string{"", testSubscriptionID} {
+		skuValues, err = dynamicProvisioner.GetSkuValuesForLocation(context.Background(), subscriptionID, expectedLocation)
+		require.NoError(t, err)
+		assert.Contains(t, skuValues, expectedSku)
+	}
+	assert.Equal(t, int32(1), listCalls.Load())
+}
+
+func TestGetSkuValuesForLocation_RequestCredential(t *testing.T) {
+	listCalls := &atomic.Int32{}
+	driverSkuValues := map[string]*LustreSkuValue{expectedSku: {IncrementInTib: 4, MaximumInTib: 128}}
+	clientSkuValues := map[string]*LustreSkuValue{otherSkuForLocation: {IncrementInTib: 8, MaximumInTib: 64}}
+	dynamicProvisioner := &DynamicProvisioner{
+		subscriptionID: testSubscriptionID,
+		skusClient:     newCountingSkusClient(t, listCalls, nil),
+		caches: &provisionerCaches{skuCache: map[string]*skuCacheEntry{
+			testSubscriptionID + "/" + expectedLocation:             {skuValues: driverSkuValues, expiresAt: time.Now().Add(time.Minute)},
+			testSubscriptionID + "/" + expectedLocation + "/client": {skuValues: clientSkuValues, expiresAt: time.Now().Add(time.Minute)},
+		}},
+	}
+
+	// The SKUs listed with the credential of a provisioner secret are only
+	// shared with the requests of the same client
+	skuValues, err := dynamicProvisioner.GetSkuValuesForLocation(context.Background(), "", expectedLocation)
+	require.NoError(t, err)
+	assert.Equal(t, driverSkuValues, skuValues)
+	ctx := withRequestCredential(context.Background(), &azfake.TokenCredential{}, "CLIENT")
+	skuValues, err = dynamicProvisioner.GetSkuValuesForLocation(ctx, "", expectedLocation)
+	require.NoError(t, err)
+	assert.Equal(t, clientSkuValues, skuValues)
+	assert.Equal(t, int32(0), listCalls.Load())
+}
+
+func TestGetSkuValuesForLocation_NotCachedOnParseError(t *testing.T) {
+	listCalls := &atomic.Int32{}
+	dynamicProvisioner := &DynamicProvisioner{
+		skusClient: newCountingSkusClient(t, listCalls, nil,
+			newResourceSku(AmlfsSkuResourceType, expectedSku, expectedLocation, expectedSkuIncrement, expectedSkuMaximum, expectedZones),
+			newResourceSku(AmlfsSkuResourceType, otherSkuForLocation, expectedLocation, "a", expectedSkuMaximum, expectedZones)),
+		caches: &provisionerCaches{skuCache: map[string]*skuCacheEntry{
+			"/" + expectedLocation: {expiresAt: time.Now().Add(-time.Second)},
+		}},
+	}
+
+	for range 2 {
+		skuValues, err := dynamicProvisioner.GetSkuValuesForLocation(context.Background(), "", expectedLocation)
+		require.NoError(t, err)
+		assert.Contains(t, skuValues, expectedSku)
+		assert.NotContains(t, skuValues, otherSkuForLocation)
+	}
+	assert.Equal(t, int32(2), listCalls.Load())
+	assert.Empty(t, dynamicProvisioner.caches.skuCache, "the expired SKUs are dropped")
+}
+
+func TestGetSkuValuesForLocation_NotCachedOnError(t *testing.T) {
+	listCalls := &atomic.Int32{}
+	dynamicProvisioner := &DynamicProvisioner{
+		skusClient: newCountingSkusClient(t, listCalls, nil,
+			newResourceSku(AmlfsSkuResourceType, expectedSku, "other-location", expectedSkuIncrement, expectedSkuMaximum, expectedZones)),
+	}
+
+	for range 2 {
+		_, err := dynamicProvisioner.GetSkuValuesForLocation(context.Background(), "", expectedLocation)
+		require.ErrorContains(t, err, "found no AMLFS SKUs for location")
+	}
+	assert.Equal(t, int32(2), listCalls.Load())
+}
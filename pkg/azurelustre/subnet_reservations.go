@@ -0,0 +1,240 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// subnetReservationsConfigMapName is the config map persisting the subnet
+	// IP reservations of in-flight AMLFS creations across controller restarts
+	// and leader changes
+	subnetReservationsConfigMapName = "azurelustre-csi-subnet-reservations"
+	subnetReservationsKey           = "reservations"
+
+	// subnetReservationTTL drops reservations that were never released, e.g.
+	// when the claim was deleted while its AMLFS cluster was being created
+	subnetReservationTTL = 4 * time.Hour
+)
+
+// subnetReservation holds the IP addresses of a subnet an in-flight AMLFS
+// creation needs but the subnet usage may not report yet
+type subnetReservation struct {
+	SubnetID string `json:"subnetID"`
+	IPs      int    `json:"ips"`
+	// AvailableIPs is the number of IP addresses the subnet usage reported
+	// available when the reservation was made, 0 when unknown. Those taken
+	// since then are the ones of the cluster once it has network interfaces.
+	AvailableIPs int       `json:"availableIPs,omitempty"`
+	ReservedAt   time.Time `json:"reservedAt"`
+}
+
+// subnetReservationStore keeps the subnet IP reservations, keyed by the
+// subscription, resource group and name of the AMLFS cluster they were made
+// for
+type subnetReservationStore interface {
+	// update applies change, if not nil, to the reservations and returns them.
+	// change reports whether it changed the reservations.
+	update(ctx context.Context, change func(reservations map[string]*subnetReservation) bool) (map[string]*subnetReservation, error)
+}
+
+// memorySubnetReservationStore keeps the reservations in memory, used when
+// the controller has no access to the Kubernetes API
+type memorySubnetReservationStore struct {
+	lock         sync.Mutex
+	reservations map[string]*subnetReservation
+}
+
+func (s *memorySubnetReservationStore) update(_ context.Context, change func(map[string]*subnetReservation) bool) (map[string]*subnetReservation, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.reservations == nil {
+		s.reservations = map[string]*subnetReservation{}
+	}
+	if change != nil {
+		change(s.reservations)
+	}
+	return maps.Clone(s.reservations), nil
+}
+
+// configMapSubnetReservationStore persists the reservations in a config map,
+// so the controller replica taking over provisioning knows about the creations
+// its predecessor started
+type configMapSubnetReservationStore struct {
+	kubeClient kubernetes.Interface
+	namespace  string
+}
+
+func (s *configMapSubnetReservationStore) update(ctx context.Context, change func(map[string]*subnetReservation) bool) (map[string]*subnetReservation, error) {
+	var reservations map[string]*subnetReservation
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMaps := s.kubeClient.CoreV1().ConfigMaps(s.namespace)
+		configMap, err := configMaps.Get(ctx, subnetReservationsConfigMapName, metav1.GetOptions{})
+		notFound := apierrors.IsNotFound(err)
+		if err != nil && !notFound {
+			return err
+		}
+
+		reservations = map[string]*subnetReservation{}
+		if !notFound && configMap.Data[subnetReservationsKey] != "" {
+			if err := json.Unmarshal([]byte(configMap.Data[subnetReservationsKey]), &reservations); err != nil {
+				klog.Warningf("discarding invalid subnet reservations in config map %s/%s: %v", s.namespace, subnetReservationsConfigMapName, err)
+				reservations = map[string]*subnetReservation{}
+			}
+		}
+		if change == nil || !change(reservations) {
+			return nil
+		}
+
+		content, err := json.Marshal(reservations)
+		if err != nil {
+			return err
+		}
+		if notFound {
+			_, err = configMaps.Create(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: subnetReservationsConfigMapName, Namespace: s.namespace},
+				Data:       map[string]string{subnetReservationsKey: string(content)},
+			}, metav1.CreateOptions{})
+			if apierrors.IsAlreadyExists(err) {
+				// Created concurrently, retry against it
+				return apierrors.NewConflict(corev1.Resource("configmaps"), subnetReservationsConfigMapName, err)
+			}
+			return err
+		}
+		configMap = configMap.DeepCopy()
+		if configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
+		configMap.Data[subnetReservationsKey] = string(content)
+		_, err = configMaps.Update(ctx, configMap, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update subnet reservations in config map %s/%s: %w", s.namespace, subnetReservationsConfigMapName, err)
+	}
+	return reservations, nil
+}
+
+// subnetReservationKey returns the key of the reservation of an AMLFS
+// cluster, the subscription of the driver standing for an empty one
+func (d *DynamicProvisioner) subnetReservationKey(subscriptionID, resourceGroupName, amlFilesystemName string) string {
+	if isDefaultSubscription(d.subscriptionID, subscriptionID) {
+		subscriptionID = d.subscriptionID
+	}
+	return strings.ToLower(subscriptionID + "/" + resourceGroupName + "/" + amlFilesystemName)
+}
+
+func (d *DynamicProvisioner) getSubnetReservationStore() subnetReservationStore {
+	d.subnetReservationsInit.Do(func() {
+		if d.subnetReservations == nil {
+			d.subnetReservations = &memorySubnetReservationStore{}
+		}
+	})
+	return d.subnetReservations
+}
+
+// reservedSubnetIPs returns the IP addresses of the subnet reserved by
+// in-flight AMLFS creations, given the number the subnet usage reports
+// available. The usage reports the IP addresses of a cluster once it has
+// network interfaces, well before its creation completes, so those taken
+// since a reservation was made are no longer subtracted. They are credited to
+// the oldest reservations first, so a pending creation is never overlooked.
+// The reservations are best effort: when they cannot be read, none are
+// subtracted from the available IP addresses.
+func (d *DynamicProvisioner) reservedSubnetIPs(ctx context.Context, subnetID string, availableIPs int) int {
+	reservations, err := d.getSubnetReservationStore().update(ctx, nil)
+	if err != nil {
+		klog.Warningf("ignoring subnet IP reservations: %v", err)
+		return 0
+	}
+	var subnetReservations []*subnetReservation
+	for _, reservation := range reservations {
+		if strings.EqualFold(reservation.SubnetID, subnetID) && time.Since(reservation.ReservedAt) < subnetReservationTTL {
+			subnetReservations = append(subnetReservations, reservation)
+		}
+	}
+	slices.SortFunc(subnetReservations, func(a, b *subnetReservation) int {
+		return a.ReservedAt.Compare(b.ReservedAt)
+	})
+
+	reserved, earlierIPs := 0, 0
+	for _, reservation := range subnetReservations {
+		pending := reservation.IPs
+		if reservation.AvailableIPs > 0 {
+			taken := reservation.AvailableIPs - availableIPs - earlierIPs
+			pending -= min(max(taken, 0), reservation.IPs)
+		}
+		reserved += pending
+		earlierIPs += reservation.IPs
+	}
+	return reserved
+}
+
+// reserveSubnetIPs records that the AMLFS cluster being created needs ips
+// addresses of the subnet, which has availableIPs according to its usage,
+// until its creation completes or fails, dropping the expired reservations
+func (d *DynamicProvisioner) reserveSubnetIPs(ctx context.Context, key, subnetID string, ips, availableIPs int) {
+	_, err := d.getSubnetReservationStore().update(ctx, func(reservations map[string]*subnetReservation) bool {
+		for reservationKey, reservation := range reservations {
+			if time.Since(reservation.ReservedAt) >= subnetReservationTTL {
+				klog.Warningf("dropping expired reservation of %d IP addresses in subnet %s for AMLFS cluster %s",
+					reservation.IPs, reservation.SubnetID, reservationKey)
+				delete(reservations, reservationKey)
+			}
+		}
+		reservations[key] = &subnetReservation{SubnetID: subnetID, IPs: ips, AvailableIPs: availableIPs, ReservedAt: time.Now()}
+		return true
+	})
+	if err != nil {
+		klog.Warningf("failed to reserve %d IP addresses in subnet %s for AMLFS cluster %s: %v", ips, subnetID, key, err)
+		return
+	}
+	klog.V(2).Infof("reserved %d IP addresses in subnet %s for AMLFS cluster %s", ips, subnetID, key)
+}
+
+// releaseSubnetIPs drops the reservation of the AMLFS cluster, if any. It
+// runs once the request that created or deleted the cluster may be done.
+func (d *DynamicProvisioner) releaseSubnetIPs(ctx context.Context, key string) {
+	released := false
+	_, err := d.getSubnetReservationStore().update(context.WithoutCancel(ctx), func(reservations map[string]*subnetReservation) bool {
+		_, released = reservations[key]
+		delete(reservations, key)
+		return released
+	})
+	if err != nil {
+		klog.Warningf("failed to release the subnet IP addresses reserved for AMLFS cluster %s: %v", key, err)
+		return
+	}
+	if released {
+		klog.V(2).Infof("released the subnet IP addresses reserved for AMLFS cluster %s", key)
+	}
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestConfigMapSubnetReservationStore(t *testing.T) {
+	kubeClient := kubefake.NewClientset()
+	leader := &DynamicProvisioner{
+		subnetReservations: &configMapSubnetReservationStore{kubeClient: kubeClient, namespace: "kube-system"},
+	}
+	leader.reserveSubnetIPs(context.Background(), "rg/amlfs-1", "subnet-1", 24, 0)
+	leader.reserveSubnetIPs(context.Background(), "rg/amlfs-2", "subnet-1", 16, 0)
+	leader.reserveSubnetIPs(context.Background(), "rg/amlfs-3", "subnet-2", 8, 0)
+
+	configMap, err := kubeClient.CoreV1().ConfigMaps("kube-system").Get(context.Background(), subnetReservationsConfigMapName, metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, configMap.Data[subnetReservationsKey], `"subnetID":"subnet-1","ips":24`)
+
+	// The next leader sees the reservations of the previous one
+	nextLeader := &DynamicProvisioner{
+		subnetReservations: &configMapSubnetReservationStore{kubeClient: kubeClient, namespace: "kube-system"},
+	}
+	assert.Equal(t, 40, nextLeader.reservedSubnetIPs(context.Background(), "SUBNET-1", 100))
+	assert.Equal(t, 8, nextLeader.reservedSubnetIPs(context.Background(), "subnet-2", 100))
+
+	nextLeader.releaseSubnetIPs(context.Background(), "rg/amlfs-1")
+	assert.Equal(t, 16, leader.reservedSubnetIPs(context.Background(), "subnet-1", 100))
+
+	// Releasing a cluster without reservation does not update the config map
+	actions := len(kubeClient.Actions())
+	nextLeader.releaseSubnetIPs(context.Background(), "rg/amlfs-1")
+	require.Len(t, kubeClient.Actions(), actions+1)
+	assert.Equal(t, "get", kubeClient.Actions()[actions].GetVerb())
+}
+
+func TestSubnetReservations_Expired(t *testing.T) {
+	store := &memorySubnetReservationStore{reservations: map[string]*subnetReservation{
+		"rg/stale": {SubnetID: "subnet-1", IPs: 100, ReservedAt: time.Now().Add(-subnetReservationTTL - time.Minute)},
+	}}
+	dynamicProvisioner := &DynamicProvisioner{subnetReservations: store}
+	assert.Equal(t, 0, dynamicProvisioner.reservedSubnetIPs(context.Background(), "subnet-1", 100))
+
+	dynamicProvisioner.reserveSubnetIPs(context.Background(), "rg/amlfs", "subnet-1", 24, 0)
+	assert.Equal(t, 24, dynamicProvisioner.reservedSubnetIPs(context.Background(), "subnet-1", 100))
+	assert.NotContains(t, store.reservations, "rg/stale")
+}
+
+func TestDynamicProvisioner_CreateAmlFilesystem_SubnetReservations(t *testing.T) {
+	recorder := newMockAmlfsRecorder([]string{})
+	dynamicProvisioner := newTestDynamicProvisioner(t, recorder)
+	properties := &AmlFilesystemProperties{
+		ResourceGroupName:  expectedResourceGroupName,
+		AmlFilesystemName:  expectedAmlFilesystemName,
+		Location:           expectedLocation,
+		SKUName:            expectedSku,
+		StorageCapacityTiB: 48,
+		SubnetInfo:         buildExpectedSubnetInfo(),
+	}
+
+	// Another creation in flight leaves 16 of the 246 free IP addresses
+	dynamicProvisioner.reserveSubnetIPs(context.Background(), "other-rg/other-amlfs", expectedAmlFilesystemSubnetID, 230, 0)
+	_, err := dynamicProvisioner.CreateAmlFilesystem(context.Background(), properties)
+	require.Error(t, err)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.ErrorContains(t, err, "not enough IP addresses available: 24 needed, 16 available, 230 reserved by other AMLFS creations")
+	assert.Empty(t, recorder.recordedAmlfsConfigurations)
+
+	// Once the other creation completed, the cluster is created and its own
+	// reservation released
+	dynamicProvisioner.releaseSubnetIPs(context.Background(), "other-rg/other-amlfs")
+	_, err = dynamicProvisioner.CreateAmlFilesystem(context.Background(), properties)
+	require.NoError(t, err)
+	assert.Len(t, recorder.recordedAmlfsConfigurations, 1)
+	assert.Equal(t, 0, dynamicProvisioner.reservedSubnetIPs(context.Background(), expectedAmlFilesystemSubnetID, 246))
+}
+
+func TestDynamicProvisioner_CreateAmlFilesystem_PollingFailureKeepsReservation(t *testing.T) {
+	recorder := newMockAmlfsRecorder([]string{clusterPollingFailureName})
+	dynamicProvisioner := newTestDynamicProvisioner(t, recorder)
+	properties := &AmlFilesystemProperties{
+		ResourceGroupName:  expectedResourceGroupName,
+		AmlFilesystemName:  expectedAmlFilesystemName,
+		Location:           expectedLocation,
+		SKUName:            expectedSku,
+		StorageCapacityTiB: 48,
+		SubnetInfo:         buildExpectedSubnetInfo(),
+	}
+
+	// The cluster is still being created when polling fails
+	_, err := dynamicProvisioner.CreateAmlFilesystem(context.Background(), properties)
+	require.Error(t, err)
+	assert.Equal(t, 24, dynamicProvisioner.reservedSubnetIPs(context.Background(), expectedAmlFilesystemSubnetID, 246))
+
+	// The retried request sees the creation complete
+	_, err = dynamicProvisioner.CreateAmlFilesystem(context.Background(), properties)
+	require.NoError(t, err)
+	assert.Equal(t, 0, dynamicProvisioner.reservedSubnetIPs(context.Background(), expectedAmlFilesystemSubnetID, 246))
+}
+
+func TestDynamicProvisioner_DeleteAmlFilesystem_ReleasesReservation(t *testing.T) {
+	recorder := newMockAmlfsRecorder([]string{})
+	dynamicProvisioner := newTestDynamicProvisioner(t, recorder)
+
+	// A creation abandoned by its request keeps its reservation until the
+	// cluster is deleted
+	dynamicProvisioner.reserveSubnetIPs(context.Background(),
+		dynamicProvisioner.subnetReservationKey("", expectedResourceGroupName, expectedAmlFilesystemName), expectedAmlFilesystemSubnetID, 24, 246)
+	require.NoError(t, dynamicProvisioner.DeleteAmlFilesystem(context.Background(), "", expectedResourceGroupName, expectedAmlFilesystemName))
+	assert.Equal(t, 0, dynamicProvisioner.reservedSubnetIPs(context.Background(), expectedAmlFilesystemSubnetID, 246))
+}
+
+func TestSubnetReservations_TakenIPs(t *testing.T) {
+	now := time.Now()
+	dynamicProvisioner := &DynamicProvisioner{subnetReservations: &memorySubnetReservationStore{reservations: map[string]*subnetReservation{
+		"sub/rg/amlfs-1": {SubnetID: "subnet-1", IPs: 24, AvailableIPs: 200, ReservedAt: now.Add(-2 * time.Minute)},
+		"sub/rg/amlfs-2": {SubnetID: "subnet-1", IPs: 16, AvailableIPs: 200, ReservedAt: now.Add(-time.Minute)},
+		"sub/rg/unknown": {SubnetID: "subnet-1", IPs: 8, ReservedAt: now},
+	}}}
+
+	// Nothing taken yet
+	assert.Equal(t, 48, dynamicProvisioner.reservedSubnetIPs(context.Background(), "subnet-1", 200))
+	// The IP addresses taken are credited to the oldest creation first
+	assert.Equal(t, 34, dynamicProvisioner.reservedSubnetIPs(context.Background(), "subnet-1", 186))
+	assert.Equal(t, 16+8, dynamicProvisioner.reservedSubnetIPs(context.Background(), "subnet-1", 176))
+	assert.Equal(t, 6+8, dynamicProvisioner.reservedSubnetIPs(context.Background(), "subnet-1", 166))
+	// A reservation without the available IP addresses is always subtracted
+	assert.Equal(t, 8, dynamicProvisioner.reservedSubnetIPs(context.Background(), "subnet-1", 100))
+}
+
+func TestSubnetReservationKey(t *testing.T) {
+	dynamicProvisioner := &DynamicProvisioner{subscriptionID: "Driver-Subscription"}
+	assert.Equal(t, "driver-subscription/rg/amlfs", dynamicProvisioner.subnetReservationKey("", "RG", "amlfs"))
+	assert.Equal(t, "driver-subscription/rg/amlfs", dynamicProvisioner.subnetReservationKey("driver-subscription", "rg", "amlfs"))
+	assert.Equal(t, "other-subscription/rg/amlfs", dynamicProvisioner.subnetReservationKey("other-subscription", "rg", "amlfs"))
+}
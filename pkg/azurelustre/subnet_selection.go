@@ -0,0 +1,160 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storagecache/armstoragecache/v4"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// subnetSelectionFirst picks the first candidate subnet with enough IP
+	// addresses available, in the order they are listed
+	subnetSelectionFirst = "first"
+	// subnetSelectionEmptiest picks the candidate subnet with the most IP
+	// addresses available
+	subnetSelectionEmptiest = "emptiest"
+)
+
+// subnetCapacityGetter returns the IP addresses an AMLFS cluster needs and
+// those available in the subnet, as GetSubnetCapacity does
+type subnetCapacityGetter func(ctx context.Context, subscriptionID string, subnetInfo SubnetProperties, skuName string, clusterSize float32) (*SubnetCapacity, error)
+
+// parseCandidateSubnets parses a comma separated list of subnets, each given
+// as subnet-name, vnet-name/subnet-name or
+// vnet-resource-group/vnet-name/subnet-name. The vnet name and resource group
+// left out are filled in later like those of the subnet-name parameter.
+func parseCandidateSubnets(value string) ([]SubnetProperties, error) {
+	var candidates []SubnetProperties
+	for entry := range strings.SplitSeq(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, "/")
+		if len(parts) > 3 || slices.Contains(parts, "") {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"CreateVolume Parameter %s must list subnets as [[vnet-resource-group/]vnet-name/]subnet-name, was: '%s'",
+				VolumeContextCandidateSubnets, entry)
+		}
+		candidate := SubnetProperties{SubnetName: parts[len(parts)-1]}
+		if len(parts) > 1 {
+			candidate.VnetName = parts[len(parts)-2]
+		}
+		if len(parts) > 2 {
+			candidate.VnetResourceGroup = parts[0]
+		}
+		candidates = append(candidates, candidate)
+	}
+	if len(candidates) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"CreateVolume Parameter %s must list at least one subnet", VolumeContextCandidateSubnets)
+	}
+	return candidates, nil
+}
+
+// setCandidateSubnetDefaults fills in the vnet name and resource group the
+// candidate subnets left out from those of the storage class, if any
+func setCandidateSubnetDefaults(candidates []SubnetProperties, subnetInfo SubnetProperties) []SubnetProperties {
+	withDefaults := make([]SubnetProperties, 0, len(candidates))
+	for _, candidate := range candidates {
+		if candidate.VnetName == "" {
+			candidate.VnetName = subnetInfo.VnetName
+			if candidate.VnetResourceGroup == "" {
+				candidate.VnetResourceGroup = subnetInfo.VnetResourceGroup
+			}
+		}
+		withDefaults = append(withDefaults, candidate)
+	}
+	return withDefaults
+}
+
+// chooseSubnet returns the candidate subnet to create the AMLFS cluster in
+// along with its capacity: the first with enough IP addresses available or,
+// with the emptiest selection, the one with the most. Candidates whose
+// capacity cannot be checked are skipped.
+func chooseSubnet(ctx context.Context, amlFilesystemProperties *AmlFilesystemProperties, getSubnetCapacity subnetCapacityGetter) (SubnetProperties, *SubnetCapacity, error) {
+	var chosen SubnetProperties
+	var chosenCapacity *SubnetCapacity
+	var checkErr error
+	capacityRejected := false
+	rejections := make([]string, 0, len(amlFilesystemProperties.candidateSubnets))
+	for _, candidate := range amlFilesystemProperties.candidateSubnets {
+		subnetCapacity, err := getSubnetCapacity(ctx, amlFilesystemProperties.SubscriptionID, candidate, amlFilesystemProperties.SKUName, amlFilesystemProperties.StorageCapacityTiB)
+		if err != nil {
+			klog.Warningf("skipping candidate subnet %s, failed to check its capacity: %v", candidate.SubnetID, err)
+			checkErr = err
+			rejections = append(rejections, fmt.Sprintf("%s: %s", candidate.SubnetID, status.Convert(err).Message()))
+			continue
+		}
+		if subnetCapacity.RequiredIPs > subnetCapacity.AvailableIPs {
+			capacityRejected = true
+			rejections = append(rejections, fmt.Sprintf("%s: %d needed, %d available, %d reserved by other AMLFS creations",
+				candidate.SubnetID, subnetCapacity.RequiredIPs, subnetCapacity.AvailableIPs, subnetCapacity.ReservedIPs))
+			continue
+		}
+		if chosenCapacity == nil || subnetCapacity.AvailableIPs > chosenCapacity.AvailableIPs {
+			chosen, chosenCapacity = candidate, subnetCapacity
+		}
+		if amlFilesystemProperties.subnetSelection != subnetSelectionEmptiest {
+			break
+		}
+	}
+
+	if chosenCapacity == nil {
+		// The failure to check a candidate is only reported when no candidate
+		// could be checked at all
+		code := codes.ResourceExhausted
+		if !capacityRejected && checkErr != nil {
+			code = status.Code(convertHTTPResponseErrorToGrpcCodeError(checkErr))
+		}
+		return SubnetProperties{}, nil, status.Errorf(code,
+			"none of the candidate subnets has enough IP addresses available for a %s cluster of %v TiB: %s",
+			amlFilesystemProperties.SKUName, amlFilesystemProperties.StorageCapacityTiB, strings.Join(rejections, "; "))
+	}
+	klog.V(2).Infof("chose subnet %s out of %d candidates for AMLFS cluster %s: %d IP addresses needed, %d available",
+		chosen.SubnetID, len(amlFilesystemProperties.candidateSubnets), amlFilesystemProperties.AmlFilesystemName,
+		chosenCapacity.RequiredIPs, chosenCapacity.AvailableIPs)
+	return chosen, chosenCapacity, nil
+}
+
+// existingAmlFilesystemSubnet returns the candidate subnet the existing AMLFS
+// cluster was created in, so its retried creation does not choose another
+func existingAmlFilesystemSubnet(ctx context.Context, amlFilesystemsClient *armstoragecache.AmlFilesystemsClient, amlFilesystemProperties *AmlFilesystemProperties) (SubnetProperties, error) {
+	resp, err := amlFilesystemsClient.Get(ctx, amlFilesystemProperties.ResourceGroupName, amlFilesystemProperties.AmlFilesystemName, nil)
+	if err != nil {
+		return SubnetProperties{}, convertHTTPResponseErrorToGrpcCodeError(err)
+	}
+	if resp.Properties == nil || resp.Properties.FilesystemSubnet == nil {
+		return SubnetProperties{}, status.Errorf(codes.Internal, "AMLFS cluster %s has no subnet", amlFilesystemProperties.AmlFilesystemName)
+	}
+	for _, candidate := range amlFilesystemProperties.candidateSubnets {
+		if strings.EqualFold(candidate.SubnetID, *resp.Properties.FilesystemSubnet) {
+			return candidate, nil
+		}
+	}
+	return SubnetProperties{}, status.Errorf(codes.FailedPrecondition,
+		"AMLFS cluster %s already exists in subnet %s, which is not one of the candidate subnets",
+		amlFilesystemProperties.AmlFilesystemName, *resp.Properties.FilesystemSubnet)
+}
@@ -0,0 +1,247 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	azure "sigs.k8s.io/cloud-provider-azure/pkg/provider"
+)
+
+func TestParseCandidateSubnets(t *testing.T) {
+	cases := []struct {
+		desc          string
+		value         string
+		expected      []SubnetProperties
+		expectedError string
+	}{
+		{
+			desc:  "subnet names",
+			value: "subnet-a, subnet-b",
+			expected: []SubnetProperties{
+				{SubnetName: "subnet-a"},
+				{SubnetName: "subnet-b"},
+			},
+		},
+		{
+			desc:  "across vnets",
+			value: "vnet-a/subnet-a,vnet-rg/vnet-b/subnet-b,",
+			expected: []SubnetProperties{
+				{VnetName: "vnet-a", SubnetName: "subnet-a"},
+				{VnetResourceGroup: "vnet-rg", VnetName: "vnet-b", SubnetName: "subnet-b"},
+			},
+		},
+		{
+			desc:          "empty",
+			value:         " , ",
+			expectedError: "must list at least one subnet",
+		},
+		{
+			desc:          "empty vnet name",
+			value:         "subnet-a,/subnet-b",
+			expectedError: "was: '/subnet-b'",
+		},
+		{
+			desc:          "too many parts",
+			value:         "sub/vnet-rg/vnet/subnet",
+			expectedError: "must list subnets as [[vnet-resource-group/]vnet-name/]subnet-name",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			candidates, err := parseCandidateSubnets(c.value)
+			if c.expectedError != "" {
+				require.ErrorContains(t, err, c.expectedError)
+				assert.Equal(t, codes.InvalidArgument, status.Code(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, candidates)
+		})
+	}
+}
+
+func TestParseAmlfilesystemProperties_CandidateSubnets(t *testing.T) {
+	parameters := map[string]string{
+		VolumeContextMaintenanceDayOfWeek:    "Monday",
+		VolumeContextMaintenanceTimeOfDayUtc: "12:00",
+		VolumeContextSkuName:                 "AMLFS-Durable-Premium-250",
+		VolumeContextVnetResourceGroup:       "vnet-rg",
+		VolumeContextVnetName:                "vnet",
+		VolumeContextCandidateSubnets:        "subnet-a,other-vnet/subnet-b,other-rg/third-vnet/subnet-c",
+		VolumeContextSubnetSelection:         "Emptiest",
+	}
+	properties, err := parseAmlFilesystemProperties(parameters)
+	require.NoError(t, err)
+	assert.Equal(t, []SubnetProperties{
+		{VnetResourceGroup: "vnet-rg", VnetName: "vnet", SubnetName: "subnet-a"},
+		{VnetName: "other-vnet", SubnetName: "subnet-b"},
+		{VnetResourceGroup: "other-rg", VnetName: "third-vnet", SubnetName: "subnet-c"},
+	}, properties.candidateSubnets)
+	assert.Equal(t, subnetSelectionEmptiest, properties.subnetSelection)
+
+	parameters[VolumeContextSubnetSelection] = "random"
+	_, err = parseAmlFilesystemProperties(parameters)
+	require.ErrorContains(t, err, "subnet-selection must be one of: [first emptiest], was: 'random'")
+
+	delete(parameters, VolumeContextSubnetSelection)
+	parameters[VolumeContextSubnetName] = "subnet"
+	_, err = parseAmlFilesystemProperties(parameters)
+	require.ErrorContains(t, err, "subnet-name and candidate-subnets cannot be used together")
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestChooseSubnet(t *testing.T) {
+	capacities := map[string]*SubnetCapacity{
+		"full":  {RequiredIPs: 24, AvailableIPs: 8, ReservedIPs: 16},
+		"small": {RequiredIPs: 24, AvailableIPs: 30},
+		"large": {RequiredIPs: 24, AvailableIPs: 200},
+	}
+	getSubnetCapacity := func(_ context.Context, _ string, subnetInfo SubnetProperties, _ string, _ float32) (*SubnetCapacity, error) {
+		if capacity, ok := capacities[subnetInfo.SubnetID]; ok {
+			return capacity, nil
+		}
+		return nil, status.Errorf(codes.NotFound, "subnet %s not found in vnet", subnetInfo.SubnetID)
+	}
+
+	cases := []struct {
+		desc          string
+		candidates    []string
+		selection     string
+		expected      string
+		expectedCode  codes.Code
+		expectedError string
+	}{
+		{desc: "first fitting", candidates: []string{"full", "missing", "small", "large"}, expected: "small"},
+		{desc: "emptiest", candidates: []string{"full", "small", "missing", "large"}, selection: subnetSelectionEmptiest, expected: "large"},
+		{
+			desc:          "none fitting",
+			candidates:    []string{"full", "missing"},
+			expectedCode:  codes.ResourceExhausted,
+			expectedError: "full: 24 needed, 8 available, 16 reserved by other AMLFS creations; missing: subnet missing not found in vnet",
+		},
+		{
+			desc:          "none checked",
+			candidates:    []string{"missing"},
+			expectedCode:  codes.NotFound,
+			expectedError: "none of the candidate subnets has enough IP addresses available for a AMLFS-Durable-Premium-250 cluster of 48 TiB",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			properties := &AmlFilesystemProperties{
+				SKUName:            "AMLFS-Durable-Premium-250",
+				StorageCapacityTiB: 48,
+				subnetSelection:    c.selection,
+			}
+			for _, candidate := range c.candidates {
+				properties.candidateSubnets = append(properties.candidateSubnets, SubnetProperties{SubnetID: candidate})
+			}
+
+			subnetInfo, subnetCapacity, err := chooseSubnet(context.Background(), properties, getSubnetCapacity)
+			if c.expectedError != "" {
+				require.ErrorContains(t, err, c.expectedError)
+				assert.Equal(t, c.expectedCode, status.Code(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, subnetInfo.SubnetID)
+			assert.Equal(t, capacities[c.expected], subnetCapacity)
+		})
+	}
+}
+
+func TestDynamicCreateVolume_CandidateSubnets(t *testing.T) {
+	d := NewFakeDriver()
+	fakeDynamicProvisioner := &FakeDynamicProvisioner{}
+	d.dynamicProvisioner = fakeDynamicProvisioner
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	d.cloud = azure.GetTestCloud(ctrl)
+
+	req := buildDynamicProvCreateVolumeRequest()
+	delete(req.Parameters, VolumeContextSubnetName)
+	req.Parameters[VolumeContextCandidateSubnets] = fullVnetName + "/subnet-a,subnet-b"
+	rep, err := d.CreateVolume(context.Background(), req)
+	require.NoError(t, err)
+	require.Len(t, fakeDynamicProvisioner.Filesystems, 1)
+	assert.Equal(t, SubnetProperties{
+		VnetResourceGroup: "test-vnet-rg",
+		VnetName:          "test-vnet-name",
+		SubnetName:        "subnet-b",
+		SubnetID:          "/subscriptions/subscription/resourceGroups/test-vnet-rg/providers/Microsoft.Network/virtualNetworks/test-vnet-name/subnets/subnet-b",
+	}, fakeDynamicProvisioner.Filesystems[0].SubnetInfo)
+
+	volumeContext := rep.GetVolume().GetVolumeContext()
+	assert.Equal(t, "test-vnet-rg", volumeContext[VolumeContextVnetResourceGroup])
+	assert.Equal(t, "test-vnet-name", volumeContext[VolumeContextVnetName])
+	assert.Equal(t, "subnet-b", volumeContext[VolumeContextSubnetName])
+	assert.NotContains(t, volumeContext, VolumeContextCandidateSubnets)
+
+	// The candidates of the driver apply when the storage class names no subnet
+	d.candidateSubnets = []SubnetProperties{{VnetName: fullVnetName, SubnetName: "subnet-a"}}
+	req = buildDynamicProvCreateVolumeRequest()
+	req.Name = "test_volume_2"
+	delete(req.Parameters, VolumeContextSubnetName)
+	_, err = d.CreateVolume(context.Background(), req)
+	require.ErrorContains(t, err, "none of the candidate subnets has enough IP addresses available")
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestDynamicProvisioner_CreateAmlFilesystem_CandidateSubnets(t *testing.T) {
+	recorder := newMockAmlfsRecorder([]string{})
+	dynamicProvisioner := newTestDynamicProvisioner(t, recorder)
+	fullSubnetInfo := buildExpectedSubnetInfo()
+	fullSubnetInfo.VnetName = fullVnetName
+	otherSubnetInfo := buildExpectedSubnetInfo()
+	otherSubnetInfo.SubnetID = "other-subnet-id"
+	newProperties := func(candidates ...SubnetProperties) *AmlFilesystemProperties {
+		return &AmlFilesystemProperties{
+			ResourceGroupName:  expectedResourceGroupName,
+			AmlFilesystemName:  expectedAmlFilesystemName,
+			Location:           expectedLocation,
+			SKUName:            expectedSku,
+			StorageCapacityTiB: 48,
+			candidateSubnets:   candidates,
+		}
+	}
+
+	properties := newProperties(fullSubnetInfo, buildExpectedSubnetInfo())
+	_, err := dynamicProvisioner.CreateAmlFilesystem(context.Background(), properties)
+	require.NoError(t, err)
+	assert.Equal(t, buildExpectedSubnetInfo(), properties.SubnetInfo)
+	require.Len(t, recorder.recordedAmlfsConfigurations, 1)
+	assert.Equal(t, expectedAmlFilesystemSubnetID, *recorder.recordedAmlfsConfigurations[expectedAmlFilesystemName].Properties.FilesystemSubnet)
+
+	// The retried creation of an existing cluster keeps its subnet
+	properties = newProperties(otherSubnetInfo, buildExpectedSubnetInfo())
+	_, err = dynamicProvisioner.CreateAmlFilesystem(context.Background(), properties)
+	require.NoError(t, err)
+	assert.Equal(t, buildExpectedSubnetInfo(), properties.SubnetInfo)
+
+	_, err = dynamicProvisioner.CreateAmlFilesystem(context.Background(), newProperties(otherSubnetInfo))
+	require.ErrorContains(t, err, "already exists in subnet "+expectedAmlFilesystemSubnetID+", which is not one of the candidate subnets")
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
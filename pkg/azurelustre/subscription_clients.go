@@ -0,0 +1,219 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v6"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storagecache/armstoragecache/v4"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/klog/v2"
+)
+
+var subscriptionIDRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// armClientFactories builds the ARM client factories of the subscriptions
+// storage classes provision AMLFS clusters in on first use and caches them,
+// all sharing the credential of the driver
+type armClientFactories struct {
+	credential azcore.TokenCredential
+	options    *arm.ClientOptions
+
+	lock             sync.Mutex
+	storageFactories map[string]*armstoragecache.ClientFactory
+	networkFactories map[string]*armnetwork.ClientFactory
+}
+
+func newARMClientFactories(credential azcore.TokenCredential, options *arm.ClientOptions) *armClientFactories {
+	return &armClientFactories{
+		credential:       credential,
+		options:          options,
+		storageFactories: map[string]*armstoragecache.ClientFactory{},
+		networkFactories: map[string]*armnetwork.ClientFactory{},
+	}
+}
+
+// clientOptions returns the options of the clients, the defaults when f is
+// nil
+func (f *armClientFactories) clientOptions() *arm.ClientOptions {
+	if f == nil {
+		return nil
+	}
+	return f.options
+}
+
+// storage returns the armstoragecache client factory of the subscription
+func (f *armClientFactories) storage(subscriptionID string) (*armstoragecache.ClientFactory, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	key := strings.ToLower(subscriptionID)
+	if factory, ok := f.storageFactories[key]; ok {
+		return factory, nil
+	}
+	factory, err := armstoragecache.NewClientFactory(subscriptionID, f.credential, f.options)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to create storage client factory for subscription %s: %v", subscriptionID, err)
+	}
+	klog.V(2).Infof("created storage client factory for subscription %s", subscriptionID)
+	f.storageFactories[key] = factory
+	return factory, nil
+}
+
+// network returns the armnetwork client factory of the subscription
+func (f *armClientFactories) network(subscriptionID string) (*armnetwork.ClientFactory, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	key := strings.ToLower(subscriptionID)
+	if factory, ok := f.networkFactories[key]; ok {
+		return factory, nil
+	}
+	factory, err := armnetwork.NewClientFactory(subscriptionID, f.credential, f.options)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to create network client factory for subscription %s: %v", subscriptionID, err)
+	}
+	klog.V(2).Infof("created network client factory for subscription %s", subscriptionID)
+	f.networkFactories[key] = factory
+	return factory, nil
+}
+
+// storageClients are the storage clients of one subscription
+type storageClients struct {
+	amlFilesystemsClient *armstoragecache.AmlFilesystemsClient
+	mgmtClient           *armstoragecache.ManagementClient
+	skusClient           *armstoragecache.SKUsClient
+	// usagesClient is nil when the quota check is skipped
+	usagesClient *armstoragecache.AscUsagesClient
+}
+
+// networkClients are the network clients of one subscription
+type networkClients struct {
+	vnetClient           *armnetwork.VirtualNetworksClient
+	subnetsClient        *armnetwork.SubnetsClient
+	securityGroupsClient *armnetwork.SecurityGroupsClient
+	routeTablesClient    *armnetwork.RouteTablesClient
+}
+
+// isDefaultSubscription reports whether subscriptionID, empty when not
+// given, is the subscription the clients of the driver were created for
+func isDefaultSubscription(defaultSubscriptionID, subscriptionID string) bool {
+	return subscriptionID == "" || strings.EqualFold(subscriptionID, defaultSubscriptionID)
+}
+
+// subnetSubscription returns the subscription of the subnet, empty when its
+// ID cannot be parsed
+func subnetSubscription(subnetInfo SubnetProperties) string {
+	resourceID, err := arm.ParseResourceID(subnetInfo.SubnetID)
+	if err != nil {
+		return ""
+	}
+	return resourceID.SubscriptionID
+}
+
+// storageClientsFor returns the storage clients of the subscription, that
+// of the driver when empty. The clients of a request with its own credential
+// are built for it alone.
+func (d *DynamicProvisioner) storageClientsFor(ctx context.Context, subscriptionID string) (*storageClients, error) {
+	credential := requestCredential(ctx)
+	if credential == nil && isDefaultSubscription(d.subscriptionID, subscriptionID) {
+		return &storageClients{
+			amlFilesystemsClient: d.amlFilesystemsClient,
+			mgmtClient:           d.mgmtClient,
+			skusClient:           d.skusClient,
+			usagesClient:         d.usagesClient,
+		}, nil
+	}
+
+	var factory *armstoragecache.ClientFactory
+	var err error
+	switch {
+	case credential != nil:
+		if subscriptionID == "" {
+			subscriptionID = d.subscriptionID
+		}
+		factory, err = armstoragecache.NewClientFactory(subscriptionID, credential, d.clientFactories.clientOptions())
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to create storage client factory for subscription %s: %v", subscriptionID, err)
+		}
+	case d.clientFactories == nil:
+		return nil, status.Errorf(codes.InvalidArgument, "cannot provision AMLFS clusters in subscription %s, only in the subscription of the driver", subscriptionID)
+	default:
+		if factory, err = d.clientFactories.storage(subscriptionID); err != nil {
+			return nil, err
+		}
+	}
+	return &storageClients{
+		amlFilesystemsClient: factory.NewAmlFilesystemsClient(),
+		mgmtClient:           factory.NewManagementClient(),
+		skusClient:           factory.NewSKUsClient(),
+		usagesClient:         factory.NewAscUsagesClient(),
+	}, nil
+}
+
+// networkClientsFor returns the network clients of the subscription of the
+// subnet, those of the driver when the subnet is in its vnet subscription and
+// the request has no credential of its own
+func (d *DynamicProvisioner) networkClientsFor(ctx context.Context, subnetInfo SubnetProperties) (*networkClients, error) {
+	subscriptionID := subnetSubscription(subnetInfo)
+	credential := requestCredential(ctx)
+	if credential == nil && isDefaultSubscription(d.vnetSubscriptionID, subscriptionID) {
+		return d.driverNetworkClients(), nil
+	}
+
+	var factory *armnetwork.ClientFactory
+	var err error
+	switch {
+	case credential != nil:
+		if subscriptionID == "" {
+			subscriptionID = d.vnetSubscriptionID
+		}
+		factory, err = armnetwork.NewClientFactory(subscriptionID, credential, d.clientFactories.clientOptions())
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "failed to create network client factory for subscription %s: %v", subscriptionID, err)
+		}
+	case d.clientFactories == nil:
+		return nil, status.Errorf(codes.InvalidArgument, "cannot use subnet %s of subscription %s, only subnets of the vnet subscription of the driver", subnetInfo.SubnetID, subscriptionID)
+	default:
+		if factory, err = d.clientFactories.network(subscriptionID); err != nil {
+			return nil, err
+		}
+	}
+	return &networkClients{
+		vnetClient:           factory.NewVirtualNetworksClient(),
+		subnetsClient:        factory.NewSubnetsClient(),
+		securityGroupsClient: factory.NewSecurityGroupsClient(),
+		routeTablesClient:    factory.NewRouteTablesClient(),
+	}, nil
+}
+
+// driverNetworkClients returns the network clients of the driver
+func (d *DynamicProvisioner) driverNetworkClients() *networkClients {
+	return &networkClients{
+		vnetClient:           d.vnetClient,
+		subnetsClient:        d.subnetsClient,
+		securityGroupsClient: d.securityGroupsClient,
+		routeTablesClient:    d.routeTablesClient,
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	azfake "github.com/Azure/azure-sdk-for-go/sdk/azcore/fake"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	azure "sigs.k8s.io/cloud-provider-azure/pkg/provider"
+)
+
+const (
+	testSubscriptionID     = "12345678-1234-1234-1234-123456789abc"
+	testVnetSubscriptionID = "87654321-4321-4321-4321-cba987654321"
+)
+
+func TestParseAmlfilesystemProperties_SubscriptionIDs(t *testing.T) {
+	parameters := map[string]string{
+		VolumeContextMaintenanceDayOfWeek:    "Monday",
+		VolumeContextMaintenanceTimeOfDayUtc: "12:00",
+		VolumeContextSkuName:                 "AMLFS-Durable-Premium-250",
+		VolumeContextSubscriptionID:          testSubscriptionID,
+		"Vnet-Subscription-ID":               testVnetSubscriptionID,
+	}
+	properties, err := parseAmlFilesystemProperties(parameters)
+	require.NoError(t, err)
+	assert.Equal(t, testSubscriptionID, properties.SubscriptionID)
+	assert.Equal(t, testVnetSubscriptionID, properties.VnetSubscriptionID)
+
+	parameters[VolumeContextSubscriptionID] = "sub#1"
+	_, err = parseAmlFilesystemProperties(parameters)
+	require.ErrorContains(t, err, "subscription-id must be a subscription ID, was: 'sub#1'")
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestDynamicCreateVolume_SubscriptionIDs(t *testing.T) {
+	d := NewFakeDriver()
+	fakeDynamicProvisioner := &FakeDynamicProvisioner{}
+	d.dynamicProvisioner = fakeDynamicProvisioner
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	d.cloud = azure.GetTestCloud(ctrl)
+
+	req := buildDynamicProvCreateVolumeRequest()
+	req.Parameters[VolumeContextSubscriptionID] = testSubscriptionID
+	req.Parameters[VolumeContextVnetSubscriptionID] = testVnetSubscriptionID
+	rep, err := d.CreateVolume(context.Background(), req)
+	require.NoError(t, err)
+	require.Len(t, fakeDynamicProvisioner.Filesystems, 1)
+	assert.Equal(t, testSubscriptionID, fakeDynamicProvisioner.Filesystems[0].SubscriptionID)
+	assert.Equal(t, "/subscriptions/"+testVnetSubscriptionID+"/resourceGroups/test-vnet-rg/providers/Microsoft.Network/virtualNetworks/test-vnet-name/subnets/test-subnet-name",
+		fakeDynamicProvisioner.Filesystems[0].SubnetInfo.SubnetID)
+
+	volumeID := rep.GetVolume().GetVolumeId()
+	assert.True(t, strings.HasSuffix(volumeID, "#t#"+fakeDynamicProvisioner.Filesystems[0].ResourceGroupName+"#"+testSubscriptionID), volumeID)
+
+	// The cluster is deleted from the subscription recorded in the volume ID
+	_, err = d.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: strings.TrimSuffix(volumeID, "#"+testSubscriptionID)})
+	require.NoError(t, err)
+	require.Len(t, fakeDynamicProvisioner.Filesystems, 1)
+	_, err = d.DeleteVolume(context.Background(), &csi.DeleteVolumeRequest{VolumeId: volumeID})
+	require.NoError(t, err)
+	assert.Empty(t, fakeDynamicProvisioner.Filesystems)
+}
+
+func TestARMClientFactories(t *testing.T) {
+	factories := newARMClientFactories(&azfake.TokenCredential{}, nil)
+	storageFactory, err := factories.storage(testSubscriptionID)
+	require.NoError(t, err)
+	cachedStorageFactory, err := factories.storage(strings.ToUpper(testSubscriptionID))
+	require.NoError(t, err)
+	assert.Same(t, storageFactory, cachedStorageFactory)
+
+	networkFactory, err := factories.network(testVnetSubscriptionID)
+	require.NoError(t, err)
+	cachedNetworkFactory, err := factories.network(testVnetSubscriptionID)
+	require.NoError(t, err)
+	assert.Same(t, networkFactory, cachedNetworkFactory)
+	assert.Len(t, factories.storageFactories, 1)
+	assert.Len(t, factories.networkFactories, 1)
+}
+
+func TestDynamicProvisioner_ClientsFor(t *testing.T) {
+	recorder := newMockAmlfsRecorder([]string{})
+	dynamicProvisioner := newTestDynamicProvisioner(t, recorder)
+	dynamicProvisioner.subscriptionID = testSubscriptionID
+	otherSubnet := SubnetProperties{
+		SubnetID: "/subscriptions/" + testVnetSubscriptionID + "/resourceGroups/vnet-rg/providers/Microsoft.Network/virtualNetworks/vnet/subnets/subnet",
+	}
+
+	// The clients of the driver are used for its own subscriptions
+	for _, subscriptionID := range []string{"", strings.ToUpper(testSubscriptionID)} {
+		clients, err := dynamicProvisioner.storageClientsFor(context.Background(), subscriptionID)
+		require.NoError(t, err)
+		assert.Same(t, dynamicProvisioner.amlFilesystemsClient, clients.amlFilesystemsClient)
+	}
+	clients, err := dynamicProvisioner.networkClientsFor(context.Background(), buildExpectedSubnetInfo())
+	require.NoError(t, err)
+	assert.Same(t, dynamicProvisioner.vnetClient, clients.vnetClient)
+
+	// Other subscriptions are rejected without client factories
+	_, err = dynamicProvisioner.storageClientsFor(context.Background(), testVnetSubscriptionID)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = dynamicProvisioner.networkClientsFor(context.Background(), otherSubnet)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = dynamicProvisioner.CreateAmlFilesystem(context.Background(), &AmlFilesystemProperties{
+		SubscriptionID: testVnetSubscriptionID,
+		SubnetInfo:     buildExpectedSubnetInfo(),
+	})
+	require.ErrorContains(t, err, "cannot provision AMLFS clusters in subscription "+testVnetSubscriptionID)
+
+	dynamicProvisioner.clientFactories = newARMClientFactories(&azfake.TokenCredential{}, nil)
+	storageClients, err := dynamicProvisioner.storageClientsFor(context.Background(), testVnetSubscriptionID)
+	require.NoError(t, err)
+	assert.NotSame(t, dynamicProvisioner.amlFilesystemsClient, storageClients.amlFilesystemsClient)
+	assert.NotNil(t, storageClients.usagesClient)
+	clients, err = dynamicProvisioner.networkClientsFor(context.Background(), otherSubnet)
+	require.NoError(t, err)
+	assert.NotSame(t, dynamicProvisioner.vnetClient, clients.vnetClient)
+	assert.Contains(t, dynamicProvisioner.clientFactories.networkFactories, testVnetSubscriptionID)
+}
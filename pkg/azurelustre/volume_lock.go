@@ -17,6 +17,7 @@ limitations under the License.
 package azurelustre
 
 import (
+	"fmt"
 	"sync"
 
 	"k8s.io/apimachinery/pkg/util/sets"
@@ -56,3 +57,9 @@ func (vl *volumeLocks) Release(volumeID string) {
 	defer vl.mux.Unlock()
 	vl.locks.Delete(volumeID)
 }
+
+// getNodeVolumeLockKey returns the lock key of the node operations on a volume
+// published at target
+func getNodeVolumeLockKey(volumeID, target string) string {
+	return fmt.Sprintf("%s-%s", volumeID, target)
+}
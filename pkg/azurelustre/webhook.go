@@ -0,0 +1,174 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/status"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// csiParameterPrefix prefixes the storage class parameters the external
+	// provisioner consumes itself instead of passing them to CreateVolume
+	csiParameterPrefix = "csi.storage.k8s.io/"
+
+	// maxAdmissionReviewBytes bounds the size of an admission review request
+	maxAdmissionReviewBytes = 3 * 1024 * 1024
+)
+
+var (
+	storageClassResource     = metav1.GroupVersionResource{Group: "storage.k8s.io", Version: "v1", Resource: "storageclasses"}
+	persistentVolumeResource = metav1.GroupVersionResource{Group: "", Version: "v1", Resource: "persistentvolumes"}
+)
+
+// ServeValidatingWebhook answers admission reviews of storage classes and
+// persistent volumes, denying those of this driver whose parameters, volume
+// attributes or mount options would later fail CreateVolume or
+// NodePublishVolume
+func (d *Driver) ServeValidatingWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	review := &admissionv1.AdmissionReview{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAdmissionReviewBytes)).Decode(review); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode admission review: %v", err), http.StatusBadRequest)
+		return
+	}
+	if review.Request == nil {
+		http.Error(w, "admission review has no request", http.StatusBadRequest)
+		return
+	}
+
+	response := &admissionv1.AdmissionResponse{
+		UID:     review.Request.UID,
+		Allowed: true,
+	}
+	if err := d.validateAdmissionRequest(review.Request); err != nil {
+		klog.V(2).Infof("denying %s of %s %s: %v", review.Request.Operation, review.Request.Resource.Resource, review.Request.Name, err)
+		response.Allowed = false
+		response.Result = &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Message: status.Convert(err).Message(),
+			Reason:  metav1.StatusReasonInvalid,
+			Code:    http.StatusUnprocessableEntity,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&admissionv1.AdmissionReview{
+		TypeMeta: review.TypeMeta,
+		Response: response,
+	}); err != nil {
+		klog.Warningf("failed to write admission review response: %v", err)
+	}
+}
+
+func (d *Driver) validateAdmissionRequest(req *admissionv1.AdmissionRequest) error {
+	// StorageClass parameters and the CSI source of a PV are immutable, so
+	// updates, e.g. removing a finalizer, are left alone
+	if req.Operation != admissionv1.Create {
+		return nil
+	}
+
+	switch req.Resource {
+	case storageClassResource:
+		storageClass := &storagev1.StorageClass{}
+		if err := json.Unmarshal(req.Object.Raw, storageClass); err != nil {
+			return fmt.Errorf("failed to decode storage class: %w", err)
+		}
+		return d.validateStorageClass(storageClass)
+	case persistentVolumeResource:
+		pv := &v1.PersistentVolume{}
+		if err := json.Unmarshal(req.Object.Raw, pv); err != nil {
+			return fmt.Errorf("failed to decode persistent volume: %w", err)
+		}
+		return d.validatePersistentVolume(pv)
+	default:
+		return nil
+	}
+}
+
+// validateStorageClass checks the parameters and mount options of a storage
+// class of this driver the way CreateVolume and NodePublishVolume would
+func (d *Driver) validateStorageClass(storageClass *storagev1.StorageClass) error {
+	if storageClass.Provisioner != d.Name {
+		return nil
+	}
+
+	parameters := removeCSIParameters(storageClass.Parameters)
+	if _, err := parseAmlFilesystemProperties(parameters); err != nil {
+		return err
+	}
+	if _, err := createVolumeIDFromParams(storageClass.Name, parameters); err != nil {
+		return err
+	}
+	return validateMountOptions(storageClass.MountOptions)
+}
+
+// removeCSIParameters returns the storage class parameters without those the
+// external provisioner consumes itself
+func removeCSIParameters(parameters map[string]string) map[string]string {
+	filtered := make(map[string]string, len(parameters))
+	for key, value := range parameters {
+		if !strings.HasPrefix(key, csiParameterPrefix) {
+			filtered[key] = value
+		}
+	}
+	return filtered
+}
+
+// validatePersistentVolume checks the volume attributes and mount options of
+// a persistent volume of this driver the way NodePublishVolume would
+func (d *Driver) validatePersistentVolume(pv *v1.PersistentVolume) error {
+	if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != d.Name {
+		return nil
+	}
+
+	if _, err := newLustreVolume(pv.Spec.CSI.VolumeHandle, pv.Name, pv.Spec.CSI.VolumeAttributes); err != nil {
+		return err
+	}
+	if _, err := parseClientTunables(pv.Spec.CSI.VolumeAttributes); err != nil {
+		return err
+	}
+	return validateMountOptions(pv.Spec.MountOptions)
+}
+
+// validateMountOptions rejects mount options that cannot be passed to mount
+// as a single option
+func validateMountOptions(mountOptions []string) error {
+	for _, mountOption := range mountOptions {
+		if strings.TrimSpace(mountOption) == "" {
+			return errors.New("mount options must not be empty")
+		}
+		if strings.ContainsFunc(mountOption, func(r rune) bool { return r == ' ' || r == '\t' || r == '\n' }) {
+			return fmt.Errorf("mount option %q must not contain whitespace", mountOption)
+		}
+	}
+	return nil
+}
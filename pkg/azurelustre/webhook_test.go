@@ -0,0 +1,220 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package azurelustre
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func newTestStorageClass(provisioner string, parameters map[string]string, mountOptions ...string) *storagev1.StorageClass {
+	return &storagev1.StorageClass{
+		ObjectMeta:   metav1.ObjectMeta{Name: "azurelustre"},
+		Provisioner:  provisioner,
+		Parameters:   parameters,
+		MountOptions: mountOptions,
+	}
+}
+
+func newTestPersistentVolume(driver string, volumeAttributes map[string]string, mountOptions ...string) *v1.PersistentVolume {
+	return &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv-azurelustre"},
+		Spec: v1.PersistentVolumeSpec{
+			MountOptions: mountOptions,
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				CSI: &v1.CSIPersistentVolumeSource{
+					Driver:           driver,
+					VolumeHandle:     "pv-azurelustre#lustrefs#127.0.0.1###",
+					VolumeAttributes: volumeAttributes,
+				},
+			},
+		},
+	}
+}
+
+func TestServeValidatingWebhook(t *testing.T) {
+	d := NewFakeDriver()
+	dynamicParameters := map[string]string{
+		"sku-name":                                   "AMLFS-Durable-Premium-250",
+		"maintenance-day-of-week":                    "Sunday",
+		"maintenance-time-of-day-utc":                "12:00",
+		"csi.storage.k8s.io/provisioner-secret-name": "secret",
+	}
+
+	cases := []struct {
+		desc            string
+		operation       admissionv1.Operation
+		resource        metav1.GroupVersionResource
+		object          runtime.Object
+		expectedAllowed bool
+		expectedMessage string
+	}{
+		{
+			desc:            "valid dynamic storage class",
+			resource:        storageClassResource,
+			object:          newTestStorageClass(d.Name, dynamicParameters, "noatime"),
+			expectedAllowed: true,
+		},
+		{
+			desc:            "valid static storage class",
+			resource:        storageClassResource,
+			object:          newTestStorageClass(d.Name, map[string]string{"mgs-ip-address": "127.0.0.1", "sub-dir": "data"}),
+			expectedAllowed: true,
+		},
+		{
+			desc:     "invalid maintenance day",
+			resource: storageClassResource,
+			object: newTestStorageClass(d.Name, map[string]string{
+				"sku-name":                    "AMLFS-Durable-Premium-250",
+				"maintenance-day-of-week":     "sunday",
+				"maintenance-time-of-day-utc": "12:00",
+			}),
+			expectedMessage: "CreateVolume Parameter maintenance-day-of-week must be one of",
+		},
+		{
+			desc:            "unknown storage class parameter",
+			resource:        storageClassResource,
+			object:          newTestStorageClass(d.Name, map[string]string{"mgs-ip-address": "127.0.0.1", "unknown": "value"}),
+			expectedMessage: "Invalid parameter(s) {unknown = value} in storage class",
+		},
+		{
+			desc:            "root sub-dir in storage class",
+			resource:        storageClassResource,
+			object:          newTestStorageClass(d.Name, map[string]string{"mgs-ip-address": "127.0.0.1", "sub-dir": "/"}),
+			expectedMessage: "CreateVolume Parameter sub-dir must not be empty if provided",
+		},
+		{
+			desc:            "invalid storage class mount option",
+			resource:        storageClassResource,
+			object:          newTestStorageClass(d.Name, map[string]string{"mgs-ip-address": "127.0.0.1"}, "noatime flock"),
+			expectedMessage: `mount option "noatime flock" must not contain whitespace`,
+		},
+		{
+			desc:            "storage class of another driver",
+			resource:        storageClassResource,
+			object:          newTestStorageClass("other.csi.k8s.io", map[string]string{"unknown": "value"}),
+			expectedAllowed: true,
+		},
+		{
+			desc:            "valid persistent volume",
+			resource:        persistentVolumeResource,
+			object:          newTestPersistentVolume(d.Name, map[string]string{"mgs-ip-address": "127.0.0.1"}, "noatime"),
+			expectedAllowed: true,
+		},
+		{
+			desc:            "persistent volume without mgs-ip-address",
+			resource:        persistentVolumeResource,
+			object:          newTestPersistentVolume(d.Name, map[string]string{"sub-dir": "data"}),
+			expectedMessage: "Context mgs-ip-address must be provided",
+		},
+		{
+			desc:            "persistent volume with invalid client tunables",
+			resource:        persistentVolumeResource,
+			object:          newTestPersistentVolume(d.Name, map[string]string{"mgs-ip-address": "127.0.0.1", "client-tunables": "llite.unknown=1"}),
+			expectedMessage: "llite.unknown",
+		},
+		{
+			desc:            "persistent volume with empty mount option",
+			resource:        persistentVolumeResource,
+			object:          newTestPersistentVolume(d.Name, map[string]string{"mgs-ip-address": "127.0.0.1"}, ""),
+			expectedMessage: "mount options must not be empty",
+		},
+		{
+			desc:            "persistent volume of another driver",
+			resource:        persistentVolumeResource,
+			object:          newTestPersistentVolume("other.csi.k8s.io", map[string]string{}),
+			expectedAllowed: true,
+		},
+		{
+			desc:            "update is not validated",
+			operation:       admissionv1.Update,
+			resource:        persistentVolumeResource,
+			object:          newTestPersistentVolume(d.Name, map[string]string{}),
+			expectedAllowed: true,
+		},
+		{
+			desc:            "delete is not validated",
+			operation:       admissionv1.Delete,
+			resource:        persistentVolumeResource,
+			object:          newTestPersistentVolume(d.Name, map[string]string{}),
+			expectedAllowed: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			operation := c.operation
+			if operation == "" {
+				operation = admissionv1.Create
+			}
+			raw, err := json.Marshal(c.object)
+			require.NoError(t, err)
+			body, err := json.Marshal(&admissionv1.AdmissionReview{
+				TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+				Request: &admissionv1.AdmissionRequest{
+					UID:       "uid",
+					Operation: operation,
+					Resource:  c.resource,
+					Object:    runtime.RawExtension{Raw: raw},
+				},
+			})
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			d.ServeValidatingWebhook(recorder, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body)))
+
+			require.Equal(t, http.StatusOK, recorder.Code)
+			review := admissionv1.AdmissionReview{}
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &review))
+			assert.Equal(t, "AdmissionReview", review.Kind)
+			require.NotNil(t, review.Response)
+			assert.Equal(t, "uid", string(review.Response.UID))
+			assert.Equal(t, c.expectedAllowed, review.Response.Allowed)
+			if c.expectedMessage != "" {
+				require.NotNil(t, review.Response.Result)
+				assert.Contains(t, review.Response.Result.Message, c.expectedMessage)
+			}
+		})
+	}
+}
+
+func TestServeValidatingWebhook_BadRequest(t *testing.T) {
+	d := NewFakeDriver()
+
+	recorder := httptest.NewRecorder()
+	d.ServeValidatingWebhook(recorder, httptest.NewRequest(http.MethodGet, "/validate", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	d.ServeValidatingWebhook(recorder, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader([]byte("{}"))))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	d.ServeValidatingWebhook(recorder, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader([]byte("not json"))))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
@@ -24,46 +24,6 @@ set -o errexit
 set -o pipefail
 set -o nounset
 
-function add_net_interfaces() {
-  echo "$(date -u) Determining ethernet interfaces."
-  echo "$(date -u) Route table is:"
-  ip route list
-  interface_list=$(ip route show | sed -n 's/.*\s\+dev\s\+\([^ ]\+\).*/\1/p' | sort -u)
-  ethernet_interfaces=()
-  for interface in ${interface_list}; do
-    interface_info=$(ip link show "${interface}")
-    # Skip interfaces that are not needed
-    if [[ "${interface_info}" =~ 'SLAVE' ]]; then
-      echo "$(date -u) Not adding slave interface: ${interface}"
-    elif [[ "${interface_info}" =~ 'link-netns' ]]; then
-      echo "$(date -u) Not adding namespaced interface: ${interface}"
-    elif [[ "${interface_info}" =~ 'UNKNOWN' ]]; then
-      echo "$(date -u) Not adding state unknown interface: ${interface}"
-    # Add remaining link/ether interface
-    elif [[ "${interface_info}" =~ 'link/ether' ]]; then
-      echo "$(date -u) Including ethernet interface: ${interface}"
-      ethernet_interfaces+=("${interface}")
-    else
-      echo "$(date -u) Skipping non-ethernet interface: ${interface}"
-    fi
-  done
-  echo "$(date -u) List of found ethernet interfaces is: ${ethernet_interfaces[*]}"
-
-  if [[ "${#ethernet_interfaces[@]}" -eq 0 ]]; then
-    echo "$(date -u) Cannot find any ethernet network interface"
-    exit 1
-  fi
-
-  for interface in "${ethernet_interfaces[@]}"; do
-    if lnetctl net show --net tcp | grep -q "\b${interface}\b"; then
-      echo "$(date -u) Interface already added, skipping: ${interface}"
-    else
-      echo "$(date -u) Adding interface: ${interface}"
-      lnetctl net add --net tcp --if "${interface}"
-    fi
-  done
-}
-
 # Update CA certificates to ensure HTTPS connections work
 update-ca-certificates
 
@@ -167,29 +127,16 @@ if [[ "${installClientPackages}" == "yes" ]]; then
     echo "$(date -u) Installed Lustre client packages for: ${pkgName}=${kernelVersion}"
   fi
 
-  init_lnet="true"
-
+  # The LNet networks themselves are configured by the CSI driver, see the
+  # --lnet-networks flag
   if lsmod | grep "^lnet"; then
-    if lnetctl net show --net tcp | grep interfaces; then
-      echo "$(date -u) LNet is loaded skip the load"
-      echo "$(date -u) Adding missing interfaces"
-      add_net_interfaces
-      init_lnet="false"
-    elif lnetctl net show | grep "net type: tcp"; then
-    # There may be a default configuration with no interface.
-    # This is configured by an old version CSI.
-      lnetctl net del --net tcp
-    fi
-  fi
-
-  if [[ "${init_lnet}" == "true" ]]; then
+    echo "$(date -u) LNet is loaded skip the load"
+  else
     echo "$(date -u) Loading the LNet."
     modprobe -v lnet
     modprobe -v ksocklnd skip_mr_route_setup=1
     lnetctl lnet configure
 
-    add_net_interfaces
-
     echo "$(date -u) Done"
   fi
 
@@ -203,7 +150,7 @@ fi
 
 echo "$(date -u) Entering Lustre CSI driver"
 
-echo Executing: "$1 ${2-} ${3-} ${4-} ${5-} ${6-} ${7-} ${8-} ${9-}"
-$1 ${2-} ${3-} ${4-} ${5-} ${6-} ${7-} ${8-} ${9-}
-
-echo "$(date -u) Exiting Lustre CSI driver"
+echo Executing: "$*"
+# Replace the shell so that the driver receives SIGTERM and can shut down
+# gracefully, unloading the Lustre kernel modules when they are unused
+exec "$@"
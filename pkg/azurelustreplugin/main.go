@@ -17,10 +17,17 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
+	"time"
 
+	"k8s.io/component-base/metrics/legacyregistry"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/azurelustre-csi-driver/pkg/azurelustre"
 )
@@ -34,6 +41,25 @@ var (
 	enableAzureLustreMockDynProv = flag.Bool("enable-azurelustre-mock-dyn-prov", true, "Whether enable mock dynamic provisioning(only for testing)")
 	workingMountDir              = flag.String("working-mount-dir", "/tmp", "working directory for provisioner to mount lustre filesystems temporarily")
 	removeNotReadyTaint          = flag.Bool("remove-not-ready-taint", true, "remove NotReady taint from node when node is ready")
+	metricsAddress               = flag.String("metrics-address", "", "address to serve Prometheus metrics and the /healthz and /readyz endpoints on, e.g. 0.0.0.0:29765, leave empty to disable")
+	lustreJobIDVar               = flag.String("lustre-jobid-var", "", "Lustre jobid_var to set on the node, e.g. procname_uid or nodelocal, leave empty to keep the node setting")
+	lustreJobIDName              = flag.String("lustre-jobid-name", "", "Lustre jobid_name template to set on the node, requires lustre-jobid-var")
+	lnetNetworks                 = flag.String("lnet-networks", "tcp", "LNet networks to configure on the node in Lustre networks syntax, e.g. tcp(eth0,eth1), a network without interfaces uses every ethernet interface with a route, leave empty to leave LNet untouched")
+	lnetConfigFile               = flag.String("lnet-config-file", "", "YAML file with the LNet networks to configure on the node, takes precedence over lnet-networks")
+	unloadLustreModules          = flag.Bool("unload-lustre-modules", true, "unload the Lustre kernel modules on shutdown when no Lustre file system remains mounted on the node")
+	lnetCheckInterval            = flag.Duration("lnet-check-interval", 5*time.Minute, "how often to check the LNet configuration of the node for drift")
+	mountReconcileInterval       = flag.Duration("mount-reconcile-interval", 10*time.Minute, "how often to clean up Lustre mounts of pods that no longer exist and corrupted Lustre mounts on the node, 0 only cleans up at startup")
+	mountReconcileDryRun         = flag.Bool("mount-reconcile-dry-run", false, "only log the Lustre mounts that would be cleaned up instead of unmounting them")
+	amlfsGCInterval              = flag.Duration("amlfs-gc-interval", time.Hour, "how often the controller looks for dynamically provisioned AMLFS clusters whose persistent volume no longer exists, 0 disables it")
+	amlfsGCDeleteGracePeriod     = flag.Duration("amlfs-gc-delete-grace-period", 0, "how long an AMLFS cluster must stay orphaned before the controller deletes it, 0 only reports orphaned clusters")
+	leaderElectionNamespace      = flag.String("leader-election-namespace", "kube-system", "namespace of the lease electing the controller that collects orphaned AMLFS clusters and of the config map persisting subnet IP reservations")
+	candidateSubnets             = flag.String("candidate-subnets", "", "comma separated subnets to create AMLFS clusters in when the storage class names none, each as [[vnet-resource-group/]vnet-name/]subnet-name, the first with enough IP addresses available is used unless the storage class sets subnet-selection")
+	networkPreflight             = flag.String("network-preflight", azurelustre.NetworkPreflightWarn, "what to do when the network security group or route table of the AMLFS subnet blocks the Lustre traffic of a new AMLFS cluster: off, warn to log it and record a warning event on the claim, or fail to fail the creation")
+	verifyPermissions            = flag.Bool("verify-permissions", false, "check at controller startup that the controller identity is granted the ARM actions dynamic provisioning needs, reporting missing ones in the logs, metrics and readiness")
+	cloudConfigReloadInterval    = flag.Duration("cloud-config-reload-interval", time.Minute, "how often to check the cloud config file for changes and rebuild the Azure credential and clients from it, requests in flight keep the previous ones, 0 disables it")
+	webhookAddress               = flag.String("webhook-address", "", "address to serve the validating admission webhook for storage classes and persistent volumes of this driver on over TLS, e.g. 0.0.0.0:9443, leave empty to disable")
+	webhookCertFile              = flag.String("webhook-cert-file", "", "TLS certificate file of the validating admission webhook")
+	webhookKeyFile               = flag.String("webhook-key-file", "", "TLS private key file of the validating admission webhook")
 )
 
 func main() {
@@ -56,10 +82,88 @@ func main() {
 		os.Exit(0)
 	}
 
+	if flag.NArg() > 0 && flag.Arg(0) == "plan" {
+		os.Exit(runPlan(flag.Args()[1:]))
+	}
+
 	handle()
 	os.Exit(0)
 }
 
+func exportMetrics(driver *azurelustre.Driver) {
+	if *metricsAddress == "" {
+		if *nodeID != "" {
+			klog.Warning("metrics-address is not set, the /readyz endpoint used by the readiness and startup probes of the node plugin is not served")
+		}
+		return
+	}
+	lc := &net.ListenConfig{}
+	l, err := lc.Listen(context.Background(), "tcp", *metricsAddress)
+	if err != nil {
+		klog.Warningf("failed to get listener for metrics endpoint: %v", err)
+		return
+	}
+	serve(l, func(l net.Listener) error {
+		return serveMetrics(l, driver)
+	})
+}
+
+func exportWebhook(driver *azurelustre.Driver) {
+	if *webhookAddress == "" {
+		return
+	}
+	if *webhookCertFile == "" || *webhookKeyFile == "" {
+		klog.Fatalln("webhook-cert-file and webhook-key-file must be provided with webhook-address")
+	}
+	lc := &net.ListenConfig{}
+	l, err := lc.Listen(context.Background(), "tcp", *webhookAddress)
+	if err != nil {
+		klog.Fatalf("failed to get listener for webhook endpoint: %v", err)
+	}
+	serve(l, func(l net.Listener) error {
+		return serveWebhook(l, driver)
+	})
+}
+
+func serve(l net.Listener, serveFunc func(net.Listener) error) {
+	path := l.Addr().String()
+	klog.V(2).Infof("set up http server on %v", path)
+	go func() {
+		if err := serveFunc(l); err != nil {
+			klog.Fatalf("serve failure(%v), address(%v)", err, path)
+		}
+	}()
+}
+
+func serveMetrics(l net.Listener, driver *azurelustre.Driver) error {
+	m := http.NewServeMux()
+	m.Handle("/metrics", legacyregistry.Handler())
+	m.HandleFunc("/healthz", driver.ServeHealthz)
+	m.HandleFunc("/readyz", driver.ServeReadyz)
+	server := &http.Server{
+		Handler:           m,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if err := server.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func serveWebhook(l net.Listener, driver *azurelustre.Driver) error {
+	m := http.NewServeMux()
+	m.HandleFunc("/validate", driver.ServeValidatingWebhook)
+	server := &http.Server{
+		Handler:           m,
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig:         &tls.Config{MinVersion: tls.VersionTLS12},
+	}
+	if err := server.ServeTLS(l, *webhookCertFile, *webhookKeyFile); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
 func handle() {
 	driverOptions := azurelustre.DriverOptions{
 		NodeID:                       *nodeID,
@@ -68,10 +172,27 @@ func handle() {
 		EnableAzureLustreMockDynProv: *enableAzureLustreMockDynProv,
 		WorkingMountDir:              *workingMountDir,
 		RemoveNotReadyTaint:          *removeNotReadyTaint,
+		LustreJobIDVar:               *lustreJobIDVar,
+		LustreJobIDName:              *lustreJobIDName,
+		LNetNetworks:                 *lnetNetworks,
+		LNetConfigFile:               *lnetConfigFile,
+		LNetCheckInterval:            *lnetCheckInterval,
+		UnloadLustreModules:          *unloadLustreModules,
+		MountReconcileInterval:       *mountReconcileInterval,
+		MountReconcileDryRun:         *mountReconcileDryRun,
+		AmlfsGCInterval:              *amlfsGCInterval,
+		AmlfsGCDeleteGracePeriod:     *amlfsGCDeleteGracePeriod,
+		LeaderElectionNamespace:      *leaderElectionNamespace,
+		VerifyPermissions:            *verifyPermissions,
+		CandidateSubnets:             *candidateSubnets,
+		NetworkPreflight:             *networkPreflight,
+		CloudConfigReloadInterval:    *cloudConfigReloadInterval,
 	}
 	driver := azurelustre.NewDriver(&driverOptions)
 	if driver == nil {
 		klog.Fatalln("Failed to initialize Azure Lustre CSI driver")
 	}
+	exportMetrics(driver)
+	exportWebhook(driver)
 	driver.Run(*endpoint, false)
 }
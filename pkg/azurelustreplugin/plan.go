@@ -0,0 +1,134 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/azurelustre-csi-driver/pkg/azurelustre"
+	"sigs.k8s.io/yaml"
+)
+
+// runPlan implements the plan subcommand, which prints the AMLFS cluster
+// CreateVolume would create for a dynamic StorageClass without creating it.
+// It returns 0 when CreateVolume would succeed, 1 when it would fail and 2
+// for invalid arguments.
+func runPlan(args []string) int {
+	planFlags := flag.NewFlagSet("plan", flag.ContinueOnError)
+	storageClassFile := planFlags.String("storage-class", "", "YAML or JSON file with the StorageClass to plan for")
+	size := planFlags.String("size", "", "requested capacity of the persistent volume claim, e.g. 48Ti, leave empty for the driver default")
+	output := planFlags.String("output", "text", "format of the report, text or json")
+	parameters := map[string]string{}
+	planFlags.Func("parameter", "StorageClass parameter in the form key=value, may be repeated and takes precedence over the parameters in storage-class", func(value string) error {
+		key, parameterValue, ok := strings.Cut(value, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("parameter %q must be in the form key=value", value)
+		}
+		parameters[key] = parameterValue
+		return nil
+	})
+	if err := planFlags.Parse(args); err != nil {
+		return 2
+	}
+	if *output != "text" && *output != "json" {
+		klog.Errorf("output must be text or json, was %q", *output)
+		return 2
+	}
+
+	if *storageClassFile != "" {
+		storageClassParameters, err := readStorageClassParameters(*storageClassFile)
+		if err != nil {
+			klog.Error(err)
+			return 2
+		}
+		for key, value := range storageClassParameters {
+			if _, ok := parameters[key]; !ok {
+				parameters[key] = value
+			}
+		}
+	}
+	if len(parameters) == 0 {
+		klog.Error("storage-class or parameter must be provided")
+		return 2
+	}
+
+	requiredBytes := int64(0)
+	if *size != "" {
+		quantity, err := resource.ParseQuantity(*size)
+		if err != nil {
+			klog.Errorf("invalid size %q: %v", *size, err)
+			return 2
+		}
+		requiredBytes = quantity.Value()
+	}
+
+	driver := azurelustre.NewDriver(&azurelustre.DriverOptions{
+		DriverName:                   *driverName,
+		EnableAzureLustreMockDynProv: false,
+		WorkingMountDir:              *workingMountDir,
+	})
+	if driver == nil {
+		klog.Error("failed to initialize Azure Lustre CSI driver")
+		return 1
+	}
+
+	plan, err := driver.PlanVolume(context.Background(), parameters, requiredBytes)
+	if err != nil {
+		klog.Errorf("failed to plan volume: %v", err)
+		return 1
+	}
+
+	if *output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(plan)
+	} else {
+		err = plan.WriteText(os.Stdout)
+	}
+	if err != nil {
+		klog.Errorf("failed to write plan: %v", err)
+		return 1
+	}
+
+	if len(plan.Problems) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func readStorageClassParameters(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read storage class file %s: %w", path, err)
+	}
+	storageClass := &storagev1.StorageClass{}
+	if err := yaml.Unmarshal(content, storageClass); err != nil {
+		return nil, fmt.Errorf("failed to parse storage class file %s: %w", path, err)
+	}
+	if storageClass.Provisioner != "" && storageClass.Provisioner != *driverName {
+		return nil, fmt.Errorf("storage class %s is provisioned by %s, not %s", storageClass.Name, storageClass.Provisioner, *driverName)
+	}
+	return storageClass.Parameters, nil
+}
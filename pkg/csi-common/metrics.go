@@ -0,0 +1,58 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package csicommon
+
+import (
+	"context"
+	"path"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+const (
+	metricsNamespace = "azurelustre"
+	metricsSubsystem = "csi"
+)
+
+var grpcOperationDuration = metrics.NewHistogramVec(
+	&metrics.HistogramOpts{
+		Namespace:      metricsNamespace,
+		Subsystem:      metricsSubsystem,
+		Name:           "rpc_duration_seconds",
+		Help:           "Latency of CSI RPCs served by the driver, by method and gRPC result code",
+		Buckets:        metrics.ExponentialBuckets(0.01, 2, 16),
+		StabilityLevel: metrics.ALPHA,
+	},
+	[]string{"method", "code"},
+)
+
+func init() {
+	legacyregistry.MustRegister(grpcOperationDuration)
+}
+
+// observeGRPC records the latency and result code of every CSI RPC
+func observeGRPC(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	grpcOperationDuration.WithLabelValues(path.Base(info.FullMethod), status.Code(err).String()).
+		Observe(time.Since(start).Seconds())
+	return resp, err
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package csicommon
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+func getRPCSampleCount(t *testing.T, method, code string) uint64 {
+	t.Helper()
+	families, err := legacyregistry.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != "azurelustre_csi_rpc_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["method"] == method && labels["code"] == code {
+				return metric.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+	return 0
+}
+
+func TestObserveGRPC(t *testing.T) {
+	info := grpc.UnaryServerInfo{FullMethod: "/csi.v1.Node/NodePublishVolume"}
+
+	successBefore := getRPCSampleCount(t, "NodePublishVolume", "OK")
+	failureBefore := getRPCSampleCount(t, "NodePublishVolume", "Aborted")
+
+	_, err := observeGRPC(context.Background(), nil, &info, func(_ context.Context, _ any) (any, error) {
+		return nil, nil
+	})
+	require.NoError(t, err)
+	_, err = observeGRPC(context.Background(), nil, &info, func(_ context.Context, _ any) (any, error) {
+		return nil, status.Error(codes.Aborted, "operation in progress")
+	})
+	require.Error(t, err)
+
+	assert.Equal(t, successBefore+1, getRPCSampleCount(t, "NodePublishVolume", "OK"))
+	assert.Equal(t, failureBefore+1, getRPCSampleCount(t, "NodePublishVolume", "Aborted"))
+}
@@ -47,6 +47,7 @@ func NewNonBlockingGRPCServer() NonBlockingGRPCServer {
 // NonBlocking server
 type nonBlockingGRPCServer struct {
 	wg     sync.WaitGroup
+	mu     sync.Mutex
 	server *grpc.Server
 }
 
@@ -60,11 +61,21 @@ func (s *nonBlockingGRPCServer) Wait() {
 }
 
 func (s *nonBlockingGRPCServer) Stop() {
-	s.server.GracefulStop()
+	if server := s.getServer(); server != nil {
+		server.GracefulStop()
+	}
 }
 
 func (s *nonBlockingGRPCServer) ForceStop() {
-	s.server.Stop()
+	if server := s.getServer(); server != nil {
+		server.Stop()
+	}
+}
+
+func (s *nonBlockingGRPCServer) getServer() *grpc.Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.server
 }
 
 func (s *nonBlockingGRPCServer) serve(endpoint string, ids csi.IdentityServer, cs csi.ControllerServer, ns csi.NodeServer, testMode bool) {
@@ -88,10 +99,12 @@ func (s *nonBlockingGRPCServer) serve(endpoint string, ids csi.IdentityServer, c
 
 	opts := []grpc.ServerOption{
 		grpc.MaxConcurrentStreams(200),
-		grpc.UnaryInterceptor(logGRPC),
+		grpc.ChainUnaryInterceptor(logGRPC, observeGRPC),
 	}
 	server := grpc.NewServer(opts...)
+	s.mu.Lock()
 	s.server = server
+	s.mu.Unlock()
 
 	if ids != nil {
 		csi.RegisterIdentityServer(server, ids)
@@ -117,4 +130,8 @@ func (s *nonBlockingGRPCServer) serve(endpoint string, ids csi.IdentityServer, c
 	if err := server.Serve(listener); err != nil {
 		klog.Errorf("Listening for connections on address: %#v, error: %v", listener.Addr(), err)
 	}
+	// Let Wait return once the server was stopped
+	if !testMode {
+		s.wg.Done()
+	}
 }
@@ -19,6 +19,7 @@ package csicommon
 import (
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
@@ -61,3 +62,12 @@ func TestForceStop(_ *testing.T) {
 	s.server = grpc.NewServer()
 	s.ForceStop()
 }
+
+func TestWaitReturnsAfterStop(t *testing.T) {
+	s := &nonBlockingGRPCServer{}
+	s.Start("tcp://127.0.0.1:0", nil, nil, nil, false)
+	assert.Eventually(t, func() bool { return s.getServer() != nil }, 10*time.Second, 10*time.Millisecond)
+
+	s.Stop()
+	s.Wait()
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package lnet configures the LNet networks of the Lustre client on a node
+// through lnetctl and keeps them in the desired state
+package lnet
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	utilexec "k8s.io/utils/exec"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// LoopbackNetType is the LNet network every node has, it is never managed
+	LoopbackNetType = "lo"
+	// StatusUp is the status of a local NI that can send and receive
+	StatusUp = "up"
+)
+
+var (
+	// netNameRegex matches LNet network names such as tcp, tcp1 or o2ib0
+	netNameRegex = regexp.MustCompile(`^([a-z][a-z0-9]*[a-z])([0-9]*)$`)
+	// interfaceNameRegex matches Linux network interface names
+	interfaceNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.:@-]{1,15}$`)
+)
+
+// Network is an LNet network and the interfaces it should use, several
+// interfaces make the network multi-rail. No interfaces means every ethernet
+// interface of the node with a route is used
+type Network struct {
+	Name       string   `json:"name"`
+	Interfaces []string `json:"interfaces,omitempty"`
+}
+
+// String formats the network the way the Lustre networks module option
+// does, e.g. tcp(eth0,eth1)
+func (n Network) String() string {
+	if len(n.Interfaces) == 0 {
+		return n.Name
+	}
+	return fmt.Sprintf("%s(%s)", n.Name, strings.Join(n.Interfaces, ","))
+}
+
+// Config is the content of an LNet configuration file
+type Config struct {
+	Networks []Network `json:"networks"`
+}
+
+// NormalizeNetName returns the name lnetctl reports for a network, dropping
+// the network number when it is 0, i.e. tcp0 is reported as tcp
+func NormalizeNetName(name string) string {
+	matches := netNameRegex.FindStringSubmatch(name)
+	if matches == nil || strings.TrimLeft(matches[2], "0") != "" {
+		return name
+	}
+	return matches[1]
+}
+
+// ParseNetworks parses networks written like the Lustre networks module
+// option, e.g. "tcp" or "tcp(eth0,eth1),tcp1(eth2)"
+func ParseNetworks(spec string) ([]Network, error) {
+	var networks []Network
+	rest := strings.TrimSpace(spec)
+	for rest != "" {
+		network := Network{}
+		end := strings.IndexAny(rest, ",(")
+		if end == -1 {
+			end = len(rest)
+		}
+		network.Name = strings.TrimSpace(rest[:end])
+		rest = rest[end:]
+
+		if strings.HasPrefix(rest, "(") {
+			closing := strings.Index(rest, ")")
+			if closing == -1 {
+				return nil, fmt.Errorf("missing closing parenthesis for LNet network %q in %q", network.Name, spec)
+			}
+			for _, iface := range strings.Split(rest[1:closing], ",") {
+				network.Interfaces = append(network.Interfaces, strings.TrimSpace(iface))
+			}
+			rest = rest[closing+1:]
+		}
+		rest = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(rest), ","))
+		networks = append(networks, network)
+	}
+
+	if err := validateNetworks(networks); err != nil {
+		return nil, fmt.Errorf("invalid LNet networks %q: %w", spec, err)
+	}
+	return networks, nil
+}
+
+// LoadConfigFile reads the desired LNet networks from a YAML file, e.g.
+//
+//	networks:
+//	- name: tcp
+//	  interfaces: [eth0, eth1]
+func LoadConfigFile(path string) ([]Network, error) {
+	content, err := os.ReadFile(path) //nolint:gosec // The path is set by the cluster administrator through a flag
+	if err != nil {
+		return nil, fmt.Errorf("failed to read LNet config file %s: %w", path, err)
+	}
+	config := Config{}
+	if err := yaml.UnmarshalStrict(content, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse LNet config file %s: %w", path, err)
+	}
+	if err := validateNetworks(config.Networks); err != nil {
+		return nil, fmt.Errorf("invalid LNet config file %s: %w", path, err)
+	}
+	return config.Networks, nil
+}
+
+func validateNetworks(networks []Network) error {
+	if len(networks) == 0 {
+		return fmt.Errorf("no networks configured")
+	}
+	names := make(map[string]bool, len(networks))
+	for _, network := range networks {
+		if !netNameRegex.MatchString(network.Name) {
+			return fmt.Errorf("network name %q is not valid", network.Name)
+		}
+		name := NormalizeNetName(network.Name)
+		if name == LoopbackNetType {
+			return fmt.Errorf("network %q cannot be managed", network.Name)
+		}
+		if names[name] {
+			return fmt.Errorf("network %q is configured more than once", network.Name)
+		}
+		names[name] = true
+
+		for i, iface := range network.Interfaces {
+			if !interfaceNameRegex.MatchString(iface) {
+				return fmt.Errorf("interface name %q of network %q is not valid", iface, network.Name)
+			}
+			if slices.Contains(network.Interfaces[:i], iface) {
+				return fmt.Errorf("interface %q is listed more than once for network %q", iface, network.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// LocalNI is a local network interface of LNet as listed by "lnetctl net show"
+type LocalNI struct {
+	NID    string `json:"nid"`
+	Status string `json:"status"`
+	// Interfaces is keyed by the interface index, e.g. {"0": "eth0"}
+	Interfaces map[string]string `json:"interfaces,omitempty"`
+}
+
+// Net is an LNet network as listed by "lnetctl net show"
+type Net struct {
+	NetType  string    `json:"net type"`
+	LocalNIs []LocalNI `json:"local NI(s)"`
+}
+
+type netShow struct {
+	Net []Net `json:"net"`
+}
+
+// ParseNetShow parses the YAML output of "lnetctl net show"
+func ParseNetShow(output []byte) ([]Net, error) {
+	show := netShow{}
+	if err := yaml.Unmarshal(output, &show); err != nil {
+		return nil, fmt.Errorf("failed to parse lnetctl net show output: %w", err)
+	}
+	return show.Net, nil
+}
+
+// ShowNetworks returns the live LNet networks of the node
+func ShowNetworks(ctx context.Context, exec utilexec.Interface) ([]Net, error) {
+	output, err := exec.CommandContext(ctx, "lnetctl", "net", "show").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("lnetctl net show failed: %w, output: %q", err, string(output))
+	}
+	return ParseNetShow(output)
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lnet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fakeNetShow = `net:
+    - net type: lo
+      local NI(s):
+        - nid: 0@lo
+          status: up
+    - net type: tcp
+      local NI(s):
+        - nid: 10.0.0.4@tcp
+          status: up
+          interfaces:
+              0: eth0
+        - nid: 10.0.1.4@tcp
+          status: down
+          interfaces:
+              0: eth1
+`
+
+func TestNormalizeNetName(t *testing.T) {
+	assert.Equal(t, "tcp", NormalizeNetName("tcp"))
+	assert.Equal(t, "tcp", NormalizeNetName("tcp0"))
+	assert.Equal(t, "tcp1", NormalizeNetName("tcp1"))
+	assert.Equal(t, "tcp10", NormalizeNetName("tcp10"))
+	assert.Equal(t, "o2ib", NormalizeNetName("o2ib0"))
+}
+
+func TestParseNetworks(t *testing.T) {
+	tests := []struct {
+		desc             string
+		spec             string
+		expectedNetworks []Network
+		expectedErr      string
+	}{
+		{
+			desc:             "network without interfaces",
+			spec:             "tcp",
+			expectedNetworks: []Network{{Name: "tcp"}},
+		},
+		{
+			desc: "multi-rail and several networks",
+			spec: "tcp(eth0, eth1), tcp1(eth2)",
+			expectedNetworks: []Network{
+				{Name: "tcp", Interfaces: []string{"eth0", "eth1"}},
+				{Name: "tcp1", Interfaces: []string{"eth2"}},
+			},
+		},
+		{
+			desc:             "several networks without interfaces",
+			spec:             "tcp,tcp1",
+			expectedNetworks: []Network{{Name: "tcp"}, {Name: "tcp1"}},
+		},
+		{
+			desc:        "missing parenthesis",
+			spec:        "tcp(eth0",
+			expectedErr: "missing closing parenthesis",
+		},
+		{
+			desc:        "invalid interface",
+			spec:        "tcp(eth0 eth1)",
+			expectedErr: `interface name "eth0 eth1" of network "tcp" is not valid`,
+		},
+		{
+			desc:        "duplicate network",
+			spec:        "tcp(eth0),tcp0(eth1)",
+			expectedErr: `network "tcp0" is configured more than once`,
+		},
+		{
+			desc:        "loopback network",
+			spec:        "lo",
+			expectedErr: `network "lo" cannot be managed`,
+		},
+		{
+			desc:        "invalid network name",
+			spec:        "tcp;eth0",
+			expectedErr: `network name "tcp;eth0" is not valid`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			networks, err := ParseNetworks(test.spec)
+			if test.expectedErr != "" {
+				require.ErrorContains(t, err, test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedNetworks, networks)
+		})
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	validFile := filepath.Join(dir, "lnet.yaml")
+	require.NoError(t, os.WriteFile(validFile, []byte(`networks:
+- name: tcp
+  interfaces: [eth0, eth1]
+- name: tcp1
+`), 0o600))
+	invalidFile := filepath.Join(dir, "invalid.yaml")
+	require.NoError(t, os.WriteFile(invalidFile, []byte(`networks:
+- net: tcp
+`), 0o600))
+
+	networks, err := LoadConfigFile(validFile)
+	require.NoError(t, err)
+	assert.Equal(t, []Network{
+		{Name: "tcp", Interfaces: []string{"eth0", "eth1"}},
+		{Name: "tcp1"},
+	}, networks)
+
+	_, err = LoadConfigFile(invalidFile)
+	require.ErrorContains(t, err, "failed to parse LNet config file")
+
+	_, err = LoadConfigFile(filepath.Join(dir, "missing.yaml"))
+	require.ErrorContains(t, err, "failed to read LNet config file")
+}
+
+func TestParseNetShow(t *testing.T) {
+	nets, err := ParseNetShow([]byte(fakeNetShow))
+	require.NoError(t, err)
+	assert.Equal(t, []Net{
+		{NetType: "lo", LocalNIs: []LocalNI{{NID: "0@lo", Status: StatusUp}}},
+		{NetType: "tcp", LocalNIs: []LocalNI{
+			{NID: "10.0.0.4@tcp", Status: StatusUp, Interfaces: map[string]string{"0": "eth0"}},
+			{NID: "10.0.1.4@tcp", Status: "down", Interfaces: map[string]string{"0": "eth1"}},
+		}},
+	}, nets)
+
+	_, err = ParseNetShow([]byte("net: ["))
+	require.Error(t, err)
+}
@@ -0,0 +1,333 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lnet
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	utilexec "k8s.io/utils/exec"
+)
+
+const (
+	// EventReasonConfigured is recorded when the LNet networks were set up
+	EventReasonConfigured = "LNetConfigured"
+	// EventReasonDrift is recorded when the live LNet networks no longer
+	// match the desired ones
+	EventReasonDrift = "LNetConfigurationDrift"
+	// EventReasonFailed is recorded when the LNet networks could not be set up
+	EventReasonFailed = "LNetConfigurationFailed"
+)
+
+// routeDeviceRegex extracts the device of a route from "ip route show"
+var routeDeviceRegex = regexp.MustCompile(`\sdev\s+(\S+)`)
+
+// Diff is the difference between the desired and the live LNet networks
+type Diff struct {
+	// Missing lists the desired interfaces LNet does not use yet
+	Missing []Network
+	// Reset lists the networks that exist without any interface, left
+	// behind by older driver versions, and have to be recreated
+	Reset []string
+	// Unmanaged lists the interfaces LNet uses that are not desired, they
+	// are left in place so mounted file systems keep working
+	Unmanaged []Network
+}
+
+// Empty reports whether the live networks match the desired ones
+func (d *Diff) Empty() bool {
+	return len(d.Missing) == 0 && len(d.Reset) == 0 && len(d.Unmanaged) == 0
+}
+
+// NeedsApply reports whether lnetctl has to change the live networks
+func (d *Diff) NeedsApply() bool {
+	return len(d.Missing) > 0 || len(d.Reset) > 0
+}
+
+// String describes the difference for logs and events
+func (d *Diff) String() string {
+	var parts []string
+	if len(d.Reset) > 0 {
+		parts = append(parts, "networks without interfaces: "+strings.Join(d.Reset, ","))
+	}
+	if len(d.Missing) > 0 {
+		parts = append(parts, "missing: "+joinNetworks(d.Missing))
+	}
+	if len(d.Unmanaged) > 0 {
+		parts = append(parts, "unmanaged: "+joinNetworks(d.Unmanaged))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func joinNetworks(networks []Network) string {
+	formatted := make([]string, 0, len(networks))
+	for _, network := range networks {
+		formatted = append(formatted, network.String())
+	}
+	return strings.Join(formatted, ",")
+}
+
+// ComputeDiff compares the desired networks, with their interfaces resolved,
+// with the live networks reported by lnetctl
+func ComputeDiff(desired []Network, live []Net) *Diff {
+	liveInterfaces := make(map[string][]string)
+	withoutInterfaces := make(map[string]bool)
+	for _, net := range live {
+		if net.NetType == LoopbackNetType {
+			continue
+		}
+		for _, ni := range net.LocalNIs {
+			if len(ni.Interfaces) == 0 {
+				withoutInterfaces[net.NetType] = true
+			}
+			for _, iface := range ni.Interfaces {
+				liveInterfaces[net.NetType] = append(liveInterfaces[net.NetType], iface)
+			}
+		}
+	}
+
+	diff := &Diff{}
+	desiredInterfaces := make(map[string][]string)
+	for _, network := range desired {
+		name := NormalizeNetName(network.Name)
+		desiredInterfaces[name] = network.Interfaces
+		current := liveInterfaces[name]
+		if withoutInterfaces[name] {
+			diff.Reset = append(diff.Reset, name)
+			current = nil
+		}
+		missing := Network{Name: name}
+		for _, iface := range network.Interfaces {
+			if !slices.Contains(current, iface) {
+				missing.Interfaces = append(missing.Interfaces, iface)
+			}
+		}
+		if len(missing.Interfaces) > 0 {
+			diff.Missing = append(diff.Missing, missing)
+		}
+	}
+
+	for _, net := range live {
+		wanted, ok := desiredInterfaces[net.NetType]
+		if net.NetType == LoopbackNetType || (ok && withoutInterfaces[net.NetType]) {
+			continue
+		}
+		unmanaged := Network{Name: net.NetType}
+		for _, iface := range liveInterfaces[net.NetType] {
+			if !slices.Contains(wanted, iface) && !slices.Contains(unmanaged.Interfaces, iface) {
+				unmanaged.Interfaces = append(unmanaged.Interfaces, iface)
+			}
+		}
+		if len(unmanaged.Interfaces) > 0 {
+			diff.Unmanaged = append(diff.Unmanaged, unmanaged)
+		}
+	}
+	return diff
+}
+
+// Manager keeps the LNet networks of the node in the desired state
+type Manager struct {
+	exec     utilexec.Interface
+	networks []Network
+	recorder record.EventRecorder
+	nodeRef  *corev1.ObjectReference
+
+	// configured is set once the desired networks were applied, later
+	// differences are reported as drift
+	configured bool
+	// lastDrift is the last reported drift, so that unchanged drift is
+	// reported only once
+	lastDrift string
+}
+
+// NewManager returns a manager for the given networks, events are recorded
+// against nodeName when recorder is not nil
+func NewManager(exec utilexec.Interface, networks []Network, recorder record.EventRecorder, nodeName string) *Manager {
+	return &Manager{
+		exec:     exec,
+		networks: networks,
+		recorder: recorder,
+		nodeRef: &corev1.ObjectReference{
+			Kind: "Node",
+			Name: nodeName,
+			UID:  k8stypes.UID(nodeName),
+		},
+	}
+}
+
+// Run reconciles the LNet networks every interval until ctx is done
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := m.Reconcile(ctx); err != nil {
+			klog.Errorf("failed to reconcile LNet configuration: %v", err)
+		}
+	}, interval)
+}
+
+// Reconcile compares the live LNet networks with the desired ones and adds
+// what is missing. Calling it again once the networks match does nothing
+func (m *Manager) Reconcile(ctx context.Context) error {
+	desired, err := m.resolveNetworks(ctx)
+	if err != nil {
+		m.event(corev1.EventTypeWarning, EventReasonFailed, err.Error())
+		return err
+	}
+	live, err := ShowNetworks(ctx, m.exec)
+	if err != nil {
+		m.event(corev1.EventTypeWarning, EventReasonFailed, err.Error())
+		return err
+	}
+
+	diff := ComputeDiff(desired, live)
+	if diff.Empty() {
+		m.lastDrift = ""
+		if !m.configured {
+			klog.V(2).Infof("LNet networks already configured: %s", joinNetworks(desired))
+			m.configured = true
+		}
+		return nil
+	}
+
+	if m.configured || !diff.NeedsApply() {
+		if description := diff.String(); description != m.lastDrift {
+			klog.Warningf("LNet configuration drifted from %s: %s", joinNetworks(desired), description)
+			m.event(corev1.EventTypeWarning, EventReasonDrift,
+				fmt.Sprintf("LNet configuration drifted from %s: %s", joinNetworks(desired), description))
+			m.lastDrift = description
+		}
+	}
+	if !diff.NeedsApply() {
+		m.configured = true
+		return nil
+	}
+
+	if err := m.apply(ctx, diff); err != nil {
+		m.event(corev1.EventTypeWarning, EventReasonFailed, err.Error())
+		return err
+	}
+	klog.Infof("configured LNet networks %s", joinNetworks(desired))
+	m.event(corev1.EventTypeNormal, EventReasonConfigured, "Configured LNet networks "+joinNetworks(desired))
+	m.configured = true
+	m.lastDrift = ""
+	return nil
+}
+
+// apply runs the lnetctl commands that bring the live networks to the
+// desired state
+func (m *Manager) apply(ctx context.Context, diff *Diff) error {
+	for _, name := range diff.Reset {
+		klog.V(2).Infof("deleting LNet network %s without interfaces", name)
+		if err := m.lnetctl(ctx, "net", "del", "--net", name); err != nil {
+			return err
+		}
+	}
+	for _, network := range diff.Missing {
+		for _, iface := range network.Interfaces {
+			klog.V(2).Infof("adding interface %s to LNet network %s", iface, network.Name)
+			if err := m.lnetctl(ctx, "net", "add", "--net", network.Name, "--if", iface); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Manager) lnetctl(ctx context.Context, args ...string) error {
+	output, err := m.exec.CommandContext(ctx, "lnetctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("lnetctl %s failed: %w, output: %q", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// resolveNetworks returns the desired networks with the ethernet interfaces
+// of the node filled in for networks that do not list any
+func (m *Manager) resolveNetworks(ctx context.Context) ([]Network, error) {
+	var ethernetInterfaces []string
+	resolved := make([]Network, 0, len(m.networks))
+	for _, network := range m.networks {
+		network.Name = NormalizeNetName(network.Name)
+		if len(network.Interfaces) == 0 {
+			if ethernetInterfaces == nil {
+				var err error
+				if ethernetInterfaces, err = m.getEthernetInterfaces(ctx); err != nil {
+					return nil, err
+				}
+			}
+			network.Interfaces = ethernetInterfaces
+		}
+		resolved = append(resolved, network)
+	}
+	return resolved, nil
+}
+
+// getEthernetInterfaces returns the ethernet interfaces that have a route,
+// skipping bonded, namespaced and interfaces in unknown state
+func (m *Manager) getEthernetInterfaces(ctx context.Context) ([]string, error) {
+	routes, err := m.exec.CommandContext(ctx, "ip", "route", "show").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("ip route show failed: %w, output: %q", err, string(routes))
+	}
+	var devices []string
+	for _, match := range routeDeviceRegex.FindAllStringSubmatch(string(routes), -1) {
+		if !slices.Contains(devices, match[1]) {
+			devices = append(devices, match[1])
+		}
+	}
+	slices.Sort(devices)
+
+	var interfaces []string
+	for _, device := range devices {
+		output, err := m.exec.CommandContext(ctx, "ip", "link", "show", device).CombinedOutput()
+		if err != nil {
+			return nil, fmt.Errorf("ip link show %s failed: %w, output: %q", device, err, string(output))
+		}
+		info := string(output)
+		switch {
+		case strings.Contains(info, "SLAVE"):
+			klog.V(4).Infof("not adding slave interface %s to LNet", device)
+		case strings.Contains(info, "link-netns"):
+			klog.V(4).Infof("not adding namespaced interface %s to LNet", device)
+		case strings.Contains(info, "state UNKNOWN"):
+			klog.V(4).Infof("not adding interface %s in unknown state to LNet", device)
+		case strings.Contains(info, "link/ether"):
+			interfaces = append(interfaces, device)
+		default:
+			klog.V(4).Infof("not adding non-ethernet interface %s to LNet", device)
+		}
+	}
+	if len(interfaces) == 0 {
+		return nil, fmt.Errorf("cannot find any ethernet network interface for LNet")
+	}
+	return interfaces, nil
+}
+
+func (m *Manager) event(eventType, reason, message string) {
+	if m.recorder == nil {
+		return
+	}
+	m.recorder.Event(m.nodeRef, eventType, reason, message)
+}
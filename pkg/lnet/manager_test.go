@@ -0,0 +1,279 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lnet
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/record"
+	utilexec "k8s.io/utils/exec"
+	testingexec "k8s.io/utils/exec/testing"
+)
+
+const (
+	fakeRoutes = `default via 10.0.0.1 dev eth0 proto dhcp src 10.0.0.4 metric 100
+10.0.0.0/24 dev eth0 proto kernel scope link src 10.0.0.4 metric 100
+10.0.1.0/24 dev eth1 proto kernel scope link src 10.0.1.4 metric 100
+10.244.0.0/24 dev cbr0 proto kernel scope link src 10.244.0.1
+`
+	fakeEth0Link = `2: eth0: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 qdisc mq state UP mode DEFAULT group default qlen 1000
+    link/ether 00:0d:3a:00:00:01 brd ff:ff:ff:ff:ff:ff
+`
+	fakeEth1Link = `3: eth1: <BROADCAST,MULTICAST,SLAVE,UP,LOWER_UP> mtu 1500 qdisc mq master eth0 state UP mode DEFAULT group default qlen 1000
+    link/ether 00:0d:3a:00:00:02 brd ff:ff:ff:ff:ff:ff
+`
+	fakeCbr0Link = `4: cbr0: <BROADCAST,MULTICAST,UP,LOWER_UP> mtu 1500 qdisc noqueue state UNKNOWN mode DEFAULT group default qlen 1000
+    link/ether 00:0d:3a:00:00:03 brd ff:ff:ff:ff:ff:ff
+`
+	fakeNetShowLoopback = `net:
+    - net type: lo
+      local NI(s):
+        - nid: 0@lo
+          status: up
+`
+	fakeNetShowEth0 = `net:
+    - net type: lo
+      local NI(s):
+        - nid: 0@lo
+          status: up
+    - net type: tcp
+      local NI(s):
+        - nid: 10.0.0.4@tcp
+          status: up
+          interfaces:
+              0: eth0
+`
+	fakeNetShowNoInterfaces = `net:
+    - net type: lo
+      local NI(s):
+        - nid: 0@lo
+          status: up
+    - net type: tcp
+      local NI(s):
+        - nid: 10.0.0.4@tcp
+          status: up
+`
+)
+
+type fakeCommandResult struct {
+	output string
+	err    error
+}
+
+func newScriptedFakeExec(results []fakeCommandResult, commandLog *[][]string) *testingexec.FakeExec {
+	fakeExec := &testingexec.FakeExec{}
+	for _, result := range results {
+		fakeExec.CommandScript = append(fakeExec.CommandScript, func(cmd string, args ...string) utilexec.Cmd {
+			*commandLog = append(*commandLog, append([]string{cmd}, args...))
+			fakeCmd := &testingexec.FakeCmd{
+				CombinedOutputScript: []testingexec.FakeAction{
+					func() ([]byte, []byte, error) {
+						return []byte(result.output), nil, result.err
+					},
+				},
+			}
+			return testingexec.InitFakeCmd(fakeCmd, cmd, args...)
+		})
+	}
+	return fakeExec
+}
+
+func getEvents(recorder *record.FakeRecorder) []string {
+	var events []string
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestComputeDiff(t *testing.T) {
+	tests := []struct {
+		desc         string
+		desired      []Network
+		live         string
+		expectedDiff *Diff
+	}{
+		{
+			desc:         "nothing configured",
+			desired:      []Network{{Name: "tcp", Interfaces: []string{"eth0", "eth1"}}},
+			live:         fakeNetShowLoopback,
+			expectedDiff: &Diff{Missing: []Network{{Name: "tcp", Interfaces: []string{"eth0", "eth1"}}}},
+		},
+		{
+			desc:         "in sync",
+			desired:      []Network{{Name: "tcp", Interfaces: []string{"eth0"}}},
+			live:         fakeNetShowEth0,
+			expectedDiff: &Diff{},
+		},
+		{
+			desc:         "multi-rail interface missing",
+			desired:      []Network{{Name: "tcp", Interfaces: []string{"eth0", "eth1"}}},
+			live:         fakeNetShowEth0,
+			expectedDiff: &Diff{Missing: []Network{{Name: "tcp", Interfaces: []string{"eth1"}}}},
+		},
+		{
+			desc:    "interface not desired",
+			desired: []Network{{Name: "tcp1", Interfaces: []string{"eth1"}}},
+			live:    fakeNetShowEth0,
+			expectedDiff: &Diff{
+				Missing:   []Network{{Name: "tcp1", Interfaces: []string{"eth1"}}},
+				Unmanaged: []Network{{Name: "tcp", Interfaces: []string{"eth0"}}},
+			},
+		},
+		{
+			desc:    "network without interfaces",
+			desired: []Network{{Name: "tcp", Interfaces: []string{"eth0"}}},
+			live:    fakeNetShowNoInterfaces,
+			expectedDiff: &Diff{
+				Missing: []Network{{Name: "tcp", Interfaces: []string{"eth0"}}},
+				Reset:   []string{"tcp"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			live, err := ParseNetShow([]byte(test.live))
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedDiff, ComputeDiff(test.desired, live))
+		})
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	var commandLog [][]string
+	recorder := record.NewFakeRecorder(10)
+	exec := newScriptedFakeExec([]fakeCommandResult{
+		{output: fakeRoutes},
+		{output: fakeCbr0Link},
+		{output: fakeEth0Link},
+		{output: fakeEth1Link},
+		{output: fakeNetShowLoopback},
+		{},
+	}, &commandLog)
+	m := NewManager(exec, []Network{{Name: "tcp0"}}, recorder, "node-1")
+
+	require.NoError(t, m.Reconcile(context.Background()))
+	assert.Equal(t, [][]string{
+		{"ip", "route", "show"},
+		{"ip", "link", "show", "cbr0"},
+		{"ip", "link", "show", "eth0"},
+		{"ip", "link", "show", "eth1"},
+		{"lnetctl", "net", "show"},
+		{"lnetctl", "net", "add", "--net", "tcp", "--if", "eth0"},
+	}, commandLog)
+	assert.Equal(t, []string{"Normal LNetConfigured Configured LNet networks tcp(eth0)"}, getEvents(recorder))
+}
+
+func TestReconcile_Idempotent(t *testing.T) {
+	var commandLog [][]string
+	recorder := record.NewFakeRecorder(10)
+	exec := newScriptedFakeExec([]fakeCommandResult{
+		{output: fakeNetShowEth0},
+		{output: fakeNetShowEth0},
+	}, &commandLog)
+	m := NewManager(exec, []Network{{Name: "tcp", Interfaces: []string{"eth0"}}}, recorder, "node-1")
+
+	require.NoError(t, m.Reconcile(context.Background()))
+	require.NoError(t, m.Reconcile(context.Background()))
+	assert.Equal(t, [][]string{
+		{"lnetctl", "net", "show"},
+		{"lnetctl", "net", "show"},
+	}, commandLog)
+	assert.Empty(t, getEvents(recorder))
+}
+
+func TestReconcile_Drift(t *testing.T) {
+	var commandLog [][]string
+	recorder := record.NewFakeRecorder(10)
+	exec := newScriptedFakeExec([]fakeCommandResult{
+		{output: fakeNetShowEth0},
+		{output: fakeNetShowNoInterfaces},
+		{},
+		{},
+	}, &commandLog)
+	m := NewManager(exec, []Network{{Name: "tcp", Interfaces: []string{"eth0"}}}, recorder, "node-1")
+
+	require.NoError(t, m.Reconcile(context.Background()))
+	require.NoError(t, m.Reconcile(context.Background()))
+	assert.Equal(t, [][]string{
+		{"lnetctl", "net", "show"},
+		{"lnetctl", "net", "show"},
+		{"lnetctl", "net", "del", "--net", "tcp"},
+		{"lnetctl", "net", "add", "--net", "tcp", "--if", "eth0"},
+	}, commandLog)
+	assert.Equal(t, []string{
+		"Warning LNetConfigurationDrift LNet configuration drifted from tcp(eth0): networks without interfaces: tcp; missing: tcp(eth0)",
+		"Normal LNetConfigured Configured LNet networks tcp(eth0)",
+	}, getEvents(recorder))
+}
+
+func TestReconcile_UnmanagedReportedOnce(t *testing.T) {
+	var commandLog [][]string
+	recorder := record.NewFakeRecorder(10)
+	exec := newScriptedFakeExec([]fakeCommandResult{
+		{output: fakeNetShow},
+		{output: fakeNetShow},
+	}, &commandLog)
+	m := NewManager(exec, []Network{{Name: "tcp", Interfaces: []string{"eth0"}}}, recorder, "node-1")
+
+	require.NoError(t, m.Reconcile(context.Background()))
+	require.NoError(t, m.Reconcile(context.Background()))
+	assert.Equal(t, [][]string{
+		{"lnetctl", "net", "show"},
+		{"lnetctl", "net", "show"},
+	}, commandLog)
+	assert.Equal(t, []string{
+		"Warning LNetConfigurationDrift LNet configuration drifted from tcp(eth0): unmanaged: tcp(eth1)",
+	}, getEvents(recorder))
+}
+
+func TestReconcile_Failure(t *testing.T) {
+	var commandLog [][]string
+	recorder := record.NewFakeRecorder(10)
+	exec := newScriptedFakeExec([]fakeCommandResult{
+		{output: fakeNetShowLoopback},
+		{output: "add net failed", err: errors.New("exit status 1")},
+	}, &commandLog)
+	m := NewManager(exec, []Network{{Name: "tcp", Interfaces: []string{"eth0"}}}, recorder, "node-1")
+
+	err := m.Reconcile(context.Background())
+	require.ErrorContains(t, err, "lnetctl net add --net tcp --if eth0 failed")
+	assert.Equal(t, []string{
+		`Warning LNetConfigurationFailed lnetctl net add --net tcp --if eth0 failed: exit status 1, output: "add net failed"`,
+	}, getEvents(recorder))
+	assert.False(t, m.configured)
+}
+
+func TestReconcile_NoEthernetInterface(t *testing.T) {
+	var commandLog [][]string
+	exec := newScriptedFakeExec([]fakeCommandResult{
+		{output: "10.244.0.0/24 dev cbr0 proto kernel scope link src 10.244.0.1\n"},
+		{output: fakeCbr0Link},
+	}, &commandLog)
+	m := NewManager(exec, []Network{{Name: "tcp"}}, nil, "node-1")
+
+	require.ErrorContains(t, m.Reconcile(context.Background()), "cannot find any ethernet network interface")
+}